@@ -3,68 +3,284 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/kurtgray/blog-api-go/internal/apiversion"
+	"github.com/kurtgray/blog-api-go/internal/backup"
 	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/content"
 	"github.com/kurtgray/blog-api-go/internal/database"
 	"github.com/kurtgray/blog-api-go/internal/handlers"
+	"github.com/kurtgray/blog-api-go/internal/health"
+	"github.com/kurtgray/blog-api-go/internal/importer"
+	"github.com/kurtgray/blog-api-go/internal/integrity"
+	"github.com/kurtgray/blog-api-go/internal/media"
+	"github.com/kurtgray/blog-api-go/internal/metrics"
 	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/privacy"
+	"github.com/kurtgray/blog-api-go/internal/render"
 	"github.com/kurtgray/blog-api-go/internal/repository"
 	"github.com/kurtgray/blog-api-go/internal/router"
+	"github.com/kurtgray/blog-api-go/internal/rpc"
+	"github.com/kurtgray/blog-api-go/internal/tracing"
+	"github.com/kurtgray/blog-api-go/internal/web"
+	"github.com/kurtgray/blog-api-go/internal/webhooks"
 )
 
 func main() {
-	// load config
-	cfg := config.Load()
+	// load config, fails fast on missing or invalid settings
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:\n", err)
+	}
+	log.Printf("Configuration:\n%s", cfg)
 
-	// connect db w. config string
-	db, err := database.Connect(cfg.MongoDB)
+	// connect db w. config, retrying while mongo comes up
+	db, err := database.ConnectWithRetry(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 	defer db.Disconnect()
 
-	// init repos
-	userRepo := repository.NewUserRepository(db.Database)
-	postRepo := repository.NewPostRepository(db.Database)
-	commentRepo := repository.NewCommentRepository(db.Database)
+	// init tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.File)
+	if err != nil {
+		log.Fatal("Failed to set up tracing:", err)
+	}
+
+	// init metrics
+	m := metrics.New()
+
+	// init repos, instrumented with spans and latency/error metrics
+	obs := repository.MultiObserver(tracing.NewRepoObserver(), m)
+	userRepo := repository.NewInstrumentedUserRepository(repository.NewUserRepository(db.Database), obs)
+	postRepo := repository.NewInstrumentedPostRepository(repository.NewPostRepository(db.Database), obs)
+	commentRepo := repository.NewInstrumentedCommentRepository(repository.NewCommentRepository(db.Database), obs)
+	mediaRepo := repository.NewInstrumentedMediaRepository(repository.NewMediaRepository(db.Database), obs)
+	importRepo := repository.NewInstrumentedImportRepository(repository.NewImportRepository(db.Database), obs)
+	auditRepo := repository.NewInstrumentedAuditRepository(repository.NewAuditRepository(db.Database), obs)
+	exportRepo := repository.NewInstrumentedExportRepository(repository.NewExportRepository(db.Database), obs)
+	erasureRepo := repository.NewInstrumentedErasureRepository(repository.NewErasureRepository(db.Database), obs)
+	webhookRepo := repository.NewInstrumentedWebhookRepository(repository.NewWebhookRepository(db.Database), obs)
+	deliveryRepo := repository.NewInstrumentedDeliveryRepository(repository.NewDeliveryRepository(db.Database), obs)
+
+	// init media storage
+	var storage media.Storage
+	var mediaFiles http.Handler
+	switch cfg.Media.Storage {
+	case "s3":
+		storage, err = media.NewS3Storage(context.Background(), media.S3Options{
+			Endpoint:  cfg.Media.S3Endpoint,
+			Region:    cfg.Media.S3Region,
+			Bucket:    cfg.Media.S3Bucket,
+			AccessKey: cfg.Media.S3AccessKey,
+			SecretKey: cfg.Media.S3SecretKey,
+			UseSSL:    cfg.Media.S3UseSSL,
+			PublicURL: cfg.Media.S3PublicURL,
+		})
+	default:
+		var local *media.LocalStorage
+		local, err = media.NewLocalStorage(cfg.Media.LocalDir, cfg.Media.LocalBaseURL)
+		if err == nil {
+			storage = local
+			mediaFiles = http.FileServer(http.Dir(local.Dir()))
+		}
+	}
+	if err != nil {
+		log.Fatal("Failed to set up media storage:", err)
+	}
+	mediaService := media.NewService(storage, int64(cfg.Media.MaxUploadSize))
+
+	// writes spanning users, posts and comments, transactional on replica sets
+	contentService := content.New(db, userRepo, postRepo, commentRepo)
+	trashCtx, stopTrash := context.WithCancel(context.Background())
+	defer stopTrash()
+	// a purge pass may run long, three intervals without one is stuck
+	trashBeat := health.NewHeartbeat(3 * cfg.Trash.PurgeInterval)
+	go contentService.Run(trashCtx, cfg.Trash.PurgeInterval, cfg.Trash.Retention, trashBeat)
+
+	// personal data exports and erasure, expired exports swept hourly
+	privacyService, err := privacy.New(userRepo, postRepo, commentRepo, mediaRepo, auditRepo, exportRepo, erasureRepo, contentService, mediaService, cfg.Privacy)
+	if err != nil {
+		log.Fatal("Failed to set up privacy exports:", err)
+	}
+	privacyCtx, stopPrivacy := context.WithCancel(context.Background())
+	defer stopPrivacy()
+	privacyBeat := health.NewHeartbeat(3 * time.Hour)
+	go privacyService.Run(privacyCtx, time.Hour, privacyBeat)
+
+	// content events for the webhooks admins register, sent in the background
+	webhookService := webhooks.New(webhookRepo, deliveryRepo, cfg.Webhooks)
+	webhooksCtx, stopWebhooks := context.WithCancel(context.Background())
+	defer stopWebhooks()
+	// beats per delivery too, an attempt takes a timeout at most
+	webhooksBeat := health.NewHeartbeat(3*cfg.Webhooks.PollInterval + 2*cfg.Webhooks.Timeout)
+	go webhookService.Run(webhooksCtx, webhooksBeat)
 
 	// init auth service
-	authService := middleware.NewAuthService(userRepo, cfg.JWTSecret)
+	authService := middleware.NewAuthService(userRepo, cfg.Auth, m)
 
 	// init handlers
-	userHandler := handlers.NewUserHandler(userRepo, authService)
-	postHandler := handlers.NewPostHandler(postRepo, userRepo)
-	commentHandler := handlers.NewCommentHandler(commentRepo)
+	userHandler := handlers.NewUserHandler(userRepo, authService, m, webhookService)
+	renderer := render.New()
+	postHandler := handlers.NewPostHandler(postRepo, userRepo, mediaRepo, contentService, webhookService, renderer, cfg.Site, cfg.Server.RequireIfMatch, cfg.Web.Enabled)
+	commentHandler := handlers.NewCommentHandler(contentService, webhookService, renderer, cfg.Server.RequireIfMatch)
+	mediaHandler := handlers.NewMediaHandler(mediaRepo, mediaService)
+	feedHandler := handlers.NewFeedHandler(postRepo, userRepo, renderer, cfg.Site, cfg.Web.Enabled)
+	sitemapHandler := handlers.NewSitemapHandler(postRepo, cfg.Site, cfg.Web.Enabled)
+	importHandler := handlers.NewImportHandler(
+		importer.New(userRepo, contentService, importRepo, renderer),
+		importRepo,
+		cfg.Web.Enabled,
+	)
+	backupHandler := handlers.NewBackupHandler(backup.New(db.Database))
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+	trashHandler := handlers.NewTrashHandler(contentService, cfg.Trash.Retention)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	integrityHandler := handlers.NewIntegrityHandler(integrity.New(db.Database, contentService, cfg.Privacy.DeletedUsername))
+
+	var graphqlHandler *handlers.GraphQLHandler
+	if cfg.GraphQL.Enabled {
+		graphqlHandler, err = handlers.NewGraphQLHandler(userHandler, postHandler, commentHandler, cfg.GraphQL)
+		if err != nil {
+			log.Fatal("Failed to set up GraphQL:", err)
+		}
+	}
+
+	var webSite *web.Site
+	if cfg.Web.Enabled {
+		webSite, err = web.New(postRepo, commentRepo, userRepo, renderer, cfg.Site, cfg.Web)
+		if err != nil {
+			log.Fatal("Failed to load web theme:", err)
+		}
+	}
+
+	// init health checks
+	healthRegistry := health.NewRegistry()
+	healthRegistry.AddReadiness("mongo", db.Ping)
+	// 100MB floor for uploads
+	if cfg.Health.DiskPath != "" {
+		healthRegistry.AddReadiness("disk", health.DiskSpace(cfg.Health.DiskPath, 100<<20))
+	} else if cfg.Media.Storage == "local" {
+		healthRegistry.AddReadiness("disk", health.DiskSpace(cfg.Media.LocalDir, 100<<20))
+	}
+	// background workers
+	healthRegistry.AddReadiness("trash-purge", trashBeat.Check)
+	healthRegistry.AddReadiness("privacy", privacyBeat.Check)
+	healthRegistry.AddReadiness("webhooks", webhooksBeat.Check)
 
 	// init CORS
-	corsMiddleware := middleware.SetupCORS()
+	corsMiddleware := middleware.SetupCORS(cfg.CORS)
+
+	// already checked by config validation
+	v1Deprecation, _ := apiversion.ParseDeprecation(cfg.Server.V1DeprecatedAt, cfg.Server.V1SunsetAt)
 
 	// router setup
-	rt := router.New(userHandler, postHandler, commentHandler, authService, corsMiddleware)
+	rt := router.New(router.Handlers{
+		Users:      userHandler,
+		Posts:      postHandler,
+		Comments:   commentHandler,
+		Media:      mediaHandler,
+		MediaFiles: mediaFiles,
+		Feed:       feedHandler,
+		Sitemap:    sitemapHandler,
+		Web:        webSite,
+		Import:     importHandler,
+		Backup:     backupHandler,
+		Privacy:    privacyHandler,
+		Integrity:  integrityHandler,
+		Trash:      trashHandler,
+		Webhooks:   webhookHandler,
+		GraphQL:    graphqlHandler,
+	}, authService, corsMiddleware, m, healthRegistry, router.Options{
+		LegacyErrors:       cfg.Server.LegacyErrors,
+		ContractValidation: cfg.Server.ContractValidation,
+		V1Deprecation:      v1Deprecation,
+	})
 	r := rt.Setup()
 
 	// create HTTP server
 	srv := &http.Server{
-		Addr:         ":" + cfg.Port,
+		Addr:         ":" + cfg.Server.Port,
 		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
 	// start server in a goroutine
 	go func() {
-		log.Printf("Server starting on port %s", cfg.Port)
+		log.Printf("Server starting on port %s", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
 
+	// gRPC for internal services, with an optional JSON gateway in front
+	var grpcSrv *rpc.Server
+	var gateway *rpc.Gateway
+	var gatewaySrv *http.Server
+	if cfg.GRPC.Port != "" {
+		grpcSrv = rpc.NewServer(handlers.NewGRPCServices(userHandler, postHandler, commentHandler), authService, cfg.GRPC.Reflection)
+		lis, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+		if err != nil {
+			log.Fatal("Failed to listen for gRPC:", err)
+		}
+
+		go func() {
+			log.Printf("gRPC server starting on port %s", cfg.GRPC.Port)
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
+
+		if cfg.GRPC.GatewayPort != "" {
+			gateway, err = rpc.NewGateway(context.Background(), "localhost:"+cfg.GRPC.Port)
+			if err != nil {
+				log.Fatal("Failed to set up the gRPC gateway:", err)
+			}
+			gatewaySrv = &http.Server{
+				Addr:         ":" + cfg.GRPC.GatewayPort,
+				Handler:      gateway,
+				ReadTimeout:  cfg.Server.ReadTimeout,
+				WriteTimeout: cfg.Server.WriteTimeout,
+				IdleTimeout:  cfg.Server.IdleTimeout,
+			}
+
+			go func() {
+				log.Printf("gRPC gateway starting on port %s", cfg.GRPC.GatewayPort)
+				if err := gatewaySrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Fatalf("gRPC gateway failed to start: %v", err)
+				}
+			}()
+		}
+	}
+
+	// admin server for /metrics, kept off the public port
+	var adminSrv *http.Server
+	if cfg.Admin.Port != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("/metrics", m.Handler(cfg.Admin.MetricsToken))
+		adminSrv = &http.Server{
+			Addr:         ":" + cfg.Admin.Port,
+			Handler:      adminMux,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 15 * time.Second,
+		}
+
+		go func() {
+			log.Printf("Admin server starting on port %s", cfg.Admin.Port)
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Admin server failed to start: %v", err)
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -72,12 +288,43 @@ func main() {
 
 	log.Println("Server shutting down...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// fail readiness first so load balancers stop routing to us
+	healthRegistry.SetShuttingDown()
+	if grpcSrv != nil {
+		grpcSrv.SetShuttingDown()
+	}
+	time.Sleep(cfg.Server.ShutdownDrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(ctx); err != nil {
+			log.Println("Admin server forced to shutdown:", err)
+		}
+	}
+
+	// the gateway first, its calls are the gRPC server's too
+	if gatewaySrv != nil {
+		if err := gatewaySrv.Shutdown(ctx); err != nil {
+			log.Println("gRPC gateway forced to shutdown:", err)
+		}
+		gateway.Close()
+	}
+	if grpcSrv != nil {
+		if err := grpcSrv.Shutdown(ctx); err != nil {
+			log.Println("gRPC server forced to shutdown:", err)
+		}
+	}
+
+	// flush buffered spans
+	if err := shutdownTracing(ctx); err != nil {
+		log.Println("Tracing shutdown error:", err)
+	}
+
 	log.Println("Server exited")
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kurtgray/blog-api-go/internal/backup"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/database"
+)
+
+func backupContent(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("out", "backup.tar.gz", "archive to write, - for stdout")
+	secrets := fs.Bool("include-secrets", false, "keep password hashes, the archive then needs guarding like the database")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.ConnectWithRetry(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Disconnect()
+
+	var w io.Writer = os.Stdout
+	var tmp *os.File
+	if *out != "-" {
+		// written beside the target and renamed, so a failed run leaves
+		// any previous backup in place
+		tmp, err = os.CreateTemp(filepath.Dir(*out), ".backup-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		w = tmp
+	}
+
+	manifest, err := backup.New(db.Database).Write(ctx, w, backup.WriteOptions{IncludeSecrets: *secrets})
+	if err != nil {
+		return err
+	}
+
+	if tmp != nil {
+		if err := tmp.Sync(); err != nil {
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), *out); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range manifest.Collections {
+		fmt.Fprintf(os.Stderr, "%-16s %d\n", c.Name, c.Count)
+	}
+	return nil
+}
+
+func restoreContent(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	conflict := fs.String("conflict", backup.ConflictFail, "for documents that already exist: fail, skip or overwrite")
+	verify := fs.Bool("verify-only", false, "check the archive and count conflicts without writing")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: blogctl restore [flags] <archive>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("an archive path is required")
+	}
+	if !backup.ValidConflict(*conflict) {
+		return fmt.Errorf("-conflict must be fail, skip or overwrite")
+	}
+	path := fs.Arg(0)
+
+	db, err := database.ConnectWithRetry(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Disconnect()
+
+	open := func() (io.ReadCloser, error) { return os.Open(path) }
+	report, err := backup.New(db.Database).Restore(ctx, open, backup.RestoreOptions{
+		Conflict:   *conflict,
+		VerifyOnly: *verify,
+	})
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(report); encErr != nil && err == nil {
+			err = encErr
+		}
+	}
+	return err
+}
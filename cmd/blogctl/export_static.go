@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/database"
+	"github.com/kurtgray/blog-api-go/internal/handlers"
+	"github.com/kurtgray/blog-api-go/internal/render"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/router"
+	"github.com/kurtgray/blog-api-go/internal/staticsite"
+	"github.com/kurtgray/blog-api-go/internal/web"
+)
+
+func exportStatic(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export-static", flag.ContinueOnError)
+	out := fs.String("out", "public", "output directory")
+	full := fs.Bool("full", false, "re-render every post, not just those changed since the last export")
+	changes := fs.String("changes", "", "also write the changed file list as JSON to this path, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.ConnectWithRetry(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Disconnect()
+
+	userRepo := repository.NewUserRepository(db.Database)
+	postRepo := repository.NewPostRepository(db.Database)
+	commentRepo := repository.NewCommentRepository(db.Database)
+	renderer := render.New()
+
+	// the export always has pages, whether or not the API serves them
+	webSite, err := web.New(postRepo, commentRepo, userRepo, renderer, cfg.Site, cfg.Web)
+	if err != nil {
+		return err
+	}
+
+	site := router.StaticSite(
+		handlers.NewPostHandler(postRepo, userRepo, nil, nil, nil, renderer, cfg.Site, false, true),
+		handlers.NewFeedHandler(postRepo, userRepo, renderer, cfg.Site, true),
+		handlers.NewSitemapHandler(postRepo, cfg.Site, true),
+		webSite,
+	)
+
+	exporter := staticsite.NewExporter(site, postRepo, webSite.Assets(), cfg.Web.PageSize)
+	manifest, err := exporter.Export(ctx, staticsite.Options{Dir: *out, Full: *full})
+	if err != nil {
+		return err
+	}
+
+	c := manifest.Changes
+	fmt.Fprintf(os.Stderr, "exported to %s: %d added, %d updated, %d removed, %d unchanged\n",
+		*out, len(c.Added), len(c.Updated), len(c.Removed), c.Unchanged)
+
+	if *changes == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *changes == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*changes, data, 0o644)
+}
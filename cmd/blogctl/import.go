@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/content"
+	"github.com/kurtgray/blog-api-go/internal/database"
+	"github.com/kurtgray/blog-api-go/internal/importer"
+	"github.com/kurtgray/blog-api-go/internal/render"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+)
+
+func importContent(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "export format: wxr, ghost or markdown")
+	source := fs.String("source", "", "label for this source, re-runs with the same label skip imported content")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without writing anything")
+	defaultAuthor := fs.String("default-author", "", "username for posts without an author in the export")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: blogctl import -format <format> -source <label> [flags] <file or directory>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *format == "" || *source == "" {
+		fs.Usage()
+		return fmt.Errorf("a format, source label and path are required")
+	}
+
+	src, err := parseExport(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	db, err := database.ConnectWithRetry(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Disconnect()
+
+	userRepo := repository.NewUserRepository(db.Database)
+	imp := importer.New(
+		userRepo,
+		content.New(db, userRepo, repository.NewPostRepository(db.Database), repository.NewCommentRepository(db.Database)),
+		repository.NewImportRepository(db.Database),
+		render.New(),
+	)
+	report, err := imp.Run(ctx, src, importer.Options{
+		Source:        *source,
+		DryRun:        *dryRun,
+		DefaultAuthor: *defaultAuthor,
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// markdown takes a directory as well as a zip of one
+func parseExport(format, path string) (*importer.Source, error) {
+	if format == importer.FormatMarkdown {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return importer.ParseMarkdown(os.DirFS(path))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return importer.Parse(format, data)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/content"
+	"github.com/kurtgray/blog-api-go/internal/database"
+	"github.com/kurtgray/blog-api-go/internal/integrity"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+)
+
+// exits non-zero while drift remains, so it can run from cron
+func checkIntegrity(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("check-integrity", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "fix what the checks find")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.ConnectWithRetry(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Disconnect()
+
+	contentService := content.New(db,
+		repository.NewUserRepository(db.Database),
+		repository.NewPostRepository(db.Database),
+		repository.NewCommentRepository(db.Database),
+	)
+	report, err := integrity.New(db.Database, contentService, cfg.Privacy.DeletedUsername).Run(ctx, *repair)
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(report); encErr != nil && err == nil {
+			err = encErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if n := report.Outstanding(); n > 0 {
+		return fmt.Errorf("%d problems found, run with -repair to fix them", n)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/kurtgray/blog-api-go/internal/config"
+)
+
+// usage: blogctl [config flags] <command> [command flags]
+// config flags, env and -config file work exactly as for the API server
+
+type command struct {
+	summary string
+	run     func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"backup": {
+		summary: "write users, posts, comments and media metadata to a checksummed archive",
+		run:     backupContent,
+	},
+	"check-integrity": {
+		summary: "report, and with -repair fix, drift between users, posts and comments",
+		run:     checkIntegrity,
+	},
+	"export-static": {
+		summary: "render the public site to a directory of static files",
+		run:     exportStatic,
+	},
+	"import": {
+		summary: "import posts, authors and comments from WordPress, Ghost or Markdown",
+		run:     importContent,
+	},
+	"restore": {
+		summary: "restore a backup archive into the configured database",
+		run:     restoreContent,
+	},
+}
+
+func main() {
+	log.SetFlags(0)
+
+	cfg, args, err := config.LoadCommand(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:\n", err)
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.run(ctx, cfg, args[1:]); err != nil {
+		log.Fatalf("%s: %v", args[0], err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: blogctl [config flags] <command> [command flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].summary)
+	}
+}
@@ -0,0 +1,144 @@
+package apiversion
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/problem"
+)
+
+// which contract a request is served under, by path prefix (/api/v1,
+// /api/v2) or, on the unversioned /api, by Accept header
+type Version int
+
+const (
+	V1 Version = 1
+	// users, posts and comments in {"data": ...} envelopes with consistent
+	// naming, see the views package
+	V2 Version = 2
+)
+
+// what v2 bodies are written as, and what asks for v2 on /api
+const MediaTypeV2 = "application/vnd.blog.v2+json"
+
+type contextKey string
+
+const versionKey contextKey = "apiVersion"
+
+// the version r is served under, v1 when no middleware picked one
+func From(ctx context.Context) Version {
+	if v, ok := ctx.Value(versionKey).(Version); ok {
+		return v
+	}
+	return V1
+}
+
+// serves every request under v, for the versioned prefixes
+func Pin(v Version) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, with(r, v))
+		})
+	}
+}
+
+// picks the version from Accept for the unversioned /api, v1 unless the
+// client accepts MediaTypeV2
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		v := V1
+		if accepts(r.Header.Get("Accept"), MediaTypeV2) {
+			v = V2
+		}
+		next.ServeHTTP(w, with(r, v))
+	})
+}
+
+func with(r *http.Request, v Version) *http.Request {
+	ctx := context.WithValue(r.Context(), versionKey, v)
+	// v2 was never written in the legacy envelope
+	if v >= V2 {
+		ctx = problem.WithoutLegacy(ctx)
+	}
+	return r.WithContext(ctx)
+}
+
+// whether an Accept header lists mediaType without q=0
+func accepts(header, mediaType string) bool {
+	for _, part := range strings.Split(header, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mt != mediaType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// when v1 was deprecated and when it goes away, a zero time leaves its
+// header out
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+}
+
+// from YYYY-MM-DD dates, empty ones stay zero
+func ParseDeprecation(since, sunset string) (Deprecation, error) {
+	var d Deprecation
+	var err error
+	if since != "" {
+		if d.Since, err = time.Parse(time.DateOnly, since); err != nil {
+			return d, err
+		}
+	}
+	if sunset != "" {
+		if d.Sunset, err = time.Parse(time.DateOnly, sunset); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// neither date is set, v1 isn't deprecated
+func (d Deprecation) IsZero() bool {
+	return d.Since.IsZero() && d.Sunset.IsZero()
+}
+
+// marks v1 responses of routes v2 replaces with Deprecation (RFC 9745) and
+// Sunset (RFC 8594) headers and a successor-version link to the same route
+// under /api/v2, leaves them alone when d is zero
+func (d Deprecation) Middleware(next http.Handler) http.Handler {
+	if d.IsZero() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if From(r.Context()) == V1 {
+			h := w.Header()
+			if !d.Since.IsZero() {
+				h.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+			}
+			if !d.Sunset.IsZero() {
+				h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			h.Add("Link", "<"+successor(r.URL.Path)+`>; rel="successor-version"`)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// /api/v1/posts and /api/posts become /api/v2/posts
+func successor(path string) string {
+	rest := strings.TrimPrefix(path, "/api")
+	if after, ok := strings.CutPrefix(rest, "/v1"); ok && (after == "" || after[0] == '/') {
+		rest = after
+	}
+	return "/api/v2" + rest
+}
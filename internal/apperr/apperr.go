@@ -0,0 +1,70 @@
+package apperr
+
+import (
+	"errors"
+	"strings"
+)
+
+// kinds of failure, errors.Is on an *Error matches its kind
+var (
+	ErrNotFound = errors.New("not found")
+	// the write clashes with what's stored, a duplicate or a bad state
+	ErrConflict = errors.New("conflict")
+	// the input is unusable, Fields says what's wrong with it
+	ErrValidation = errors.New("validation failed")
+	// the write was based on a version that has since moved on
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrForbidden          = errors.New("forbidden")
+)
+
+// what's wrong with one input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// a failure safe to show a client, Code is stable and machine-readable,
+// Message is for people
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func New(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// e.g. NotFound("post") is post_not_found, "post not found"
+func NotFound(what string) *Error {
+	return New(ErrNotFound, strings.ReplaceAll(what, " ", "_")+"_not_found", what+" not found")
+}
+
+func Conflict(code, message string) *Error {
+	return New(ErrConflict, code, message)
+}
+
+// one or more invalid fields, the message is the first field's
+func Validation(fields ...FieldError) *Error {
+	e := New(ErrValidation, "validation_failed", "validation failed")
+	if len(fields) > 0 {
+		e.Message = fields[0].Message
+	}
+	e.Fields = fields
+	return e
+}
+
+// a single invalid field, code says how it's invalid, e.g. "required"
+func Field(field, code, message string) *Error {
+	return Validation(FieldError{Field: field, Code: code, Message: message})
+}
@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// archive layout: a gzipped tar holding one <collection>.jsonl file per
+// collection, documents as canonical extended json so ids and dates
+// survive, followed by manifest.json with the count and sha256 of each
+const (
+	Format          = "blog-api-backup"
+	Version         = 1
+	ManifestName    = "manifest.json"
+	ContentType     = "application/gzip"
+	fileMode        = 0o644
+	collectionExt   = ".jsonl"
+	secretUserField = "password"
+)
+
+// backed up in this order and restored in the same order
+var Collections = []string{"users", "posts", "comments", "media", "import_mappings"}
+
+type Manifest struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	// password hashes are only kept when asked for
+	IncludesSecrets bool             `json:"includesSecrets"`
+	Collections     []CollectionInfo `json:"collections"`
+}
+
+type CollectionInfo struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Count  int    `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+type Service struct {
+	db *mongo.Database
+}
+
+func New(db *mongo.Database) *Service {
+	return &Service{db: db}
+}
+
+type WriteOptions struct {
+	IncludeSecrets bool
+}
+
+// streams a backup archive to w, each collection is spooled to a temp
+// file first since tar needs its size up front
+func (s *Service) Write(ctx context.Context, w io.Writer, opts WriteOptions) (*Manifest, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest := &Manifest{
+		Format:          Format,
+		Version:         Version,
+		CreatedAt:       time.Now().UTC(),
+		IncludesSecrets: opts.IncludeSecrets,
+		Collections:     []CollectionInfo{},
+	}
+
+	for _, name := range Collections {
+		info, err := s.writeCollection(ctx, tw, name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: %w", name, err)
+		}
+		manifest.Collections = append(manifest.Collections, *info)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, ManifestName, int64(len(data)), manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (s *Service) writeCollection(ctx context.Context, tw *tar.Writer, name string, opts WriteOptions) (*CollectionInfo, error) {
+	spool, err := os.CreateTemp("", "backup-"+name+"-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	cursor, err := s.db.Collection(name).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sum := sha256.New()
+	out := io.MultiWriter(spool, sum)
+	info := &CollectionInfo{Name: name, File: name + collectionExt}
+
+	for cursor.Next(ctx) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if name == "users" && !opts.IncludeSecrets {
+			doc = without(doc, secretUserField)
+		}
+		line, err := bson.MarshalExtJSON(doc, true, false)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := out.Write(append(line, '\n')); err != nil {
+			return nil, err
+		}
+		info.Count++
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, info.File, size, time.Now()); err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tw, spool); err != nil {
+		return nil, err
+	}
+
+	info.SHA256 = hexSum(sum)
+	return info, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, size int64, modified time.Time) error {
+	return tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    fileMode,
+		Size:    size,
+		ModTime: modified,
+		Format:  tar.FormatPAX,
+	})
+}
+
+func without(doc bson.D, field string) bson.D {
+	out := doc[:0]
+	for _, e := range doc {
+		if e.Key != field {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,313 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// what to do with documents whose _id is already in the database
+const (
+	// leave the existing document alone
+	ConflictSkip = "skip"
+	// fields from the backup replace the existing ones, fields the backup
+	// doesn't have (e.g. stripped passwords) are kept
+	ConflictOverwrite = "overwrite"
+	// refuse to restore anything if any document exists
+	ConflictFail = "fail"
+)
+
+const writeBatchSize = 500
+
+var ErrConflict = errors.New("documents in the backup already exist")
+
+type RestoreOptions struct {
+	Conflict string
+	// check the archive and report conflicts without writing
+	VerifyOnly bool
+}
+
+type RestoreCounts struct {
+	Created int64 `json:"created"`
+	Updated int64 `json:"updated"`
+	Skipped int64 `json:"skipped"`
+	// documents already in the database, counted while verifying
+	Conflicts int64 `json:"conflicts"`
+}
+
+type RestoreReport struct {
+	Manifest    *Manifest                 `json:"manifest"`
+	Conflict    string                    `json:"conflict"`
+	VerifyOnly  bool                      `json:"verifyOnly"`
+	Collections map[string]*RestoreCounts `json:"collections"`
+}
+
+func ValidConflict(strategy string) bool {
+	switch strategy {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return true
+	}
+	return false
+}
+
+// restores an archive in two passes: the first verifies checksums and
+// counts conflicts, the second writes, so a damaged archive changes nothing
+// open is called once per pass
+func (s *Service) Restore(ctx context.Context, open func() (io.ReadCloser, error), opts RestoreOptions) (*RestoreReport, error) {
+	if !ValidConflict(opts.Conflict) {
+		return nil, fmt.Errorf("unknown conflict strategy %q", opts.Conflict)
+	}
+
+	report := &RestoreReport{
+		Conflict:    opts.Conflict,
+		VerifyOnly:  opts.VerifyOnly,
+		Collections: map[string]*RestoreCounts{},
+	}
+	for _, name := range Collections {
+		report.Collections[name] = &RestoreCounts{}
+	}
+
+	// pass 1: verify and look for existing ids
+	checker := &batcher{size: writeBatchSize, flush: func(name string, docs []bson.D) error {
+		n, err := s.countExisting(ctx, name, docs)
+		report.Collections[name].Conflicts += n
+		return err
+	}}
+	manifest, err := s.readFrom(open, checker.add)
+	if err != nil {
+		return nil, err
+	}
+	if err := checker.done(); err != nil {
+		return nil, err
+	}
+	report.Manifest = manifest
+
+	if opts.VerifyOnly {
+		return report, nil
+	}
+	if opts.Conflict == ConflictFail {
+		var total int64
+		for _, c := range report.Collections {
+			total += c.Conflicts
+		}
+		if total > 0 {
+			return report, fmt.Errorf("%w: %d documents", ErrConflict, total)
+		}
+	}
+
+	// pass 2: write
+	writer := &batcher{size: writeBatchSize, flush: func(name string, docs []bson.D) error {
+		return s.write(ctx, name, docs, opts.Conflict, report.Collections[name])
+	}}
+	if _, err := s.readFrom(open, writer.add); err != nil {
+		return nil, err
+	}
+	if err := writer.done(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (s *Service) readFrom(open func() (io.ReadCloser, error), fn func(collection string, doc bson.D) error) (*Manifest, error) {
+	rc, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ReadArchive(rc, fn)
+}
+
+func (s *Service) countExisting(ctx context.Context, name string, docs []bson.D) (int64, error) {
+	ids := make(bson.A, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, idOf(doc))
+	}
+	return s.db.Collection(name).CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (s *Service) write(ctx context.Context, name string, docs []bson.D, conflict string, counts *RestoreCounts) error {
+	models := make([]mongo.WriteModel, 0, len(docs))
+	for _, doc := range docs {
+		filter := bson.M{"_id": idOf(doc)}
+		fields := without(append(bson.D{}, doc...), "_id")
+		switch conflict {
+		case ConflictSkip:
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(filter).
+				SetUpdate(bson.M{"$setOnInsert": fields}).
+				SetUpsert(true))
+		case ConflictOverwrite:
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(filter).
+				SetUpdate(bson.M{"$set": fields}).
+				SetUpsert(true))
+		default:
+			models = append(models, mongo.NewInsertOneModel().SetDocument(doc))
+		}
+	}
+
+	result, err := s.db.Collection(name).BulkWrite(ctx, models)
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", name, err)
+	}
+
+	counts.Created += result.InsertedCount + result.UpsertedCount
+	if conflict == ConflictSkip {
+		counts.Skipped += result.MatchedCount
+	} else {
+		counts.Updated += result.MatchedCount
+	}
+	return nil
+}
+
+// reads and verifies an archive, calling fn for every document
+// documents are handed over before the manifest at the end of the archive
+// has been checked, so fn must not write anything that can't be discarded
+func ReadArchive(r io.Reader, fn func(collection string, doc bson.D) error) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	known := map[string]string{}
+	for _, name := range Collections {
+		known[name+collectionExt] = name
+	}
+
+	var manifest *Manifest
+	seen := map[string]CollectionInfo{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Name == ManifestName {
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("manifest: %w", err)
+			}
+			continue
+		}
+
+		name, ok := known[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("unexpected file %q in archive", hdr.Name)
+		}
+		info, err := readCollection(tr, name, fn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		seen[hdr.Name] = *info
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("archive has no %s", ManifestName)
+	}
+	if manifest.Format != Format {
+		return nil, fmt.Errorf("not a backup archive: format %q", manifest.Format)
+	}
+	if manifest.Version > Version {
+		return nil, fmt.Errorf("backup version %d is newer than supported version %d", manifest.Version, Version)
+	}
+	for _, want := range manifest.Collections {
+		got, ok := seen[want.File]
+		if !ok {
+			return nil, fmt.Errorf("%s is listed in the manifest but missing", want.File)
+		}
+		if got.Count != want.Count || got.SHA256 != want.SHA256 {
+			return nil, fmt.Errorf("%s: checksum mismatch, archive is damaged", want.File)
+		}
+		delete(seen, want.File)
+	}
+	for file := range seen {
+		return nil, fmt.Errorf("%s is not listed in the manifest", file)
+	}
+
+	return manifest, nil
+}
+
+func readCollection(r io.Reader, name string, fn func(collection string, doc bson.D) error) (*CollectionInfo, error) {
+	sum := sha256.New()
+	br := bufio.NewReader(io.TeeReader(r, sum))
+	info := &CollectionInfo{Name: name, File: name + collectionExt}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			info.Count++
+			var doc bson.D
+			if err := bson.UnmarshalExtJSON(line, true, &doc); err != nil {
+				return nil, fmt.Errorf("line %d: %w", info.Count, err)
+			}
+			if idOf(doc) == nil {
+				return nil, fmt.Errorf("line %d: document has no _id", info.Count)
+			}
+			if err := fn(name, doc); err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	info.SHA256 = hexSum(sum)
+	return info, nil
+}
+
+func idOf(doc bson.D) interface{} {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+// groups documents of the same collection into batches
+type batcher struct {
+	size       int
+	flush      func(collection string, docs []bson.D) error
+	collection string
+	docs       []bson.D
+}
+
+func (b *batcher) add(collection string, doc bson.D) error {
+	if collection != b.collection || len(b.docs) >= b.size {
+		if err := b.done(); err != nil {
+			return err
+		}
+		b.collection = collection
+	}
+	b.docs = append(b.docs, doc)
+	return nil
+}
+
+func (b *batcher) done() error {
+	if len(b.docs) == 0 {
+		return nil
+	}
+	docs := b.docs
+	b.docs = nil
+	return b.flush(b.collection, docs)
+}
@@ -1,22 +1,498 @@
 package config
 
 import (
-	"log"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
 )
 
+// precedence, lowest to highest: default tag, config file, env var, command-line flag
+// leaf fields are described by struct tags:
+//
+//	env      environment variable
+//	flag     command-line flag name
+//	default  value used when nothing else sets the field
+//	required must be non-zero after loading
+//	secret   redacted by String
+//
+// supported leaf types: string, int, bool, time.Duration, []string (comma separated)
 type Config struct {
-	MongoDB string
-	JWTSecret string
-	Port string
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	CORS     CORSConfig     `yaml:"cors" toml:"cors"`
+	Admin    AdminConfig    `yaml:"admin" toml:"admin"`
+	Tracing  TracingConfig  `yaml:"tracing" toml:"tracing"`
+	Health   HealthConfig   `yaml:"health" toml:"health"`
+	Media    MediaConfig    `yaml:"media" toml:"media"`
+	Site     SiteConfig     `yaml:"site" toml:"site"`
+	Web      WebConfig      `yaml:"web" toml:"web"`
+	Privacy  PrivacyConfig  `yaml:"privacy" toml:"privacy"`
+	Trash    TrashConfig    `yaml:"trash" toml:"trash"`
+	GraphQL  GraphQLConfig  `yaml:"graphql" toml:"graphql"`
+	GRPC     GRPCConfig     `yaml:"grpc" toml:"grpc"`
+	Webhooks WebhooksConfig `yaml:"webhooks" toml:"webhooks"`
+}
+
+type ServerConfig struct {
+	Port            string        `yaml:"port" toml:"port" env:"PORT" flag:"port" default:"8080" required:"true"`
+	ReadTimeout     time.Duration `yaml:"readTimeout" toml:"readTimeout" env:"SERVER_READ_TIMEOUT" flag:"read-timeout" default:"15s"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout" toml:"writeTimeout" env:"SERVER_WRITE_TIMEOUT" flag:"write-timeout" default:"15s"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout" toml:"idleTimeout" env:"SERVER_IDLE_TIMEOUT" flag:"idle-timeout" default:"60s"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout" env:"SERVER_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" default:"30s"`
+	// time between failing readiness and closing listeners
+	ShutdownDrainDelay time.Duration `yaml:"shutdownDrainDelay" toml:"shutdownDrainDelay" env:"SERVER_SHUTDOWN_DRAIN_DELAY" flag:"shutdown-drain-delay" default:"5s"`
+	// PUT, PATCH and DELETE on posts and comments must send If-Match, when
+	// off writes without one skip the version check
+	RequireIfMatch bool `yaml:"requireIfMatch" toml:"requireIfMatch" env:"SERVER_REQUIRE_IF_MATCH" flag:"require-if-match" default:"false"`
+	// errors in the old {"success": false, "message"} envelope instead of
+	// application/problem+json, for clients not yet moved over
+	LegacyErrors bool `yaml:"legacyErrors" toml:"legacyErrors" env:"SERVER_LEGACY_ERRORS" flag:"legacy-errors" default:"false"`
+	// checks requests and responses against /openapi.json: off, log, or
+	// strict to also turn responses that break it into 500s, for tests and
+	// staging
+	ContractValidation string `yaml:"contractValidation" toml:"contractValidation" env:"SERVER_CONTRACT_VALIDATION" flag:"contract-validation" default:"off"`
+	// YYYY-MM-DD dates for the Deprecation and Sunset headers on v1
+	// responses of routes v2 replaces, empty leaves a header out and with
+	// both empty v1 isn't marked deprecated at all
+	V1DeprecatedAt string `yaml:"v1DeprecatedAt" toml:"v1DeprecatedAt" env:"SERVER_V1_DEPRECATED_AT" flag:"v1-deprecated-at"`
+	V1SunsetAt     string `yaml:"v1SunsetAt" toml:"v1SunsetAt" env:"SERVER_V1_SUNSET_AT" flag:"v1-sunset-at"`
+}
+
+type DatabaseConfig struct {
+	URI            string        `yaml:"uri" toml:"uri" env:"MONGO_DB" flag:"mongo-uri" required:"true" secret:"true"`
+	Name           string        `yaml:"name" toml:"name" env:"DB_NAME" flag:"db-name" default:"blog" required:"true"`
+	ConnectTimeout time.Duration `yaml:"connectTimeout" toml:"connectTimeout" env:"DB_CONNECT_TIMEOUT" flag:"db-connect-timeout" default:"10s"`
+	// startup connection attempts before giving up
+	ConnectAttempts int `yaml:"connectAttempts" toml:"connectAttempts" env:"DB_CONNECT_ATTEMPTS" flag:"db-connect-attempts" default:"5"`
+}
+
+type AuthConfig struct {
+	JWTSecret  string        `yaml:"jwtSecret" toml:"jwtSecret" env:"JWT_SECRET" flag:"jwt-secret" required:"true" secret:"true"`
+	TokenTTL   time.Duration `yaml:"tokenTTL" toml:"tokenTTL" env:"JWT_TTL" flag:"jwt-ttl" default:"24h"`
+	BcryptCost int           `yaml:"bcryptCost" toml:"bcryptCost" env:"BCRYPT_COST" flag:"bcrypt-cost" default:"10"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins" toml:"allowedOrigins" env:"CORS_ALLOWED_ORIGINS" flag:"cors-origins" default:"http://localhost:3000,https://morning-meadow-95658.herokuapp.com"`
+	// preflight cache, seconds
+	MaxAge int `yaml:"maxAge" toml:"maxAge" env:"CORS_MAX_AGE" flag:"cors-max-age" default:"300"`
+}
+
+type AdminConfig struct {
+	// admin listener for /metrics, disabled when empty
+	Port string `yaml:"port" toml:"port" env:"ADMIN_PORT" flag:"admin-port"`
+	// optional bearer token guarding /metrics
+	MetricsToken string `yaml:"metricsToken" toml:"metricsToken" env:"METRICS_TOKEN" flag:"metrics-token" secret:"true"`
+}
+
+type TracingConfig struct {
+	// none, otlp or stdout
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACE_EXPORTER" flag:"trace-exporter" default:"none"`
+	// stdout exporter target, standard output when empty
+	File string `yaml:"file" toml:"file" env:"TRACE_FILE" flag:"trace-file"`
 }
 
-func Load() *Config {
+type HealthConfig struct {
+	// filesystem checked by /readyz for free space, skipped when empty
+	DiskPath string `yaml:"diskPath" toml:"diskPath" env:"HEALTH_DISK_PATH" flag:"health-disk-path"`
+}
+
+type MediaConfig struct {
+	// local or s3
+	Storage string `yaml:"storage" toml:"storage" env:"MEDIA_STORAGE" flag:"media-storage" default:"local"`
+	// upload size limit in bytes
+	MaxUploadSize int `yaml:"maxUploadSize" toml:"maxUploadSize" env:"MEDIA_MAX_UPLOAD_SIZE" flag:"media-max-upload-size" default:"10485760"`
+	// local storage root, served by the API under /media
+	LocalDir string `yaml:"localDir" toml:"localDir" env:"MEDIA_LOCAL_DIR" flag:"media-local-dir" default:"uploads"`
+	// prefix for local media URLs, e.g. https://api.example.com/media
+	LocalBaseURL string `yaml:"localBaseURL" toml:"localBaseURL" env:"MEDIA_LOCAL_BASE_URL" flag:"media-local-base-url" default:"/media"`
+
+	S3Endpoint  string `yaml:"s3Endpoint" toml:"s3Endpoint" env:"MEDIA_S3_ENDPOINT" flag:"media-s3-endpoint"`
+	S3Region    string `yaml:"s3Region" toml:"s3Region" env:"MEDIA_S3_REGION" flag:"media-s3-region"`
+	S3Bucket    string `yaml:"s3Bucket" toml:"s3Bucket" env:"MEDIA_S3_BUCKET" flag:"media-s3-bucket"`
+	S3AccessKey string `yaml:"s3AccessKey" toml:"s3AccessKey" env:"MEDIA_S3_ACCESS_KEY" flag:"media-s3-access-key" secret:"true"`
+	S3SecretKey string `yaml:"s3SecretKey" toml:"s3SecretKey" env:"MEDIA_S3_SECRET_KEY" flag:"media-s3-secret-key" secret:"true"`
+	S3UseSSL    bool   `yaml:"s3UseSSL" toml:"s3UseSSL" env:"MEDIA_S3_USE_SSL" flag:"media-s3-use-ssl" default:"true"`
+	// public base URL for objects, defaults to the bucket URL on the endpoint
+	S3PublicURL string `yaml:"s3PublicURL" toml:"s3PublicURL" env:"MEDIA_S3_PUBLIC_URL" flag:"media-s3-public-url"`
+}
+
+// public face of the blog, used by feeds and other generated documents
+type SiteConfig struct {
+	Title       string `yaml:"title" toml:"title" env:"SITE_TITLE" flag:"site-title" default:"Blog"`
+	Description string `yaml:"description" toml:"description" env:"SITE_DESCRIPTION" flag:"site-description"`
+	// absolute URL the site is reachable at, without trailing slash
+	BaseURL  string `yaml:"baseURL" toml:"baseURL" env:"SITE_BASE_URL" flag:"site-base-url" default:"http://localhost:8080" required:"true"`
+	Language string `yaml:"language" toml:"language" env:"SITE_LANGUAGE" flag:"site-language" default:"en"`
+	// posts per feed
+	FeedLimit int `yaml:"feedLimit" toml:"feedLimit" env:"SITE_FEED_LIMIT" flag:"site-feed-limit" default:"20"`
+}
+
+// server-rendered reader pages
+type WebConfig struct {
+	// when off, / redirects to the JSON API instead
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"WEB_ENABLED" flag:"web-enabled" default:"false"`
+	// name of an embedded theme
+	Theme string `yaml:"theme" toml:"theme" env:"WEB_THEME" flag:"web-theme" default:"default"`
+	// directory holding a custom theme, overrides Theme when set
+	ThemeDir string `yaml:"themeDir" toml:"themeDir" env:"WEB_THEME_DIR" flag:"web-theme-dir"`
+	// posts per index, author and tag page
+	PageSize int `yaml:"pageSize" toml:"pageSize" env:"WEB_PAGE_SIZE" flag:"web-page-size" default:"10"`
+	// Cache-Control max-age for rendered pages
+	CacheMaxAge time.Duration `yaml:"cacheMaxAge" toml:"cacheMaxAge" env:"WEB_CACHE_MAX_AGE" flag:"web-cache-max-age" default:"1m"`
+}
+
+// personal data exports and account erasure
+type PrivacyConfig struct {
+	// where export archives are written, never served publicly
+	ExportDir string `yaml:"exportDir" toml:"exportDir" env:"PRIVACY_EXPORT_DIR" flag:"privacy-export-dir" default:"exports"`
+	// how long a finished export can be downloaded
+	ExportTTL time.Duration `yaml:"exportTTL" toml:"exportTTL" env:"PRIVACY_EXPORT_TTL" flag:"privacy-export-ttl" default:"24h"`
+	// what happens to an erased user's posts: reassign or delete
+	ErasedPosts string `yaml:"erasedPosts" toml:"erasedPosts" env:"PRIVACY_ERASED_POSTS" flag:"privacy-erased-posts" default:"reassign"`
+	// account that keeps reassigned posts and anonymized comments
+	DeletedUsername string `yaml:"deletedUsername" toml:"deletedUsername" env:"PRIVACY_DELETED_USERNAME" flag:"privacy-deleted-username" default:"deleted-user"`
+}
+
+// deleted posts and comments
+type TrashConfig struct {
+	// how long trashed items can be restored before they are purged
+	Retention time.Duration `yaml:"retention" toml:"retention" env:"TRASH_RETENTION" flag:"trash-retention" default:"720h"`
+	// how often the purge job looks for expired items
+	PurgeInterval time.Duration `yaml:"purgeInterval" toml:"purgeInterval" env:"TRASH_PURGE_INTERVAL" flag:"trash-purge-interval" default:"1h"`
+}
+
+// the /graphql endpoint over users, posts and comments
+type GraphQLConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"GRAPHQL_ENABLED" flag:"graphql-enabled" default:"false"`
+	// deepest a selection may nest
+	MaxDepth int `yaml:"maxDepth" toml:"maxDepth" env:"GRAPHQL_MAX_DEPTH" flag:"graphql-max-depth" default:"10"`
+	// what a query may cost, each field costs one per item it's resolved
+	// for, so page sizes multiply whatever is selected below them
+	// checked before the query runs
+	MaxComplexity int `yaml:"maxComplexity" toml:"maxComplexity" env:"GRAPHQL_MAX_COMPLEXITY" flag:"graphql-max-complexity" default:"5000"`
+	// longest query text in bytes
+	MaxQueryLength int `yaml:"maxQueryLength" toml:"maxQueryLength" env:"GRAPHQL_MAX_QUERY_LENGTH" flag:"graphql-max-query-length" default:"20000"`
+	// JSON file of sha256 hashes to query text, clients may send just the hash
+	PersistedQueries string `yaml:"persistedQueries" toml:"persistedQueries" env:"GRAPHQL_PERSISTED_QUERIES" flag:"graphql-persisted-queries"`
+	// runs nothing but the queries in PersistedQueries, new ones aren't learned
+	PersistedOnly bool `yaml:"persistedOnly" toml:"persistedOnly" env:"GRAPHQL_PERSISTED_ONLY" flag:"graphql-persisted-only"`
+	Introspection bool `yaml:"introspection" toml:"introspection" env:"GRAPHQL_INTROSPECTION" flag:"graphql-introspection" default:"false"`
+}
+
+type GRPCConfig struct {
+	// gRPC listener for the blog.v1 services, disabled when empty
+	Port string `yaml:"port" toml:"port" env:"GRPC_PORT" flag:"grpc-port"`
+	// JSON over HTTP transcoded to the gRPC services, disabled when empty
+	GatewayPort string `yaml:"gatewayPort" toml:"gatewayPort" env:"GRPC_GATEWAY_PORT" flag:"grpc-gateway-port"`
+	// lets grpcurl and the like list the services and their messages
+	Reflection bool `yaml:"reflection" toml:"reflection" env:"GRPC_REFLECTION" flag:"grpc-reflection" default:"true"`
+}
+
+// delivery of events to the webhooks admins register
+type WebhooksConfig struct {
+	// attempts per delivery, the first included, before it's marked failed
+	MaxAttempts int `yaml:"maxAttempts" toml:"maxAttempts" env:"WEBHOOKS_MAX_ATTEMPTS" flag:"webhooks-max-attempts" default:"8"`
+	// wait before the first retry, doubled after each failed attempt
+	RetryBackoff time.Duration `yaml:"retryBackoff" toml:"retryBackoff" env:"WEBHOOKS_RETRY_BACKOFF" flag:"webhooks-retry-backoff" default:"30s"`
+	// longest wait between two attempts
+	MaxBackoff time.Duration `yaml:"maxBackoff" toml:"maxBackoff" env:"WEBHOOKS_MAX_BACKOFF" flag:"webhooks-max-backoff" default:"1h"`
+	// deliveries in a row that fail every attempt before the webhook is disabled
+	DisableAfter int `yaml:"disableAfter" toml:"disableAfter" env:"WEBHOOKS_DISABLE_AFTER" flag:"webhooks-disable-after" default:"5"`
+	// per attempt, an endpoint slower than this has failed
+	Timeout time.Duration `yaml:"timeout" toml:"timeout" env:"WEBHOOKS_TIMEOUT" flag:"webhooks-timeout" default:"10s"`
+	// how often retries that have come due are looked for, new events go out right away
+	PollInterval time.Duration `yaml:"pollInterval" toml:"pollInterval" env:"WEBHOOKS_POLL_INTERVAL" flag:"webhooks-poll-interval" default:"10s"`
+	// how long finished deliveries stay in the log
+	LogRetention time.Duration `yaml:"logRetention" toml:"logRetention" env:"WEBHOOKS_LOG_RETENTION" flag:"webhooks-log-retention" default:"720h"`
+}
+
+// loads config from defaults, an optional file, env and args (usually os.Args[1:])
+// the file comes from -config or CONFIG_FILE, .yaml/.yml or .toml
+func Load(args []string) (*Config, error) {
+	cfg, _, err := LoadCommand(args)
+	return cfg, err
+}
+
+// like Load, but stops at the first non-flag argument and returns it and
+// everything after, for tools that take a subcommand after config flags
+func LoadCommand(args []string) (*Config, []string, error) {
 	_ = godotenv.Load()
-	return &Config{
-		MongoDB: os.Getenv("MONGO_DB"),
-		JWTSecret: os.Getenv("JWT_SECRET"),
-		Port: os.Getenv("PORT"),
+
+	cfg := &Config{}
+	fields := fieldsOf(cfg)
+
+	for _, f := range fields {
+		if f.def == "" {
+			continue
+		}
+		if err := f.set(f.def); err != nil {
+			return nil, nil, fmt.Errorf("default for %s: %w", f.path, err)
+		}
+	}
+
+	// parse flags up front to find -config, values are applied last
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file (env CONFIG_FILE)")
+	staged := map[string]*stagedFlag{}
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		sf := &stagedFlag{isBool: f.isBool()}
+		staged[f.flag] = sf
+		fs.Var(sf, f.flag, f.usage())
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(f.env); ok {
+			if err := f.set(v); err != nil {
+				return nil, nil, fmt.Errorf("env %s: %w", f.env, err)
+			}
+		}
 	}
-}
\ No newline at end of file
+
+	for _, f := range fields {
+		if sf, ok := staged[f.flag]; ok && sf.set {
+			if err := f.set(sf.raw); err != nil {
+				return nil, nil, fmt.Errorf("flag -%s: %w", f.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config file %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported extension, want .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// checks required fields and value ranges, reporting every problem at once
+func (c *Config) Validate() error {
+	var errs []error
+	for _, f := range fieldsOf(c) {
+		if f.required && f.value.IsZero() {
+			src := f.path
+			if f.env != "" {
+				src += " (env " + f.env + ")"
+			}
+			errs = append(errs, fmt.Errorf("%s is required", src))
+		}
+	}
+
+	if _, err := strconv.Atoi(c.Server.Port); c.Server.Port != "" && err != nil {
+		errs = append(errs, fmt.Errorf("server.port must be numeric, got %q", c.Server.Port))
+	}
+	if c.Admin.Port != "" && c.Admin.Port == c.Server.Port {
+		errs = append(errs, errors.New("admin.port must differ from server.port"))
+	}
+	// every listener on a port of its own
+	ports := map[string]string{c.Server.Port: "server.port"}
+	if c.Admin.Port != "" {
+		ports[c.Admin.Port] = "admin.port"
+	}
+	for _, p := range []struct{ name, port string }{
+		{"grpc.port", c.GRPC.Port},
+		{"grpc.gatewayPort", c.GRPC.GatewayPort},
+	} {
+		if p.port == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(p.port); err != nil {
+			errs = append(errs, fmt.Errorf("%s must be numeric, got %q", p.name, p.port))
+		}
+		if other, ok := ports[p.port]; ok {
+			errs = append(errs, fmt.Errorf("%s must differ from %s", p.name, other))
+		}
+		ports[p.port] = p.name
+	}
+	if c.GRPC.GatewayPort != "" && c.GRPC.Port == "" {
+		errs = append(errs, errors.New("grpc.gatewayPort needs grpc.port"))
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+		{"database.connectTimeout", c.Database.ConnectTimeout},
+		{"auth.tokenTTL", c.Auth.TokenTTL},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", d.name))
+		}
+	}
+	if c.Server.ShutdownDrainDelay < 0 {
+		errs = append(errs, errors.New("server.shutdownDrainDelay must not be negative"))
+	}
+	if c.Database.ConnectAttempts < 1 {
+		errs = append(errs, errors.New("database.connectAttempts must be at least 1"))
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("auth.bcryptCost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if c.Media.MaxUploadSize <= 0 {
+		errs = append(errs, errors.New("media.maxUploadSize must be positive"))
+	}
+	switch c.Media.Storage {
+	case "local":
+		if c.Media.LocalDir == "" {
+			errs = append(errs, errors.New("media.localDir is required for local storage"))
+		}
+	case "s3":
+		if c.Media.S3Endpoint == "" || c.Media.S3Bucket == "" {
+			errs = append(errs, errors.New("media.s3Endpoint and media.s3Bucket are required for s3 storage"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("media.storage must be local or s3, got %q", c.Media.Storage))
+	}
+	if u, err := url.Parse(c.Site.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("site.baseURL must be an absolute URL, got %q", c.Site.BaseURL))
+	}
+	if c.Site.FeedLimit < 1 {
+		errs = append(errs, errors.New("site.feedLimit must be at least 1"))
+	}
+	if c.Web.PageSize < 1 {
+		errs = append(errs, errors.New("web.pageSize must be at least 1"))
+	}
+	if c.Web.CacheMaxAge < 0 {
+		errs = append(errs, errors.New("web.cacheMaxAge must not be negative"))
+	}
+	if c.Privacy.ExportDir == "" {
+		errs = append(errs, errors.New("privacy.exportDir is required"))
+	}
+	if c.Privacy.ExportTTL <= 0 {
+		errs = append(errs, errors.New("privacy.exportTTL must be positive"))
+	}
+	switch c.Privacy.ErasedPosts {
+	case "reassign", "delete":
+	default:
+		errs = append(errs, fmt.Errorf("privacy.erasedPosts must be reassign or delete, got %q", c.Privacy.ErasedPosts))
+	}
+	if c.Privacy.DeletedUsername == "" {
+		errs = append(errs, errors.New("privacy.deletedUsername is required"))
+	}
+	if c.Trash.Retention <= 0 {
+		errs = append(errs, errors.New("trash.retention must be positive"))
+	}
+	if c.Trash.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("trash.purgeInterval must be positive"))
+	}
+	switch c.Server.ContractValidation {
+	case "off", "log", "strict":
+	default:
+		errs = append(errs, fmt.Errorf("server.contractValidation must be off, log or strict, got %q", c.Server.ContractValidation))
+	}
+	if _, err := time.Parse(time.DateOnly, c.Server.V1DeprecatedAt); c.Server.V1DeprecatedAt != "" && err != nil {
+		errs = append(errs, fmt.Errorf("server.v1DeprecatedAt must be a YYYY-MM-DD date, got %q", c.Server.V1DeprecatedAt))
+	}
+	if _, err := time.Parse(time.DateOnly, c.Server.V1SunsetAt); c.Server.V1SunsetAt != "" && err != nil {
+		errs = append(errs, fmt.Errorf("server.v1SunsetAt must be a YYYY-MM-DD date, got %q", c.Server.V1SunsetAt))
+	}
+	if c.GraphQL.MaxDepth < 1 {
+		errs = append(errs, errors.New("graphql.maxDepth must be at least 1"))
+	}
+	if c.GraphQL.MaxComplexity < 1 {
+		errs = append(errs, errors.New("graphql.maxComplexity must be at least 1"))
+	}
+	if c.GraphQL.MaxQueryLength < 1 {
+		errs = append(errs, errors.New("graphql.maxQueryLength must be at least 1"))
+	}
+	if c.GraphQL.PersistedOnly && c.GraphQL.PersistedQueries == "" {
+		errs = append(errs, errors.New("graphql.persistedOnly needs graphql.persistedQueries"))
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhooks.maxAttempts must be at least 1"))
+	}
+	if c.Webhooks.RetryBackoff <= 0 {
+		errs = append(errs, errors.New("webhooks.retryBackoff must be positive"))
+	}
+	if c.Webhooks.MaxBackoff < c.Webhooks.RetryBackoff {
+		errs = append(errs, errors.New("webhooks.maxBackoff must be at least webhooks.retryBackoff"))
+	}
+	if c.Webhooks.DisableAfter < 1 {
+		errs = append(errs, errors.New("webhooks.disableAfter must be at least 1"))
+	}
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, errors.New("webhooks.timeout must be positive"))
+	}
+	if c.Webhooks.PollInterval <= 0 {
+		errs = append(errs, errors.New("webhooks.pollInterval must be positive"))
+	}
+	if c.Webhooks.LogRetention <= 0 {
+		errs = append(errs, errors.New("webhooks.logRetention must be positive"))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, otlp or stdout, got %q", c.Tracing.Exporter))
+	}
+
+	return errors.Join(errs...)
+}
+
+// one "path=value" per line, secrets redacted, safe to log
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, f := range fieldsOf(c) {
+		v := f.format()
+		if f.secret && v != "" {
+			v = "[REDACTED]"
+		}
+		fmt.Fprintf(&b, "%s=%s\n", f.path, v)
+	}
+	return b.String()
+}
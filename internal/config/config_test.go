@@ -0,0 +1,206 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the required fields that have no default
+var required = []string{"-mongo-uri=mongodb://db.internal:27017", "-jwt-secret=s3cr3t-jwt"}
+
+// leaves Load nothing from the environment but what the test sets
+func clearEnv(t *testing.T) {
+	t.Helper()
+	envs := []string{"CONFIG_FILE"}
+	for _, f := range fieldsOf(&Config{}) {
+		if f.env != "" {
+			envs = append(envs, f.env)
+		}
+	}
+	for _, env := range envs {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", "server:\n  port: \"8001\"\nsite:\n  title: From file\n  feedLimit: 30\n")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		port string
+	}{
+		{"default", nil, nil, "8080"},
+		{"file over default", nil, []string{"-config=" + file}, "8001"},
+		{"env over file", map[string]string{"PORT": "8002"}, []string{"-config=" + file}, "8002"},
+		{"flag over env", map[string]string{"PORT": "8002"}, []string{"-config=" + file, "-port=8003"}, "8003"},
+		{"file from env", map[string]string{"CONFIG_FILE": file}, nil, "8001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load(append(tt.args, required...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.port {
+				t.Errorf("server.port = %q, want %q", cfg.Server.Port, tt.port)
+			}
+			// fields no layer above the default touches keep it
+			if cfg.Server.ReadTimeout != 15*time.Second {
+				t.Errorf("server.readTimeout = %v, want the default", cfg.Server.ReadTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadTypes(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://a.example , ,https://b.example")
+	t.Setenv("WEB_CACHE_MAX_AGE", "5m")
+	file := writeFile(t, "config.toml", "[site]\ntitle = \"From file\"\nfeedLimit = 30\n")
+
+	cfg, err := Load(append([]string{"-config=" + file, "-web-enabled"}, required...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.CORS.AllowedOrigins, " "); got != "https://a.example https://b.example" {
+		t.Errorf("cors.allowedOrigins = %q", got)
+	}
+	if cfg.Web.CacheMaxAge != 5*time.Minute {
+		t.Errorf("web.cacheMaxAge = %v, want 5m", cfg.Web.CacheMaxAge)
+	}
+	if !cfg.Web.Enabled {
+		t.Error("web.enabled = false, want a bare bool flag to set it")
+	}
+	if cfg.Site.Title != "From file" || cfg.Site.FeedLimit != 30 {
+		t.Errorf("site = %q %d, want the toml values", cfg.Site.Title, cfg.Site.FeedLimit)
+	}
+}
+
+func TestLoadRequired(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"both missing", nil, []string{"database.uri (env MONGO_DB) is required", "auth.jwtSecret (env JWT_SECRET) is required"}},
+		{"one missing", []string{"-mongo-uri=mongodb://db.internal:27017"}, []string{"auth.jwtSecret (env JWT_SECRET) is required"}},
+		{"emptied default", append([]string{"-db-name="}, required...), []string{"database.name (env DB_NAME) is required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			_, err := Load(tt.args)
+			if err == nil {
+				t.Fatal("Load() = nil, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() = %v, want it to say %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"env int", "", "", map[string]string{"DB_CONNECT_ATTEMPTS": "five"}, nil, "env DB_CONNECT_ATTEMPTS"},
+		{"env duration", "", "", map[string]string{"JWT_TTL": "1 day"}, nil, "env JWT_TTL"},
+		{"env bool", "", "", map[string]string{"WEB_ENABLED": "yes please"}, nil, "env WEB_ENABLED"},
+		{"flag int", "", "", nil, []string{"-bcrypt-cost=high"}, "flag -bcrypt-cost"},
+		{"yaml type", "config.yaml", "site:\n  feedLimit: lots\n", nil, nil, "config file"},
+		{"yaml duration", "config.yaml", "server:\n  readTimeout: soon\n", nil, nil, "config file"},
+		{"yaml unknown key", "config.yaml", "server:\n  prot: \"8001\"\n", nil, nil, "prot"},
+		{"toml type", "config.toml", "[site]\nfeedLimit = \"lots\"\n", nil, nil, "config file"},
+		{"toml unknown key", "config.toml", "[server]\nprot = \"8001\"\n", nil, nil, "unknown keys"},
+		{"unsupported extension", "config.json", "{}", nil, nil, "unsupported extension"},
+		{"missing file", "", "", map[string]string{"CONFIG_FILE": "/nonexistent/config.yaml"}, nil, "config file"},
+		{"out of range", "", "", nil, []string{"-bcrypt-cost=99"}, "auth.bcryptCost must be between"},
+		{"bad choice", "", "", map[string]string{"MEDIA_STORAGE": "ftp"}, nil, `media.storage must be local or s3, got "ftp"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config="+writeFile(t, tt.file, tt.data))
+			}
+			_, err := Load(append(args, required...))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringRedactsSecrets(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("METRICS_TOKEN", "metrics-t0ken")
+	cfg, err := Load(append([]string{"-media-s3-secret-key=s3-s3cr3t"}, required...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := cfg.String()
+	for _, secret := range []string{"db.internal", "s3cr3t-jwt", "metrics-t0ken", "s3-s3cr3t"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("String() leaks %q:\n%s", secret, s)
+		}
+	}
+	for _, line := range []string{
+		"database.uri=[REDACTED]\n",
+		"auth.jwtSecret=[REDACTED]\n",
+		"admin.metricsToken=[REDACTED]\n",
+		"media.s3SecretKey=[REDACTED]\n",
+		// unset secrets stay empty rather than hint at a value
+		"media.s3AccessKey=\n",
+		"server.port=8080\n",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("String() is missing %q:\n%s", line, s)
+		}
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	clearEnv(t)
+	cfg, args, err := LoadCommand(append(required, "-port=9000", "export", "-out", "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != "9000" {
+		t.Errorf("server.port = %q, want 9000", cfg.Server.Port)
+	}
+	if got := strings.Join(args, " "); got != "export -out dir" {
+		t.Errorf("args = %q, want the subcommand and its flags", got)
+	}
+}
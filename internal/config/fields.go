@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a settable leaf of Config and its tags
+type field struct {
+	path     string
+	value    reflect.Value
+	env      string
+	flag     string
+	def      string
+	required bool
+	secret   bool
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// flattens cfg into its leaf fields, paths are dotted yaml keys
+func fieldsOf(cfg *Config) []field {
+	var fields []field
+	collect(reflect.ValueOf(cfg).Elem(), "", &fields)
+	return fields
+}
+
+func collect(v reflect.Value, prefix string, fields *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = sf.Name
+		}
+		path := prefix + name
+
+		if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+			collect(v.Field(i), path+".", fields)
+			continue
+		}
+
+		*fields = append(*fields, field{
+			path:     path,
+			value:    v.Field(i),
+			env:      sf.Tag.Get("env"),
+			flag:     sf.Tag.Get("flag"),
+			def:      sf.Tag.Get("default"),
+			required: sf.Tag.Get("required") == "true",
+			secret:   sf.Tag.Get("secret") == "true",
+		})
+	}
+}
+
+// parses raw into the field according to its type
+func (f field) set(raw string) error {
+	switch {
+	case f.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(raw)
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case f.value.Kind() == reflect.Slice && f.value.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config type %s", f.value.Type())
+	}
+	return nil
+}
+
+func (f field) format() string {
+	switch {
+	case f.value.Type() == durationType:
+		return time.Duration(f.value.Int()).String()
+	case f.value.Kind() == reflect.Slice:
+		return strings.Join(f.value.Interface().([]string), ",")
+	default:
+		return fmt.Sprint(f.value.Interface())
+	}
+}
+
+func (f field) isBool() bool {
+	return f.value.Kind() == reflect.Bool
+}
+
+func (f field) usage() string {
+	var parts []string
+	if f.env != "" {
+		parts = append(parts, "env "+f.env)
+	}
+	if f.def != "" {
+		parts = append(parts, "default "+f.def)
+	}
+	if f.required {
+		parts = append(parts, "required")
+	}
+	usage := f.path
+	if len(parts) > 0 {
+		usage += " (" + strings.Join(parts, ", ") + ")"
+	}
+	return usage
+}
+
+// flag.Value holding the raw string until Load applies it
+type stagedFlag struct {
+	raw    string
+	set    bool
+	isBool bool
+}
+
+func (s *stagedFlag) String() string {
+	if s == nil {
+		return ""
+	}
+	return s.raw
+}
+
+func (s *stagedFlag) Set(raw string) error {
+	s.raw, s.set = raw, true
+	return nil
+}
+
+func (s *stagedFlag) IsBoolFlag() bool {
+	return s.isBool
+}
@@ -0,0 +1,198 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrPostNotFound     = apperr.NotFound("post")
+	ErrPostNotPublished = apperr.Conflict("post_not_published", "comments are closed until the post is published")
+	ErrPostTrashed      = apperr.Conflict("post_trashed", "the post is in the trash, restore it first")
+	ErrCommentNotFound  = apperr.NotFound("comment")
+	ErrNotAuthor        = apperr.New(apperr.ErrForbidden, "not_author", "only the author or an admin can change this")
+)
+
+// ErrNotAuthor unless user is author or an admin, the rule
+// for editing and trashing posts and comments
+func CanModify(user *models.User, author primitive.ObjectID) error {
+	if user.Admin || user.ID == author {
+		return nil
+	}
+	return ErrNotAuthor
+}
+
+// runs fn as one unit of work, *database.MongoDB in production
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// writes that touch more than one of users, posts and comments go through
+// here, so a post's comments, its comment count and the users' post and
+// comment lists change together
+type Service struct {
+	tx          Transactor
+	userRepo    repository.UserRepository
+	postRepo    repository.PostRepository
+	commentRepo repository.CommentRepository
+}
+
+func New(
+	tx Transactor,
+	userRepo repository.UserRepository,
+	postRepo repository.PostRepository,
+	commentRepo repository.CommentRepository,
+) *Service {
+	return &Service{
+		tx:          tx,
+		userRepo:    userRepo,
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+	}
+}
+
+// creates the post and adds it to its author's posts
+func (s *Service) CreatePost(ctx context.Context, post *models.Post) error {
+	// a new post has no comments whatever the caller set
+	post.CommentCount = 0
+	return s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.postRepo.Create(ctx, post); err != nil {
+			return err
+		}
+		return s.userRepo.AddPosts(ctx, post.Author, post.ID)
+	})
+}
+
+// comments on a post, ErrPostNotFound rather than an empty list when the
+// post doesn't exist
+func (s *Service) PostComments(ctx context.Context, postID primitive.ObjectID) ([]models.CommentWithAuthor, error) {
+	if _, err := s.post(ctx, postID); err != nil {
+		return nil, err
+	}
+	return s.commentRepo.FindByPostWithAuthor(ctx, postID)
+}
+
+// comment only when it belongs to postID and the post isn't trashed
+func (s *Service) Comment(ctx context.Context, postID, commentID primitive.ObjectID) (*models.CommentWithAuthor, error) {
+	if _, err := s.post(ctx, postID); err != nil {
+		return nil, commentNotFound(err)
+	}
+	comment, err := s.commentRepo.FindByIDWithAuthor(ctx, commentID)
+	if err != nil {
+		return nil, commentNotFound(err)
+	}
+	if comment.Post != postID {
+		return nil, ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+// readers can only comment on published posts
+func (s *Service) CreateComment(ctx context.Context, comment *models.Comment) error {
+	post, err := s.post(ctx, comment.Post)
+	if err != nil {
+		return err
+	}
+	if !post.Published {
+		return ErrPostNotPublished
+	}
+	return s.insertComment(ctx, comment)
+}
+
+// for comments brought over from another platform, which may sit on drafts
+func (s *Service) ImportComment(ctx context.Context, comment *models.Comment) error {
+	if _, err := s.post(ctx, comment.Post); err != nil {
+		return err
+	}
+	return s.insertComment(ctx, comment)
+}
+
+func (s *Service) insertComment(ctx context.Context, comment *models.Comment) error {
+	return s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.commentRepo.Create(ctx, comment); err != nil {
+			return err
+		}
+		if err := s.postRepo.IncCommentCount(ctx, comment.Post, 1); err != nil {
+			return err
+		}
+		return s.userRepo.AddComments(ctx, comment.Author, comment.ID)
+	})
+}
+
+// ErrNotAuthor unless user may edit or trash the comment, see CanModify
+func (s *Service) CanModifyComment(ctx context.Context, user *models.User, postID, commentID primitive.ObjectID) error {
+	comment, err := s.comment(ctx, postID, commentID)
+	if err != nil {
+		return err
+	}
+	return CanModify(user, comment.Author)
+}
+
+// version is the one the editor started from, nil skips the check
+// returns the comment's new version
+func (s *Service) UpdateComment(ctx context.Context, postID, commentID primitive.ObjectID, version *int64, text, html string) (int64, error) {
+	if _, err := s.comment(ctx, postID, commentID); err != nil {
+		return 0, err
+	}
+	v, err := s.commentRepo.Update(ctx, commentID, version, text, html)
+	return v, notFound(err, ErrCommentNotFound)
+}
+
+// the placeholder account content is attributed to once its author is gone,
+// created on first use without a password so nobody can log in as it
+func (s *Service) DeletedUser(ctx context.Context, username string) (*models.User, error) {
+	user, err := s.userRepo.FindByUsername(ctx, username)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		// a real account registered under the name must not inherit content
+		if user.Password != "" || user.GoogleID != "" {
+			return nil, fmt.Errorf("deleted user name %q belongs to a real account", username)
+		}
+		return user, nil
+	}
+
+	user = &models.User{
+		Username: username,
+		Fname:    "Deleted",
+		Lname:    "User",
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *Service) post(ctx context.Context, id primitive.ObjectID) (*models.Post, error) {
+	post, err := s.postRepo.FindByID(ctx, id)
+	return post, notFound(err, ErrPostNotFound)
+}
+
+func (s *Service) comment(ctx context.Context, postID, commentID primitive.ObjectID) (*models.Comment, error) {
+	if _, err := s.post(ctx, postID); err != nil {
+		return nil, commentNotFound(err)
+	}
+	comment, err := s.commentRepo.FindByID(ctx, commentID)
+	if err != nil {
+		return nil, commentNotFound(err)
+	}
+	if comment.Post != postID {
+		return nil, ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+// a missing post or comment as ErrCommentNotFound, other errors as they are
+func commentNotFound(err error) error {
+	if errors.Is(err, apperr.ErrNotFound) {
+		return ErrCommentNotFound
+	}
+	return err
+}
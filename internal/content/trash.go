@@ -0,0 +1,217 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/health"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// what one purge run removed for good
+type PurgeResult struct {
+	Posts    int `json:"posts"`
+	Comments int `json:"comments"`
+}
+
+// trashed posts keep their comments, which come back when the post is
+// restored and go with it when it's purged
+func (s *Service) TrashPost(ctx context.Context, id, by primitive.ObjectID, version *int64) error {
+	if err := s.postRepo.Trash(ctx, id, by, version); err != nil {
+		return notFound(err, ErrPostNotFound)
+	}
+	return nil
+}
+
+func (s *Service) RestorePost(ctx context.Context, id primitive.ObjectID) error {
+	if err := s.postRepo.Restore(ctx, id); err != nil {
+		return notFound(err, ErrPostNotFound)
+	}
+	return nil
+}
+
+// a post in the trash, ErrPostNotFound when it isn't there
+func (s *Service) TrashedPost(ctx context.Context, id primitive.ObjectID) (*models.Post, error) {
+	post, err := s.postRepo.FindTrashedByID(ctx, id)
+	return post, notFound(err, ErrPostNotFound)
+}
+
+// deletes the post and all its comments, trashed or not, and drops them
+// from their authors' lists, returns how many comments went with it
+func (s *Service) PurgePost(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	var deleted int64
+	err := s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		live, err := s.commentRepo.FindByPost(ctx, id)
+		if err != nil {
+			return err
+		}
+		trashed, err := s.commentRepo.FindTrash(ctx, repository.TrashFilter{Post: &id})
+		if err != nil {
+			return err
+		}
+		ids := make([]primitive.ObjectID, 0, len(live)+len(trashed))
+		for _, c := range append(live, trashed...) {
+			ids = append(ids, c.ID)
+		}
+
+		if deleted, err = s.commentRepo.DeleteByPost(ctx, id); err != nil {
+			return fmt.Errorf("deleting comments: %w", err)
+		}
+		if err := s.userRepo.RemoveComments(ctx, ids...); err != nil {
+			return err
+		}
+		if err := s.postRepo.Delete(ctx, id); err != nil {
+			return notFound(err, ErrPostNotFound)
+		}
+		return s.userRepo.RemovePosts(ctx, id)
+	})
+	return deleted, err
+}
+
+// trashed comments stop counting towards the post's comment count
+func (s *Service) TrashComment(ctx context.Context, postID, commentID, by primitive.ObjectID, version *int64) error {
+	return s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.comment(ctx, postID, commentID); err != nil {
+			return err
+		}
+		if err := s.commentRepo.Trash(ctx, commentID, by, version); err != nil {
+			return notFound(err, ErrCommentNotFound)
+		}
+		return s.postRepo.IncCommentCount(ctx, postID, -1)
+	})
+}
+
+// a comment on a trashed post comes back with the post, not on its own
+func (s *Service) RestoreComment(ctx context.Context, id primitive.ObjectID) error {
+	return s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		comment, err := s.TrashedComment(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := s.post(ctx, comment.Post); errors.Is(err, ErrPostNotFound) {
+			if _, err := s.postRepo.FindTrashedByID(ctx, comment.Post); err == nil {
+				return ErrPostTrashed
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		if err := s.commentRepo.Restore(ctx, id); err != nil {
+			return notFound(err, ErrCommentNotFound)
+		}
+		return s.postRepo.IncCommentCount(ctx, comment.Post, 1)
+	})
+}
+
+// a comment in the trash, ErrCommentNotFound when it isn't there
+func (s *Service) TrashedComment(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	comment, err := s.commentRepo.FindTrashedByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrCommentNotFound)
+	}
+	return comment, nil
+}
+
+// deletes a trashed comment and drops it from its author's comments
+func (s *Service) PurgeComment(ctx context.Context, id primitive.ObjectID) error {
+	return s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.TrashedComment(ctx, id); err != nil {
+			return err
+		}
+		if err := s.commentRepo.Delete(ctx, id); err != nil {
+			return notFound(err, ErrCommentNotFound)
+		}
+		return s.userRepo.RemoveComments(ctx, id)
+	})
+}
+
+// what's in the trash, only what author wrote unless author is nil
+func (s *Service) Trash(ctx context.Context, author *primitive.ObjectID) ([]models.Post, []models.Comment, error) {
+	filter := repository.TrashFilter{Author: author}
+	posts, err := s.postRepo.FindTrash(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	comments, err := s.commentRepo.FindTrash(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return posts, comments, nil
+}
+
+// purges everything trashed before the cutoff
+func (s *Service) PurgeTrash(ctx context.Context, before time.Time) (*PurgeResult, error) {
+	result := &PurgeResult{}
+	filter := repository.TrashFilter{DeletedBefore: before}
+
+	// posts first, their trashed comments go with them
+	posts, err := s.postRepo.FindTrash(ctx, filter)
+	if err != nil {
+		return result, err
+	}
+	var errs []error
+	for _, post := range posts {
+		_, err := s.PurgePost(ctx, post.ID)
+		switch {
+		case errors.Is(err, ErrPostNotFound):
+			// purged by someone else since the lookup
+		case err != nil:
+			errs = append(errs, fmt.Errorf("post %s: %w", post.ID.Hex(), err))
+		default:
+			result.Posts++
+		}
+	}
+
+	comments, err := s.commentRepo.FindTrash(ctx, filter)
+	if err != nil {
+		return result, errors.Join(append(errs, err)...)
+	}
+	for _, comment := range comments {
+		err := s.PurgeComment(ctx, comment.ID)
+		switch {
+		case errors.Is(err, ErrCommentNotFound):
+		case err != nil:
+			errs = append(errs, fmt.Errorf("comment %s: %w", comment.ID.Hex(), err))
+		default:
+			result.Comments++
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// purges items older than retention every interval until ctx is done,
+// beating hb on every pass
+func (s *Service) Run(ctx context.Context, interval, retention time.Duration, hb *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		hb.Beat()
+		result, err := s.PurgeTrash(ctx, time.Now().Add(-retention))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("content: purging trash: %v", err)
+		}
+		if result != nil && result.Posts+result.Comments > 0 {
+			log.Printf("content: purged %d posts and %d comments from the trash", result.Posts, result.Comments)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maps the repositories' not found errors onto the service's own
+func notFound(err, target error) error {
+	if errors.Is(err, apperr.ErrNotFound) {
+		return target
+	}
+	return err
+}
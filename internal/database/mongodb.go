@@ -4,40 +4,119 @@ import (
 	"context"
 	"log"
 	"time"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type MongoDB struct {
 	Client *mongo.Client
 	Database *mongo.Database
+	// replica sets and sharded clusters only, standalone servers have no transactions
+	transactions bool
 }
 
-func Connect(uri string) (*MongoDB, error) {
+func Connect(cfg config.DatabaseConfig) (*MongoDB, error) {
 	// context, as req in Node
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
 	// connect to MongoDB
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
 	if err != nil {
 		return nil, err
 	}
-	
+
+	// mongo.Connect is lazy, ping so a dead server fails here
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
 	log.Println("Connected to MongoDB")
 
 	// get database
-	database := client.Database("blog")
+	database := client.Database(cfg.Name)
+
+	transactions, err := supportsTransactions(ctx, client)
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	if !transactions {
+		log.Println("MongoDB is a standalone server, multi-document writes run without transactions")
+	}
 
 	return &MongoDB{
 		Client: client,
 		Database: database,
+		transactions: transactions,
 	}, nil
 }
 
+// retries Connect up to cfg.ConnectAttempts times with exponential backoff (1s, 2s, 4s... capped at 30s)
+func ConnectWithRetry(cfg config.DatabaseConfig) (*MongoDB, error) {
+	delay := time.Second
+	var err error
+	for i := 1; i <= cfg.ConnectAttempts; i++ {
+		var db *MongoDB
+		db, err = Connect(cfg)
+		if err == nil {
+			return db, nil
+		}
+		if i == cfg.ConnectAttempts {
+			break
+		}
+
+		log.Printf("MongoDB connection attempt %d/%d failed: %v (retrying in %s)", i, cfg.ConnectAttempts, err, delay)
+		time.Sleep(delay)
+		delay = min(delay*2, 30*time.Second)
+	}
+	return nil, err
+}
+
+func supportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// runs fn in a multi-document transaction, retried on transient errors
+// repositories pick the transaction up from the ctx handed to fn
+// on a standalone server fn just runs, each write committing on its own
+func (db *MongoDB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !db.transactions {
+		return fn(ctx)
+	}
+
+	session, err := db.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// health check against the primary
+func (db *MongoDB) Ping(ctx context.Context) error {
+	return db.Client.Ping(ctx, readpref.Primary())
+}
+
 func (db *MongoDB) Disconnect() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	
+
 	return db.Client.Disconnect(ctx)
 }
\ No newline at end of file
@@ -0,0 +1,323 @@
+package feed
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// format-neutral feed, encoded by RSS, Atom and JSON
+type Feed struct {
+	Title       string
+	Description string
+	// html page the feed mirrors
+	Link string
+	// url the feed itself is served from
+	FeedURL  string
+	Language string
+	Updated  time.Time
+	Items    []Item
+}
+
+type Item struct {
+	// permalink, doubles as the stable id
+	Link string
+	// Link's media type, text/html when empty
+	LinkType  string
+	Title     string
+	Author    string
+	AuthorURL string
+	// plain-text excerpt
+	Summary string
+	// sanitized html, omitted in excerpt mode
+	Content   string
+	Image     string
+	Tags      []string
+	Published time.Time
+	Updated   time.Time
+}
+
+const (
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	JSONContentType = "application/feed+json; charset=utf-8"
+)
+
+// RSS 2.0 (https://www.rssboard.org/rss-specification)
+// authors go in dc:creator since <author> must be an email address
+
+type rss struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Generator     string    `xml:"generator"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+	Content     *cdata   `xml:"content:encoded,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+func RSS(f Feed) ([]byte, error) {
+	doc := rss{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Language:    f.Language,
+			Generator:   "blog-api-go",
+			Self:        atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"},
+			Items:       []rssItem{},
+		},
+	}
+	// description is required on the channel
+	if doc.Channel.Description == "" {
+		doc.Channel.Description = f.Title
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, it := range f.Items {
+		item := rssItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			GUID:        rssGUID{IsPermaLink: true, Value: it.Link},
+			PubDate:     it.Published.UTC().Format(time.RFC1123Z),
+			Creator:     it.Author,
+			Categories:  it.Tags,
+			Description: it.Summary,
+		}
+		if it.Content != "" {
+			item.Content = &cdata{Value: xmlChars(it.Content)}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	return marshalXML(doc)
+}
+
+// Atom 1.0 (RFC 4287)
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string      `xml:"xml:lang,attr,omitempty"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func Atom(f Feed) ([]byte, error) {
+	doc := atomFeed{
+		Lang:     f.Language,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.FeedURL,
+		Updated:  atomTime(f.Updated),
+		Links: []atomLink{
+			{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: []atomEntry{},
+	}
+
+	for _, it := range f.Items {
+		entry := atomEntry{
+			Title:     it.Title,
+			ID:        it.Link,
+			Link:      atomLink{Href: it.Link, Rel: "alternate", Type: cmp.Or(it.LinkType, "text/html")},
+			Published: atomTime(it.Published),
+			Updated:   atomTime(latest(it.Updated, it.Published)),
+			// atom requires an author on every entry when the feed has none
+			Author: atomPerson{Name: it.Author, URI: it.AuthorURL},
+		}
+		if entry.Author.Name == "" {
+			entry.Author.Name = "unknown"
+		}
+		for _, tag := range it.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if it.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Value: it.Summary}
+		}
+		if it.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: it.Content}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return marshalXML(doc)
+}
+
+// JSON Feed 1.1 (https://www.jsonfeed.org/version/1.1/)
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url,omitempty"`
+	FeedURL     string     `json:"feed_url,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Language    string     `json:"language,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title,omitempty"`
+	ContentHTML   string       `json:"content_html,omitempty"`
+	ContentText   string       `json:"content_text,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	Image         string       `json:"image,omitempty"`
+	DatePublished string       `json:"date_published,omitempty"`
+	DateModified  string       `json:"date_modified,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+func JSON(f Feed) ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Language:    f.Language,
+		Items:       []jsonItem{},
+	}
+
+	for _, it := range f.Items {
+		item := jsonItem{
+			ID:            it.Link,
+			URL:           it.Link,
+			Title:         it.Title,
+			ContentHTML:   it.Content,
+			Summary:       it.Summary,
+			Image:         it.Image,
+			DatePublished: atomTime(it.Published),
+			Tags:          it.Tags,
+		}
+		// every item needs content_html or content_text
+		if item.ContentHTML == "" {
+			item.ContentText = it.Summary
+		}
+		if !it.Updated.IsZero() {
+			item.DateModified = atomTime(it.Updated)
+		}
+		if it.Author != "" {
+			item.Authors = []jsonAuthor{{Name: it.Author, URL: it.AuthorURL}}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func marshalXML(v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// encoding/xml writes cdata verbatim, so characters XML 1.0 can't carry
+// are swapped for U+FFFD here the way it already does for text
+func xmlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r',
+			r >= 0x20 && r <= 0xD7FF,
+			r >= 0xE000 && r <= 0xFFFD,
+			r >= 0x10000 && r <= 0x10FFFF:
+			return r
+		}
+		return '\uFFFD'
+	}, s)
+}
+
+// RFC 3339, as required by atom and json feed
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,491 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// markup in every text field, a cdata terminator and a character XML
+// can't carry in the content, and a feed url that needs attribute escaping
+const (
+	testContent = "<p>if a &lt; b &amp;&amp; c</p><pre>]]></pre>\x0b"
+	// what survives XML, the control character can't
+	testXMLContent = "<p>if a &lt; b &amp;&amp; c</p><pre>]]></pre>�"
+)
+
+var (
+	est = time.FixedZone("EST", -5*60*60)
+
+	published = time.Date(2024, 3, 1, 9, 0, 0, 0, est)
+	edited    = time.Date(2024, 3, 2, 10, 30, 15, 0, est)
+)
+
+func testFeed() Feed {
+	return Feed{
+		Title:       `Tom & Jerry's "<Blog>"`,
+		Description: "Cats <vs> mice & more",
+		Link:        "https://blog.example.com/",
+		FeedURL:     "https://blog.example.com/feed.xml?tag=go&content=full",
+		Language:    "en",
+		Updated:     edited,
+		Items: []Item{
+			{
+				Link:      "https://blog.example.com/posts/1",
+				Title:     "<script>alert(1)</script> & friends",
+				Author:    "Ada <Lovelace>",
+				AuthorURL: "https://blog.example.com/authors/2",
+				Summary:   `Less < more & "quotes"`,
+				Content:   testContent,
+				Image:     "https://blog.example.com/media/a%20b.png",
+				Tags:      []string{"go", "c&c"},
+				Published: published,
+				Updated:   edited,
+			},
+			// excerpt mode, no author, never edited and not an html page
+			{
+				Link:      "https://blog.example.com/api/posts/2",
+				LinkType:  "application/json",
+				Title:     "Excerpt only",
+				Summary:   "Just a summary",
+				Published: published,
+			},
+		},
+	}
+}
+
+func checkAbsolute(t *testing.T, what, ref string) {
+	t.Helper()
+	u, err := url.Parse(ref)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		t.Errorf("%s %q isn't an absolute URL", what, ref)
+	}
+}
+
+func checkTime(t *testing.T, what, layout, value string, want time.Time) {
+	t.Helper()
+	got, err := time.Parse(layout, value)
+	if err != nil {
+		t.Errorf("%s %q: %v", what, value, err)
+		return
+	}
+	if !got.Equal(want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+	if _, offset := got.Zone(); offset != 0 {
+		t.Errorf("%s %q isn't in UTC", what, value)
+	}
+}
+
+func checkEqual(t *testing.T, what string, got, want any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %q, want %q", what, got, want)
+	}
+}
+
+// decoded by namespace rather than prefix, as a reader would
+
+type rssDoc struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel []struct {
+		// ahead of link, which would otherwise take atom:link as well
+		Self []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"http://www.w3.org/2005/Atom link"`
+		Title         *string `xml:"title"`
+		Link          *string `xml:"link"`
+		Description   *string `xml:"description"`
+		Language      string  `xml:"language"`
+		LastBuildDate string  `xml:"lastBuildDate"`
+		Items         []struct {
+			Title       *string `xml:"title"`
+			Link        string  `xml:"link"`
+			Description *string `xml:"description"`
+			GUID        struct {
+				IsPermaLink string `xml:"isPermaLink,attr"`
+				Value       string `xml:",chardata"`
+			} `xml:"guid"`
+			PubDate    string   `xml:"pubDate"`
+			Creator    *string  `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			Categories []string `xml:"category"`
+			Content    *string  `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func TestRSS(t *testing.T) {
+	f := testFeed()
+	out, err := RSS(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), xml.Header) {
+		t.Errorf("missing XML declaration: %.60s", out)
+	}
+	var doc rssDoc
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("not well-formed: %v\n%s", err, out)
+	}
+
+	checkEqual(t, "version", doc.Version, "2.0")
+	if len(doc.Channel) != 1 {
+		t.Fatalf("got %d channels, want 1", len(doc.Channel))
+	}
+	ch := doc.Channel[0]
+	// title, link and description are required on the channel
+	if ch.Title == nil || ch.Link == nil || ch.Description == nil {
+		t.Fatalf("channel is missing a required element\n%s", out)
+	}
+	checkEqual(t, "title", *ch.Title, f.Title)
+	checkEqual(t, "description", *ch.Description, f.Description)
+	checkEqual(t, "link", *ch.Link, f.Link)
+	checkAbsolute(t, "link", *ch.Link)
+	checkEqual(t, "language", ch.Language, "en")
+	checkTime(t, "lastBuildDate", time.RFC1123Z, ch.LastBuildDate, edited)
+	if len(ch.Self) != 1 || ch.Self[0].Rel != "self" || ch.Self[0].Type != "application/rss+xml" {
+		t.Fatalf("atom:link = %+v, want one self link", ch.Self)
+	}
+	checkEqual(t, "atom:link href", ch.Self[0].Href, f.FeedURL)
+
+	if len(ch.Items) != len(f.Items) {
+		t.Fatalf("got %d items, want %d", len(ch.Items), len(f.Items))
+	}
+	for i, item := range ch.Items {
+		want := f.Items[i]
+		// an item needs a title or a description, these have both
+		if item.Title == nil || item.Description == nil {
+			t.Fatalf("item %d is missing its title or description", i)
+		}
+		checkEqual(t, "item title", *item.Title, want.Title)
+		checkEqual(t, "item description", *item.Description, want.Summary)
+		checkEqual(t, "item link", item.Link, want.Link)
+		checkAbsolute(t, "item link", item.Link)
+		checkEqual(t, "guid", item.GUID.Value, want.Link)
+		checkEqual(t, "guid isPermaLink", item.GUID.IsPermaLink, "true")
+		checkTime(t, "pubDate", time.RFC1123Z, item.PubDate, want.Published)
+		if len(want.Tags) > 0 {
+			checkEqual(t, "categories", item.Categories, want.Tags)
+		}
+	}
+
+	first, second := ch.Items[0], ch.Items[1]
+	if first.Creator == nil || first.Content == nil {
+		t.Fatalf("first item is missing dc:creator or content:encoded\n%s", out)
+	}
+	checkEqual(t, "dc:creator", *first.Creator, "Ada <Lovelace>")
+	checkEqual(t, "content:encoded", *first.Content, testXMLContent)
+	if second.Creator != nil || second.Content != nil {
+		t.Errorf("excerpt item has dc:creator %v or content %v", second.Creator, second.Content)
+	}
+	// <author> must be an email address, names go in dc:creator
+	if strings.Contains(string(out), "<author>") {
+		t.Errorf("names written to <author>\n%s", out)
+	}
+}
+
+func TestRSSWithoutDescription(t *testing.T) {
+	f := testFeed()
+	f.Description = ""
+	out, err := RSS(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc rssDoc
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	ch := doc.Channel[0]
+	if ch.Description == nil || *ch.Description != f.Title {
+		t.Errorf("description = %v, want the title in its place", ch.Description)
+	}
+}
+
+type atomLinkDoc struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type atomTextDoc struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomDoc struct {
+	XMLName  xml.Name      `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string        `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	ID       *string       `xml:"http://www.w3.org/2005/Atom id"`
+	Title    *string       `xml:"http://www.w3.org/2005/Atom title"`
+	Subtitle string        `xml:"http://www.w3.org/2005/Atom subtitle"`
+	Updated  *string       `xml:"http://www.w3.org/2005/Atom updated"`
+	Author   []struct{}    `xml:"http://www.w3.org/2005/Atom author"`
+	Links    []atomLinkDoc `xml:"http://www.w3.org/2005/Atom link"`
+	Entries  []struct {
+		ID        *string       `xml:"http://www.w3.org/2005/Atom id"`
+		Title     *string       `xml:"http://www.w3.org/2005/Atom title"`
+		Updated   *string       `xml:"http://www.w3.org/2005/Atom updated"`
+		Published string        `xml:"http://www.w3.org/2005/Atom published"`
+		Links     []atomLinkDoc `xml:"http://www.w3.org/2005/Atom link"`
+		Authors   []struct {
+			Name *string `xml:"http://www.w3.org/2005/Atom name"`
+			URI  string  `xml:"http://www.w3.org/2005/Atom uri"`
+		} `xml:"http://www.w3.org/2005/Atom author"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"http://www.w3.org/2005/Atom category"`
+		Summary *atomTextDoc `xml:"http://www.w3.org/2005/Atom summary"`
+		Content *atomTextDoc `xml:"http://www.w3.org/2005/Atom content"`
+	} `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+func linkByRel(links []atomLinkDoc, rel string) (atomLinkDoc, bool) {
+	for _, l := range links {
+		// rel defaults to alternate (RFC 4287 section 4.2.7.2)
+		if l.Rel == rel || l.Rel == "" && rel == "alternate" {
+			return l, true
+		}
+	}
+	return atomLinkDoc{}, false
+}
+
+func TestAtom(t *testing.T) {
+	f := testFeed()
+	out, err := Atom(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), xml.Header) {
+		t.Errorf("missing XML declaration: %.60s", out)
+	}
+	var doc atomDoc
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("not well-formed or not in the atom namespace: %v\n%s", err, out)
+	}
+
+	// id, title and updated are required on the feed (section 4.1.1)
+	if doc.ID == nil || doc.Title == nil || doc.Updated == nil {
+		t.Fatalf("feed is missing a required element\n%s", out)
+	}
+	checkEqual(t, "id", *doc.ID, f.FeedURL)
+	checkAbsolute(t, "id", *doc.ID)
+	checkEqual(t, "title", *doc.Title, f.Title)
+	checkEqual(t, "subtitle", doc.Subtitle, f.Description)
+	checkEqual(t, "xml:lang", doc.Lang, "en")
+	checkTime(t, "updated", time.RFC3339, *doc.Updated, edited)
+
+	self, ok := linkByRel(doc.Links, "self")
+	if !ok {
+		t.Fatalf("no self link in %+v", doc.Links)
+	}
+	checkEqual(t, "self link", self.Href, f.FeedURL)
+	checkEqual(t, "self link type", self.Type, "application/atom+xml")
+	alternate, ok := linkByRel(doc.Links, "alternate")
+	if !ok {
+		t.Fatalf("no alternate link in %+v", doc.Links)
+	}
+	checkEqual(t, "alternate link", alternate.Href, f.Link)
+	checkAbsolute(t, "alternate link", alternate.Href)
+
+	if len(doc.Entries) != len(f.Items) {
+		t.Fatalf("got %d entries, want %d", len(doc.Entries), len(f.Items))
+	}
+	for i, entry := range doc.Entries {
+		want := f.Items[i]
+		if entry.ID == nil || entry.Title == nil || entry.Updated == nil {
+			t.Fatalf("entry %d is missing a required element\n%s", i, out)
+		}
+		checkEqual(t, "entry id", *entry.ID, want.Link)
+		checkAbsolute(t, "entry id", *entry.ID)
+		checkEqual(t, "entry title", *entry.Title, want.Title)
+		checkTime(t, "entry published", time.RFC3339, entry.Published, want.Published)
+
+		link, ok := linkByRel(entry.Links, "alternate")
+		if !ok {
+			t.Fatalf("entry %d has no alternate link", i)
+		}
+		checkEqual(t, "entry link", link.Href, want.Link)
+
+		// the feed has no author so every entry needs one (section 4.1.1)
+		if len(doc.Author) == 0 && (len(entry.Authors) != 1 || entry.Authors[0].Name == nil || *entry.Authors[0].Name == "") {
+			t.Errorf("entry %d has no author name", i)
+		}
+		if entry.Summary == nil || entry.Summary.Type != "text" {
+			t.Fatalf("entry %d summary = %+v, want text", i, entry.Summary)
+		}
+		checkEqual(t, "entry summary", entry.Summary.Value, want.Summary)
+	}
+
+	first, second := doc.Entries[0], doc.Entries[1]
+	checkTime(t, "entry updated", time.RFC3339, *first.Updated, edited)
+	checkEqual(t, "author name", *first.Authors[0].Name, "Ada <Lovelace>")
+	checkEqual(t, "author uri", first.Authors[0].URI, "https://blog.example.com/authors/2")
+	checkAbsolute(t, "author uri", first.Authors[0].URI)
+	var terms []string
+	for _, c := range first.Categories {
+		terms = append(terms, c.Term)
+	}
+	checkEqual(t, "category terms", terms, []string{"go", "c&c"})
+	if first.Content == nil || first.Content.Type != "html" {
+		t.Fatalf("content = %+v, want escaped html", first.Content)
+	}
+	checkEqual(t, "content", first.Content.Value, testXMLContent)
+
+	firstLink, _ := linkByRel(first.Links, "alternate")
+	secondLink, _ := linkByRel(second.Links, "alternate")
+	checkEqual(t, "entry link type", firstLink.Type, "text/html")
+	checkEqual(t, "api entry link type", secondLink.Type, "application/json")
+
+	// never edited, so updated falls back to published
+	checkTime(t, "unedited entry updated", time.RFC3339, *second.Updated, published)
+	checkEqual(t, "anonymous author", *second.Authors[0].Name, "unknown")
+	if second.Content != nil {
+		t.Errorf("excerpt entry has content %+v", second.Content)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	f := testFeed()
+	out, err := JSON(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// members are checked raw first so a missing one isn't read as ""
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(out, &raw); err != nil {
+		t.Fatalf("not JSON: %v\n%s", err, out)
+	}
+	for _, key := range []string{"version", "title", "items"} {
+		if _, ok := raw[key]; !ok {
+			t.Errorf("feed is missing %q", key)
+		}
+	}
+	var rawItems []map[string]json.RawMessage
+	if err := json.Unmarshal(raw["items"], &rawItems); err != nil {
+		t.Fatalf("items isn't an array of objects: %v", err)
+	}
+	for i, item := range rawItems {
+		if _, ok := item["id"]; !ok {
+			t.Errorf("item %d is missing id", i)
+		}
+		_, html := item["content_html"]
+		_, text := item["content_text"]
+		if !html && !text {
+			t.Errorf("item %d has neither content_html nor content_text", i)
+		}
+		// author is the deprecated 1.0 member, 1.1 uses authors
+		if _, ok := item["author"]; ok {
+			t.Errorf("item %d uses the 1.0 author member", i)
+		}
+	}
+
+	var doc struct {
+		Version     string `json:"version"`
+		Title       string `json:"title"`
+		HomePageURL string `json:"home_page_url"`
+		FeedURL     string `json:"feed_url"`
+		Description string `json:"description"`
+		Language    string `json:"language"`
+		Items       []struct {
+			ID            string  `json:"id"`
+			URL           string  `json:"url"`
+			Title         string  `json:"title"`
+			ContentHTML   *string `json:"content_html"`
+			ContentText   *string `json:"content_text"`
+			Summary       string  `json:"summary"`
+			Image         string  `json:"image"`
+			DatePublished string  `json:"date_published"`
+			DateModified  *string `json:"date_modified"`
+			Authors       []struct {
+				Name string `json:"name"`
+				URL  string `json:"url"`
+			} `json:"authors"`
+			Tags []string `json:"tags"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	checkEqual(t, "version", doc.Version, "https://jsonfeed.org/version/1.1")
+	checkEqual(t, "title", doc.Title, f.Title)
+	checkEqual(t, "description", doc.Description, f.Description)
+	checkEqual(t, "language", doc.Language, "en")
+	checkEqual(t, "home_page_url", doc.HomePageURL, f.Link)
+	checkAbsolute(t, "home_page_url", doc.HomePageURL)
+	checkEqual(t, "feed_url", doc.FeedURL, f.FeedURL)
+	checkAbsolute(t, "feed_url", doc.FeedURL)
+
+	for i, item := range doc.Items {
+		want := f.Items[i]
+		checkEqual(t, "item id", item.ID, want.Link)
+		checkEqual(t, "item url", item.URL, want.Link)
+		checkAbsolute(t, "item url", item.URL)
+		checkEqual(t, "item title", item.Title, want.Title)
+		checkEqual(t, "item summary", item.Summary, want.Summary)
+		checkTime(t, "date_published", time.RFC3339, item.DatePublished, want.Published)
+	}
+
+	first, second := doc.Items[0], doc.Items[1]
+	// JSON can carry the control character XML couldn't
+	if first.ContentHTML == nil || first.ContentText != nil {
+		t.Fatalf("full item has content_html %v and content_text %v", first.ContentHTML, first.ContentText)
+	}
+	checkEqual(t, "content_html", *first.ContentHTML, testContent)
+	checkEqual(t, "image", first.Image, "https://blog.example.com/media/a%20b.png")
+	checkAbsolute(t, "image", first.Image)
+	if first.DateModified == nil {
+		t.Fatal("edited item has no date_modified")
+	}
+	checkTime(t, "date_modified", time.RFC3339, *first.DateModified, edited)
+	if len(first.Authors) != 1 {
+		t.Fatalf("got %d authors, want 1", len(first.Authors))
+	}
+	checkEqual(t, "author name", first.Authors[0].Name, "Ada <Lovelace>")
+	checkAbsolute(t, "author url", first.Authors[0].URL)
+	checkEqual(t, "tags", first.Tags, []string{"go", "c&c"})
+
+	if second.ContentHTML != nil || second.ContentText == nil {
+		t.Fatalf("excerpt item has content_html %v and content_text %v", second.ContentHTML, second.ContentText)
+	}
+	checkEqual(t, "content_text", *second.ContentText, "Just a summary")
+	if second.DateModified != nil || second.Authors != nil {
+		t.Errorf("unedited anonymous item has date_modified %v and authors %v", second.DateModified, second.Authors)
+	}
+}
+
+func TestEmptyFeed(t *testing.T) {
+	f := testFeed()
+	f.Items = nil
+
+	for name, encode := range map[string]func(Feed) ([]byte, error){"rss": RSS, "atom": Atom} {
+		out, err := encode(f)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := xml.Unmarshal(out, new(struct{})); err != nil {
+			t.Errorf("%s: not well-formed: %v", name, err)
+		}
+	}
+
+	out, err := JSON(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Items json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	// items is required, null won't do
+	checkEqual(t, "items", string(doc.Items), "[]")
+}
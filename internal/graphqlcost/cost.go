@@ -0,0 +1,108 @@
+package graphqlcost
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrSyntax = errors.New("graphqlcost: syntax error")
+	// no operation by the name asked for, or several and no name
+	ErrNoOperation = errors.New("graphqlcost: no operation to run")
+)
+
+// what a query costs before any of it runs: a field costs one per item it's
+// resolved for and a connection's nodes are its page size times as many as
+// the connection, the page size being its first argument or pageSize
+// stops counting once the cost is past limit, past it every cost is as bad
+// as any other
+func Cost(query, operationName string, variables map[string]interface{}, pageSize, limit int) (int, error) {
+	doc, err := parse(query)
+	if err != nil {
+		return 0, err
+	}
+	op := doc.operation(operationName)
+	if op == nil {
+		return 0, ErrNoOperation
+	}
+	w := &walker{doc: doc, op: op, variables: variables, pageSize: pageSize, limit: limit, seen: map[string]bool{}}
+	return w.cost(op.selections, 1, pageSize), nil
+}
+
+type walker struct {
+	doc       *document
+	op        *operation
+	variables map[string]interface{}
+	pageSize  int
+	limit     int
+	// fragments being expanded, a cycle is left for validation to refuse
+	seen map[string]bool
+}
+
+// the cost of selections resolved items times, pageSize is the page size
+// of the connection they're selected on
+func (w *walker) cost(selections []selection, items, pageSize int) int {
+	total := 0
+	for _, sel := range selections {
+		switch {
+		case sel.field != nil:
+			total += items
+			childItems := items
+			if sel.field.name == "nodes" {
+				childItems = w.clamp(items * pageSize)
+			}
+			total += w.cost(sel.field.selections, childItems, w.first(sel.field))
+		case sel.spread != "":
+			frag, ok := w.doc.fragments[sel.spread]
+			if !ok || w.seen[sel.spread] {
+				continue
+			}
+			w.seen[sel.spread] = true
+			total += w.cost(frag, items, pageSize)
+			delete(w.seen, sel.spread)
+		default:
+			total += w.cost(sel.inline, items, pageSize)
+		}
+		if total > w.limit {
+			return w.clamp(total)
+		}
+	}
+	return total
+}
+
+func (w *walker) clamp(n int) int {
+	return min(n, w.limit+1)
+}
+
+// the field's first argument, from a literal or a variable, or the default
+// page size
+func (w *walker) first(f *field) int {
+	v, ok := f.args["first"]
+	if name, isVar := v.(variable); isVar {
+		v, ok = w.variables[string(name)]
+		if !ok {
+			v, ok = w.op.defaults[string(name)]
+		}
+	}
+	if !ok {
+		return w.pageSize
+	}
+
+	var n int64
+	switch v := v.(type) {
+	case int64:
+		n = v
+	case float64:
+		n = int64(v)
+	case json.Number:
+		n, _ = v.Int64()
+	case int:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	}
+	if n < 1 {
+		return w.pageSize
+	}
+	return int(min(n, int64(w.limit)+1))
+}
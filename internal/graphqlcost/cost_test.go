@@ -0,0 +1,93 @@
+package graphqlcost
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const (
+	pageSize = 20
+	limit    = 1000
+)
+
+func TestCost(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]interface{}
+		want      int
+	}{
+		{"one field", `{ me { username } }`, "", nil, 2},
+		// posts 1, nodes 1, then 5 of title and author and username
+		{"connection", `{ posts(first: 5) { nodes { title author { username } } } }`, "", nil, 17},
+		{"default page size", `{ posts { nodes { title } } }`, "", nil, 2 + pageSize},
+		{"zero first is the default", `{ posts(first: 0) { nodes { title } } }`, "", nil, 2 + pageSize},
+		// 1 + 1 + 10 posts, each with 1 + 10 comments of one field
+		{"nested lists", `{ posts(first: 10) { nodes { comments(first: 10) { nodes { text } } } } }`, "", nil, 1 + 1 + 10 + 10 + 100},
+		{"nested past the limit", `{ posts(first: 100) { nodes { comments(first: 100) { nodes { text author { username } } } } } }`, "", nil, limit + 1},
+		{"first past the limit", `{ posts(first: 5000) { nodes { title } } }`, "", nil, limit + 1},
+		{"aliases each cost", `{ a: posts(first: 5) { nodes { title } } b: posts(first: 5) { nodes { title } } }`, "", nil, 14},
+		{"aliased nodes", `{ posts(first: 5) { items: nodes { title } } }`, "", nil, 7},
+		{"fragment", `query { posts(first: 5) { nodes { ...summary } } } fragment summary on Post { title tags }`, "", nil, 12},
+		{"fragment used twice", `{ a: post(id: "1") { ...f } b: post(id: "2") { ...f } } fragment f on Post { title }`, "", nil, 4},
+		{"inline fragment", `{ posts(first: 5) { nodes { ... on Post { title } ... @include(if: true) { tags } } } }`, "", nil, 12},
+		{"fragment cycle", `{ me { ...a } } fragment a on User { username ...b } fragment b on User { ...a }`, "", nil, 2},
+		{"unknown fragment", `{ me { ...missing } }`, "", nil, 1},
+		{"variable", `query($n: Int) { posts(first: $n) { nodes { title } } }`, "", map[string]interface{}{"n": json.Number("50")}, 52},
+		{"float variable", `query($n: Int) { posts(first: $n) { nodes { title } } }`, "", map[string]interface{}{"n": float64(50)}, 52},
+		{"variable default", `query($n: Int = 7) { posts(first: $n) { nodes { title } } }`, "", nil, 9},
+		{"variable over its default", `query($n: Int = 7) { posts(first: $n) { nodes { title } } }`, "", map[string]interface{}{"n": float64(3)}, 5},
+		{"variable past the limit", `query($n: Int) { posts(first: $n) { nodes { title tags } } }`, "", map[string]interface{}{"n": float64(1 << 40)}, limit + 1},
+		{"unset variable", `query($n: Int) { posts(first: $n) { nodes { title } } }`, "", nil, 2 + pageSize},
+		{"named operation", `query Small { me { username } } query Big { posts(first: 50) { nodes { title } } }`, "Big", nil, 52},
+		{"mutation", `mutation { deletePost(id: "1") }`, "", nil, 1},
+		{"comments and commas", "{\n  # the caller\n  me { username, fname }\n}", "", nil, 3},
+		{"string arguments", `{ posts(tag: "a \"quoted\" }", first: 2) { nodes { title } } }`, "", nil, 4},
+		{"block string", `{ post(id: """ { """) { title } }`, "", nil, 2},
+		{"list and object arguments", `{ posts(filter: {tags: ["a", "b"], min: -1.5e3}, first: 3) { nodes { title } } }`, "", nil, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Cost(tt.query, tt.operation, tt.variables, pageSize, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Cost() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCostErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		want      error
+	}{
+		{"empty", ``, "", ErrNoOperation},
+		{"only fragments", `fragment f on Post { title }`, "", ErrNoOperation},
+		{"several without a name", `query A { me { username } } query B { me { fname } }`, "", ErrNoOperation},
+		{"unknown name", `query A { me { username } }`, "B", ErrNoOperation},
+		{"unclosed", `{ me { username }`, "", ErrSyntax},
+		{"empty selection", `{ me { } }`, "", ErrSyntax},
+		{"bad operation kind", `fetch { me }`, "", ErrSyntax},
+		{"unterminated string", `{ post(id: "1) { title } }`, "", ErrSyntax},
+		{"newline in string", "{ post(id: \"1\n\") { title } }", "", ErrSyntax},
+		{"unterminated block string", `{ post(id: """1) { title } }`, "", ErrSyntax},
+		{"stray character", `{ me { username } } %`, "", ErrSyntax},
+		{"fragment without type", `{ me { ...f } } fragment f { username }`, "", ErrSyntax},
+		{"argument without value", `{ posts(first:) { nodes { title } } }`, "", ErrSyntax},
+		{"int overflow", `{ posts(first: 99999999999999999999) { nodes { title } } }`, "", ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Cost(tt.query, tt.operation, nil, pageSize, limit); !errors.Is(err, tt.want) {
+				t.Errorf("Cost() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
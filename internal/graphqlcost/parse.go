@@ -0,0 +1,459 @@
+package graphqlcost
+
+import (
+	"strconv"
+	"strings"
+)
+
+// just enough of the GraphQL query language to count what's selected, the
+// server's own parser and validation still have the last word
+
+type document struct {
+	operations []*operation
+	fragments  map[string][]selection
+}
+
+type operation struct {
+	name       string
+	selections []selection
+	// variables' default values
+	defaults map[string]interface{}
+}
+
+// a field, a fragment spread or, with neither, an inline fragment
+type selection struct {
+	field  *field
+	spread string
+	inline []selection
+}
+
+type field struct {
+	name       string
+	args       map[string]interface{}
+	selections []selection
+}
+
+// $name in an argument
+type variable string
+
+// the named operation, or the only one when name is empty
+func (d *document) operation(name string) *operation {
+	if name == "" {
+		if len(d.operations) == 1 {
+			return d.operations[0]
+		}
+		return nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op
+		}
+	}
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(query string) (*document, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	doc := &document{fragments: map[string][]selection{}}
+	for p.peek().kind != tokEOF {
+		if p.is(tokName, "fragment") {
+			p.next()
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if !p.is(tokName, "on") {
+				return nil, ErrSyntax
+			}
+			p.next()
+			if _, err := p.name(); err != nil {
+				return nil, err
+			}
+			if err := p.directives(); err != nil {
+				return nil, err
+			}
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = sel
+			continue
+		}
+
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		doc.operations = append(doc.operations, op)
+	}
+	return doc, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(kind tokenKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && t.text == text
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.is(tokPunct, punct) {
+		return ErrSyntax
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokName {
+		return "", ErrSyntax
+	}
+	return t.text, nil
+}
+
+// { ... } alone, or query, mutation or subscription with an optional name,
+// variables and directives
+func (p *parser) operation() (*operation, error) {
+	op := &operation{defaults: map[string]interface{}{}}
+	if !p.is(tokPunct, "{") {
+		kind, err := p.name()
+		if err != nil || (kind != "query" && kind != "mutation" && kind != "subscription") {
+			return nil, ErrSyntax
+		}
+		if p.peek().kind == tokName {
+			op.name = p.next().text
+		}
+		if p.is(tokPunct, "(") {
+			if err := p.variableDefinitions(op); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.directives(); err != nil {
+			return nil, err
+		}
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sel
+	return op, nil
+}
+
+// ($name: Type = default @directive, ...)
+func (p *parser) variableDefinitions(op *operation) error {
+	p.next()
+	for !p.is(tokPunct, ")") {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if p.is(tokPunct, "=") {
+			p.next()
+			v, err := p.value()
+			if err != nil {
+				return err
+			}
+			op.defaults[name] = v
+		}
+		if err := p.directives(); err != nil {
+			return err
+		}
+	}
+	p.next()
+	return nil
+}
+
+// Name, [Type] and either followed by !
+func (p *parser) typeRef() error {
+	if p.is(tokPunct, "[") {
+		p.next()
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.is(tokPunct, "!") {
+		p.next()
+	}
+	return nil
+}
+
+func (p *parser) directives() error {
+	for p.is(tokPunct, "@") {
+		p.next()
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if p.is(tokPunct, "(") {
+			if _, err := p.arguments(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []selection
+	for !p.is(tokPunct, "}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	p.next()
+	if len(selections) == 0 {
+		return nil, ErrSyntax
+	}
+	return selections, nil
+}
+
+func (p *parser) selection() (selection, error) {
+	if p.is(tokPunct, "...") {
+		p.next()
+		// ...Name, or ... on Type, or just ... with directives
+		if p.peek().kind == tokName && !p.is(tokName, "on") {
+			name := p.next().text
+			return selection{spread: name}, p.directives()
+		}
+		if p.is(tokName, "on") {
+			p.next()
+			if _, err := p.name(); err != nil {
+				return selection{}, err
+			}
+		}
+		if err := p.directives(); err != nil {
+			return selection{}, err
+		}
+		sel, err := p.selectionSet()
+		return selection{inline: sel}, err
+	}
+
+	// alias: name, or just name
+	name, err := p.name()
+	if err != nil {
+		return selection{}, err
+	}
+	if p.is(tokPunct, ":") {
+		p.next()
+		if name, err = p.name(); err != nil {
+			return selection{}, err
+		}
+	}
+	f := &field{name: name}
+	if p.is(tokPunct, "(") {
+		if f.args, err = p.arguments(); err != nil {
+			return selection{}, err
+		}
+	}
+	if err := p.directives(); err != nil {
+		return selection{}, err
+	}
+	if p.is(tokPunct, "{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return selection{}, err
+		}
+	}
+	return selection{field: f}, nil
+}
+
+func (p *parser) arguments() (map[string]interface{}, error) {
+	p.next()
+	args := map[string]interface{}{}
+	for !p.is(tokPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	p.next()
+	return args, nil
+}
+
+// ints are int64, other numbers float64, lists and objects are read but
+// only variables and numbers matter here
+func (p *parser) value() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokInt:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, ErrSyntax
+		}
+		return n, nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, ErrSyntax
+		}
+		return f, nil
+	case tokString, tokName:
+		return t.text, nil
+	case tokPunct:
+		switch t.text {
+		case "$":
+			name, err := p.name()
+			return variable(name), err
+		case "[":
+			var list []interface{}
+			for !p.is(tokPunct, "]") {
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			p.next()
+			return list, nil
+		case "{":
+			obj := map[string]interface{}{}
+			for !p.is(tokPunct, "}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if obj[name], err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			p.next()
+			return obj, nil
+		}
+	}
+	return nil, ErrSyntax
+}
+
+// the query's tokens ending with tokEOF, commas and comments dropped
+// strings keep their quotes, their value is never needed
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\ufeff"):
+			i += len("\ufeff")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			tokens = append(tokens, token{tokPunct, "..."})
+			i += 3
+		case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+			tokens = append(tokens, token{tokPunct, string(c)})
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] >= 'a' && src[i] <= 'z' || src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, token{tokName, src[start:i]})
+		case c == '-' || c >= '0' && c <= '9':
+			start := i
+			kind := tokInt
+			i++
+			for i < len(src) {
+				d := src[i]
+				if d == '.' || d == 'e' || d == 'E' || (d == '+' || d == '-') && (src[i-1] == 'e' || src[i-1] == 'E') {
+					kind = tokFloat
+				} else if d < '0' || d > '9' {
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, token{kind, src[start:i]})
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(strings.ReplaceAll(src[i+3:], `\"""`, `xxxx`), `"""`)
+			if end < 0 {
+				return nil, ErrSyntax
+			}
+			tokens = append(tokens, token{tokString, src[i : i+3+end+3]})
+			i += 3 + end + 3
+		case c == '"':
+			start := i
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				} else if src[i] == '\n' || src[i] == '\r' {
+					return nil, ErrSyntax
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, ErrSyntax
+			}
+			i++
+			tokens = append(tokens, token{tokString, src[start:i]})
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/backup"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+)
+
+type BackupHandler struct {
+	backup *backup.Service
+}
+
+func NewBackupHandler(b *backup.Service) *BackupHandler {
+	return &BackupHandler{backup: b}
+}
+
+// GET /api/admin/backup?secrets=true
+// streams the archive as it is written, restore goes through blogctl restore
+func (h *BackupHandler) Download(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	secrets := false
+	if v := r.URL.Query().Get("secrets"); v != "" {
+		var err error
+		if secrets, err = strconv.ParseBool(v); err != nil {
+			respondError(w, r, problem.New(http.StatusBadRequest, "", "secrets must be true or false"))
+			return
+		}
+	}
+
+	name := fmt.Sprintf("backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", backup.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Cache-Control", "no-store")
+
+	// large sites take longer than the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("backup download: clearing write deadline: %v", err)
+	}
+
+	// headers are gone by the time anything can fail, a cut off archive
+	// fails its checksums on restore
+	if _, err := h.backup.Write(r.Context(), w, backup.WriteOptions{IncludeSecrets: secrets}); err != nil {
+		log.Printf("backup download failed: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -1,24 +1,39 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"log"
 	"net/http"
-	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/content"
 	"github.com/kurtgray/blog-api-go/internal/middleware"
 	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/render"
 	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/validate"
+	"github.com/kurtgray/blog-api-go/internal/views"
+	"github.com/kurtgray/blog-api-go/internal/webhooks"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type CommentHandler struct {
-	commentRepo repository.CommentRepository
+	content  *content.Service
+	events   *webhooks.Service
+	renderer *render.Renderer
+	// writes without If-Match get a 428 instead of overwriting blindly
+	requireIfMatch bool
 }
 
-func NewCommentHandler(commentRepo repository.CommentRepository) *CommentHandler {
+func NewCommentHandler(content *content.Service, events *webhooks.Service, renderer *render.Renderer, requireIfMatch bool) *CommentHandler {
 	return &CommentHandler{
-		commentRepo: commentRepo,
+		content:        content,
+		events:         events,
+		renderer:       renderer,
+		requireIfMatch: requireIfMatch,
 	}
 }
 
@@ -26,19 +41,13 @@ func NewCommentHandler(commentRepo repository.CommentRepository) *CommentHandler
 func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request) {
 	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid post ID",
-		})
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid post ID"))
 		return
 	}
 
-	comments, err := h.commentRepo.FindByPostWithAuthor(r.Context(), postID)
+	comments, err := h.content.PostComments(r.Context(), postID)
 	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error fetching comments",
-		})
+		respondError(w, r, err)
 		return
 	}
 
@@ -46,6 +55,10 @@ func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request)
 		comments = []models.CommentWithAuthor{}
 	}
 
+	if isV2(r) {
+		respondData(w, http.StatusOK, views.NewComments(comments))
+		return
+	}
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success":  true,
 		"comments": comments,
@@ -54,160 +67,264 @@ func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request)
 
 // GET /api/posts/:postId/comments/:commentId
 func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
-	commentID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "commentId"))
-	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid comment ID",
-		})
+	postID, commentID, ok := commentIDs(w, r)
+	if !ok {
 		return
 	}
 
-	comment, err := h.commentRepo.FindByIDWithAuthor(r.Context(), commentID)
+	comment, err := h.content.Comment(r.Context(), postID, commentID)
 	if err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"message": "Comment not found",
-		})
+		respondError(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	respondRepresentation(w, r, comment.Version, map[string]interface{}{
 		"success": true,
 		"comment": comment,
-	})
+	}, views.NewComment(comment))
 }
 
 // POST /api/posts/:postId/comments
 func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	user, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
-		respondJSON(w, http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"message": "Unauthorized",
-		})
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
 		return
 	}
 
 	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid post ID",
-		})
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid post ID"))
 		return
 	}
 
 	// comment from client
-	var req struct {
-		Text string `json:"text"`
+	var req CommentDocument
+	if !decodeJSON(w, r, &req) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid request body",
-		})
+	comment, err := h.createComment(r.Context(), user, postID, req)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	if strings.TrimSpace(req.Text) == "" {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Comment must be entered.",
-		})
+	w.Header().Set("ETag", versionETag(comment.Version))
+	if isV2(r) {
+		respondData(w, http.StatusCreated, views.NewCommentBy(comment, user))
 		return
 	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"comment": comment,
+	})
+}
+
+// renders and stores user's comment on a published post, for CreateComment
+// and the createComment mutation
+func (h *CommentHandler) createComment(ctx context.Context, user *models.User, postID primitive.ObjectID, req CommentDocument) (*models.Comment, error) {
+	html, err := h.renderer.Comment(req.Text)
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error rendering comment")
+	}
 
 	comment := &models.Comment{
 		Author: user.ID,
 		Text:   req.Text,
+		HTML:   html,
 		Post:   postID,
 	}
 
-	if err := h.commentRepo.Create(r.Context(), comment); err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error creating comment",
-		})
-		return
+	err = h.content.CreateComment(ctx, comment)
+	switch {
+	case errors.Is(err, apperr.ErrNotFound), errors.Is(err, content.ErrPostNotPublished):
+		return nil, err
+	case err != nil:
+		log.Printf("creating comment on %s: %v", postID.Hex(), err)
+		return nil, problem.New(http.StatusInternalServerError, "", "Error creating comment")
 	}
+	publish(ctx, h.events, models.EventCommentCreated, views.NewCommentBy(comment, user))
+	return comment, nil
+}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"comment": comment,
-	})
+// the editable part of a comment
+type CommentDocument struct {
+	Text string `json:"text" validate:"required,max=5000" label:"Comment"`
 }
 
 // PATCH /api/posts/:postId/comments/:commentId
+// takes a merge patch or a JSON patch over the comment's editable fields
 func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
-	commentID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "commentId"))
+	user, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid comment ID",
-		})
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	postID, commentID, ok := commentIDs(w, r)
+	if !ok {
 		return
 	}
 
-	var req struct {
-		Text string `json:"text"`
+	version, ok := ifMatchVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	if err := h.content.CanModifyComment(r.Context(), user, postID, commentID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	comment, err := h.content.Comment(r.Context(), postID, commentID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if version != nil && *version != comment.Version {
+		respondError(w, r, repository.ErrVersionMismatch)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid request body",
-		})
+	current := CommentDocument{Text: comment.Text}
+	var next CommentDocument
+	if !applyPatch(w, r, "comment", current, commentPatchable, &next) {
 		return
 	}
 
-	if err := h.commentRepo.Update(r.Context(), commentID, req.Text); err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		})
+	// against the version the patch was applied to
+	newVersion, err := h.editComment(r.Context(), postID, commentID, comment, next)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
 	// fetch updated comment
-	updatedComment, err := h.commentRepo.FindByIDWithAuthor(r.Context(), commentID)
+	updatedComment, err := h.content.Comment(r.Context(), postID, commentID)
 	if err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"message": "Comment not found after update",
-		})
+		respondError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(newVersion))
+	if isV2(r) {
+		respondData(w, http.StatusOK, views.NewComment(updatedComment))
+		return
+	}
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success":        true,
 		"updatedComment": updatedComment,
 	})
 }
 
+// validates next and writes it when it changes comment, against the
+// version comment was read at, for UpdateComment and the updateComment
+// mutation
+func (h *CommentHandler) editComment(ctx context.Context, postID, commentID primitive.ObjectID, comment *models.CommentWithAuthor, next CommentDocument) (int64, error) {
+	if err := validate.Struct(&next); err != nil {
+		return 0, err
+	}
+	if next.Text == comment.Text {
+		return comment.Version, nil
+	}
+
+	html, err := h.renderer.Comment(next.Text)
+	if err != nil {
+		return 0, problem.New(http.StatusInternalServerError, "", "Error rendering comment")
+	}
+	version, err := h.content.UpdateComment(ctx, postID, commentID, &comment.Version, next.Text, html)
+	if err != nil {
+		return 0, err
+	}
+	if updated, err := h.content.Comment(ctx, postID, commentID); err == nil {
+		publish(ctx, h.events, models.EventCommentUpdated, views.NewComment(updated))
+	}
+	return version, nil
+}
+
+// sets the comment's text as a whole, for the updateComment mutation and
+// the UpdateComment RPC, which take no patch
+func (h *CommentHandler) replaceComment(ctx context.Context, user *models.User, postID, commentID primitive.ObjectID, version *int64, text string) (*models.CommentWithAuthor, error) {
+	if err := h.content.CanModifyComment(ctx, user, postID, commentID); err != nil {
+		return nil, err
+	}
+	comment, err := h.content.Comment(ctx, postID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if version != nil && *version != comment.Version {
+		return nil, repository.ErrVersionMismatch
+	}
+	if _, err := h.editComment(ctx, postID, commentID, comment, CommentDocument{Text: text}); err != nil {
+		return nil, err
+	}
+
+	updated, err := h.content.Comment(ctx, postID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
 // DELETE /api/posts/:postId/comments/:commentId
+// moves the comment to the trash
 func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
-	commentID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "commentId"))
+	user, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid comment ID",
-		})
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
 		return
 	}
 
-	if err := h.commentRepo.Delete(r.Context(), commentID); err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		})
+	postID, commentID, ok := commentIDs(w, r)
+	if !ok {
 		return
 	}
 
+	version, ok := ifMatchVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	if err := h.trashComment(r.Context(), user, postID, commentID, version); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if isV2(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
-		"message": "Comment deleted.",
+		"message": "Comment moved to trash.",
 		"id":      commentID.Hex(),
 	})
 }
+
+// for DeleteComment, the deleteComment mutation and the DeleteComment RPC
+func (h *CommentHandler) trashComment(ctx context.Context, user *models.User, postID, commentID primitive.ObjectID, version *int64) error {
+	if err := h.content.CanModifyComment(ctx, user, postID, commentID); err != nil {
+		return err
+	}
+	if err := h.content.TrashComment(ctx, postID, commentID, user.ID, version); err != nil {
+		return err
+	}
+	publish(ctx, h.events, models.EventCommentDeleted, map[string]string{"id": commentID.Hex(), "postId": postID.Hex()})
+	return nil
+}
+
+// both ids from the url, a comment is only found under its own post
+func commentIDs(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, primitive.ObjectID, bool) {
+	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid post ID"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	commentID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "commentId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid comment ID"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return postID, commentID, true
+}
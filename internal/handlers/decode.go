@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/validate"
+)
+
+// largest JSON body a handler reads, uploads and imports have their own
+const maxJSONBody = 1 << 20
+
+// reads the request's JSON body into v and checks its validate tags
+// returns false once an error response has been written
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return readJSON(w, r, v, validate.JSON)
+}
+
+// reads the request's JSON body into v without checking it, for documents
+// the caller normalizes before validating
+func decodeJSONOnly(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return readJSON(w, r, v, validate.Decode)
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}, decode func(io.Reader, interface{}) error) bool {
+	err := decode(http.MaxBytesReader(w, r.Body, maxJSONBody), v)
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondError(w, r, problem.New(http.StatusRequestEntityTooLarge, "", "Request body is too large."))
+		return false
+	}
+	respondError(w, r, err)
+	return false
+}
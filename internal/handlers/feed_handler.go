@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/feed"
+	"github.com/kurtgray/blog-api-go/internal/httpcache"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/render"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// summary length in excerpt mode and for the item description
+const feedExcerptLength = 280
+
+type FeedHandler struct {
+	postRepo repository.PostRepository
+	userRepo repository.UserRepository
+	renderer *render.Renderer
+	site     config.SiteConfig
+	// without the html site items link to the API and there are no author
+	// or tag pages to link to
+	webEnabled bool
+}
+
+func NewFeedHandler(postRepo repository.PostRepository, userRepo repository.UserRepository, renderer *render.Renderer, site config.SiteConfig, webEnabled bool) *FeedHandler {
+	site.BaseURL = strings.TrimRight(site.BaseURL, "/")
+	return &FeedHandler{
+		postRepo:   postRepo,
+		userRepo:   userRepo,
+		renderer:   renderer,
+		site:       site,
+		webEnabled: webEnabled,
+	}
+}
+
+// GET /feed.{format} (rss, atom or json), ?content=excerpt drops the full body
+func (h *FeedHandler) SiteFeed(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, repository.PostFilter{}, h.site.Title, h.site.BaseURL)
+}
+
+// GET /authors/:userId/feed.{format}
+func (h *FeedHandler) AuthorFeed(w http.ResponseWriter, r *http.Request) {
+	userID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid user ID"))
+		return
+	}
+
+	user, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	h.serveFeed(w, r,
+		repository.PostFilter{Author: &userID},
+		h.site.Title+" - "+user.Username,
+		h.page("/authors/"+userID.Hex()),
+	)
+}
+
+// GET /tags/:tag/feed.{format}
+func (h *FeedHandler) TagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := models.NormalizeTags([]string{chi.URLParam(r, "tag")})
+	if len(tag) == 0 {
+		respondError(w, r, problem.New(http.StatusBadRequest, "", "Invalid tag"))
+		return
+	}
+
+	h.serveFeed(w, r,
+		repository.PostFilter{Tag: tag[0]},
+		h.site.Title+" - #"+tag[0],
+		h.page("/tags/"+url.PathEscape(tag[0])),
+	)
+}
+
+func (h *FeedHandler) serveFeed(w http.ResponseWriter, r *http.Request, filter repository.PostFilter, title, link string) {
+	var encode func(feed.Feed) ([]byte, error)
+	var contentType string
+	switch chi.URLParam(r, "format") {
+	case "rss":
+		encode, contentType = feed.RSS, feed.RSSContentType
+	case "atom":
+		encode, contentType = feed.Atom, feed.AtomContentType
+	case "json":
+		encode, contentType = feed.JSON, feed.JSONContentType
+	default:
+		respondError(w, r, problem.New(http.StatusNotFound, "", "Feed format must be rss, atom or json"))
+		return
+	}
+
+	full := true
+	switch r.URL.Query().Get("content") {
+	case "", "full":
+	case "excerpt":
+		full = false
+	default:
+		respondError(w, r, problem.New(http.StatusBadRequest, "", "content must be full or excerpt"))
+		return
+	}
+
+	filter.Limit = int64(h.site.FeedLimit)
+	posts, err := h.postRepo.FindPublished(r.Context(), filter)
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error fetching posts"))
+		return
+	}
+
+	f := feed.Feed{
+		Title:       title,
+		Description: h.site.Description,
+		Link:        link,
+		FeedURL:     h.site.BaseURL + r.URL.RequestURI(),
+		Language:    h.site.Language,
+		Items:       make([]feed.Item, 0, len(posts)),
+	}
+	for i := range posts {
+		item := h.feedItem(&posts[i], full)
+		if modified := latestTime(item.Updated, item.Published); modified.After(f.Updated) {
+			f.Updated = modified
+		}
+		f.Items = append(f.Items, item)
+	}
+
+	body, err := encode(f)
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error generating feed"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	httpcache.Serve(w, r, contentType, f.Updated, body)
+}
+
+func (h *FeedHandler) feedItem(post *models.PostWithAuthor, full bool) feed.Item {
+	if post.HTML == "" && post.Text != "" {
+		if rendered, err := h.renderer.Post(post.Format, post.Text); err == nil {
+			post.HTML = rendered.HTML
+		}
+	}
+
+	item := feed.Item{
+		Link:      h.site.BaseURL + "/posts/" + post.ID,
+		Title:     post.Title,
+		Summary:   render.Excerpt(post.HTML, feedExcerptLength),
+		Tags:      post.Tags,
+		Published: post.Timestamp,
+		Updated:   post.UpdatedAt,
+	}
+	if !h.webEnabled {
+		item.Link, item.LinkType = h.site.BaseURL+"/api/posts/"+post.ID, "application/json"
+	}
+	if full {
+		item.Content = post.HTML
+	}
+	if post.ImgURL != "" {
+		item.Image = h.absoluteURL(post.ImgURL)
+	}
+	if post.Author != nil {
+		item.Author = strings.TrimSpace(post.Author.Fname + " " + post.Author.Lname)
+		if item.Author == "" {
+			item.Author = post.Author.Username
+		}
+		if h.webEnabled {
+			item.AuthorURL = h.site.BaseURL + "/authors/" + post.Author.ID
+		}
+	}
+	return item
+}
+
+// path on the html site, or the home page when it isn't mounted
+func (h *FeedHandler) page(path string) string {
+	if h.webEnabled {
+		return h.site.BaseURL + path
+	}
+	return h.site.BaseURL + "/"
+}
+
+// local media URLs are site-relative, feed readers need them absolute
+func (h *FeedHandler) absoluteURL(ref string) string {
+	if strings.HasPrefix(ref, "/") && !strings.HasPrefix(ref, "//") {
+		return h.site.BaseURL + ref
+	}
+	return ref
+}
+
+func latestTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/graphqlcost"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+// most queries learned from clients that send a hash with the query text,
+// the manifest's don't count
+const maxLearnedQueries = 1000
+
+// GET and POST /graphql, users, posts and comments for clients that want
+// them in one round trip
+// the mutations go through the same code as the REST writes
+type GraphQLHandler struct {
+	schema        *graphql.Schema
+	resolver      *graphqlResolver
+	persisted     *persistedQueries
+	maxComplexity int
+}
+
+func NewGraphQLHandler(userHandler *UserHandler, postHandler *PostHandler, commentHandler *CommentHandler, cfg config.GraphQLConfig) (*GraphQLHandler, error) {
+	persisted, err := loadPersistedQueries(cfg.PersistedQueries, cfg.PersistedOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []graphql.SchemaOpt{
+		graphql.UseStringDescriptions(),
+		graphql.UseFieldResolvers(),
+		graphql.MaxDepth(cfg.MaxDepth),
+		graphql.MaxQueryLength(cfg.MaxQueryLength),
+	}
+	if !cfg.Introspection {
+		opts = append(opts, graphql.DisableIntrospection())
+	}
+	resolver := &graphqlResolver{
+		users:          userHandler,
+		posts:          postHandler,
+		comments:       commentHandler,
+		requireVersion: postHandler.requireIfMatch,
+	}
+	schema, err := graphql.ParseSchema(graphqlSchema, resolver, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("graphql schema: %w", err)
+	}
+
+	return &GraphQLHandler{
+		schema:        schema,
+		resolver:      resolver,
+		persisted:     persisted,
+		maxComplexity: cfg.MaxComplexity,
+	}, nil
+}
+
+// body of POST /graphql, GET takes the same as query parameters with
+// variables and extensions JSON-encoded
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    struct {
+		// an automatic persisted query, the query may be left out once the
+		// server has seen it
+		PersistedQuery *struct {
+			Version    int    `json:"version"`
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery,omitempty"`
+	} `json:"extensions"`
+}
+
+// query of GET /graphql, GraphQLRequest's fields
+type GraphQLQuery struct {
+	Query         string `json:"query" doc:"left out for a persisted query"`
+	OperationName string `json:"operationName"`
+	Variables     string `json:"variables" doc:"JSON object"`
+	Extensions    string `json:"extensions" doc:"JSON object, e.g. {\"persistedQuery\":{\"version\":1,\"sha256Hash\":\"...\"}}"`
+}
+
+type GraphQLResponse struct {
+	Data   json.RawMessage         `json:"data,omitempty"`
+	Errors []*gqlerrors.QueryError `json:"errors,omitempty"`
+}
+
+// GET /graphql and POST /graphql
+// a request the server can't read is a problem response, anything after
+// that is a 200 with the GraphQL errors in the body
+func (h *GraphQLHandler) Execute(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		if err := graphqlParams(r, &req); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	} else if !decodeJSONOnly(w, r, &req) {
+		return
+	}
+
+	query, qerr := h.persisted.resolve(&req)
+	if qerr != nil {
+		respondJSON(w, http.StatusOK, GraphQLResponse{Errors: []*gqlerrors.QueryError{qerr}})
+		return
+	}
+	cost, err := graphqlcost.Cost(query, req.OperationName, req.Variables, defaultPageSize, h.maxComplexity)
+	switch {
+	case errors.Is(err, graphqlcost.ErrNoOperation):
+		// Exec refuses it before anything runs
+	case err != nil:
+		// a query that can't be costed isn't run, Exec's validation says
+		// what's wrong with it when it can
+		errs := h.schema.Validate(query)
+		if len(errs) == 0 {
+			p := problem.New(http.StatusBadRequest, "query_not_costed", "Query cost can't be worked out.")
+			errs = []*gqlerrors.QueryError{{Message: p.Detail, Extensions: problemExtensions(p)}}
+		}
+		respondJSON(w, http.StatusOK, GraphQLResponse{Errors: errs})
+		return
+	case cost > h.maxComplexity:
+		p := tooComplex(h.maxComplexity)
+		respondJSON(w, http.StatusOK, GraphQLResponse{Errors: []*gqlerrors.QueryError{{Message: p.Detail, Extensions: problemExtensions(p)}}})
+		return
+	}
+
+	state := &graphqlState{
+		readOnly:      r.Method == http.MethodGet,
+		maxComplexity: h.maxComplexity,
+		users:         newUserLoader(h.resolver.posts.userRepo),
+	}
+	state.budget.Store(int64(h.maxComplexity))
+	ctx := context.WithValue(r.Context(), graphqlKey, state)
+	resp := h.schema.Exec(ctx, query, req.OperationName, req.Variables)
+	for _, qe := range resp.Errors {
+		if qe.ResolverError != nil {
+			// internal errors are logged there and get a generic message
+			p := problem.From(qe.ResolverError)
+			qe.Message = p.Detail
+			qe.Extensions = problemExtensions(p)
+		}
+	}
+	respondJSON(w, http.StatusOK, GraphQLResponse{Data: resp.Data, Errors: resp.Errors})
+}
+
+func graphqlParams(r *http.Request, req *GraphQLRequest) error {
+	q := r.URL.Query()
+	req.Query = q.Get("query")
+	req.OperationName = q.Get("operationName")
+	if v := q.Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+			return problem.New(http.StatusBadRequest, "invalid_variables", "Variables must be a JSON object.")
+		}
+	}
+	if v := q.Get("extensions"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+			return problem.New(http.StatusBadRequest, "invalid_extensions", "Extensions must be a JSON object.")
+		}
+	}
+	return nil
+}
+
+// the code, status and field errors of a problem, for a GraphQL error
+func problemExtensions(p *problem.Problem) map[string]interface{} {
+	ext := map[string]interface{}{
+		"code":   p.Code,
+		"status": p.Status,
+	}
+	if len(p.Errors) > 0 {
+		ext["errors"] = p.Errors
+	}
+	for k, v := range p.Extra {
+		ext[k] = v
+	}
+	return ext
+}
+
+// queries clients may send by hash, from the manifest file and, unless
+// only the manifest's may run, learned from clients that sent the hash
+// with the query text
+type persistedQueries struct {
+	known map[string]string
+	only  bool
+
+	mu      sync.Mutex
+	learned map[string]string
+}
+
+// a JSON object of sha256 hex hashes to query text, an empty path is an
+// empty manifest
+func loadPersistedQueries(path string, only bool) (*persistedQueries, error) {
+	pq := &persistedQueries{known: map[string]string{}, only: only, learned: map[string]string{}}
+	if path == "" {
+		return pq, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("persisted queries: %w", err)
+	}
+	if err := json.Unmarshal(data, &pq.known); err != nil {
+		return nil, fmt.Errorf("persisted queries %s: %w", path, err)
+	}
+	for hash, query := range pq.known {
+		if queryHash(query) != hash {
+			return nil, fmt.Errorf("persisted queries %s: %s is not the sha256 of its query", path, hash)
+		}
+	}
+	return pq, nil
+}
+
+// the query to run for req
+// the error codes are the ones automatic persisted query clients look for
+// to retry with the full text
+func (pq *persistedQueries) resolve(req *GraphQLRequest) (string, *gqlerrors.QueryError) {
+	ext := req.Extensions.PersistedQuery
+	if ext == nil {
+		if req.Query == "" {
+			return "", queryError("query_required", "A query or a persisted query hash is required.")
+		}
+		if pq.only {
+			if _, ok := pq.known[queryHash(req.Query)]; !ok {
+				return "", queryError("PERSISTED_QUERY_ONLY", "Only persisted queries are accepted.")
+			}
+		}
+		return req.Query, nil
+	}
+	if ext.Version != 1 {
+		return "", queryError("PERSISTED_QUERY_VERSION", "Unsupported persisted query version.")
+	}
+
+	if req.Query == "" {
+		if query, ok := pq.lookup(ext.SHA256Hash); ok {
+			return query, nil
+		}
+		return "", queryError("PERSISTED_QUERY_NOT_FOUND", "PersistedQueryNotFound")
+	}
+	if queryHash(req.Query) != ext.SHA256Hash {
+		return "", queryError("PERSISTED_QUERY_HASH_MISMATCH", "The hash is not the sha256 of the query.")
+	}
+	if _, ok := pq.known[ext.SHA256Hash]; !ok {
+		if pq.only {
+			return "", queryError("PERSISTED_QUERY_ONLY", "Only persisted queries are accepted.")
+		}
+		pq.learn(ext.SHA256Hash, req.Query)
+	}
+	return req.Query, nil
+}
+
+func (pq *persistedQueries) lookup(hash string) (string, bool) {
+	if query, ok := pq.known[hash]; ok {
+		return query, true
+	}
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	query, ok := pq.learned[hash]
+	return query, ok
+}
+
+// once full, an arbitrary learned query makes room, its clients just send
+// the text again
+func (pq *persistedQueries) learn(hash, query string) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+	if _, ok := pq.learned[hash]; ok {
+		return
+	}
+	if len(pq.learned) >= maxLearnedQueries {
+		for k := range pq.learned {
+			delete(pq.learned, k)
+			break
+		}
+	}
+	pq.learned[hash] = query
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+func queryError(code, message string) *gqlerrors.QueryError {
+	return &gqlerrors.QueryError{Message: message, Extensions: map[string]interface{}{"code": code}}
+}
+
+type graphqlContextKey string
+
+const graphqlKey graphqlContextKey = "graphql"
+
+// what a GraphQL request's resolvers share
+type graphqlState struct {
+	// sent with GET, mutations are refused
+	readOnly bool
+	// what the query may still spend, see charge
+	budget        atomic.Int64
+	maxComplexity int
+	users         *userLoader
+}
+
+func graphqlStateFrom(ctx context.Context) *graphqlState {
+	state, _ := ctx.Value(graphqlKey).(*graphqlState)
+	return state
+}
@@ -0,0 +1,997 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/content"
+	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/render"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/validate"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// page sizes for posts and comments
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// the Query and Mutation fields of schema.graphql
+type graphqlResolver struct {
+	users    *UserHandler
+	posts    *PostHandler
+	comments *CommentHandler
+	// writes without a version are refused, If-Match's rule
+	requireVersion bool
+}
+
+// query
+
+func (q *graphqlResolver) Me(ctx context.Context) (*userResolver, error) {
+	if err := charge(ctx, 0); err != nil {
+		return nil, err
+	}
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	return q.user(user), nil
+}
+
+func (q *graphqlResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	if err := charge(ctx, 0); err != nil {
+		return nil, err
+	}
+	id, err := objectID(string(args.ID), "Invalid user ID")
+	if err != nil {
+		return nil, err
+	}
+	user, err := graphqlStateFrom(ctx).users.load(ctx, id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return q.user(user), nil
+}
+
+type postsArgs struct {
+	First     int32
+	After     *string
+	Author    *graphql.ID
+	Tag       *string
+	Published *bool
+}
+
+func (q *graphqlResolver) Posts(ctx context.Context, args postsArgs) (*postConnection, error) {
+	query := repository.PostQuery{Published: args.Published}
+	if args.Author != nil {
+		author, err := objectID(string(*args.Author), "Invalid user ID")
+		if err != nil {
+			return nil, err
+		}
+		query.Author = &author
+	}
+	if args.Tag != nil {
+		tag := models.NormalizeTags([]string{*args.Tag})
+		if len(tag) == 0 {
+			return nil, problem.New(http.StatusBadRequest, "", "Invalid tag")
+		}
+		query.Tag = tag[0]
+	}
+	return q.postPage(ctx, query, args.First, args.After)
+}
+
+func (q *graphqlResolver) Post(ctx context.Context, args struct{ ID graphql.ID }) (*postResolver, error) {
+	if err := charge(ctx, 0); err != nil {
+		return nil, err
+	}
+	id, err := objectID(string(args.ID), "Invalid post ID")
+	if err != nil {
+		return nil, err
+	}
+	// a missing post is null, not an error
+	post, err := q.posts.postRepo.FindByID(ctx, id)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error fetching post")
+	}
+	return q.post(post), nil
+}
+
+type commentArgs struct {
+	PostID graphql.ID
+	ID     graphql.ID
+}
+
+func (q *graphqlResolver) Comment(ctx context.Context, args commentArgs) (*commentResolver, error) {
+	if err := charge(ctx, 0); err != nil {
+		return nil, err
+	}
+	postID, commentID, err := commentObjectIDs(string(args.PostID), string(args.ID))
+	if err != nil {
+		return nil, err
+	}
+	comment, err := q.comments.content.Comment(ctx, postID, commentID)
+	if errors.Is(err, content.ErrCommentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q.commentWithAuthor(ctx, comment), nil
+}
+
+// a page of posts newest first, charged for before it's fetched
+func (q *graphqlResolver) postPage(ctx context.Context, query repository.PostQuery, first int32, after *string) (*postConnection, error) {
+	offset, err := pageStart(first, after)
+	if err != nil {
+		return nil, err
+	}
+	if err := charge(ctx, int(first)); err != nil {
+		return nil, err
+	}
+
+	// one more than asked for says whether there's a next page
+	query.Skip = int64(offset)
+	query.Limit = int64(first) + 1
+	posts, err := q.posts.postRepo.Find(ctx, query)
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error fetching posts")
+	}
+
+	conn := &postConnection{q: q, query: query, offset: offset}
+	if len(posts) > int(first) {
+		posts = posts[:first]
+		conn.hasNext = true
+	}
+	ids := make([]primitive.ObjectID, len(posts))
+	for i := range posts {
+		conn.nodes = append(conn.nodes, q.post(&posts[i]))
+		ids[i] = posts[i].Author
+	}
+	// the page's authors are fetched together by the first one asked for
+	graphqlStateFrom(ctx).users.want(ids...)
+	return conn, nil
+}
+
+// mutation
+
+type registerInput struct {
+	Fname    string
+	Lname    string
+	Username string
+	Password string
+}
+
+func (q *graphqlResolver) CreateUser(ctx context.Context, args struct{ Input registerInput }) (*userResolver, error) {
+	if err := mutation(ctx); err != nil {
+		return nil, err
+	}
+	req := RegisterRequest(args.Input)
+	if err := validate.Struct(&req); err != nil {
+		return nil, err
+	}
+	user, err := q.users.register(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	return q.user(user), nil
+}
+
+type loginArgs struct {
+	Username string
+	Password string
+}
+
+type sessionResolver struct {
+	Token string
+	User  *userResolver
+}
+
+func (q *graphqlResolver) Login(ctx context.Context, args loginArgs) (*sessionResolver, error) {
+	if err := mutation(ctx); err != nil {
+		return nil, err
+	}
+	user, token, err := q.users.passwordLogin(ctx, args.Username, args.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionResolver{Token: token, User: q.user(user)}, nil
+}
+
+type seoInput struct {
+	Title        *string
+	Description  *string
+	CanonicalURL *string
+	Image        *string
+	NoIndex      *bool
+}
+
+type postInput struct {
+	Title     string
+	Text      string
+	Format    *string
+	Tags      *[]string
+	ImgURL    *string
+	MediaID   *graphql.ID
+	SEO       *seoInput
+	Published *bool
+}
+
+// what the REST body would have been, left out fields are empty
+func (in postInput) document() PostDocument {
+	doc := PostDocument{
+		Title:     in.Title,
+		Text:      in.Text,
+		Format:    deref(in.Format),
+		ImgURL:    deref(in.ImgURL),
+		Published: deref(in.Published),
+	}
+	if in.Tags != nil {
+		doc.Tags = *in.Tags
+	}
+	if in.MediaID != nil {
+		doc.MediaID = string(*in.MediaID)
+	}
+	if in.SEO != nil {
+		doc.SEO = &models.SEO{
+			Title:        deref(in.SEO.Title),
+			Description:  deref(in.SEO.Description),
+			CanonicalURL: deref(in.SEO.CanonicalURL),
+			Image:        deref(in.SEO.Image),
+			NoIndex:      deref(in.SEO.NoIndex),
+		}
+	}
+	return doc
+}
+
+func (q *graphqlResolver) CreatePost(ctx context.Context, args struct{ Input postInput }) (*postResolver, error) {
+	user, err := mutationUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc := args.Input.document()
+	post, err := q.posts.createPost(ctx, user, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return q.post(post), nil
+}
+
+type updatePostArgs struct {
+	ID      graphql.ID
+	Input   postInput
+	Version *int32
+}
+
+func (q *graphqlResolver) UpdatePost(ctx context.Context, args updatePostArgs) (*postResolver, error) {
+	user, err := mutationUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := objectID(string(args.ID), "Invalid post ID")
+	if err != nil {
+		return nil, err
+	}
+	version, err := q.version(args.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := args.Input.document()
+	if _, err := q.posts.replacePost(ctx, user, id, version, &doc); err != nil {
+		return nil, err
+	}
+	post, err := q.posts.postRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return q.post(post), nil
+}
+
+type deleteArgs struct {
+	ID      graphql.ID
+	Version *int32
+}
+
+func (q *graphqlResolver) DeletePost(ctx context.Context, args deleteArgs) (graphql.ID, error) {
+	user, err := mutationUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	id, err := objectID(string(args.ID), "Invalid post ID")
+	if err != nil {
+		return "", err
+	}
+	version, err := q.version(args.Version)
+	if err != nil {
+		return "", err
+	}
+	if err := q.posts.trashPost(ctx, user, id, version); err != nil {
+		return "", err
+	}
+	return args.ID, nil
+}
+
+type createCommentArgs struct {
+	PostID graphql.ID
+	Text   string
+}
+
+func (q *graphqlResolver) CreateComment(ctx context.Context, args createCommentArgs) (*commentResolver, error) {
+	user, err := mutationUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	postID, err := objectID(string(args.PostID), "Invalid post ID")
+	if err != nil {
+		return nil, err
+	}
+	req := CommentDocument{Text: args.Text}
+	if err := validate.Struct(&req); err != nil {
+		return nil, err
+	}
+	comment, err := q.comments.createComment(ctx, user, postID, req)
+	if err != nil {
+		return nil, err
+	}
+	return q.comment(comment), nil
+}
+
+type updateCommentArgs struct {
+	PostID  graphql.ID
+	ID      graphql.ID
+	Text    string
+	Version *int32
+}
+
+func (q *graphqlResolver) UpdateComment(ctx context.Context, args updateCommentArgs) (*commentResolver, error) {
+	user, err := mutationUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	postID, commentID, err := commentObjectIDs(string(args.PostID), string(args.ID))
+	if err != nil {
+		return nil, err
+	}
+	version, err := q.version(args.Version)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := q.comments.replaceComment(ctx, user, postID, commentID, version, args.Text)
+	if err != nil {
+		return nil, err
+	}
+	return q.commentWithAuthor(ctx, updated), nil
+}
+
+type deleteCommentArgs struct {
+	PostID  graphql.ID
+	ID      graphql.ID
+	Version *int32
+}
+
+func (q *graphqlResolver) DeleteComment(ctx context.Context, args deleteCommentArgs) (graphql.ID, error) {
+	user, err := mutationUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	postID, commentID, err := commentObjectIDs(string(args.PostID), string(args.ID))
+	if err != nil {
+		return "", err
+	}
+	version, err := q.version(args.Version)
+	if err != nil {
+		return "", err
+	}
+	if err := q.comments.trashComment(ctx, user, postID, commentID, version); err != nil {
+		return "", err
+	}
+	return args.ID, nil
+}
+
+// a write's version argument as If-Match's version
+func (q *graphqlResolver) version(v *int32) (*int64, error) {
+	if v == nil {
+		return writeVersion(nil, q.requireVersion)
+	}
+	version := int64(*v)
+	return &version, nil
+}
+
+// mutations aren't sent with GET, they'd be cached and prefetched like reads
+// and their selections are charged like a query's
+func mutation(ctx context.Context) error {
+	if state := graphqlStateFrom(ctx); state != nil && state.readOnly {
+		return problem.New(http.StatusMethodNotAllowed, "mutation_needs_post", "Mutations must be sent with POST.")
+	}
+	return charge(ctx, 0)
+}
+
+// the signed in user a mutation is made by, the REST writes' RequireAuth
+func mutationUser(ctx context.Context) (*models.User, error) {
+	if err := mutation(ctx); err != nil {
+		return nil, err
+	}
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, problem.New(http.StatusUnauthorized, "", "Unauthorized")
+	}
+	return user, nil
+}
+
+// complexity
+
+// takes what the fields selected below the current root field cost out of
+// the request's budget, first is the page size when it's a connection
+// a field costs one per item it's resolved for, so a page of n posts with
+// their authors costs 2n
+// Execute refuses a query graphqlcost prices too high before it runs, root
+// fields are charged again as they run and the one that goes over fails
+// before it fetches anything
+func charge(ctx context.Context, first int) error {
+	state := graphqlStateFrom(ctx)
+	if state == nil {
+		return nil
+	}
+	cost := 1 + selectionCost(ctx, first)
+	if state.budget.Add(-int64(cost)) < 0 {
+		return tooComplex(state.maxComplexity)
+	}
+	return nil
+}
+
+func tooComplex(maxComplexity int) *problem.Problem {
+	return problem.New(http.StatusBadRequest, "query_too_complex",
+		fmt.Sprintf("Query is too complex, it may cost at most %d.", maxComplexity))
+}
+
+// selected paths are dotted, e.g. nodes.author.username, a connection's
+// nodes are its page size times as many as the connection
+func selectionCost(ctx context.Context, first int) int {
+	items := map[string]int{"": 1}
+	var count func(path string) int
+	count = func(path string) int {
+		if n, ok := items[path]; ok {
+			return n
+		}
+		parent, name := "", path
+		if i := strings.LastIndexByte(path, '.'); i >= 0 {
+			parent, name = path[:i], path[i+1:]
+		}
+		n := count(parent)
+		if name == "nodes" {
+			n *= pageSizeAt(ctx, parent, first)
+		}
+		items[path] = n
+		return n
+	}
+
+	cost := 0
+	for _, path := range graphql.SelectedFieldNames(ctx) {
+		parent := ""
+		if i := strings.LastIndexByte(path, '.'); i >= 0 {
+			parent = path[:i]
+		}
+		cost += count(parent)
+	}
+	return cost
+}
+
+// the page size of the connection at path, the root field's own at ""
+func pageSizeAt(ctx context.Context, path string, first int) int {
+	if path == "" {
+		return first
+	}
+	var args struct{ First int32 }
+	if ok, err := graphql.DecodeSelectedFieldArgs(ctx, path, &args); ok && err == nil && args.First > 0 {
+		return int(args.First)
+	}
+	return defaultPageSize
+}
+
+// paging
+
+// where a page starts, cursors are opaque to clients but are offsets
+func pageStart(first int32, after *string) (int, error) {
+	if first < 1 || first > maxPageSize {
+		return 0, problem.New(http.StatusBadRequest, "invalid_page_size", fmt.Sprintf("first must be between 1 and %d.", maxPageSize))
+	}
+	if after == nil {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(*after)
+	offset, convErr := strconv.Atoi(strings.TrimPrefix(string(raw), "offset:"))
+	if err != nil || convErr != nil || offset < 0 || !strings.HasPrefix(string(raw), "offset:") {
+		return 0, problem.New(http.StatusBadRequest, "invalid_cursor", "Invalid cursor")
+	}
+	return offset + 1, nil
+}
+
+func cursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+type pageInfo struct {
+	hasNext bool
+	end     *string
+}
+
+func newPageInfo(offset, n int, hasNext bool) *pageInfo {
+	info := &pageInfo{hasNext: hasNext}
+	if n > 0 {
+		end := cursor(offset + n - 1)
+		info.end = &end
+	}
+	return info
+}
+
+func (p *pageInfo) HasNextPage() bool {
+	return p.hasNext
+}
+
+func (p *pageInfo) EndCursor() *string {
+	return p.end
+}
+
+type postConnection struct {
+	q       *graphqlResolver
+	query   repository.PostQuery
+	offset  int
+	nodes   []*postResolver
+	hasNext bool
+}
+
+func (c *postConnection) Nodes() []*postResolver {
+	return c.nodes
+}
+
+// only counted when asked for
+func (c *postConnection) TotalCount(ctx context.Context) (int32, error) {
+	n, err := c.q.posts.postRepo.Count(ctx, c.query)
+	if err != nil {
+		return 0, problem.New(http.StatusInternalServerError, "", "Error counting posts")
+	}
+	return int32(n), nil
+}
+
+func (c *postConnection) PageInfo() *pageInfo {
+	return newPageInfo(c.offset, len(c.nodes), c.hasNext)
+}
+
+type commentConnection struct {
+	nodes  []*commentResolver
+	total  int
+	offset int
+}
+
+func (c *commentConnection) Nodes() []*commentResolver {
+	return c.nodes
+}
+
+func (c *commentConnection) TotalCount() int32 {
+	return int32(c.total)
+}
+
+func (c *commentConnection) PageInfo() *pageInfo {
+	return newPageInfo(c.offset, len(c.nodes), c.offset+len(c.nodes) < c.total)
+}
+
+// objects
+
+func (q *graphqlResolver) user(u *models.User) *userResolver {
+	return &userResolver{q: q, u: u}
+}
+
+type userResolver struct {
+	q *graphqlResolver
+	u *models.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(r.u.ID.Hex())
+}
+
+func (r *userResolver) Username() string {
+	return r.u.Username
+}
+
+func (r *userResolver) Fname() string {
+	return r.u.Fname
+}
+
+func (r *userResolver) Lname() string {
+	return r.u.Lname
+}
+
+func (r *userResolver) Admin() bool {
+	return r.u.Admin
+}
+
+func (r *userResolver) CanPublish() bool {
+	return r.u.CanPublish
+}
+
+func (r *userResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.u.CreatedAt.UTC()}
+}
+
+type userPostsArgs struct {
+	First     int32
+	After     *string
+	Published *bool
+}
+
+// like GET /api/users/{userId}/posts, only for signed in users
+func (r *userResolver) Posts(ctx context.Context, args userPostsArgs) (*postConnection, error) {
+	if _, err := middleware.GetUserFromContext(ctx); err != nil {
+		return nil, problem.New(http.StatusUnauthorized, "", "Unauthorized")
+	}
+	return r.q.postPage(ctx, repository.PostQuery{
+		PostFilter: repository.PostFilter{Author: &r.u.ID},
+		Published:  args.Published,
+	}, args.First, args.After)
+}
+
+func (q *graphqlResolver) post(p *models.Post) *postResolver {
+	return &postResolver{q: q, p: p}
+}
+
+type postResolver struct {
+	q *graphqlResolver
+	p *models.Post
+
+	// posts from before rendering existed are rendered on read, once
+	renderOnce sync.Once
+	rendered   *render.Result
+}
+
+func (r *postResolver) ID() graphql.ID {
+	return graphql.ID(r.p.ID.Hex())
+}
+
+// null once the author's account is gone
+func (r *postResolver) Author(ctx context.Context) (*userResolver, error) {
+	user, err := graphqlStateFrom(ctx).users.load(ctx, r.p.Author)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return r.q.user(user), nil
+}
+
+func (r *postResolver) Title() string {
+	return r.p.Title
+}
+
+func (r *postResolver) Text() string {
+	return r.p.Text
+}
+
+func (r *postResolver) Format() string {
+	if r.p.Format == "" {
+		return models.FormatHTML
+	}
+	return r.p.Format
+}
+
+func (r *postResolver) HTML() string {
+	if r.p.HTML != "" {
+		return r.p.HTML
+	}
+	return r.renderedHTML().HTML
+}
+
+func (r *postResolver) TOC() []*tocResolver {
+	toc := r.p.TOC
+	if r.p.HTML == "" {
+		toc = r.renderedHTML().TOC
+	}
+	out := make([]*tocResolver, len(toc))
+	for i := range toc {
+		out[i] = &tocResolver{toc[i]}
+	}
+	return out
+}
+
+func (r *postResolver) renderedHTML() *render.Result {
+	r.renderOnce.Do(func() {
+		r.rendered = &render.Result{}
+		if r.p.Text == "" {
+			return
+		}
+		if rendered, err := r.q.posts.renderer.Post(r.p.Format, r.p.Text); err == nil {
+			r.rendered = &rendered
+		}
+	})
+	return r.rendered
+}
+
+func (r *postResolver) Tags() []string {
+	if r.p.Tags == nil {
+		return []string{}
+	}
+	return r.p.Tags
+}
+
+func (r *postResolver) ImgURL() string {
+	return r.p.ImgURL
+}
+
+func (r *postResolver) MediaID() *graphql.ID {
+	if r.p.MediaID == nil {
+		return nil
+	}
+	id := graphql.ID(r.p.MediaID.Hex())
+	return &id
+}
+
+func (r *postResolver) SEO() *models.SEO {
+	return r.p.SEO
+}
+
+func (r *postResolver) Published() bool {
+	return r.p.Published
+}
+
+func (r *postResolver) CommentCount() int32 {
+	return int32(r.p.CommentCount)
+}
+
+func (r *postResolver) Version() int32 {
+	return int32(r.p.Version)
+}
+
+func (r *postResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.p.Timestamp.UTC()}
+}
+
+func (r *postResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.p.UpdatedAt.UTC()}
+}
+
+type pageArgs struct {
+	First int32
+	After *string
+}
+
+// one query per post, the whole list is read and paged here
+func (r *postResolver) Comments(ctx context.Context, args pageArgs) (*commentConnection, error) {
+	offset, err := pageStart(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := r.q.comments.content.PostComments(ctx, r.p.ID)
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error fetching comments")
+	}
+
+	conn := &commentConnection{total: len(comments), offset: offset}
+	page := comments[min(offset, len(comments)):min(offset+int(args.First), len(comments))]
+	for i := range page {
+		conn.nodes = append(conn.nodes, r.q.commentWithAuthor(ctx, &page[i]))
+	}
+	if conn.nodes == nil {
+		conn.nodes = []*commentResolver{}
+	}
+	return conn, nil
+}
+
+type tocResolver struct {
+	e models.TOCEntry
+}
+
+func (r *tocResolver) Level() int32 {
+	return int32(r.e.Level)
+}
+
+func (r *tocResolver) ID() string {
+	return r.e.ID
+}
+
+func (r *tocResolver) Text() string {
+	return r.e.Text
+}
+
+// comments are read with their authors joined and written with their
+// author's id, either way the author comes from the loader so it's the
+// same User as anywhere else in the response
+type commentResolver struct {
+	q         *graphqlResolver
+	id        string
+	postID    primitive.ObjectID
+	author    primitive.ObjectID
+	text      string
+	html      string
+	version   int64
+	createdAt graphql.Time
+}
+
+func (q *graphqlResolver) comment(c *models.Comment) *commentResolver {
+	return &commentResolver{
+		q:         q,
+		id:        c.ID.Hex(),
+		postID:    c.Post,
+		author:    c.Author,
+		text:      c.Text,
+		html:      c.HTML,
+		version:   c.Version,
+		createdAt: graphql.Time{Time: c.Timestamp.UTC()},
+	}
+}
+
+func (q *graphqlResolver) commentWithAuthor(ctx context.Context, c *models.CommentWithAuthor) *commentResolver {
+	r := &commentResolver{
+		q:         q,
+		id:        c.ID,
+		postID:    c.Post,
+		text:      c.Text,
+		html:      c.HTML,
+		version:   c.Version,
+		createdAt: graphql.Time{Time: c.Timestamp.UTC()},
+	}
+	// the empty author the $lookup leaves when the user is gone stays nil
+	if c.Author != nil {
+		r.author, _ = primitive.ObjectIDFromHex(c.Author.ID)
+		graphqlStateFrom(ctx).users.want(r.author)
+	}
+	return r
+}
+
+func (r *commentResolver) ID() graphql.ID {
+	return graphql.ID(r.id)
+}
+
+func (r *commentResolver) PostID() graphql.ID {
+	return graphql.ID(r.postID.Hex())
+}
+
+func (r *commentResolver) Author(ctx context.Context) (*userResolver, error) {
+	if r.author.IsZero() {
+		return nil, nil
+	}
+	user, err := graphqlStateFrom(ctx).users.load(ctx, r.author)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return r.q.user(user), nil
+}
+
+func (r *commentResolver) Text() string {
+	return r.text
+}
+
+func (r *commentResolver) HTML() string {
+	return r.html
+}
+
+func (r *commentResolver) Version() int32 {
+	return int32(r.version)
+}
+
+func (r *commentResolver) CreatedAt() graphql.Time {
+	return r.createdAt
+}
+
+// authors
+
+// batches user lookups for one request, ids wanted before the first load
+// are fetched with it in one FindByIDs, and every user is fetched at most
+// once per request
+type userLoader struct {
+	repo repository.UserRepository
+
+	mu      sync.Mutex
+	pending []primitive.ObjectID
+	users   map[primitive.ObjectID]*userBatch
+}
+
+// users fetched together, done closes once they are in
+type userBatch struct {
+	done  chan struct{}
+	users map[primitive.ObjectID]*models.User
+	err   error
+}
+
+func newUserLoader(repo repository.UserRepository) *userLoader {
+	return &userLoader{repo: repo, users: map[primitive.ObjectID]*userBatch{}}
+}
+
+// queues ids for the next batch
+func (l *userLoader) want(ids ...primitive.ObjectID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := l.users[id]; !ok && !id.IsZero() {
+			l.pending = append(l.pending, id)
+		}
+	}
+}
+
+// nil when there's no such user
+func (l *userLoader) load(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	l.mu.Lock()
+	batch, ok := l.users[id]
+	if !ok {
+		batch = &userBatch{done: make(chan struct{})}
+		ids := []primitive.ObjectID{id}
+		for _, p := range l.pending {
+			if _, ok := l.users[p]; !ok && p != id {
+				ids = append(ids, p)
+				l.users[p] = batch
+			}
+		}
+		l.users[id] = batch
+		l.pending = nil
+		l.mu.Unlock()
+
+		l.fetch(ctx, batch, ids)
+	} else {
+		l.mu.Unlock()
+	}
+
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if batch.err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error fetching users")
+	}
+	return batch.users[id], nil
+}
+
+func (l *userLoader) fetch(ctx context.Context, batch *userBatch, ids []primitive.ObjectID) {
+	defer close(batch.done)
+	users, err := l.repo.FindByIDs(ctx, ids)
+	if err != nil {
+		batch.err = err
+		return
+	}
+	batch.users = make(map[primitive.ObjectID]*models.User, len(users))
+	for i := range users {
+		batch.users[users[i].ID] = &users[i]
+	}
+}
+
+// ids
+
+func objectID(id, message string) (primitive.ObjectID, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, problem.New(http.StatusBadRequest, "invalid_id", message)
+	}
+	return oid, nil
+}
+
+func commentObjectIDs(postID, commentID string) (primitive.ObjectID, primitive.ObjectID, error) {
+	pid, err := objectID(postID, "Invalid post ID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	cid, err := objectID(commentID, "Invalid comment ID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	return pid, cid, nil
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/rpc/blogv1"
+	"github.com/kurtgray/blog-api-go/internal/validate"
+	"github.com/kurtgray/blog-api-go/internal/views"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// posts ListPosts reads at a time
+const streamBatchSize = 100
+
+// the blog.v1 gRPC services, over the same code as the REST writes
+// they return problems like the handlers do, the rpc package's
+// interceptors turn them into statuses
+type GRPCServices struct {
+	Users    blogv1.UserServiceServer
+	Posts    blogv1.PostServiceServer
+	Comments blogv1.CommentServiceServer
+}
+
+func NewGRPCServices(userHandler *UserHandler, postHandler *PostHandler, commentHandler *CommentHandler) *GRPCServices {
+	return &GRPCServices{
+		Users:    &userService{users: userHandler},
+		Posts:    &postService{posts: postHandler},
+		Comments: &commentService{comments: commentHandler},
+	}
+}
+
+type userService struct {
+	blogv1.UnimplementedUserServiceServer
+	users *UserHandler
+}
+
+func (s *userService) CreateUser(ctx context.Context, req *blogv1.CreateUserRequest) (*blogv1.User, error) {
+	register := RegisterRequest{
+		Fname:    req.GetFname(),
+		Lname:    req.GetLname(),
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+	}
+	if err := validate.Struct(&register); err != nil {
+		return nil, err
+	}
+	user, err := s.users.register(ctx, &register)
+	if err != nil {
+		return nil, err
+	}
+	return userMessage(user), nil
+}
+
+func (s *userService) Login(ctx context.Context, req *blogv1.LoginRequest) (*blogv1.Session, error) {
+	user, token, err := s.users.passwordLogin(ctx, req.GetUsername(), req.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+	return &blogv1.Session{Token: token, User: userMessage(user)}, nil
+}
+
+func (s *userService) GetCurrentUser(ctx context.Context, _ *blogv1.GetCurrentUserRequest) (*blogv1.User, error) {
+	user, err := rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return userMessage(user), nil
+}
+
+type postService struct {
+	blogv1.UnimplementedPostServiceServer
+	posts *PostHandler
+}
+
+func (s *postService) ListPosts(req *blogv1.ListPostsRequest, stream grpc.ServerStreamingServer[blogv1.Post]) error {
+	ctx := stream.Context()
+	if req.GetLimit() < 0 {
+		return problem.New(http.StatusBadRequest, "invalid_limit", "limit can't be negative.")
+	}
+
+	query := repository.PostQuery{Published: req.Published}
+	if req.GetAuthor() != "" {
+		author, err := objectID(req.GetAuthor(), "Invalid user ID")
+		if err != nil {
+			return err
+		}
+		query.Author = &author
+	}
+	if req.GetTag() != "" {
+		tag := models.NormalizeTags([]string{req.GetTag()})
+		if len(tag) == 0 {
+			return problem.New(http.StatusBadRequest, "", "Invalid tag")
+		}
+		query.Tag = tag[0]
+	}
+
+	// a batch at a time so a long list is never all in memory, with each
+	// batch's authors fetched together
+	left := int64(req.GetLimit())
+	for {
+		query.Limit = streamBatchSize
+		if left > 0 && left < query.Limit {
+			query.Limit = left
+		}
+		posts, err := s.posts.postRepo.Find(ctx, query)
+		if err != nil {
+			return problem.New(http.StatusInternalServerError, "", "Error fetching posts")
+		}
+		authors, err := s.authors(ctx, posts)
+		if err != nil {
+			return err
+		}
+		for i := range posts {
+			s.posts.ensurePostRendered(&posts[i])
+			if err := stream.Send(postMessage(views.NewPostBy(&posts[i], authors[posts[i].Author]))); err != nil {
+				return err
+			}
+		}
+
+		if int64(len(posts)) < query.Limit {
+			return nil
+		}
+		query.Skip += int64(len(posts))
+		if left > 0 {
+			if left -= int64(len(posts)); left == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// the authors of posts that still have one, by id
+func (s *postService) authors(ctx context.Context, posts []models.Post) (map[primitive.ObjectID]*models.User, error) {
+	ids := make([]primitive.ObjectID, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.Author)
+	}
+	users, err := s.posts.userRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error fetching users")
+	}
+	byID := make(map[primitive.ObjectID]*models.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	return byID, nil
+}
+
+func (s *postService) GetPost(ctx context.Context, req *blogv1.GetPostRequest) (*blogv1.Post, error) {
+	id, err := objectID(req.GetId(), "Invalid post ID")
+	if err != nil {
+		return nil, err
+	}
+	return s.read(ctx, id)
+}
+
+func (s *postService) read(ctx context.Context, id primitive.ObjectID) (*blogv1.Post, error) {
+	post, err := s.posts.postRepo.FindByIDWithAuthor(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.posts.ensureRendered(post)
+	return postMessage(views.NewPost(post)), nil
+}
+
+func (s *postService) CreatePost(ctx context.Context, req *blogv1.CreatePostRequest) (*blogv1.Post, error) {
+	user, err := rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc := postDocument(req.GetPost())
+	post, err := s.posts.createPost(ctx, user, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return postMessage(views.NewPostBy(post, user)), nil
+}
+
+func (s *postService) UpdatePost(ctx context.Context, req *blogv1.UpdatePostRequest) (*blogv1.Post, error) {
+	user, err := rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := objectID(req.GetId(), "Invalid post ID")
+	if err != nil {
+		return nil, err
+	}
+	version, err := writeVersion(req.Version, s.posts.requireIfMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := postDocument(req.GetPost())
+	if _, err := s.posts.replacePost(ctx, user, id, version, &doc); err != nil {
+		return nil, err
+	}
+	return s.read(ctx, id)
+}
+
+func (s *postService) DeletePost(ctx context.Context, req *blogv1.DeletePostRequest) (*emptypb.Empty, error) {
+	user, err := rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := objectID(req.GetId(), "Invalid post ID")
+	if err != nil {
+		return nil, err
+	}
+	version, err := writeVersion(req.Version, s.posts.requireIfMatch)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.posts.trashPost(ctx, user, id, version); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+type commentService struct {
+	blogv1.UnimplementedCommentServiceServer
+	comments *CommentHandler
+}
+
+func (s *commentService) ListComments(req *blogv1.ListCommentsRequest, stream grpc.ServerStreamingServer[blogv1.Comment]) error {
+	postID, err := objectID(req.GetPostId(), "Invalid post ID")
+	if err != nil {
+		return err
+	}
+	comments, err := s.comments.content.PostComments(stream.Context(), postID)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		if err := stream.Send(commentMessage(views.NewComment(&comments[i]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *commentService) GetComment(ctx context.Context, req *blogv1.GetCommentRequest) (*blogv1.Comment, error) {
+	postID, commentID, err := commentObjectIDs(req.GetPostId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	comment, err := s.comments.content.Comment(ctx, postID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	return commentMessage(views.NewComment(comment)), nil
+}
+
+func (s *commentService) CreateComment(ctx context.Context, req *blogv1.CreateCommentRequest) (*blogv1.Comment, error) {
+	user, err := rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	postID, err := objectID(req.GetPostId(), "Invalid post ID")
+	if err != nil {
+		return nil, err
+	}
+	doc := CommentDocument{Text: req.GetText()}
+	if err := validate.Struct(&doc); err != nil {
+		return nil, err
+	}
+	comment, err := s.comments.createComment(ctx, user, postID, doc)
+	if err != nil {
+		return nil, err
+	}
+	return commentMessage(views.NewCommentBy(comment, user)), nil
+}
+
+func (s *commentService) UpdateComment(ctx context.Context, req *blogv1.UpdateCommentRequest) (*blogv1.Comment, error) {
+	user, err := rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	postID, commentID, err := commentObjectIDs(req.GetPostId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	version, err := writeVersion(req.Version, s.comments.requireIfMatch)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := s.comments.replaceComment(ctx, user, postID, commentID, version, req.GetText())
+	if err != nil {
+		return nil, err
+	}
+	return commentMessage(views.NewComment(comment)), nil
+}
+
+func (s *commentService) DeleteComment(ctx context.Context, req *blogv1.DeleteCommentRequest) (*emptypb.Empty, error) {
+	user, err := rpcUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	postID, commentID, err := commentObjectIDs(req.GetPostId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	version, err := writeVersion(req.Version, s.comments.requireIfMatch)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.comments.trashComment(ctx, user, postID, commentID, version); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// the caller the rpc package's interceptor authenticated, RequireAuth's
+// rule for the calls that write
+func rpcUser(ctx context.Context) (*models.User, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return nil, problem.New(http.StatusUnauthorized, "", "Unauthorized")
+	}
+	return user, nil
+}
+
+// what the REST body would have been
+func postDocument(in *blogv1.PostInput) PostDocument {
+	doc := PostDocument{
+		Title:     in.GetTitle(),
+		Text:      in.GetText(),
+		Format:    in.GetFormat(),
+		Tags:      in.GetTags(),
+		ImgURL:    in.GetImgUrl(),
+		MediaID:   in.GetMediaId(),
+		Published: in.GetPublished(),
+	}
+	if seo := in.GetSeo(); seo != nil {
+		doc.SEO = &models.SEO{
+			Title:        seo.GetTitle(),
+			Description:  seo.GetDescription(),
+			CanonicalURL: seo.GetCanonicalUrl(),
+			Image:        seo.GetImage(),
+			NoIndex:      seo.GetNoIndex(),
+		}
+	}
+	return doc
+}
+
+// messages, built from the v2 views so both APIs show the same fields
+
+func userMessage(u *models.User) *blogv1.User {
+	v := views.NewUser(u)
+	return &blogv1.User{
+		Id:         v.ID,
+		Username:   v.Username,
+		Fname:      v.Fname,
+		Lname:      v.Lname,
+		Admin:      v.Admin,
+		CanPublish: v.CanPublish,
+		CreatedAt:  timestamppb.New(v.CreatedAt),
+	}
+}
+
+func authorMessage(a *views.Author) *blogv1.Author {
+	if a == nil {
+		return nil
+	}
+	return &blogv1.Author{Id: a.ID, Username: a.Username, Fname: a.Fname, Lname: a.Lname}
+}
+
+func postMessage(p views.Post) *blogv1.Post {
+	msg := &blogv1.Post{
+		Id:           p.ID,
+		Author:       authorMessage(p.Author),
+		Title:        p.Title,
+		Text:         p.Text,
+		Format:       p.Format,
+		Html:         p.HTML,
+		Tags:         p.Tags,
+		ImgUrl:       p.ImgURL,
+		Published:    p.Published,
+		CommentCount: p.CommentCount,
+		Version:      p.Version,
+		CreatedAt:    timestamppb.New(p.CreatedAt),
+		UpdatedAt:    timestamppb.New(p.UpdatedAt),
+	}
+	for _, e := range p.TOC {
+		msg.Toc = append(msg.Toc, &blogv1.TOCEntry{Level: int32(e.Level), Id: e.ID, Text: e.Text})
+	}
+	if p.MediaID != nil {
+		msg.MediaId = *p.MediaID
+	}
+	if p.SEO != nil {
+		msg.Seo = &blogv1.SEO{
+			Title:        p.SEO.Title,
+			Description:  p.SEO.Description,
+			CanonicalUrl: p.SEO.CanonicalURL,
+			Image:        p.SEO.Image,
+			NoIndex:      p.SEO.NoIndex,
+		}
+	}
+	return msg
+}
+
+func commentMessage(c views.Comment) *blogv1.Comment {
+	return &blogv1.Comment{
+		Id:        c.ID,
+		PostId:    c.PostID,
+		Author:    authorMessage(c.Author),
+		Text:      c.Text,
+		Html:      c.HTML,
+		Version:   c.Version,
+		CreatedAt: timestamppb.New(c.CreatedAt),
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/kurtgray/blog-api-go/internal/importer"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/validate"
+)
+
+// largest export accepted over http, bigger sites go through blogctl import
+const maxImportSize = 64 << 20
+
+type ImportHandler struct {
+	importer   *importer.Importer
+	importRepo repository.ImportRepository
+	// redirect to html pages rather than the API
+	webEnabled bool
+}
+
+func NewImportHandler(imp *importer.Importer, importRepo repository.ImportRepository, webEnabled bool) *ImportHandler {
+	return &ImportHandler{
+		importer:   imp,
+		importRepo: importRepo,
+		webEnabled: webEnabled,
+	}
+}
+
+// query of POST /api/admin/import
+type ImportQuery struct {
+	Format string `json:"format" validate:"required,oneof=wxr ghost markdown" doc:"what the body is, markdown takes a zip of the directory"`
+	Source string `json:"source" validate:"required,max=100" doc:"label for the site imported from, reruns with the same one skip what's already imported"`
+	DryRun bool   `json:"dryRun" doc:"report what would be imported without writing anything"`
+}
+
+// POST /api/admin/import?format=wxr|ghost|markdown&source=label&dryRun=true
+// body is the export file, a zip of the directory for markdown
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	params := ImportQuery{Format: q.Get("format"), Source: q.Get("source")}
+	if err := validate.Struct(&params); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if v := q.Get("dryRun"); v != "" {
+		var err error
+		if params.DryRun, err = strconv.ParseBool(v); err != nil {
+			respondError(w, r, problem.New(http.StatusBadRequest, "", "dryRun must be true or false"))
+			return
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(w, r, problem.New(http.StatusRequestEntityTooLarge, "", "Export is too large, use blogctl import instead."))
+			return
+		}
+		respondError(w, r, problem.New(http.StatusBadRequest, "", "Error reading request body"))
+		return
+	}
+
+	src, err := importer.Parse(params.Format, data)
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_import", err.Error()))
+		return
+	}
+
+	report, err := h.importer.Run(r.Context(), src, importer.Options{
+		Source:        params.Source,
+		DryRun:        params.DryRun,
+		DefaultAuthor: user.Username,
+	})
+	if err != nil {
+		log.Printf("import %s failed: %v", params.Source, err)
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error importing content"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// NotFound handler: sends links from the old site to the imported records
+func (h *ImportHandler) Redirect(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		if target := h.redirectTarget(r); target != "" {
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+	}
+
+	respondError(w, r, problem.New(http.StatusNotFound, "", "Not found"))
+}
+
+func (h *ImportHandler) redirectTarget(r *http.Request) string {
+	path := importer.NormalizePath(r.URL.RequestURI())
+	if path == "" {
+		return ""
+	}
+
+	mapping, err := h.importRepo.FindByOldPath(r.Context(), path)
+	if err != nil {
+		log.Printf("redirect lookup %s: %v", path, err)
+		return ""
+	}
+	if mapping == nil {
+		return ""
+	}
+
+	switch {
+	case mapping.Kind == models.ImportPost && h.webEnabled:
+		return "/posts/" + mapping.TargetID.Hex()
+	case mapping.Kind == models.ImportPost:
+		return "/api/posts/" + mapping.TargetID.Hex()
+	case mapping.Kind == models.ImportUser && h.webEnabled:
+		return "/authors/" + mapping.TargetID.Hex()
+	}
+	// author pages only exist on the html site
+	return ""
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/kurtgray/blog-api-go/internal/integrity"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+)
+
+type IntegrityHandler struct {
+	checker *integrity.Checker
+}
+
+func NewIntegrityHandler(checker *integrity.Checker) *IntegrityHandler {
+	return &IntegrityHandler{checker: checker}
+}
+
+// GET /api/admin/integrity
+func (h *IntegrityHandler) Check(w http.ResponseWriter, r *http.Request) {
+	h.run(w, r, false)
+}
+
+// POST /api/admin/integrity/repair
+func (h *IntegrityHandler) Repair(w http.ResponseWriter, r *http.Request) {
+	h.run(w, r, true)
+}
+
+func (h *IntegrityHandler) run(w http.ResponseWriter, r *http.Request, repair bool) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.checker.Run(r.Context(), repair)
+	if err != nil {
+		log.Printf("integrity check by %s: %v", admin.ID.Hex(), err)
+		// repairs made before the failure are still in the report
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error checking integrity").With("report", report))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"report":      report,
+		"outstanding": report.Outstanding(),
+	})
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/media"
+	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// room for multipart boundaries and headers on top of the file itself
+const multipartOverhead = 1 << 20
+
+type MediaHandler struct {
+	mediaRepo    repository.MediaRepository
+	mediaService *media.Service
+}
+
+func NewMediaHandler(mediaRepo repository.MediaRepository, mediaService *media.Service) *MediaHandler {
+	return &MediaHandler{
+		mediaRepo:    mediaRepo,
+		mediaService: mediaService,
+	}
+}
+
+// POST /api/media (multipart, field "file")
+func (h *MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.mediaService.MaxSize()+multipartOverhead)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "", "Expected multipart/form-data body"))
+		return
+	}
+
+	// stream parts until the file, nothing is buffered to disk
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			respondUploadError(w, r, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		m, err := h.mediaService.Process(r.Context(), user.ID, part.FileName(), part)
+		part.Close()
+		if err != nil {
+			respondUploadError(w, r, err)
+			return
+		}
+
+		if err := h.mediaRepo.Create(r.Context(), m); err != nil {
+			h.mediaService.Delete(r.Context(), m)
+			respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error saving media"))
+			return
+		}
+
+		respondJSON(w, http.StatusCreated, map[string]interface{}{
+			"success": true,
+			"media":   m,
+		})
+		return
+	}
+
+	respondError(w, r, apperr.Field("file", "required", "File must be provided in the \"file\" field."))
+}
+
+// GET /api/media (current user's library)
+func (h *MediaHandler) GetMyMedia(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	items, err := h.mediaRepo.FindByOwner(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error fetching media"))
+		return
+	}
+
+	if items == nil {
+		items = []models.Media{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"media":   items,
+	})
+}
+
+// GET /api/media/:mediaId
+func (h *MediaHandler) GetMedia(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.findOwned(w, r)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"media":   m,
+	})
+}
+
+// DELETE /api/media/:mediaId
+func (h *MediaHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.findOwned(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.mediaRepo.Delete(r.Context(), m.ID); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	// record is gone, a leftover file is only wasted space
+	if err := h.mediaService.Delete(r.Context(), m); err != nil {
+		log.Printf("media %s: error deleting stored files: %v", m.ID.Hex(), err)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Media deleted.",
+		"id":      m.ID.Hex(),
+	})
+}
+
+// loads the media from the URL, only its owner or an admin may access it
+func (h *MediaHandler) findOwned(w http.ResponseWriter, r *http.Request) (*models.Media, bool) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return nil, false
+	}
+
+	mediaID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "mediaId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid media ID"))
+		return nil, false
+	}
+
+	m, err := h.mediaRepo.FindByID(r.Context(), mediaID)
+	if err != nil {
+		respondError(w, r, err)
+		return nil, false
+	}
+
+	if m.Owner != user.ID && !user.Admin {
+		respondError(w, r, problem.New(http.StatusForbidden, "", "Forbidden"))
+		return nil, false
+	}
+
+	return m, true
+}
+
+func respondUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, media.ErrTooLarge), errors.As(err, &maxBytesErr):
+		respondError(w, r, problem.New(http.StatusRequestEntityTooLarge, "", "File is too large."))
+	case errors.Is(err, media.ErrUnsupportedType):
+		respondError(w, r, problem.New(http.StatusUnsupportedMediaType, "", "Only JPEG, PNG and GIF images are supported."))
+	default:
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error uploading file"))
+	}
+}
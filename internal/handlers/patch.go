@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/patch"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+)
+
+// what PATCH accepts, plain json is read as a merge patch so older clients
+// sending {"published": true} keep working
+var acceptPatch = patch.MergePatchType + ", " + patch.JSONPatchType
+
+// the members of each document PATCH may write, the rest are kept by the
+// server
+var (
+	postPatchable    = []string{"title", "text", "format", "tags", "imgUrl", "mediaId", "seo", "published"}
+	commentPatchable = []string{"text"}
+)
+
+// applies the request's merge or JSON patch to doc and decodes the result
+// into out, what names the document in error messages
+// returns false once an error response has been written
+func applyPatch(w http.ResponseWriter, r *http.Request, what string, doc interface{}, patchable []string, out interface{}) bool {
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			mediaType = ""
+		}
+	}
+	if mediaType != "application/json" && mediaType != patch.MergePatchType && mediaType != patch.JSONPatchType {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		respondError(w, r, problem.New(http.StatusUnsupportedMediaType, "", "PATCH takes "+acceptPatch))
+		return false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJSONBody))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondError(w, r, problem.New(http.StatusRequestEntityTooLarge, "", "Request body is too large."))
+			return false
+		}
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_body", "Invalid request body"))
+		return false
+	}
+
+	var members []string
+	var ops patch.Patch
+	if mediaType == patch.JSONPatchType {
+		if ops, err = patch.Decode(body); err == nil {
+			members = ops.Members()
+		}
+	} else {
+		members, err = patch.MergeMembers(body)
+	}
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_patch", err.Error()))
+		return false
+	}
+
+	for _, m := range members {
+		if m == "" {
+			respondError(w, r, problem.New(http.StatusUnprocessableEntity, "whole_document_patch", fmt.Sprintf("A patch can't replace the whole %s, patch its fields.", what)))
+			return false
+		}
+		if !slices.Contains(patchable, m) {
+			p := problem.New(http.StatusUnprocessableEntity, "field_not_patchable", fmt.Sprintf("%s can't be patched, patchable fields are %s.", m, strings.Join(patchable, ", ")))
+			p.Errors = []apperr.FieldError{{Field: m, Code: "not_patchable", Message: p.Detail}}
+			respondError(w, r, p)
+			return false
+		}
+	}
+
+	current, err := json.Marshal(doc)
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error applying patch"))
+		return false
+	}
+	var patched []byte
+	if ops != nil {
+		patched, err = ops.Apply(current)
+	} else {
+		patched, err = patch.Merge(current, body)
+	}
+	switch {
+	case errors.Is(err, patch.ErrTestFailed):
+		respondError(w, r, problem.New(http.StatusConflict, "patch_test_failed", err.Error()))
+		return false
+	case errors.Is(err, patch.ErrMalformed):
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_patch", err.Error()))
+		return false
+	case err != nil:
+		respondError(w, r, problem.New(http.StatusUnprocessableEntity, "patch_failed", err.Error()))
+		return false
+	}
+
+	// the patched document must still be a valid one
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		p := problem.New(http.StatusUnprocessableEntity, "invalid_patched_document", fmt.Sprintf("Patched %s is not valid.", what))
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			p.Detail = fmt.Sprintf("%s can't be a %s.", typeErr.Field, typeErr.Value)
+			p.Errors = []apperr.FieldError{{Field: typeErr.Field, Code: "invalid_type", Message: p.Detail}}
+		}
+		respondError(w, r, p)
+		return false
+	}
+	return true
+}
@@ -1,27 +1,58 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"reflect"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/content"
 	"github.com/kurtgray/blog-api-go/internal/middleware"
 	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/render"
 	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/seo"
+	"github.com/kurtgray/blog-api-go/internal/validate"
+	"github.com/kurtgray/blog-api-go/internal/views"
+	"github.com/kurtgray/blog-api-go/internal/webhooks"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type PostHandler struct {
-	postRepo repository.PostRepository
-	userRepo repository.UserRepository
+	postRepo  repository.PostRepository
+	userRepo  repository.UserRepository
+	mediaRepo repository.MediaRepository
+	content   *content.Service
+	events    *webhooks.Service
+	renderer  *render.Renderer
+	site      config.SiteConfig
+	// writes without If-Match get a 428 instead of overwriting blindly
+	requireIfMatch bool
+	// whether post pages exist for page metadata to point to
+	webEnabled bool
 }
 
-func NewPostHandler(postRepo repository.PostRepository, userRepo repository.UserRepository) *PostHandler {
+func NewPostHandler(postRepo repository.PostRepository, userRepo repository.UserRepository, mediaRepo repository.MediaRepository, content *content.Service, events *webhooks.Service, renderer *render.Renderer, site config.SiteConfig, requireIfMatch, webEnabled bool) *PostHandler {
 	return &PostHandler{
-		postRepo: postRepo,
-		userRepo: userRepo,
+		postRepo:       postRepo,
+		userRepo:       userRepo,
+		mediaRepo:      mediaRepo,
+		content:        content,
+		events:         events,
+		renderer:       renderer,
+		site:           site,
+		requireIfMatch: requireIfMatch,
+		webEnabled:     webEnabled,
 	}
 }
 
@@ -29,15 +60,18 @@ func NewPostHandler(postRepo repository.PostRepository, userRepo repository.User
 func (h *PostHandler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 	posts, err := h.postRepo.FindAllWithAuthor(r.Context())
 	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error fetching posts",
-		})
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error fetching posts"))
 		return
 	}
 
-	// TODO: Populate author data (we'll do this with aggregation later if needed)
+	for i := range posts {
+		h.ensureRendered(&posts[i])
+	}
 
+	if isV2(r) {
+		respondData(w, http.StatusOK, views.NewPosts(posts))
+		return
+	}
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"posts": posts,
 	})
@@ -48,184 +82,413 @@ func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 	// parse post id from param
 	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid post ID",
-		})
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid post ID"))
 		return
 	}
 
 	post, err := h.postRepo.FindByIDWithAuthor(r.Context(), postID)
 	if err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"message": "Post not found",
-		})
+		respondError(w, r, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+	h.ensureRendered(post)
+	post.Meta = seo.PostMeta(h.site, post, h.webEnabled)
+
+	respondRepresentation(w, r, post.Version, map[string]interface{}{
 		"post": post,
-	})
+	}, views.NewPost(post))
 }
 
 // POST /api/posts
 func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	user, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
-		respondJSON(w, http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"message": "Unauthorized",
-		})
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
 		return
 	}
 
 	// from client
-	var req struct {
-		Title     string `json:"title"`
-		Text      string `json:"text"`
-		ImgURL    string `json:"imgUrl"`
-		Published bool   `json:"published"`
+	var req PostDocument
+	if !decodeJSONOnly(w, r, &req) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid request body",
-		})
+	post, err := h.createPost(r.Context(), user, &req)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	// validate input
-	if strings.TrimSpace(req.Title) == "" {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Title must be specified.",
-		})
+	w.Header().Set("ETag", versionETag(post.Version))
+	if isV2(r) {
+		respondData(w, http.StatusCreated, views.NewPostBy(post, user))
 		return
 	}
-	if strings.TrimSpace(req.Text) == "" {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Text must be specified.",
-		})
-		return
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"post":    post,
+	})
+}
+
+// validates, renders and stores a new post by user, for CreatePost and
+// the createPost mutation
+func (h *PostHandler) createPost(ctx context.Context, user *models.User, req *PostDocument) (*models.Post, error) {
+	postSEO, err := validatePost(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := h.renderer.Post(req.Format, req.Text)
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error rendering post")
 	}
 
 	post := &models.Post{
 		Author:    user.ID,
 		Title:     req.Title,
 		Text:      req.Text,
+		Format:    req.Format,
+		HTML:      rendered.HTML,
+		TOC:       rendered.TOC,
+		Tags:      models.NormalizeTags(req.Tags),
 		ImgURL:    req.ImgURL,
+		SEO:       postSEO,
 		Published: req.Published,
 	}
 
-	if err := h.postRepo.Create(r.Context(), post); err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error creating post",
-		})
-		return
+	if req.MediaID != "" {
+		m, err := h.resolveMedia(ctx, user, req.MediaID)
+		if err != nil {
+			return nil, err
+		}
+		post.MediaID = &m.ID
+		post.ImgURL = m.URL
 	}
 
-	respondJSON(w, http.StatusCreated, map[string]interface{}{
-		"success": true,
-		"post":    post,
-	})
+	if err := h.content.CreatePost(ctx, post); err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error creating post")
+	}
+
+	view := views.NewPostBy(post, user)
+	publish(ctx, h.events, models.EventPostCreated, view)
+	if post.Published {
+		publish(ctx, h.events, models.EventPostPublished, view)
+	}
+	return post, nil
+}
+
+// the editable part of a post, what PUT replaces and PATCH patches
+// MediaID is empty when no upload is attached
+type PostDocument struct {
+	Title     string      `json:"title" validate:"required,max=200" label:"Title"`
+	Text      string      `json:"text" validate:"required,max=100000" label:"Text"`
+	Format    string      `json:"format" validate:"oneof=markdown html plain" label:"Format"`
+	Tags      []string    `json:"tags" validate:"max=20" label:"Tags"`
+	ImgURL    string      `json:"imgUrl" validate:"link,max=2048" label:"Image URL"`
+	MediaID   string      `json:"mediaId" validate:"pattern=objectid" label:"Media ID"`
+	SEO       *models.SEO `json:"seo"`
+	Published bool        `json:"published"`
+}
+
+// longest tag, in characters
+const maxTagLength = 50
+
+func newPostDocument(post *models.Post) PostDocument {
+	doc := PostDocument{
+		Title:     post.Title,
+		Text:      post.Text,
+		Format:    post.Format,
+		Tags:      post.Tags,
+		ImgURL:    post.ImgURL,
+		SEO:       post.SEO,
+		Published: post.Published,
+	}
+	if doc.Format == "" {
+		doc.Format = models.FormatHTML
+	}
+	// present but empty so JSON patch can add to them
+	if doc.Tags == nil {
+		doc.Tags = []string{}
+	}
+	if doc.SEO == nil {
+		doc.SEO = &models.SEO{}
+	}
+	if post.MediaID != nil {
+		doc.MediaID = post.MediaID.Hex()
+	}
+	return doc
+}
+
+// checks what every stored post needs, defaults the format and normalizes
+// the SEO overrides
+func validatePost(doc *PostDocument) (*models.SEO, error) {
+	doc.SEO = normalizeSEO(doc.SEO)
+	err := validate.Struct(doc)
+
+	// the tag rules can't reach into the list
+	for i, tag := range doc.Tags {
+		if utf8.RuneCountInString(strings.TrimSpace(tag)) > maxTagLength {
+			fe := apperr.FieldError{
+				Field:   fmt.Sprintf("tags.%d", i),
+				Code:    "too_long",
+				Message: fmt.Sprintf("Tags must be at most %d characters.", maxTagLength),
+			}
+			var e *apperr.Error
+			if errors.As(err, &e) {
+				e.Fields = append(e.Fields, fe)
+			} else {
+				err = apperr.Validation(fe)
+			}
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.Format == "" {
+		doc.Format = models.FormatHTML
+	}
+	return doc.SEO, nil
 }
 
 // PUT /api/posts/:postId
+// replaces every editable field, omitted ones are cleared
 func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
 	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid post ID",
-		})
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid post ID"))
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r, h.requireIfMatch)
+	if !ok {
 		return
 	}
 
 	// from client
-	var req struct {
-		Title     string `json:"title"`
-		Text      string `json:"text"`
-		ImgURL    string `json:"imgUrl"`
-		Published bool   `json:"published"`
+	var req PostDocument
+	if !decodeJSONOnly(w, r, &req) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		// can use any instead of interface{}
-		respondJSON(w, http.StatusBadRequest, map[string]any{
-			"success": false,
-			"message": "Invalid request body",
-		})
+	newVersion, err := h.replacePost(r.Context(), user, postID, version, &req)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(newVersion))
+	// v2 writes answer with what was written
+	if isV2(r) {
+		updatedPost, err := h.postRepo.FindByIDWithAuthor(r.Context(), postID)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		respondData(w, http.StatusOK, views.NewPost(updatedPost))
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"message": "Post updated",
+		"version": newVersion,
+	})
+}
+
+// validates and renders req and writes it over every editable field of the
+// post, for UpdatePost and the updatePost mutation
+func (h *PostHandler) replacePost(ctx context.Context, user *models.User, postID primitive.ObjectID, version *int64, req *PostDocument) (int64, error) {
+	// read first to check the author, and to tell whether this write is
+	// what publishes the post
+	before, err := h.postRepo.FindByID(ctx, postID)
+	if err != nil {
+		return 0, err
+	}
+	if err := content.CanModify(user, before.Author); err != nil {
+		return 0, err
+	}
+
+	postSEO, err := validatePost(req)
+	if err != nil {
+		return 0, err
+	}
+
+	// re-render on every write so the stored html never goes stale
+	rendered, err := h.renderer.Post(req.Format, req.Text)
+	if err != nil {
+		return 0, problem.New(http.StatusInternalServerError, "", "Error rendering post")
+	}
+
 	update := bson.M{
 		"title":     req.Title,
 		"text":      req.Text,
+		"format":    req.Format,
+		"html":      rendered.HTML,
+		"toc":       rendered.TOC,
+		"tags":      models.NormalizeTags(req.Tags),
 		"imgUrl":    req.ImgURL,
+		"mediaId":   nil,
+		"seo":       postSEO,
 		"published": req.Published,
 	}
 
-	if err := h.postRepo.Update(r.Context(), postID, update); err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]any{
-			"success": false,
-			"message": err.Error(),
-		})
-		return
+	if req.MediaID != "" {
+		m, err := h.resolveMedia(ctx, user, req.MediaID)
+		if err != nil {
+			return 0, err
+		}
+		update["mediaId"] = m.ID
+		update["imgUrl"] = m.URL
 	}
 
-	respondJSON(w, http.StatusOK, map[string]any{
-		"success": true,
-		"message": "Post updated",
-	})
+	// the version check and the write are one atomic update
+	newVersion, err := h.postRepo.Update(ctx, postID, version, update)
+	if err != nil {
+		return 0, err
+	}
+	if updated, err := h.postRepo.FindByIDWithAuthor(ctx, postID); err == nil {
+		h.publishUpdate(ctx, updated, before.Published)
+	}
+	return newVersion, nil
+}
+
+// post.updated for a post that was just written, and post.published when
+// the write published it
+func (h *PostHandler) publishUpdate(ctx context.Context, post *models.PostWithAuthor, wasPublished bool) {
+	h.ensureRendered(post)
+	view := views.NewPost(post)
+	publish(ctx, h.events, models.EventPostUpdated, view)
+	if post.Published && !wasPublished {
+		publish(ctx, h.events, models.EventPostPublished, view)
+	}
 }
 
-// PATCH /api/posts/:postId (update published status)
+// PATCH /api/posts/:postId
+// takes a merge patch or a JSON patch over the post's editable fields and
+// writes only the fields it changes
 func (h *PostHandler) PatchPost(w http.ResponseWriter, r *http.Request) {
-	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
+	user, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid post ID",
-		})
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
 		return
 	}
 
-	var req struct {
-		Published bool `json:"published"`
+	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid post ID"))
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid request body",
-		})
+	version, ok := ifMatchVersion(w, r, h.requireIfMatch)
+	if !ok {
 		return
 	}
 
-	update := bson.M{"published": req.Published}
+	post, err := h.postRepo.FindByID(r.Context(), postID)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if err := content.CanModify(user, post.Author); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if version != nil && *version != post.Version {
+		respondError(w, r, repository.ErrVersionMismatch)
+		return
+	}
 
-	if err := h.postRepo.Update(r.Context(), postID, update); err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		})
+	current := newPostDocument(post)
+	var next PostDocument
+	if !applyPatch(w, r, "post", current, postPatchable, &next) {
+		return
+	}
+	postSEO, err := validatePost(&next)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
+	update := bson.M{}
+	if next.Title != current.Title {
+		update["title"] = next.Title
+	}
+	if next.Text != current.Text || next.Format != current.Format {
+		rendered, err := h.renderer.Post(next.Format, next.Text)
+		if err != nil {
+			respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error rendering post"))
+			return
+		}
+		update["text"] = next.Text
+		update["format"] = next.Format
+		update["html"] = rendered.HTML
+		update["toc"] = rendered.TOC
+	}
+	if tags := models.NormalizeTags(next.Tags); !slices.Equal(tags, models.NormalizeTags(post.Tags)) {
+		update["tags"] = tags
+	}
+	if !reflect.DeepEqual(postSEO, post.SEO) {
+		update["seo"] = postSEO
+	}
+	if next.Published != current.Published {
+		update["published"] = next.Published
+	}
+
+	switch {
+	case next.MediaID == current.MediaID:
+		// an image url set by hand replaces the upload
+		if next.ImgURL != current.ImgURL && post.MediaID != nil {
+			update["mediaId"] = nil
+		}
+	case next.MediaID == "":
+		update["mediaId"] = nil
+	default:
+		m, err := h.resolveMedia(r.Context(), user, next.MediaID)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		update["mediaId"] = m.ID
+		next.ImgURL = m.URL
+	}
+	if next.ImgURL != current.ImgURL {
+		update["imgUrl"] = next.ImgURL
+	}
+
+	newVersion := post.Version
+	if len(update) > 0 {
+		// against the version the patch was applied to, so a write since
+		// then fails instead of being overwritten
+		newVersion, err = h.postRepo.Update(r.Context(), postID, &post.Version, update)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
 	// fetch updated post
 	updatedPost, err := h.postRepo.FindByIDWithAuthor(r.Context(), postID)
 	if err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"message": "Post not found after update",
-		})
+		respondError(w, r, err)
 		return
 	}
+	if len(update) > 0 {
+		h.publishUpdate(r.Context(), updatedPost, post.Published)
+	}
 
+	w.Header().Set("ETag", versionETag(newVersion))
+	if isV2(r) {
+		respondData(w, http.StatusOK, views.NewPost(updatedPost))
+		return
+	}
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success":     true,
 		"updatedPost": updatedPost,
@@ -233,48 +496,91 @@ func (h *PostHandler) PatchPost(w http.ResponseWriter, r *http.Request) {
 }
 
 // DELETE /api/posts/:postId
+// moves the post to the trash, see TrashHandler for getting it back
 func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
 	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid post ID",
-		})
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid post ID"))
 		return
 	}
 
-	if err := h.postRepo.Delete(r.Context(), postID); err != nil {
-		respondJSON(w, http.StatusNotFound, map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		})
+	version, ok := ifMatchVersion(w, r, h.requireIfMatch)
+	if !ok {
 		return
 	}
 
+	if err := h.trashPost(r.Context(), user, postID, version); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if isV2(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
-		"message": "Post deleted.",
+		"message": "Post moved to trash.",
 		"id":      postID.Hex(),
 	})
 }
 
+// for DeletePost and the deletePost mutation
+func (h *PostHandler) trashPost(ctx context.Context, user *models.User, postID primitive.ObjectID, version *int64) error {
+	post, err := h.postRepo.FindByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if err := content.CanModify(user, post.Author); err != nil {
+		return err
+	}
+
+	// its comments are hidden with it and come back when it's restored
+	err = h.content.TrashPost(ctx, postID, user.ID, version)
+	if errors.Is(err, repository.ErrVersionMismatch) || errors.Is(err, content.ErrPostNotFound) {
+		return err
+	}
+	if err != nil {
+		log.Printf("deleting post %s: %v", postID.Hex(), err)
+		return problem.New(http.StatusInternalServerError, "", "Error deleting post")
+	}
+	publish(ctx, h.events, models.EventPostDeleted, map[string]string{"id": postID.Hex()})
+	return nil
+}
+
 // GET /api/users/:userId/posts
 func (h *PostHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 	userID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "userId"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid user ID",
-		})
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid user ID"))
 		return
 	}
 
 	posts, err := h.postRepo.FindByAuthor(r.Context(), userID)
 	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error fetching posts",
-		})
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error fetching posts"))
+		return
+	}
+
+	// one list, each post says whether it's published
+	if isV2(r) {
+		// an unknown user just has no posts
+		author, err := h.userRepo.FindByID(r.Context(), userID)
+		if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+			respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error fetching posts"))
+			return
+		}
+		out := make([]views.Post, len(posts))
+		for i := range posts {
+			out[i] = views.NewPostBy(&posts[i], author)
+		}
+		respondData(w, http.StatusOK, out)
 		return
 	}
 
@@ -296,3 +602,73 @@ func (h *PostHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 }
+
+// looks up an uploaded image for a post, user must own it
+func (h *PostHandler) resolveMedia(ctx context.Context, user *models.User, id string) (*models.Media, error) {
+	mediaID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, apperr.Field("mediaId", "invalid", "Invalid media ID")
+	}
+
+	m, err := h.mediaRepo.FindByID(ctx, mediaID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, apperr.Field("mediaId", "not_found", "Media not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Owner != user.ID {
+		return nil, problem.New(http.StatusForbidden, "media_not_owned", "Media belongs to another user")
+	}
+
+	return m, nil
+}
+
+// GET /api/highlight.css (styles for highlighted code in rendered posts)
+func (h *PostHandler) HighlightCSS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write([]byte(render.HighlightCSS()))
+}
+
+// posts written before rendering existed have no stored html, render them on read
+func (h *PostHandler) ensureRendered(post *models.PostWithAuthor) {
+	if post.HTML != "" || post.Text == "" {
+		return
+	}
+	if rendered, err := h.renderer.Post(post.Format, post.Text); err == nil {
+		post.HTML = rendered.HTML
+		post.TOC = rendered.TOC
+	}
+}
+
+// ensureRendered for a post read without its author
+func (h *PostHandler) ensurePostRendered(post *models.Post) {
+	if post.HTML != "" || post.Text == "" {
+		return
+	}
+	if rendered, err := h.renderer.Post(post.Format, post.Text); err == nil {
+		post.HTML = rendered.HTML
+		post.TOC = rendered.TOC
+	}
+}
+
+// trims author SEO overrides, nil when nothing is set
+func normalizeSEO(in *models.SEO) *models.SEO {
+	if in == nil {
+		return nil
+	}
+
+	out := models.SEO{
+		Title:        strings.TrimSpace(in.Title),
+		Description:  strings.TrimSpace(in.Description),
+		CanonicalURL: strings.TrimSpace(in.CanonicalURL),
+		Image:        strings.TrimSpace(in.Image),
+		NoIndex:      in.NoIndex,
+	}
+	if out == (models.SEO{}) {
+		return nil
+	}
+	return &out
+}
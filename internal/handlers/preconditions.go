@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kurtgray/blog-api-go/internal/apiversion"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+)
+
+// strong validator for a post or comment at version, what writes answer
+// with and If-Match sends
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// strong validator for the body a read of a post or comment at version
+// sends, the v1 and v2 bodies and what the version doesn't cover, like the
+// comment count or the author's name, each get their own
+// If-Match goes by the version in front
+func representationETag(version int64, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.FormatInt(version, 10) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// writes the read of a post or comment at version, v1 as is or v2 in its
+// envelope, tagged with representationETag or a 304 when If-None-Match
+// already has it
+func respondRepresentation(w http.ResponseWriter, r *http.Request, version int64, v1, v2 interface{}) {
+	contentType, payload := "application/json", v1
+	if isV2(r) {
+		contentType, payload = apiversion.MediaTypeV2, map[string]interface{}{"data": v2}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	// the newline respondJSON's encoder ends with
+	body = append(body, '\n')
+
+	if notModified(w, r, representationETag(version, body)) {
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// sets the ETag and answers 304 when If-None-Match already has it
+// returns true when the response has been written
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	for _, tag := range etagList(r.Header.Get("If-None-Match")) {
+		// weak comparison, W/"3" matches "3"
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// the version a write's If-Match names, nil for * or, when not required,
+// no header at all
+// ok is false once a 428, 412 or 400 has been written
+func ifMatchVersion(w http.ResponseWriter, r *http.Request, required bool) (version *int64, ok bool) {
+	tags := etagList(r.Header.Get("If-Match"))
+	switch {
+	case len(tags) == 0 && required:
+		respondError(w, r, problem.New(http.StatusPreconditionRequired, "if_match_required", "If-Match header is required, send the ETag you last read."))
+		return nil, false
+	case len(tags) == 0, len(tags) == 1 && tags[0] == "*":
+		return nil, true
+	case len(tags) > 1:
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_if_match", "If-Match must be a single ETag or *"))
+		return nil, false
+	}
+
+	// weak tags and tags this server never issued can't match, a read's
+	// tag names its version in front
+	tag := tags[0]
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		respondError(w, r, repository.ErrVersionMismatch)
+		return nil, false
+	}
+	num, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+	v, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		respondError(w, r, repository.ErrVersionMismatch)
+		return nil, false
+	}
+	return &v, true
+}
+
+// ifMatchVersion for GraphQL and gRPC writes, which send the version as an
+// argument rather than a header
+func writeVersion(version *int64, required bool) (*int64, error) {
+	if version == nil && required {
+		return nil, problem.New(http.StatusPreconditionRequired, "version_required", "version is required, send the version you last read.")
+	}
+	return version, nil
+}
+
+func etagList(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/privacy"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PrivacyHandler struct {
+	privacy *privacy.Service
+}
+
+func NewPrivacyHandler(p *privacy.Service) *PrivacyHandler {
+	return &PrivacyHandler{privacy: p}
+}
+
+// GET /api/users/me/export
+// 202 while the archive is being built, poll until 200 with a downloadUrl
+func (h *PrivacyHandler) Export(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	export, err := h.privacy.RequestExport(r.Context(), user)
+	if err != nil {
+		log.Printf("data export for %s: %v", user.ID.Hex(), err)
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error starting export"))
+		return
+	}
+
+	if export.Status != models.ExportReady {
+		w.Header().Set("Retry-After", "10")
+		respondJSON(w, http.StatusAccepted, map[string]interface{}{
+			"success": true,
+			"export":  export,
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":     true,
+		"export":      export,
+		"downloadUrl": "/api/users/me/export/" + export.ID.Hex(),
+	})
+}
+
+// GET /api/users/me/export/:exportId
+func (h *PrivacyHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	exportID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "exportId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid export ID"))
+		return
+	}
+
+	export, f, err := h.privacy.OpenExport(r.Context(), user, exportID)
+	switch {
+	case errors.Is(err, privacy.ErrExportNotReady):
+		respondError(w, r, err)
+		return
+	case errors.Is(err, privacy.ErrExportExpired):
+		respondError(w, r, problem.New(http.StatusGone, "export_expired", "Export has expired, request a new one"))
+		return
+	case err != nil:
+		respondError(w, r, problem.New(http.StatusNotFound, "export_not_found", "Export not found"))
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "data-export-"+export.ID.Hex()+".zip"))
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, "", *export.CompletedAt, f)
+}
+
+// body of POST /api/users/me/erasure
+type ErasureBody struct {
+	Reason string `json:"reason" validate:"max=1000" label:"Reason"`
+}
+
+// POST /api/users/me/erasure
+func (h *PrivacyHandler) RequestErasure(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	// body is optional
+	var req ErasureBody
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+
+	erasure, err := h.privacy.RequestErasure(r.Context(), user, reason)
+	switch {
+	case errors.Is(err, privacy.ErrErasurePending):
+		respondError(w, r, problem.From(err).With("request", erasure))
+		return
+	case errors.Is(err, privacy.ErrProtectedUser):
+		respondError(w, r, err)
+		return
+	case err != nil:
+		log.Printf("erasure request for %s: %v", user.ID.Hex(), err)
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error requesting erasure"))
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"success": true,
+		"message": "Erasure requested, an admin will review it.",
+		"request": erasure,
+	})
+}
+
+// DELETE /api/users/me/erasure
+func (h *PrivacyHandler) CancelErasure(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	err = h.privacy.CancelErasure(r.Context(), user)
+	if errors.Is(err, privacy.ErrNoErasure) || errors.Is(err, repository.ErrErasureNotPending) {
+		respondError(w, r, problem.New(http.StatusNotFound, "no_pending_erasure", "No pending erasure request"))
+		return
+	}
+	if err != nil {
+		log.Printf("cancel erasure for %s: %v", user.ID.Hex(), err)
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error cancelling erasure"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Erasure request cancelled.",
+	})
+}
+
+// GET /api/admin/erasure-requests
+func (h *PrivacyHandler) PendingErasures(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	reqs, err := h.privacy.PendingErasures(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error fetching erasure requests"))
+		return
+	}
+	if reqs == nil {
+		reqs = []models.ErasureRequest{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"requests": reqs,
+	})
+}
+
+// POST /api/admin/erasure-requests/:requestId/approve
+func (h *PrivacyHandler) ApproveErasure(w http.ResponseWriter, r *http.Request) {
+	admin, id, ok := h.erasureRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.privacy.ApproveErasure(r.Context(), admin, id)
+	if err != nil {
+		h.respondErasureError(w, r, id, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "User erased.",
+		"result":  result,
+	})
+}
+
+// POST /api/admin/erasure-requests/:requestId/reject
+func (h *PrivacyHandler) RejectErasure(w http.ResponseWriter, r *http.Request) {
+	admin, id, ok := h.erasureRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.privacy.RejectErasure(r.Context(), admin, id); err != nil {
+		h.respondErasureError(w, r, id, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Erasure request rejected.",
+	})
+}
+
+func (h *PrivacyHandler) erasureRequest(w http.ResponseWriter, r *http.Request) (*models.User, primitive.ObjectID, bool) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return nil, primitive.NilObjectID, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "requestId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid request ID"))
+		return nil, primitive.NilObjectID, false
+	}
+	return admin, id, true
+}
+
+func (h *PrivacyHandler) respondErasureError(w http.ResponseWriter, r *http.Request, id primitive.ObjectID, err error) {
+	switch {
+	case errors.Is(err, privacy.ErrNoErasure), errors.Is(err, repository.ErrErasureNotPending):
+		respondError(w, r, problem.New(http.StatusConflict, "erasure_not_pending", "Erasure request is not pending"))
+	case errors.Is(err, privacy.ErrProtectedUser), errors.Is(err, apperr.ErrNotFound):
+		respondError(w, r, err)
+	default:
+		log.Printf("erasure request %s: %v", id.Hex(), err)
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error processing erasure request"))
+	}
+}
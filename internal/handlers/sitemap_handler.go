@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/httpcache"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/seo"
+)
+
+type SitemapHandler struct {
+	postRepo repository.PostRepository
+	site     config.SiteConfig
+	// without the html site there are no post pages to list
+	webEnabled bool
+}
+
+func NewSitemapHandler(postRepo repository.PostRepository, site config.SiteConfig, webEnabled bool) *SitemapHandler {
+	site.BaseURL = strings.TrimRight(site.BaseURL, "/")
+	return &SitemapHandler{
+		postRepo:   postRepo,
+		site:       site,
+		webEnabled: webEnabled,
+	}
+}
+
+// GET /sitemap.xml, a sitemap index once there are more URLs than one file may hold
+func (h *SitemapHandler) Sitemap(w http.ResponseWriter, r *http.Request) {
+	total, ok := h.countURLs(w, r)
+	if !ok {
+		return
+	}
+
+	if total <= seo.MaxSitemapURLs {
+		h.servePage(w, r, 1)
+		return
+	}
+
+	pages := (total + seo.MaxSitemapURLs - 1) / seo.MaxSitemapURLs
+	sitemaps := make([]seo.URL, 0, pages)
+	for page := int64(1); page <= pages; page++ {
+		sitemaps = append(sitemaps, seo.URL{Loc: h.site.BaseURL + "/sitemap-" + strconv.FormatInt(page, 10) + ".xml"})
+	}
+
+	body, err := seo.Index(sitemaps)
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error generating sitemap"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	httpcache.Serve(w, r, seo.SitemapContentType, time.Time{}, body)
+}
+
+// GET /sitemap-:page.xml
+func (h *SitemapHandler) SitemapPage(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.ParseInt(chi.URLParam(r, "page"), 10, 64)
+	if err != nil || page < 1 {
+		respondError(w, r, problem.New(http.StatusNotFound, "sitemap_not_found", "Sitemap not found"))
+		return
+	}
+
+	total, ok := h.countURLs(w, r)
+	if !ok {
+		return
+	}
+	if (page-1)*seo.MaxSitemapURLs >= total {
+		respondError(w, r, problem.New(http.StatusNotFound, "sitemap_not_found", "Sitemap not found"))
+		return
+	}
+
+	h.servePage(w, r, page)
+}
+
+// GET /robots.txt
+func (h *SitemapHandler) Robots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write([]byte(seo.Robots(h.site.BaseURL)))
+}
+
+// indexable posts plus the home page
+func (h *SitemapHandler) countURLs(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if !h.webEnabled {
+		return 1, true
+	}
+	count, err := h.postRepo.CountIndexable(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error generating sitemap"))
+		return 0, false
+	}
+	return count + 1, true
+}
+
+// page 1 starts with the home page, posts fill the rest in id order
+func (h *SitemapHandler) servePage(w http.ResponseWriter, r *http.Request, page int64) {
+	start := (page - 1) * seo.MaxSitemapURLs
+	limit := int64(seo.MaxSitemapURLs)
+
+	var urls []seo.URL
+	if page == 1 {
+		urls = append(urls, seo.URL{Loc: h.site.BaseURL + "/"})
+		limit--
+	} else {
+		start--
+	}
+
+	var refs []models.PostRef
+	if h.webEnabled {
+		var err error
+		if refs, err = h.postRepo.FindIndexable(r.Context(), start, limit); err != nil {
+			respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error generating sitemap"))
+			return
+		}
+	}
+
+	var modified time.Time
+	for _, ref := range refs {
+		lastMod := latestTime(ref.UpdatedAt, ref.Timestamp)
+		if lastMod.After(modified) {
+			modified = lastMod
+		}
+		urls = append(urls, seo.URL{
+			Loc:          h.site.BaseURL + "/posts/" + ref.ID.Hex(),
+			LastModified: lastMod,
+		})
+	}
+	if page == 1 {
+		urls[0].LastModified = modified
+	}
+
+	body, err := seo.URLSet(urls)
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error generating sitemap"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	httpcache.Serve(w, r, seo.SitemapContentType, modified, body)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/content"
+	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TrashHandler struct {
+	content *content.Service
+	// how long items stay in the trash before the purge job removes them
+	retention time.Duration
+}
+
+func NewTrashHandler(content *content.Service, retention time.Duration) *TrashHandler {
+	return &TrashHandler{content: content, retention: retention}
+}
+
+// a post in the trash and when the purge job removes it
+type TrashedPost struct {
+	models.Post
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+// a comment in the trash and when the purge job removes it
+type TrashedComment struct {
+	models.Comment
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+// GET /api/trash
+// admins see everything in the trash, everyone else what they wrote
+func (h *TrashHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	var author *primitive.ObjectID
+	if !user.Admin {
+		author = &user.ID
+	}
+	posts, comments, err := h.content.Trash(r.Context(), author)
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error fetching trash"))
+		return
+	}
+
+	trashedPosts := make([]TrashedPost, len(posts))
+	for i, p := range posts {
+		trashedPosts[i] = TrashedPost{Post: p, PurgeAt: p.DeletedAt.Add(h.retention)}
+	}
+	trashedComments := make([]TrashedComment, len(comments))
+	for i, c := range comments {
+		trashedComments[i] = TrashedComment{Comment: c, PurgeAt: c.DeletedAt.Add(h.retention)}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"posts":    trashedPosts,
+		"comments": trashedComments,
+	})
+}
+
+// POST /api/trash/posts/:postId/restore
+func (h *TrashHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid post ID"))
+		return
+	}
+
+	// someone else's trash looks the same as an empty one
+	post, err := h.content.TrashedPost(r.Context(), postID)
+	if err == nil && !user.Admin && post.Author != user.ID {
+		err = content.ErrPostNotFound
+	}
+	if err == nil {
+		err = h.content.RestorePost(r.Context(), postID)
+	}
+	if errors.Is(err, content.ErrPostNotFound) {
+		respondError(w, r, problem.New(http.StatusNotFound, "post_not_found", "Post not found in trash"))
+		return
+	}
+	if err != nil {
+		log.Printf("restoring post %s: %v", postID.Hex(), err)
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error restoring post"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Post restored.",
+		"id":      postID.Hex(),
+	})
+}
+
+// POST /api/trash/comments/:commentId/restore
+func (h *TrashHandler) RestoreComment(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return
+	}
+
+	commentID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "commentId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid comment ID"))
+		return
+	}
+
+	comment, err := h.content.TrashedComment(r.Context(), commentID)
+	if err == nil && !user.Admin && comment.Author != user.ID {
+		err = content.ErrCommentNotFound
+	}
+	if err == nil {
+		err = h.content.RestoreComment(r.Context(), commentID)
+	}
+	switch {
+	case errors.Is(err, content.ErrCommentNotFound), errors.Is(err, content.ErrPostNotFound):
+		respondError(w, r, problem.New(http.StatusNotFound, "comment_not_found", "Comment not found in trash"))
+		return
+	case errors.Is(err, content.ErrPostTrashed):
+		respondError(w, r, err)
+		return
+	case err != nil:
+		log.Printf("restoring comment %s: %v", commentID.Hex(), err)
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error restoring comment"))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Comment restored.",
+		"id":      commentID.Hex(),
+	})
+}
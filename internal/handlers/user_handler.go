@@ -1,78 +1,136 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/kurtgray/blog-api-go/internal/apiversion"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/metrics"
 	"github.com/kurtgray/blog-api-go/internal/middleware"
 	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
 	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/validate"
+	"github.com/kurtgray/blog-api-go/internal/views"
+	"github.com/kurtgray/blog-api-go/internal/webhooks"
 )
 
+var errUsernameTaken = &apperr.Error{
+	Kind:    apperr.ErrConflict,
+	Code:    "username_taken",
+	Message: "username is already taken",
+	Fields:  []apperr.FieldError{{Field: "username", Code: "taken", Message: "Username is already taken."}},
+}
+
 type UserHandler struct {
 	userRepo    repository.UserRepository
 	authService *middleware.AuthService
+	metrics     *metrics.Metrics
+	events      *webhooks.Service
 }
 
-func NewUserHandler(userRepo repository.UserRepository, authService *middleware.AuthService) *UserHandler {
+func NewUserHandler(userRepo repository.UserRepository, authService *middleware.AuthService, m *metrics.Metrics, events *webhooks.Service) *UserHandler {
 	return &UserHandler{
 		userRepo:    userRepo,
 		authService: authService,
+		metrics:     m,
+		events:      events,
+	}
+}
+
+// body of POST /api/users
+type RegisterRequest struct {
+	Fname    string `json:"fname" validate:"required,max=50" label:"First name"`
+	Lname    string `json:"lname" validate:"required,max=50" label:"Last name"`
+	Username string `json:"username" validate:"required,min=3,max=30,pattern=username" label:"Username"`
+	// bcrypt ignores anything past 72 bytes
+	Password string `json:"password" validate:"required,min=6,max=72" label:"Password"`
+}
+
+// body of POST /api/users/login, a username and password or a google
+// profile
+type LoginRequest struct {
+	Username string `json:"username" validate:"max=100" label:"Username"`
+	Password string `json:"password" validate:"max=72" label:"Password"`
+	GoogleID string `json:"googleId,omitempty" validate:"max=255" label:"Google ID"`
+	// a GoogleProfile, read leniently as Google sends more than it needs
+	Profile json.RawMessage `json:"profile,omitempty"`
+}
+
+// the part of a Google profile a first sign-in registers with, the rest,
+// like email and picture, is ignored
+type GoogleProfile struct {
+	Sub        string `json:"sub" validate:"max=255"`
+	Name       string `json:"name" validate:"max=100"`
+	GivenName  string `json:"given_name" validate:"max=50"`
+	FamilyName string `json:"family_name" validate:"max=50"`
+}
+
+// the request's profile, unknown fields and all, checked under "profile"
+func (req *LoginRequest) googleProfile() (*GoogleProfile, error) {
+	var body struct {
+		Profile GoogleProfile `json:"profile"`
 	}
+	if len(req.Profile) > 0 {
+		if err := json.Unmarshal(req.Profile, &body.Profile); err != nil {
+			return nil, apperr.Field("profile", "invalid", "Profile must be a JSON object of strings.")
+		}
+	}
+	if err := validate.Struct(&body); err != nil {
+		return nil, err
+	}
+	return &body.Profile, nil
 }
 
 // POST /api/users (user registration)
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-		Fname    string `json:"fname"`
-		Lname    string `json:"lname"`
-	}
+	var req RegisterRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid request body",
-		})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	if err := h.validateUserInput(req.Username, req.Password, req.Fname, req.Lname); err !=
-		nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": err.Error(),
-		})
+	user, err := h.register(r.Context(), &req)
+	if err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	existingUser, err := h.userRepo.FindByUsername(r.Context(), req.Username)
-	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Database error",
-		})
+	if isV2(r) {
+		respondData(w, http.StatusCreated, views.NewUser(user))
 		return
 	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"message": "User created successfully",
+		"user": map[string]interface{}{
+			"id":         user.ID.Hex(),
+			"fname":      user.Fname,
+			"username":   user.Username,
+			"canPublish": user.CanPublish,
+			"admin":      user.Admin,
+		},
+	})
+}
 
-	if existingUser != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Username is already taken.",
-			"field":   "usernameTaken",
-		})
-		return
+// creates an account from a validated registration, for CreateUser and the
+// createUser mutation
+func (h *UserHandler) register(ctx context.Context, req *RegisterRequest) (*models.User, error) {
+	_, err := h.userRepo.FindByUsername(ctx, req.Username)
+	if err == nil {
+		return nil, errUsernameTaken
+	}
+	if !errors.Is(err, apperr.ErrNotFound) {
+		return nil, problem.New(http.StatusInternalServerError, "", "Database error")
 	}
 
 	hashedPassword, err := h.authService.HashPassword(req.Password)
 	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error creating user",
-		})
-		return
+		return nil, problem.New(http.StatusInternalServerError, "", "Error creating user")
 	}
 
 	user := &models.User{
@@ -84,46 +142,23 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		CanPublish: false,
 	}
 
-	if err := h.userRepo.Create(r.Context(), user); err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error saving user",
-		})
-		return
+	if err := h.userRepo.Create(ctx, user); err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Error saving user")
 	}
-
-	respondJSON(w, http.StatusCreated, map[string]interface{}{
-		"success": true,
-		"message": "User created successfully",
-		"user": map[string]interface{}{
-			"id":         user.ID.Hex(),
-			"fname":      user.Fname,
-			"username":   user.Username,
-			"canPublish": user.CanPublish,
-			"admin":      user.Admin,
-		},
-	})
+	publish(ctx, h.events, models.EventUserRegistered, views.NewUser(user))
+	return user, nil
 }
 
 // POST /api/users/login
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-		GoogleID string `json:"googleId,omitempty"`
-		Profile  struct {
-			Sub        string `json:"sub"`
-			Name       string `json:"name"`
-			GivenName  string `json:"given_name"`
-			FamilyName string `json:"family_name"`
-		} `json:"profile,omitempty"`
-	}
+	var req LoginRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondJSON(w, http.StatusBadRequest, map[string]interface{}{
-			"success": false,
-			"message": "Invalid request body",
-		})
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	// a username is only needed without google
+	if req.GoogleID == "" && strings.TrimSpace(req.Username) == "" {
+		respondError(w, r, apperr.Field("username", "required", "Username must be specified."))
 		return
 	}
 
@@ -132,59 +167,38 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	// Google OAuth
 	if req.GoogleID != "" {
-		user, err = h.userRepo.FindByGoogleID(r.Context(), req.GoogleID)
+		profile, err := req.googleProfile()
 		if err != nil {
-			respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-				"success": false,
-				"message": "Database error",
-			})
+			respondError(w, r, err)
+			return
+		}
+		user, err = h.userRepo.FindByGoogleID(r.Context(), req.GoogleID)
+		if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+			respondError(w, r, problem.New(http.StatusInternalServerError, "", "Database error"))
 			return
 		}
 
 		// create new user if doesn't exist
-		if user == nil {
+		if errors.Is(err, apperr.ErrNotFound) {
 			user = &models.User{
-				GoogleID:   req.Profile.Sub,
-				Username:   req.Profile.Name,
-				Fname:      req.Profile.GivenName,
-				Lname:      req.Profile.FamilyName,
+				GoogleID:   profile.Sub,
+				Username:   profile.Name,
+				Fname:      profile.GivenName,
+				Lname:      profile.FamilyName,
 				Admin:      false,
 				CanPublish: false,
 			}
 			if err := h.userRepo.Create(r.Context(), user); err != nil {
-				respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-					"success": false,
-					"message": "Error creating user",
-				})
+				respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error creating user"))
 				return
 			}
+			publish(r.Context(), h.events, models.EventUserRegistered, views.NewUser(user))
 		}
 	} else {
 		// non-oauth login
-		user, err = h.userRepo.FindByUsername(r.Context(), req.Username)
+		user, err = h.checkPassword(r.Context(), req.Username, req.Password)
 		if err != nil {
-			respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-				"success": false,
-				"message": "Database error",
-			})
-			return
-		}
-
-		if user == nil {
-			respondJSON(w, http.StatusUnauthorized, map[string]interface{}{
-				"success": false,
-				"message": "User does not exist",
-				"field":   "username",
-			})
-			return
-		}
-
-		if err := h.authService.ComparePassword(user.Password, req.Password); err != nil {
-			respondJSON(w, http.StatusUnauthorized, map[string]interface{}{
-				"success": false,
-				"message": "Password does not match",
-				"field":   "password",
-			})
+			respondError(w, r, err)
 			return
 		}
 	}
@@ -192,13 +206,20 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// generate jwt
 	token, err := h.authService.GenerateToken(user)
 	if err != nil {
-		respondJSON(w, http.StatusInternalServerError, map[string]interface{}{
-			"success": false,
-			"message": "Error generating token",
-		})
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error generating token"))
 		return
 	}
 
+	loginMethod := "password"
+	if req.GoogleID != "" {
+		loginMethod = "google"
+	}
+	h.metrics.ObserveLogin(loginMethod, true)
+
+	if isV2(r) {
+		respondData(w, http.StatusOK, views.Session{Token: token, User: views.NewUser(user)})
+		return
+	}
 	// return token w. user info
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -215,18 +236,61 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// the user username names if password is theirs, failures are counted, for
+// Login and the login mutation
+func (h *UserHandler) checkPassword(ctx context.Context, username, password string) (*models.User, error) {
+	user, err := h.userRepo.FindByUsername(ctx, username)
+	if errors.Is(err, apperr.ErrNotFound) {
+		h.metrics.ObserveLogin("password", false)
+		return nil, problem.New(http.StatusUnauthorized, "unknown_user", "User does not exist").With("field", "username")
+	}
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "", "Database error")
+	}
+
+	if err := h.authService.ComparePassword(user.Password, password); err != nil {
+		h.metrics.ObserveLogin("password", false)
+		return nil, problem.New(http.StatusUnauthorized, "wrong_password", "Password does not match").With("field", "password")
+	}
+	return user, nil
+}
+
+// a token for username, for the login mutation and the Login RPC, which
+// have no Google sign-in
+func (h *UserHandler) passwordLogin(ctx context.Context, username, password string) (*models.User, string, error) {
+	req := LoginRequest{Username: username, Password: password}
+	if err := validate.Struct(&req); err != nil {
+		return nil, "", err
+	}
+	if strings.TrimSpace(username) == "" {
+		return nil, "", apperr.Field("username", "required", "Username must be specified.")
+	}
+
+	user, err := h.checkPassword(ctx, username, password)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := h.authService.GenerateToken(user)
+	if err != nil {
+		return nil, "", problem.New(http.StatusInternalServerError, "", "Error generating token")
+	}
+	h.metrics.ObserveLogin("password", true)
+	return user, token, nil
+}
+
 // GET /api/users (refresh/verify token)
 func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	// User is already in context from auth middleware
 	user, err := middleware.GetUserFromContext(r.Context())
 	if err != nil {
-		respondJSON(w, http.StatusUnauthorized, map[string]interface{}{
-			"success": false,
-			"message": "Unauthorized",
-		})
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
 		return
 	}
 
+	if isV2(r) {
+		respondData(w, http.StatusOK, views.NewUser(user))
+		return
+	}
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
 		"user": map[string]interface{}{
@@ -240,20 +304,18 @@ func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *UserHandler) validateUserInput(username, password, fname, lname string) error {
-	if len(strings.TrimSpace(fname)) == 0 {
-		return jsonError("First name must be specified.")
-	}
-	if len(strings.TrimSpace(lname)) == 0 {
-		return jsonError("Last name must be specified.")
-	}
-	if len(strings.TrimSpace(username)) == 0 {
-		return jsonError("Username must be specified.")
+// the current user if they are an admin, otherwise responds 401 or 403
+func requireAdmin(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := middleware.GetUserFromContext(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusUnauthorized, "", "Unauthorized"))
+		return nil, false
 	}
-	if len(password) < 6 {
-		return jsonError("Password must be at least 6 characters.")
+	if !user.Admin {
+		respondError(w, r, problem.New(http.StatusForbidden, "", "Forbidden"))
+		return nil, false
 	}
-	return nil
+	return user, true
 }
 
 func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
@@ -262,14 +324,19 @@ func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	json.NewEncoder(w).Encode(payload)
 }
 
-func jsonError(message string) error {
-	return &validationError{message: message}
+// served under API v2, where bodies are views in a {"data": ...} envelope
+func isV2(r *http.Request) bool {
+	return apiversion.From(r.Context()) == apiversion.V2
 }
 
-type validationError struct {
-	message string
+// writes data in the v2 envelope
+func respondData(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", apiversion.MediaTypeV2)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
 }
 
-func (e *validationError) Error() string {
-	return e.message
+// writes err as an error response, see problem.From for the mapping
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Write(w, r, err)
 }
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/webhooks"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// most deliveries a webhook's log lists at once
+const maxDeliveries = 100
+
+type WebhookHandler struct {
+	webhooks *webhooks.Service
+}
+
+func NewWebhookHandler(w *webhooks.Service) *WebhookHandler {
+	return &WebhookHandler{webhooks: w}
+}
+
+// body of POST /api/admin/webhooks and PUT /api/admin/webhooks/:webhookId
+type WebhookBody struct {
+	URL    string   `json:"url" validate:"required,url,max=2048" label:"URL"`
+	Events []string `json:"events" validate:"required,max=20" label:"Events"`
+	// generated when a webhook is created without one, kept when an update
+	// leaves it out
+	Secret      string `json:"secret,omitempty" validate:"min=16,max=200" label:"Secret"`
+	Description string `json:"description,omitempty" validate:"max=200" label:"Description"`
+	// true when left out
+	Active *bool `json:"active,omitempty"`
+}
+
+func (b *WebhookBody) webhook() *models.Webhook {
+	return &models.Webhook{
+		URL:         b.URL,
+		Events:      b.Events,
+		Secret:      b.Secret,
+		Description: b.Description,
+		Active:      b.Active == nil || *b.Active,
+	}
+}
+
+// GET /api/admin/webhooks
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	hooks, err := h.webhooks.Webhooks(r.Context())
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error fetching webhooks"))
+		return
+	}
+	if hooks == nil {
+		hooks = []models.Webhook{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":  true,
+		"webhooks": hooks,
+	})
+}
+
+// POST /api/admin/webhooks
+// the answer carries the signing secret, it isn't shown again
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookBody
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	hook := req.webhook()
+	secret, err := h.webhooks.Create(r.Context(), admin, hook)
+	if err != nil {
+		h.respondWebhookError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"webhook": hook,
+		"secret":  secret,
+	})
+}
+
+// GET /api/admin/webhooks/:webhookId
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	hook, err := h.webhooks.Webhook(r.Context(), id)
+	if err != nil {
+		h.respondWebhookError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"webhook": hook,
+	})
+}
+
+// PUT /api/admin/webhooks/:webhookId
+// active: true enables a webhook that was disabled and clears its failures
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookBody
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	hook, err := h.webhooks.Update(r.Context(), id, req.webhook())
+	if err != nil {
+		h.respondWebhookError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"webhook": hook,
+	})
+}
+
+// DELETE /api/admin/webhooks/:webhookId
+// its delivery log goes with it
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.webhooks.Delete(r.Context(), id); err != nil {
+		h.respondWebhookError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Webhook deleted.",
+		"id":      id.Hex(),
+	})
+}
+
+// GET /api/admin/webhooks/:webhookId/deliveries?limit=n
+// newest first
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := int64(maxDeliveries)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxDeliveries {
+			respondError(w, r, problem.New(http.StatusBadRequest, "", "limit must be a number from 1 to 100"))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.webhooks.Deliveries(r.Context(), id, limit)
+	if err != nil {
+		h.respondWebhookError(w, r, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"deliveries": deliveries,
+	})
+}
+
+// POST /api/admin/webhooks/:webhookId/deliveries/:deliveryId/redeliver
+// sends the delivery's payload again as a new delivery
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid delivery ID"))
+		return
+	}
+
+	delivery, err := h.webhooks.Redeliver(r.Context(), id, deliveryID)
+	if err != nil {
+		h.respondWebhookError(w, r, err)
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{
+		"success":  true,
+		"message":  "Redelivery queued.",
+		"delivery": delivery,
+	})
+}
+
+// the webhook id from the url, for admins only
+func webhookID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return primitive.NilObjectID, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		respondError(w, r, problem.New(http.StatusBadRequest, "invalid_id", "Invalid webhook ID"))
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+func (h *WebhookHandler) respondWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		respondError(w, r, err)
+		return
+	}
+	log.Printf("webhooks %s %s: %v", r.Method, r.URL.Path, err)
+	respondError(w, r, problem.New(http.StatusInternalServerError, "", "Error processing webhook"))
+}
+
+// queues event for the webhooks subscribed to it once the write it reports
+// is done, a failure is logged rather than failing that write
+// the request may be gone by then, so this doesn't share its cancellation
+func publish(ctx context.Context, events *webhooks.Service, event string, data any) {
+	if events == nil {
+		return
+	}
+	if err := events.Publish(context.WithoutCancel(ctx), event, data); err != nil {
+		log.Printf("publishing %s: %v", event, err)
+	}
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// tracks a background worker, the worker calls Beat on every loop iteration
+type Heartbeat struct {
+	last   atomic.Int64
+	maxAge time.Duration
+}
+
+// maxAge should comfortably exceed the worker's loop interval
+func NewHeartbeat(maxAge time.Duration) *Heartbeat {
+	hb := &Heartbeat{maxAge: maxAge}
+	hb.Beat()
+	return hb
+}
+
+func (hb *Heartbeat) Beat() {
+	hb.last.Store(time.Now().UnixNano())
+}
+
+// fails when the worker hasn't beaten within maxAge
+func (hb *Heartbeat) Check(ctx context.Context) error {
+	age := time.Since(time.Unix(0, hb.last.Load()))
+	if age > hb.maxAge {
+		return fmt.Errorf("no heartbeat for %s", age.Truncate(time.Second))
+	}
+	return nil
+}
+
+// fails when the filesystem holding path has less than minFree bytes available
+func DiskSpace(path string, minFree uint64) Check {
+	return func(ctx context.Context) error {
+		free, err := freeBytes(path)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("%d bytes free on %s, want at least %d", free, path, minFree)
+		}
+		return nil
+	}
+}
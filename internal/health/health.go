@@ -0,0 +1,148 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// a single dependency probe, nil means healthy
+type Check func(ctx context.Context) error
+
+// per-check timeout so one hung dependency can't stall the probe
+const checkTimeout = 2 * time.Second
+
+// holds liveness and readiness checks and the shutdown flag
+type Registry struct {
+	mu        sync.RWMutex
+	liveness  map[string]Check
+	readiness map[string]Check
+
+	shuttingDown atomic.Bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		liveness:  map[string]Check{},
+		readiness: map[string]Check{},
+	}
+}
+
+// registers a check run by /healthz, failing restarts the process
+// keep these to things a restart would fix
+func (reg *Registry) AddLiveness(name string, check Check) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.liveness[name] = check
+}
+
+// registers a check run by /readyz, failing takes the instance out of rotation
+func (reg *Registry) AddReadiness(name string, check Check) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.readiness[name] = check
+}
+
+// flips readiness to failing so load balancers drain us before shutdown
+func (reg *Registry) SetShuttingDown() {
+	reg.shuttingDown.Store(true)
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// what the probes respond with, /readyz leaves out Checks
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// GET /healthz
+func (reg *Registry) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	reg.mu.RLock()
+	checks := maps.Clone(reg.liveness)
+	reg.mu.RUnlock()
+
+	respond(w, run(r.Context(), checks))
+}
+
+// GET /readyz
+// public, so failing checks are logged and only the status is sent
+func (reg *Registry) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	reg.mu.RLock()
+	checks := maps.Clone(reg.readiness)
+	reg.mu.RUnlock()
+
+	rep := run(r.Context(), checks)
+	if reg.shuttingDown.Load() {
+		rep.Status = "fail"
+		rep.Checks["shutdown"] = CheckResult{Status: "fail", Error: "server is shutting down"}
+	}
+	for _, name := range slices.Sorted(maps.Keys(rep.Checks)) {
+		if c := rep.Checks[name]; c.Status != "ok" {
+			log.Printf("health: readiness check %s failed after %dms: %s", name, c.DurationMs, c.Error)
+		}
+	}
+
+	respond(w, Report{Status: rep.Status})
+}
+
+func respond(w http.ResponseWriter, rep Report) {
+	status := http.StatusOK
+	if rep.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rep)
+}
+
+// runs checks concurrently, overall status fails if any check fails
+func run(ctx context.Context, checks map[string]Check) Report {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(cctx)
+			results[i] = CheckResult{Status: "ok", DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				results[i].Status = "fail"
+				results[i].Error = err.Error()
+			}
+		}(i, checks[name])
+	}
+	wg.Wait()
+
+	rep := Report{Status: "ok", Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		rep.Checks[name] = results[i]
+		if results[i].Status != "ok" {
+			rep.Status = "fail"
+		}
+	}
+	return rep
+}
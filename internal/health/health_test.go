@@ -0,0 +1,100 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// the standard logger's output until the test ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func TestReadinessHidesCheckErrors(t *testing.T) {
+	logs := captureLog(t)
+
+	reg := NewRegistry()
+	reg.AddReadiness("mongo", func(ctx context.Context) error { return nil })
+	reg.AddReadiness("storage", func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.7:9000: connection refused")
+	})
+
+	rec := httptest.NewRecorder()
+	reg.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"status":"fail"}` {
+		t.Errorf("body = %s, want the status only", got)
+	}
+	if !strings.Contains(logs.String(), "storage failed") || !strings.Contains(logs.String(), "10.0.0.7:9000") {
+		t.Errorf("log = %q, want the failing check and its error", logs.String())
+	}
+	if strings.Contains(logs.String(), "mongo") {
+		t.Errorf("log = %q, want passing checks left out", logs.String())
+	}
+}
+
+func TestReadinessShuttingDown(t *testing.T) {
+	captureLog(t)
+
+	reg := NewRegistry()
+	reg.SetShuttingDown()
+
+	rec := httptest.NewRecorder()
+	reg.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
+
+func TestLivenessReportsChecks(t *testing.T) {
+	reg := NewRegistry()
+	reg.AddLiveness("worker", func(ctx context.Context) error { return errors.New("no heartbeat for 5m0s") })
+
+	rec := httptest.NewRecorder()
+	reg.LivenessHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"worker":{"status":"fail","error":"no heartbeat for 5m0s"`) {
+		t.Errorf("body = %s, want the failing check", rec.Body.String())
+	}
+}
+
+// run with -race, probes must not read the maps checks are added to
+func TestChecksAddedWhileProbing(t *testing.T) {
+	captureLog(t)
+
+	reg := NewRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("check-%d", i)
+			reg.AddLiveness(name, func(ctx context.Context) error { return nil })
+			reg.AddReadiness(name, func(ctx context.Context) error { return nil })
+		}()
+		go func() {
+			defer wg.Done()
+			reg.LivenessHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			reg.ReadinessHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+)
+
+// Ghost Labs > Export json, from 0.x through 5.x
+// comments aren't part of Ghost exports
+
+type ghostExport struct {
+	DB []struct {
+		Data ghostData `json:"data"`
+	} `json:"db"`
+	// some tools write the data block at the top level
+	Data *ghostData `json:"data"`
+}
+
+type ghostData struct {
+	Posts []ghostPost `json:"posts"`
+	Users []struct {
+		ID    ghostID `json:"id"`
+		Name  string  `json:"name"`
+		Slug  string  `json:"slug"`
+		Email string  `json:"email"`
+	} `json:"users"`
+	Tags []struct {
+		ID   ghostID `json:"id"`
+		Name string  `json:"name"`
+	} `json:"tags"`
+	PostsTags []struct {
+		PostID ghostID `json:"post_id"`
+		TagID  ghostID `json:"tag_id"`
+	} `json:"posts_tags"`
+	PostsAuthors []struct {
+		PostID    ghostID `json:"post_id"`
+		AuthorID  ghostID `json:"author_id"`
+		SortOrder int     `json:"sort_order"`
+	} `json:"posts_authors"`
+}
+
+type ghostPost struct {
+	ID          ghostID   `json:"id"`
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	HTML        string    `json:"html"`
+	Markdown    string    `json:"markdown"`
+	Status      string    `json:"status"`
+	Type        string    `json:"type"`
+	Page        bool      `json:"page"`
+	AuthorID    ghostID   `json:"author_id"`
+	CreatedAt   ghostTime `json:"created_at"`
+	UpdatedAt   ghostTime `json:"updated_at"`
+	PublishedAt ghostTime `json:"published_at"`
+}
+
+// ids are numbers before Ghost 1.0 and object id strings after
+type ghostID string
+
+func (id *ghostID) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*id = ghostID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*id = ghostID(n.String())
+	return nil
+}
+
+// ISO strings in newer exports, "2006-01-02 15:04:05" or epoch millis in older ones
+type ghostTime time.Time
+
+func (t *ghostTime) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		ms, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return fmt.Errorf("ghost: bad time %s", b)
+		}
+		*t = ghostTime(time.UnixMilli(ms).UTC())
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			*t = ghostTime(parsed)
+			return nil
+		}
+	}
+	return fmt.Errorf("ghost: bad time %q", s)
+}
+
+func ParseGhost(r io.Reader) (*Source, error) {
+	var export ghostExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("ghost: %w", err)
+	}
+
+	var data ghostData
+	switch {
+	case len(export.DB) > 0:
+		data = export.DB[0].Data
+	case export.Data != nil:
+		data = *export.Data
+	default:
+		return nil, fmt.Errorf("ghost: no data in export")
+	}
+
+	src := &Source{}
+	for _, u := range data.Users {
+		fname, lname, _ := strings.Cut(strings.TrimSpace(u.Name), " ")
+		src.Authors = append(src.Authors, Author{
+			Key:      string(u.ID),
+			Username: firstNonEmpty(u.Slug, u.Name),
+			Email:    u.Email,
+			Fname:    fname,
+			Lname:    lname,
+		})
+	}
+
+	tagNames := map[ghostID]string{}
+	for _, t := range data.Tags {
+		// tags starting with # are Ghost's internal tags
+		if !strings.HasPrefix(t.Name, "#") {
+			tagNames[t.ID] = t.Name
+		}
+	}
+	postTags := map[ghostID][]string{}
+	for _, pt := range data.PostsTags {
+		if name, ok := tagNames[pt.TagID]; ok {
+			postTags[pt.PostID] = append(postTags[pt.PostID], name)
+		}
+	}
+	// primary author is the first by sort order
+	primaryAuthor := map[ghostID]ghostID{}
+	primaryOrder := map[ghostID]int{}
+	for _, pa := range data.PostsAuthors {
+		if order, seen := primaryOrder[pa.PostID]; !seen || pa.SortOrder < order {
+			primaryAuthor[pa.PostID] = pa.AuthorID
+			primaryOrder[pa.PostID] = pa.SortOrder
+		}
+	}
+
+	for _, p := range data.Posts {
+		if p.Page || (p.Type != "" && p.Type != "post") {
+			continue
+		}
+
+		post := Post{
+			Key:       string(p.ID),
+			AuthorKey: string(p.AuthorID),
+			Title:     strings.TrimSpace(p.Title),
+			Body:      p.HTML,
+			Format:    models.FormatHTML,
+			Tags:      postTags[p.ID],
+			Published: p.Status == "published",
+			Date:      time.Time(p.PublishedAt),
+			Updated:   time.Time(p.UpdatedAt),
+		}
+		if author, ok := primaryAuthor[p.ID]; ok {
+			post.AuthorKey = string(author)
+		}
+		// pre-1.0 exports carry markdown and sometimes no html
+		if post.Body == "" && p.Markdown != "" {
+			post.Body = p.Markdown
+			post.Format = models.FormatMarkdown
+		}
+		if post.Date.IsZero() {
+			post.Date = time.Time(p.CreatedAt)
+		}
+		if p.Slug != "" {
+			post.OldPaths = []string{"/" + p.Slug + "/"}
+		}
+
+		src.Posts = append(src.Posts, post)
+	}
+
+	return src, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
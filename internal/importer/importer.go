@@ -0,0 +1,414 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/content"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/render"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Options struct {
+	// label recorded on every mapping, re-running with the same label
+	// skips records already imported
+	Source string
+	// report what would happen without writing anything
+	DryRun bool
+	// username given to posts whose author isn't in the source
+	DefaultAuthor string
+}
+
+type Counts struct {
+	Created  int `json:"created"`
+	Existing int `json:"existing"`
+	Skipped  int `json:"skipped"`
+}
+
+type Report struct {
+	Source   string   `json:"source"`
+	DryRun   bool     `json:"dryRun"`
+	Users    Counts   `json:"users"`
+	Posts    Counts   `json:"posts"`
+	Comments Counts   `json:"comments"`
+	Warnings []string `json:"warnings"`
+}
+
+type Importer struct {
+	userRepo   repository.UserRepository
+	content    *content.Service
+	importRepo repository.ImportRepository
+	renderer   *render.Renderer
+}
+
+func New(
+	userRepo repository.UserRepository,
+	content *content.Service,
+	importRepo repository.ImportRepository,
+	renderer *render.Renderer,
+) *Importer {
+	return &Importer{
+		userRepo:   userRepo,
+		content:    content,
+		importRepo: importRepo,
+		renderer:   renderer,
+	}
+}
+
+// state for a single run
+type run struct {
+	*Importer
+	opts   Options
+	report *Report
+	// source key -> user id, filled as authors are resolved
+	users map[string]primitive.ObjectID
+	// usernames handed out during a dry run, which never reach the db
+	claimed map[string]bool
+}
+
+func (im *Importer) Run(ctx context.Context, src *Source, opts Options) (*Report, error) {
+	opts.Source = strings.TrimSpace(opts.Source)
+	if opts.Source == "" {
+		return nil, fmt.Errorf("import source label is required")
+	}
+
+	r := &run{
+		Importer: im,
+		opts:     opts,
+		report:   &Report{Source: opts.Source, DryRun: opts.DryRun, Warnings: []string{}},
+		users:    map[string]primitive.ObjectID{},
+		claimed:  map[string]bool{},
+	}
+
+	authors := map[string]Author{}
+	for _, a := range src.Authors {
+		authors[a.Key] = a
+	}
+
+	for _, p := range src.Posts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := r.post(ctx, p, authors); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.report, nil
+}
+
+func (r *run) warn(format string, args ...interface{}) {
+	r.report.Warnings = append(r.report.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (r *run) post(ctx context.Context, p Post, authors map[string]Author) error {
+	if p.Key == "" {
+		r.report.Posts.Skipped++
+		r.warn("post %q has no id in the source, skipped", p.Title)
+		return nil
+	}
+
+	mapping, err := r.importRepo.Find(ctx, r.opts.Source, models.ImportPost, p.Key)
+	if err != nil {
+		return err
+	}
+
+	var postID primitive.ObjectID
+	if mapping != nil {
+		r.report.Posts.Existing++
+		postID = mapping.TargetID
+	} else {
+		if strings.TrimSpace(p.Title) == "" || strings.TrimSpace(p.Body) == "" {
+			r.report.Posts.Skipped++
+			r.warn("post %s has no title or body, skipped", p.Key)
+			return nil
+		}
+
+		authorID, err := r.author(ctx, p.AuthorKey, authors)
+		if err != nil {
+			return err
+		}
+		if authorID.IsZero() {
+			r.report.Posts.Skipped++
+			r.warn("post %s has no author and no default author is set, skipped", p.Key)
+			return nil
+		}
+
+		rendered, err := r.renderer.Post(p.Format, p.Body)
+		if err != nil {
+			r.report.Posts.Skipped++
+			r.warn("post %s: %v", p.Key, err)
+			return nil
+		}
+
+		post := &models.Post{
+			Author:    authorID,
+			Title:     strings.TrimSpace(p.Title),
+			Text:      p.Body,
+			Format:    p.Format,
+			HTML:      rendered.HTML,
+			TOC:       rendered.TOC,
+			Tags:      models.NormalizeTags(p.Tags),
+			Published: p.Published,
+			Timestamp: p.Date,
+			UpdatedAt: p.Updated,
+		}
+		if !post.UpdatedAt.After(post.Timestamp) {
+			post.UpdatedAt = post.Timestamp
+		}
+
+		postID = primitive.NewObjectID()
+		if !r.opts.DryRun {
+			if err := r.content.CreatePost(ctx, post); err != nil {
+				return err
+			}
+			postID = post.ID
+			if err := r.importRepo.Create(ctx, &models.ImportMapping{
+				Source:   r.opts.Source,
+				Kind:     models.ImportPost,
+				Key:      p.Key,
+				TargetID: postID,
+				OldPaths: oldPaths(p.OldPaths),
+			}); err != nil {
+				return err
+			}
+		}
+		r.report.Posts.Created++
+	}
+
+	// comments are checked even on existing posts, so an import cut short
+	// part way through a post's comments picks up where it stopped
+	for _, c := range p.Comments {
+		if err := r.comment(ctx, postID, p.Key, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *run) comment(ctx context.Context, postID primitive.ObjectID, postKey string, c Comment) error {
+	// comment ids are only unique within a post in some exports
+	key := postKey + "/" + c.Key
+	if c.Key == "" || strings.TrimSpace(c.Body) == "" {
+		r.report.Comments.Skipped++
+		return nil
+	}
+
+	mapping, err := r.importRepo.Find(ctx, r.opts.Source, models.ImportComment, key)
+	if err != nil {
+		return err
+	}
+	if mapping != nil {
+		r.report.Comments.Existing++
+		return nil
+	}
+
+	authorID, err := r.commenter(ctx, c)
+	if err != nil {
+		return err
+	}
+	if authorID.IsZero() {
+		r.report.Comments.Skipped++
+		r.warn("comment %s has no author, skipped", key)
+		return nil
+	}
+
+	html, err := r.renderer.Comment(c.Body)
+	if err != nil {
+		r.report.Comments.Skipped++
+		r.warn("comment %s: %v", key, err)
+		return nil
+	}
+
+	comment := &models.Comment{
+		Post:      postID,
+		Author:    authorID,
+		Text:      c.Body,
+		HTML:      html,
+		Timestamp: c.Date,
+	}
+	if !r.opts.DryRun {
+		err := r.content.ImportComment(ctx, comment)
+		if errors.Is(err, content.ErrPostNotFound) {
+			// imported earlier, then trashed or deleted here
+			r.report.Comments.Skipped++
+			r.warn("comment %s: post is no longer on the blog, skipped", key)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.importRepo.Create(ctx, &models.ImportMapping{
+			Source:   r.opts.Source,
+			Kind:     models.ImportComment,
+			Key:      key,
+			TargetID: comment.ID,
+		}); err != nil {
+			return err
+		}
+	}
+	r.report.Comments.Created++
+	return nil
+}
+
+// resolves a post author to a user id, zero when there is none to use
+func (r *run) author(ctx context.Context, key string, authors map[string]Author) (primitive.ObjectID, error) {
+	if key == "" {
+		return r.defaultAuthor(ctx)
+	}
+	a, ok := authors[key]
+	if !ok {
+		// posts can name authors the export has no record of
+		a = Author{Key: key, Username: key}
+	}
+	return r.user(ctx, a)
+}
+
+func (r *run) defaultAuthor(ctx context.Context) (primitive.ObjectID, error) {
+	if r.opts.DefaultAuthor == "" {
+		return primitive.NilObjectID, nil
+	}
+	if id, ok := r.users[""]; ok {
+		return id, nil
+	}
+	user, err := r.userRepo.FindByUsername(ctx, r.opts.DefaultAuthor)
+	if errors.Is(err, apperr.ErrNotFound) {
+		return primitive.NilObjectID, fmt.Errorf("default author %q not found", r.opts.DefaultAuthor)
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	r.users[""] = user.ID
+	return user.ID, nil
+}
+
+// commenters become users too, keyed by email when the source has one
+func (r *run) commenter(ctx context.Context, c Comment) (primitive.ObjectID, error) {
+	id := strings.ToLower(strings.TrimSpace(c.AuthorEmail))
+	if id == "" {
+		id = strings.TrimSpace(c.AuthorName)
+	}
+	if id == "" {
+		return primitive.NilObjectID, nil
+	}
+	fname, lname, _ := strings.Cut(strings.TrimSpace(c.AuthorName), " ")
+	return r.user(ctx, Author{
+		Key:      "commenter:" + id,
+		Username: firstNonEmpty(c.AuthorName, strings.Split(id, "@")[0]),
+		Email:    c.AuthorEmail,
+		Fname:    fname,
+		Lname:    lname,
+	})
+}
+
+// existing mapping, then a user with the same username, then a new user
+func (r *run) user(ctx context.Context, a Author) (primitive.ObjectID, error) {
+	if id, ok := r.users[a.Key]; ok {
+		return id, nil
+	}
+
+	mapping, err := r.importRepo.Find(ctx, r.opts.Source, models.ImportUser, a.Key)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if mapping != nil {
+		r.report.Users.Existing++
+		r.users[a.Key] = mapping.TargetID
+		return mapping.TargetID, nil
+	}
+
+	base := slugify(firstNonEmpty(a.Username, a.Key))
+	if base == "" {
+		base = "imported"
+	}
+	// an author is matched to an account that already uses the login,
+	// commenters never are since anyone can type any name
+	if !strings.HasPrefix(a.Key, "commenter:") {
+		existing, err := r.userRepo.FindByUsername(ctx, base)
+		if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+			return primitive.NilObjectID, err
+		}
+		if err == nil {
+			r.report.Users.Existing++
+			r.users[a.Key] = existing.ID
+			return existing.ID, r.mapUser(ctx, a.Key, existing.ID)
+		}
+	}
+
+	username, err := r.freeUsername(ctx, base)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	// imported accounts have no password and can't log in until one is set
+	user := &models.User{
+		Username: username,
+		Fname:    a.Fname,
+		Lname:    a.Lname,
+	}
+	id := primitive.NewObjectID()
+	if !r.opts.DryRun {
+		if err := r.userRepo.Create(ctx, user); err != nil {
+			return primitive.NilObjectID, err
+		}
+		id = user.ID
+		if err := r.mapUser(ctx, a.Key, id); err != nil {
+			return primitive.NilObjectID, err
+		}
+	}
+	r.report.Users.Created++
+	r.users[a.Key] = id
+	return id, nil
+}
+
+func (r *run) mapUser(ctx context.Context, key string, id primitive.ObjectID) error {
+	if r.opts.DryRun {
+		return nil
+	}
+	return r.importRepo.Create(ctx, &models.ImportMapping{
+		Source:   r.opts.Source,
+		Kind:     models.ImportUser,
+		Key:      key,
+		TargetID: id,
+	})
+}
+
+// base, then base-2, base-3 and so on
+func (r *run) freeUsername(ctx context.Context, base string) (string, error) {
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = base + "-" + strconv.Itoa(n)
+		}
+		if r.claimed[name] {
+			continue
+		}
+		_, err := r.userRepo.FindByUsername(ctx, name)
+		if errors.Is(err, apperr.ErrNotFound) {
+			r.claimed[name] = true
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func oldPaths(raw []string) []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, p := range raw {
+		p = NormalizePath(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		paths = append(paths, p)
+	}
+	return paths
+}
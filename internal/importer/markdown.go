@@ -0,0 +1,176 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// a directory of .md files with YAML front matter, as used by Hugo,
+// Jekyll and most other static site generators
+
+type frontMatter struct {
+	Title      string     `yaml:"title"`
+	Date       string     `yaml:"date"`
+	Updated    string     `yaml:"updated"`
+	LastMod    string     `yaml:"lastmod"`
+	Tags       stringList `yaml:"tags"`
+	Categories stringList `yaml:"categories"`
+	Author     string     `yaml:"author"`
+	Draft      bool       `yaml:"draft"`
+	Published  *bool      `yaml:"published"`
+	Slug       string     `yaml:"slug"`
+	URL        string     `yaml:"url"`
+	Permalink  string     `yaml:"permalink"`
+	Aliases    stringList `yaml:"aliases"`
+}
+
+// accepts both a list and a comma separated string
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		for _, s := range strings.Split(node.Value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				*l = append(*l, s)
+			}
+		}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+var frontMatterLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// authors are only known by the names used in front matter
+func ParseMarkdown(fsys fs.FS) (*Source, error) {
+	src := &Source{}
+	authors := map[string]bool{}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// skip .git and the like
+			if name != "." && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		ext := path.Ext(name)
+		if ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		post, err := parseMarkdownFile(name, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if post.Date.IsZero() {
+			if info, err := d.Info(); err == nil {
+				post.Date = info.ModTime()
+			}
+		}
+
+		if post.AuthorKey != "" && !authors[post.AuthorKey] {
+			authors[post.AuthorKey] = true
+			fname, lname, _ := strings.Cut(post.AuthorKey, " ")
+			src.Authors = append(src.Authors, Author{
+				Key:      post.AuthorKey,
+				Username: post.AuthorKey,
+				Fname:    fname,
+				Lname:    lname,
+			})
+		}
+		src.Posts = append(src.Posts, *post)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("markdown: %w", err)
+	}
+
+	return src, nil
+}
+
+func parseMarkdownFile(name string, data []byte) (*Post, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var fm frontMatter
+	body := data
+	if rest, ok := bytes.CutPrefix(data, []byte("---\n")); ok {
+		head, tail, found := bytes.Cut(rest, []byte("\n---"))
+		if !found {
+			return nil, fmt.Errorf("unterminated front matter")
+		}
+		if err := yaml.Unmarshal(head, &fm); err != nil {
+			return nil, err
+		}
+		// drop the rest of the closing --- line
+		if _, after, found := bytes.Cut(tail, []byte("\n")); found {
+			body = after
+		} else {
+			body = nil
+		}
+	}
+
+	base := strings.TrimSuffix(name, path.Ext(name))
+	post := &Post{
+		Key:       firstNonEmpty(fm.Slug, base),
+		AuthorKey: strings.TrimSpace(fm.Author),
+		Title:     firstNonEmpty(fm.Title, path.Base(base)),
+		Body:      strings.TrimSpace(string(body)),
+		Format:    models.FormatMarkdown,
+		Tags:      append(fm.Tags, fm.Categories...),
+		Published: !fm.Draft,
+		Date:      frontMatterTime(fm.Date),
+		Updated:   frontMatterTime(firstNonEmpty(fm.LastMod, fm.Updated)),
+	}
+	if fm.Published != nil {
+		post.Published = *fm.Published
+	}
+
+	for _, p := range []string{fm.URL, fm.Permalink} {
+		if p != "" {
+			post.OldPaths = append(post.OldPaths, p)
+		}
+	}
+	post.OldPaths = append(post.OldPaths, fm.Aliases...)
+	if len(post.OldPaths) == 0 {
+		// the usual /slug/ permalink when the file doesn't name one
+		post.OldPaths = append(post.OldPaths, "/"+path.Base(post.Key)+"/")
+	}
+
+	return post, nil
+}
+
+func frontMatterTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range frontMatterLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
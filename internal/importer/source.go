@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// input formats
+const (
+	FormatWXR      = "wxr"
+	FormatGhost    = "ghost"
+	FormatMarkdown = "markdown"
+)
+
+// blog content parsed from any adapter, keys are ids in the source system
+type Source struct {
+	Authors []Author
+	Posts   []Post
+}
+
+type Author struct {
+	Key      string
+	Username string
+	Email    string
+	Fname    string
+	Lname    string
+}
+
+type Post struct {
+	Key string
+	// empty uses Options.DefaultAuthor
+	AuthorKey string
+	Title     string
+	Body      string
+	// models.FormatHTML or models.FormatMarkdown
+	Format    string
+	Tags      []string
+	Published bool
+	Date      time.Time
+	Updated   time.Time
+	// where the post lived on the old site, absolute URLs or paths
+	OldPaths []string
+	Comments []Comment
+}
+
+type Comment struct {
+	Key         string
+	AuthorName  string
+	AuthorEmail string
+	Body        string
+	Date        time.Time
+}
+
+// path form used to store and look up old URLs: no host, no trailing
+// slash, query kept only for WordPress ?p=123 style links
+func NormalizePath(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+
+	p := path.Clean("/" + u.Path)
+	if p == "/" {
+		if id := u.Query().Get("p"); id != "" {
+			return "/?p=" + id
+		}
+		return ""
+	}
+	return strings.TrimSuffix(p, "/")
+}
+
+var usernameChars = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// username-safe form of a display name or login
+func slugify(s string) string {
+	s = usernameChars.ReplaceAllString(strings.ToLower(strings.TrimSpace(s)), "-")
+	return strings.Trim(s, "-.")
+}
+
+// parses an uploaded export, markdown directories come zipped
+func Parse(format string, data []byte) (*Source, error) {
+	switch format {
+	case FormatWXR:
+		return ParseWXR(bytes.NewReader(data))
+	case FormatGhost:
+		return ParseGhost(bytes.NewReader(data))
+	case FormatMarkdown:
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("markdown: %w", err)
+		}
+		return ParseMarkdown(zr)
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+}
@@ -0,0 +1,171 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+)
+
+// WordPress eXtended RSS, as written by Tools > Export
+// element names are matched without namespace since the wp: namespace URL
+// changes between export versions
+
+type wxrDocument struct {
+	Channel struct {
+		Authors []wxrAuthor `xml:"author"`
+		Items   []wxrItem   `xml:"item"`
+	} `xml:"channel"`
+}
+
+type wxrAuthor struct {
+	ID          string `xml:"author_id"`
+	Login       string `xml:"author_login"`
+	Email       string `xml:"author_email"`
+	DisplayName string `xml:"author_display_name"`
+	FirstName   string `xml:"author_first_name"`
+	LastName    string `xml:"author_last_name"`
+}
+
+type wxrItem struct {
+	Title      string        `xml:"title"`
+	Link       string        `xml:"link"`
+	Creator    string        `xml:"creator"`
+	Content    string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID     string        `xml:"post_id"`
+	Date       string        `xml:"post_date"`
+	DateGMT    string        `xml:"post_date_gmt"`
+	Modified   string        `xml:"post_modified_gmt"`
+	Name       string        `xml:"post_name"`
+	Status     string        `xml:"status"`
+	Type       string        `xml:"post_type"`
+	Categories []wxrCategory `xml:"category"`
+	Comments   []wxrComment  `xml:"comment"`
+}
+
+type wxrCategory struct {
+	Domain string `xml:"domain,attr"`
+	Name   string `xml:",chardata"`
+}
+
+type wxrComment struct {
+	ID       string `xml:"comment_id"`
+	Author   string `xml:"comment_author"`
+	Email    string `xml:"comment_author_email"`
+	DateGMT  string `xml:"comment_date_gmt"`
+	Date     string `xml:"comment_date"`
+	Content  string `xml:"comment_content"`
+	Approved string `xml:"comment_approved"`
+	Type     string `xml:"comment_type"`
+}
+
+const wxrTimeLayout = "2006-01-02 15:04:05"
+
+func ParseWXR(r io.Reader) (*Source, error) {
+	var doc wxrDocument
+	dec := xml.NewDecoder(r)
+	// exports are routinely not quite valid xml
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("wxr: %w", err)
+	}
+
+	src := &Source{}
+	for _, a := range doc.Channel.Authors {
+		if a.Login == "" {
+			continue
+		}
+		src.Authors = append(src.Authors, Author{
+			Key:      a.Login,
+			Username: a.Login,
+			Email:    a.Email,
+			Fname:    a.FirstName,
+			Lname:    a.LastName,
+		})
+	}
+
+	for _, item := range doc.Channel.Items {
+		// attachments, pages, menu items and the like aren't posts
+		if item.Type != "post" || item.Status == "trash" || item.Status == "auto-draft" {
+			continue
+		}
+
+		post := Post{
+			Key:       item.PostID,
+			AuthorKey: item.Creator,
+			Title:     strings.TrimSpace(item.Title),
+			Body:      autop(item.Content),
+			Format:    models.FormatHTML,
+			Published: item.Status == "publish",
+			Date:      wxrTime(item.DateGMT, item.Date),
+			Updated:   wxrTime(item.Modified),
+		}
+		if item.Link != "" {
+			post.OldPaths = append(post.OldPaths, item.Link)
+		}
+		if item.PostID != "" {
+			post.OldPaths = append(post.OldPaths, "/?p="+item.PostID)
+		}
+		for _, c := range item.Categories {
+			if c.Domain == "category" || c.Domain == "post_tag" {
+				post.Tags = append(post.Tags, strings.TrimSpace(c.Name))
+			}
+		}
+
+		for _, c := range item.Comments {
+			// spam, pending and pingbacks stay behind
+			if c.Approved != "1" || (c.Type != "" && c.Type != "comment") {
+				continue
+			}
+			post.Comments = append(post.Comments, Comment{
+				Key:         c.ID,
+				AuthorName:  c.Author,
+				AuthorEmail: c.Email,
+				Body:        c.Content,
+				Date:        wxrTime(c.DateGMT, c.Date),
+			})
+		}
+
+		src.Posts = append(src.Posts, post)
+	}
+
+	return src, nil
+}
+
+// first parseable value, drafts carry a zero gmt date
+func wxrTime(values ...string) time.Time {
+	for _, v := range values {
+		if t, err := time.Parse(wxrTimeLayout, strings.TrimSpace(v)); err == nil && t.Year() > 1 {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var blockTag = regexp.MustCompile(`(?i)<(p|div|h[1-6]|ul|ol|pre|blockquote|table|figure)[\s>]`)
+
+// WordPress stores post bodies without paragraph tags and adds them on
+// display, do the same for bodies that have no block markup of their own
+func autop(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	if strings.TrimSpace(body) == "" || blockTag.MatchString(body) {
+		return body
+	}
+
+	var b strings.Builder
+	for _, para := range strings.Split(body, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(para, "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,283 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/content"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// checks, in the order they run, later repairs rely on earlier ones
+const (
+	// comments whose post is gone, repaired by deleting them
+	OrphanComments = "orphan-comments"
+	// comments and posts whose author is gone, repaired by reassigning
+	// them to the deleted user placeholder
+	CommentsMissingAuthor = "comments-missing-author"
+	PostsMissingAuthor    = "posts-missing-author"
+	// Post.CommentCount differs from the comments on the post, which
+	// leaves out trashed ones
+	CommentCounts = "comment-counts"
+	// User.Posts and User.Comments differ from what the user wrote, trash
+	// included
+	UserPosts    = "user-posts"
+	UserComments = "user-comments"
+)
+
+// ids listed per check, the count covers the rest
+const sampleSize = 10
+
+type Result struct {
+	Check    string   `json:"check"`
+	Found    int      `json:"found"`
+	Repaired int      `json:"repaired"`
+	Sample   []string `json:"sample"`
+}
+
+type Report struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	Repair    bool      `json:"repair"`
+	Results   []Result  `json:"results"`
+}
+
+// drift still in the database after the run
+func (r *Report) Outstanding() int {
+	n := 0
+	for _, res := range r.Results {
+		n += res.Found - res.Repaired
+	}
+	return n
+}
+
+// finds drift between users, posts and comments that writes made outside
+// the content service, or before it existed, left behind
+type Checker struct {
+	db              *mongo.Database
+	content         *content.Service
+	deletedUsername string
+}
+
+func New(db *mongo.Database, contentService *content.Service, deletedUsername string) *Checker {
+	return &Checker{db: db, content: contentService, deletedUsername: deletedUsername}
+}
+
+// one document out of step, Want is what a repair writes
+type finding struct {
+	ID    primitive.ObjectID   `bson:"_id"`
+	Count int64                `bson:"count"`
+	Want  []primitive.ObjectID `bson:"want"`
+}
+
+type check struct {
+	name   string
+	find   func(ctx context.Context) ([]finding, error)
+	repair func(ctx context.Context, found []finding) error
+}
+
+// runs every check, repairing as it goes when repair is set
+func (c *Checker) Run(ctx context.Context, repair bool) (*Report, error) {
+	report := &Report{CheckedAt: time.Now(), Repair: repair}
+	for _, ch := range c.checks() {
+		found, err := ch.find(ctx)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", ch.name, err)
+		}
+
+		res := Result{Check: ch.name, Found: len(found), Sample: []string{}}
+		for i := 0; i < len(found) && i < sampleSize; i++ {
+			res.Sample = append(res.Sample, found[i].ID.Hex())
+		}
+		if repair && len(found) > 0 {
+			if err := ch.repair(ctx, found); err != nil {
+				report.Results = append(report.Results, res)
+				return report, fmt.Errorf("repairing %s: %w", ch.name, err)
+			}
+			res.Repaired = len(found)
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report, nil
+}
+
+func (c *Checker) checks() []check {
+	users := c.db.Collection("users")
+	posts := c.db.Collection("posts")
+	comments := c.db.Collection("comments")
+
+	return []check{
+		{
+			name: OrphanComments,
+			find: func(ctx context.Context) ([]finding, error) {
+				return missing(ctx, comments, "post", "posts")
+			},
+			repair: func(ctx context.Context, found []finding) error {
+				_, err := comments.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids(found)}})
+				return err
+			},
+		},
+		{
+			name: CommentsMissingAuthor,
+			find: func(ctx context.Context) ([]finding, error) {
+				return missing(ctx, comments, "author", "users")
+			},
+			repair: func(ctx context.Context, found []finding) error {
+				return c.reassign(ctx, comments, found)
+			},
+		},
+		{
+			name: PostsMissingAuthor,
+			find: func(ctx context.Context) ([]finding, error) {
+				return missing(ctx, posts, "author", "users")
+			},
+			repair: func(ctx context.Context, found []finding) error {
+				return c.reassign(ctx, posts, found)
+			},
+		},
+		{
+			name: CommentCounts,
+			find: func(ctx context.Context) ([]finding, error) {
+				return aggregate(ctx, posts, mongo.Pipeline{
+					{{Key: "$lookup", Value: bson.D{
+						{Key: "from", Value: "comments"},
+						{Key: "let", Value: bson.D{{Key: "id", Value: "$_id"}}},
+						{Key: "pipeline", Value: mongo.Pipeline{
+							{{Key: "$match", Value: bson.D{
+								{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$post", "$$id"}}}},
+								{Key: "deletedAt", Value: nil},
+							}}},
+							{{Key: "$count", Value: "n"}},
+						}},
+						{Key: "as", Value: "comments"},
+					}}},
+					{{Key: "$project", Value: bson.D{
+						{Key: "have", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$commentCount", 0}}}},
+						{Key: "count", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+							bson.D{{Key: "$arrayElemAt", Value: bson.A{"$comments.n", 0}}}, 0,
+						}}}},
+					}}},
+					{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$ne", Value: bson.A{"$have", "$count"}}}}}}},
+				})
+			},
+			repair: func(ctx context.Context, found []finding) error {
+				models := make([]mongo.WriteModel, len(found))
+				for i, f := range found {
+					models[i] = mongo.NewUpdateOneModel().
+						SetFilter(bson.M{"_id": f.ID}).
+						SetUpdate(bson.M{"$set": bson.M{"commentCount": f.Count}})
+				}
+				_, err := posts.BulkWrite(ctx, models)
+				return err
+			},
+		},
+		{
+			name: UserPosts,
+			find: func(ctx context.Context) ([]finding, error) {
+				return refs(ctx, users, "posts")
+			},
+			repair: func(ctx context.Context, found []finding) error {
+				return setRefs(ctx, users, "posts", found)
+			},
+		},
+		{
+			name: UserComments,
+			find: func(ctx context.Context) ([]finding, error) {
+				return refs(ctx, users, "comments")
+			},
+			repair: func(ctx context.Context, found []finding) error {
+				return setRefs(ctx, users, "comments", found)
+			},
+		},
+	}
+}
+
+// documents in coll whose field points at nothing in from
+func missing(ctx context.Context, coll *mongo.Collection, field, from string) ([]finding, error) {
+	return aggregate(ctx, coll, mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: from},
+			{Key: "localField", Value: field},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "parent"},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "parent", Value: bson.D{{Key: "$size", Value: 0}}}}}},
+		{{Key: "$project", Value: bson.D{{Key: "_id", Value: 1}}}},
+	})
+}
+
+// users whose list under field differs from the ids of what they wrote,
+// the lists are named after the collections they point into
+func refs(ctx context.Context, users *mongo.Collection, field string) ([]finding, error) {
+	return aggregate(ctx, users, mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: field},
+			{Key: "let", Value: bson.D{{Key: "id", Value: "$_id"}}},
+			{Key: "pipeline", Value: mongo.Pipeline{
+				{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$author", "$$id"}}}}}}},
+				{{Key: "$project", Value: bson.D{{Key: "_id", Value: 1}}}},
+			}},
+			{Key: "as", Value: "written"},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "want", Value: "$written._id"},
+			{Key: "have", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$" + field, bson.A{}}}}},
+		}}},
+		// duplicates count as drift, setEquals alone would miss them
+		{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "$not", Value: bson.A{bson.D{{Key: "$setEquals", Value: bson.A{"$want", "$have"}}}}}},
+			bson.D{{Key: "$ne", Value: bson.A{bson.D{{Key: "$size", Value: "$want"}}, bson.D{{Key: "$size", Value: "$have"}}}}},
+		}}}}}}},
+	})
+}
+
+func (c *Checker) reassign(ctx context.Context, coll *mongo.Collection, found []finding) error {
+	placeholder, err := c.content.DeletedUser(ctx, c.deletedUsername)
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids(found)}},
+		bson.M{"$set": bson.M{"author": placeholder.ID}},
+	)
+	return err
+}
+
+func setRefs(ctx context.Context, users *mongo.Collection, field string, found []finding) error {
+	models := make([]mongo.WriteModel, len(found))
+	for i, f := range found {
+		want := f.Want
+		if want == nil {
+			want = []primitive.ObjectID{}
+		}
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": f.ID}).
+			SetUpdate(bson.M{"$set": bson.M{field: want}})
+	}
+	_, err := users.BulkWrite(ctx, models)
+	return err
+}
+
+func aggregate(ctx context.Context, coll *mongo.Collection, pipeline mongo.Pipeline) ([]finding, error) {
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var found []finding
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func ids(found []finding) []primitive.ObjectID {
+	out := make([]primitive.ObjectID, len(found))
+	for i, f := range found {
+		out[i] = f.ID
+	}
+	return out
+}
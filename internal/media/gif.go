@@ -0,0 +1,69 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errBadGIF = errors.New("malformed gif")
+
+// counts a gif's frames and the pixels decoding all of them would allocate,
+// walking the block structure without decompressing anything
+func gifFrames(data []byte) (frames, pixels int, err error) {
+	if len(data) < 13 {
+		return 0, 0, errBadGIF
+	}
+	pos := 13 + colorTableSize(data[10])
+
+	for pos < len(data) {
+		switch data[pos] {
+		// trailer
+		case 0x3B:
+			return frames, pixels, nil
+		// extension: label then data sub-blocks
+		case 0x21:
+			pos, err = skipSubBlocks(data, pos+2)
+		// image descriptor: position, size, flags, optional local color
+		// table, LZW code size then data sub-blocks
+		case 0x2C:
+			if pos+10 > len(data) {
+				return 0, 0, errBadGIF
+			}
+			w := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			h := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			frames++
+			pixels += w * h
+			pos = pos + 10 + colorTableSize(data[pos+9]) + 1
+			pos, err = skipSubBlocks(data, pos)
+		default:
+			return 0, 0, errBadGIF
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return 0, 0, errBadGIF
+}
+
+// bytes in the color table a screen or image descriptor's flags announce
+func colorTableSize(flags byte) int {
+	if flags&0x80 == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
+
+// returns the position after a run of length-prefixed sub-blocks
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errBadGIF
+		}
+		n := int(data[pos])
+		pos++
+		if n == 0 {
+			return pos, nil
+		}
+		pos += n
+	}
+}
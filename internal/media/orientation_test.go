@@ -0,0 +1,134 @@
+package media
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// APP1 segment holding an EXIF IFD0 with just the orientation tag,
+// followed by extra bytes standing in for the rest of the metadata
+func exifSegment(order binary.AppendByteOrder, orientation uint16, extra string) []byte {
+	tiff := []byte("MM")
+	if order == binary.LittleEndian {
+		tiff = []byte("II")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	// one entry: tag, type SHORT, count 1, value, then no next IFD
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	tiff = order.AppendUint32(tiff, 0)
+	tiff = append(tiff, extra...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	return jpegSegment(0xE1, payload)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// inserts segments right after SOI
+func withSegments(jpg []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, jpg[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, jpg[2:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	jpg := encodeJPEG(t, testImage(8, 8))
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"no exif", jpg, 1},
+		{"big endian", withSegments(jpg, exifSegment(binary.BigEndian, 6, "")), 6},
+		{"little endian", withSegments(jpg, exifSegment(binary.LittleEndian, 8, "")), 8},
+		{"after another segment", withSegments(jpg, jpegSegment(0xE0, []byte("JFIF\x00\x01\x01")), exifSegment(binary.BigEndian, 3, "")), 3},
+		{"out of range", withSegments(jpg, exifSegment(binary.BigEndian, 9, "")), 1},
+		{"zero", withSegments(jpg, exifSegment(binary.LittleEndian, 0, "")), 1},
+		{"not exif", withSegments(jpg, jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"))), 1},
+		{"no segments", jpg[:2:2], 1},
+		{"bad segment length", append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF}, "Exif"...), 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"empty", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 3x2, each pixel a distinct grey
+	//   A B C
+	//   D E F
+	const (
+		A, B, C = 10, 20, 30
+		D, E, F = 40, 50, 60
+	)
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []uint8{A, B, C, D, E, F})
+
+	tests := []struct {
+		orientation int
+		want        [][]uint8
+	}{
+		{1, [][]uint8{{A, B, C}, {D, E, F}}},
+		// mirrored
+		{2, [][]uint8{{C, B, A}, {F, E, D}}},
+		// rotated 180
+		{3, [][]uint8{{F, E, D}, {C, B, A}}},
+		// flipped
+		{4, [][]uint8{{D, E, F}, {A, B, C}}},
+		// transposed
+		{5, [][]uint8{{A, D}, {B, E}, {C, F}}},
+		// rotated 90 clockwise
+		{6, [][]uint8{{D, A}, {E, B}, {F, C}}},
+		// transversed
+		{7, [][]uint8{{F, C}, {E, B}, {D, A}}},
+		// rotated 90 counterclockwise
+		{8, [][]uint8{{C, F}, {B, E}, {A, D}}},
+	}
+	for _, tt := range tests {
+		got := applyOrientation(src, tt.orientation)
+		b := got.Bounds()
+		if b.Dx() != len(tt.want[0]) || b.Dy() != len(tt.want) {
+			t.Errorf("orientation %d: got %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), len(tt.want[0]), len(tt.want))
+			continue
+		}
+		for y, row := range tt.want {
+			for x, want := range row {
+				if g := color.GrayModel.Convert(got.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y; g != want {
+					t.Errorf("orientation %d: pixel (%d,%d) = %d, want %d", tt.orientation, x, y, g, want)
+				}
+			}
+		}
+	}
+}
+
+func TestApplyOrientationOffsetBounds(t *testing.T) {
+	// sub-images don't start at the origin
+	img := testImage(4, 2).SubImage(image.Rect(2, 0, 4, 2))
+	got := applyOrientation(img, 6)
+	if got.Bounds() != image.Rect(0, 0, 2, 2) {
+		t.Fatalf("bounds = %v, want 2x2 at the origin", got.Bounds())
+	}
+	if got.At(1, 0) != img.At(2, 0) {
+		t.Errorf("pixel (1,0) = %v, want %v", got.At(1, 0), img.At(2, 0))
+	}
+}
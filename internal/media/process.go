@@ -0,0 +1,246 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrTooLarge        = errors.New("file exceeds upload size limit")
+	ErrUnsupportedType = errors.New("unsupported file type")
+)
+
+// resized copies generated on upload, bounded by the longest side
+var variantSizes = []struct {
+	name    string
+	maxSide int
+}{
+	{"thumb", 200},
+	{"medium", 800},
+	{"large", 1600},
+}
+
+// guards against decompression bombs, ~50 megapixels
+// for gifs the budget covers every frame together
+const maxPixels = 50_000_000
+
+const maxFrames = 500
+
+const jpegQuality = 85
+
+// validates, sanitizes and stores uploads
+type Service struct {
+	storage Storage
+	maxSize int64
+}
+
+func NewService(storage Storage, maxSize int64) *Service {
+	return &Service{storage: storage, maxSize: maxSize}
+}
+
+func (s *Service) Storage() Storage {
+	return s.storage
+}
+
+func (s *Service) MaxSize() int64 {
+	return s.maxSize
+}
+
+// sniffs and re-encodes the upload (dropping EXIF and other metadata),
+// stores it with its resized variants and returns the unsaved media record
+func (s *Service) Process(ctx context.Context, owner primitive.ObjectID, filename string, r io.Reader) (*models.Media, error) {
+	// read one byte past the limit to detect oversize uploads
+	data, err := io.ReadAll(io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.maxSize {
+		return nil, ErrTooLarge
+	}
+
+	// trust the bytes, not the client's Content-Type or extension
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	// DecodeConfig only sees the logical screen, every frame gets decoded
+	if contentType == "image/gif" {
+		frames, pixels, err := gifFrames(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+		}
+		if frames > maxFrames || pixels > maxPixels {
+			return nil, ErrTooLarge
+		}
+	}
+
+	original, img, err := sanitize(data, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	m := &models.Media{
+		ID:          primitive.NewObjectID(),
+		Owner:       owner,
+		Filename:    path.Base(filename),
+		ContentType: contentType,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Variants:    []models.MediaVariant{},
+		Timestamp:   time.Now(),
+	}
+	prefix := owner.Hex() + "/" + m.ID.Hex() + "/"
+
+	m.Key = prefix + "original" + extension(contentType)
+	m.Size = int64(len(original))
+	if err := s.storage.Put(ctx, m.Key, bytes.NewReader(original), m.Size, contentType); err != nil {
+		return nil, err
+	}
+	m.URL = s.storage.URL(m.Key)
+
+	// variants of gifs are stills, png keeps their palette sharp
+	variantType := contentType
+	if variantType == "image/gif" {
+		variantType = "image/png"
+	}
+
+	for _, size := range variantSizes {
+		resized := resize(img, size.maxSide)
+		if resized == nil {
+			continue
+		}
+		encoded, err := encode(resized, variantType)
+		if err != nil {
+			s.cleanup(ctx, m)
+			return nil, err
+		}
+
+		v := models.MediaVariant{
+			Name:   size.name,
+			Key:    prefix + size.name + extension(variantType),
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Size:   int64(len(encoded)),
+		}
+		if err := s.storage.Put(ctx, v.Key, bytes.NewReader(encoded), v.Size, variantType); err != nil {
+			s.cleanup(ctx, m)
+			return nil, err
+		}
+		v.URL = s.storage.URL(v.Key)
+		m.Variants = append(m.Variants, v)
+	}
+
+	return m, nil
+}
+
+// removes every stored object for m
+func (s *Service) Delete(ctx context.Context, m *models.Media) error {
+	var errs []error
+	for _, v := range m.Variants {
+		errs = append(errs, s.storage.Delete(ctx, v.Key))
+	}
+	errs = append(errs, s.storage.Delete(ctx, m.Key))
+	return errors.Join(errs...)
+}
+
+// best effort removal after a failed upload
+func (s *Service) cleanup(ctx context.Context, m *models.Media) {
+	s.Delete(context.WithoutCancel(ctx), m)
+}
+
+// decodes and re-encodes so only pixel data survives
+// jpeg orientation is applied first since the EXIF tag carrying it is dropped
+func sanitize(data []byte, contentType string) ([]byte, image.Image, error) {
+	if contentType == "image/gif" {
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			return nil, nil, err
+		}
+		// the first frame on the logical screen, which it may not fill
+		still := image.NewPaletted(image.Rect(0, 0, g.Config.Width, g.Config.Height), g.Image[0].Palette)
+		draw.Draw(still, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Src)
+		return buf.Bytes(), still, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	encoded, err := encode(img, contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encoded, img, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		err = png.Encode(&buf, img)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	return buf.Bytes(), err
+}
+
+// scales img to fit maxSide, nil when it is already small enough
+func resize(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return nil
+	}
+
+	if w >= h {
+		h = max(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = max(1, w*maxSide/h)
+		h = maxSide
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+func extension(contentType string) string {
+	return "." + strings.TrimPrefix(contentType, "image/")
+}
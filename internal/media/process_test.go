@@ -0,0 +1,680 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memObject struct {
+	data        []byte
+	contentType string
+}
+
+// in-memory Storage, Put fails for keys containing failOn
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string]memObject
+	failOn  string
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objects: map[string]memObject{}}
+}
+
+func (s *memStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if s.failOn != "" && strings.Contains(key, s.failOn) {
+		return errors.New("storage unavailable")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	// S3 rejects a body that doesn't match the declared size
+	if int64(len(data)) != size {
+		return fmt.Errorf("put %s: size %d, got %d bytes", key, size, len(data))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memObject{data: data, contentType: contentType}
+	return nil
+}
+
+func (s *memStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (s *memStorage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memStorage) URL(key string) string {
+	return "https://media.example.com/" + key
+}
+
+func (s *memStorage) object(t *testing.T, key string) memObject {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		t.Fatalf("nothing stored at %s", key)
+	}
+	return obj
+}
+
+func (s *memStorage) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.objects)
+}
+
+var owner = primitive.ObjectID{0x01}
+
+// gradient so resizing and encoding have something to work with
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), 128, 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// animated gif with the given number of frames
+func encodeGIF(t *testing.T, w, h, frames int) []byte {
+	t.Helper()
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9)
+		for p := range frame.Pix {
+			frame.Pix[p] = uint8(p + i*7)
+		}
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decode(t *testing.T, data []byte) (image.Image, string) {
+	t.Helper()
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("stored object doesn't decode: %v", err)
+	}
+	return img, format
+}
+
+func TestProcessSniffsContent(t *testing.T) {
+	pngData := encodePNG(t, testImage(16, 16))
+	jpegData := encodeJPEG(t, testImage(16, 16))
+	gifData := encodeGIF(t, 16, 16, 1)
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		// sniffed type, empty when the upload is refused
+		want string
+	}{
+		{"png named as jpeg", "photo.jpg", pngData, "image/png"},
+		{"jpeg named as png", "photo.png", jpegData, "image/jpeg"},
+		{"gif without an extension", "animation", gifData, "image/gif"},
+		{"html named as png", "x.png", []byte("<!DOCTYPE html><html><script>alert(1)</script></html>"), ""},
+		{"svg", "logo.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`), ""},
+		{"pdf", "doc.png", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), ""},
+		{"webp", "photo.webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 \x18\x00\x00\x00"), ""},
+		{"bmp", "photo.bmp", append([]byte("BM"), make([]byte, 64)...), ""},
+		{"plain text", "notes.png", []byte("just some text"), ""},
+		{"empty", "empty.png", nil, ""},
+		{"png magic only", "broken.png", pngData[:16], ""},
+		{"truncated png", "broken.png", pngData[:len(pngData)-20], ""},
+		{"png magic then html", "polyglot.png", append(append([]byte{}, pngData[:8]...), "<html><script>alert(1)</script>"...), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMemStorage()
+			m, err := NewService(storage, 1<<20).Process(context.Background(), owner, tt.filename, bytes.NewReader(tt.data))
+
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnsupportedType) {
+					t.Fatalf("got %v, want ErrUnsupportedType", err)
+				}
+				if n := storage.len(); n != 0 {
+					t.Errorf("%d objects stored for a refused upload", n)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.ContentType != tt.want {
+				t.Errorf("content type = %s, want %s", m.ContentType, tt.want)
+			}
+			if ext := "." + strings.TrimPrefix(tt.want, "image/"); !strings.HasSuffix(m.Key, ext) {
+				t.Errorf("key %s doesn't end in %s", m.Key, ext)
+			}
+			obj := storage.object(t, m.Key)
+			if obj.contentType != tt.want {
+				t.Errorf("stored as %s, want %s", obj.contentType, tt.want)
+			}
+			if _, format := decode(t, obj.data); "image/"+format != tt.want {
+				t.Errorf("stored object is a %s", format)
+			}
+		})
+	}
+}
+
+func TestProcessRecord(t *testing.T) {
+	storage := newMemStorage()
+	m, err := NewService(storage, 1<<20).Process(context.Background(), owner, "../../etc/cat.png", bytes.NewReader(encodePNG(t, testImage(30, 20))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the base name is kept, and it plays no part in the key
+	if m.Filename != "cat.png" {
+		t.Errorf("filename = %q, want cat.png", m.Filename)
+	}
+	if want := owner.Hex() + "/" + m.ID.Hex() + "/original.png"; m.Key != want {
+		t.Errorf("key = %s, want %s", m.Key, want)
+	}
+	if m.URL != storage.URL(m.Key) {
+		t.Errorf("url = %s, want %s", m.URL, storage.URL(m.Key))
+	}
+	if m.Owner != owner || m.Width != 30 || m.Height != 20 {
+		t.Errorf("owner %s %dx%d, want %s 30x20", m.Owner.Hex(), m.Width, m.Height, owner.Hex())
+	}
+	if m.Size != int64(len(storage.object(t, m.Key).data)) {
+		t.Errorf("size %d doesn't match the stored object", m.Size)
+	}
+	if m.ID.IsZero() || m.Timestamp.IsZero() {
+		t.Errorf("id %s or timestamp %v not set", m.ID.Hex(), m.Timestamp)
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	data := encodePNG(t, testImage(16, 16))
+
+	t.Run("at the limit", func(t *testing.T) {
+		if _, err := NewService(newMemStorage(), int64(len(data))).Process(context.Background(), owner, "a.png", bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("a byte over", func(t *testing.T) {
+		storage := newMemStorage()
+		_, err := NewService(storage, int64(len(data)-1)).Process(context.Background(), owner, "a.png", bytes.NewReader(data))
+		if !errors.Is(err, ErrTooLarge) {
+			t.Fatalf("got %v, want ErrTooLarge", err)
+		}
+		if storage.len() != 0 {
+			t.Error("oversize upload was stored")
+		}
+	})
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		// a tiny gif whose header claims 10000x10000
+		bomb := encodeGIF(t, 1, 1, 1)
+		binary.LittleEndian.PutUint16(bomb[6:], 10000)
+		binary.LittleEndian.PutUint16(bomb[8:], 10000)
+
+		storage := newMemStorage()
+		_, err := NewService(storage, 1<<20).Process(context.Background(), owner, "bomb.gif", bytes.NewReader(bomb))
+		if !errors.Is(err, ErrTooLarge) {
+			t.Fatalf("got %v, want ErrTooLarge", err)
+		}
+		if storage.len() != 0 {
+			t.Error("bomb was stored")
+		}
+	})
+
+	// a blank frame compresses to almost nothing, so repeating it makes a
+	// small file that decodes to far more than the pixel budget
+	t.Run("animated gif bomb", func(t *testing.T) {
+		var blank bytes.Buffer
+		if err := gif.Encode(&blank, image.NewPaletted(image.Rect(0, 0, 2000, 2000), palette.Plan9), nil); err != nil {
+			t.Fatal(err)
+		}
+		bomb := repeatFrame(t, blank.Bytes(), 20)
+		if len(bomb) > 1<<20 {
+			t.Fatalf("bad test, bomb is %d bytes", len(bomb))
+		}
+
+		storage := newMemStorage()
+		_, err := NewService(storage, 1<<20).Process(context.Background(), owner, "bomb.gif", bytes.NewReader(bomb))
+		if !errors.Is(err, ErrTooLarge) {
+			t.Fatalf("got %v, want ErrTooLarge", err)
+		}
+		if storage.len() != 0 {
+			t.Error("bomb was stored")
+		}
+	})
+
+	t.Run("too many frames", func(t *testing.T) {
+		many := repeatFrame(t, encodeGIF(t, 1, 1, 1), maxFrames+1)
+		_, err := NewService(newMemStorage(), 1<<20).Process(context.Background(), owner, "many.gif", bytes.NewReader(many))
+		if !errors.Is(err, ErrTooLarge) {
+			t.Fatalf("got %v, want ErrTooLarge", err)
+		}
+	})
+
+	t.Run("frames at the limit", func(t *testing.T) {
+		gifData := repeatFrame(t, encodeGIF(t, 1, 1, 1), maxFrames)
+		if _, err := NewService(newMemStorage(), 1<<20).Process(context.Background(), owner, "many.gif", bytes.NewReader(gifData)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// copies the only frame of a single-frame gif n times
+func repeatFrame(t *testing.T, single []byte, n int) []byte {
+	t.Helper()
+	// header, screen descriptor and global color table, then the frame
+	// with its control extension, then the trailer
+	start := 13 + colorTableSize(single[10])
+	if single[start] != 0x21 && single[start] != 0x2C {
+		t.Fatalf("bad test gif, no frame at %d", start)
+	}
+	frame := single[start : len(single)-1]
+	out := append([]byte{}, single[:start]...)
+	out = append(out, bytes.Repeat(frame, n)...)
+	return append(out, 0x3B)
+}
+
+func TestGIFFrames(t *testing.T) {
+	data := encodeGIF(t, 30, 20, 3)
+	frames, pixels, err := gifFrames(data)
+	if err != nil || frames != 3 || pixels != 3*30*20 {
+		t.Errorf("gifFrames() = %d, %d, %v, want 3, %d", frames, pixels, err, 3*30*20)
+	}
+
+	for name, bad := range map[string][]byte{
+		"header only":    data[:13],
+		"no trailer":     data[:len(data)-1],
+		"cut mid-frame":  data[:len(data)/2],
+		"unknown block":  append(append([]byte{}, data[:len(data)-1]...), 0x99, 0x3B),
+		"cut descriptor": append(append([]byte{}, data[:len(data)-1]...), 0x2C, 0, 0),
+	} {
+		if _, _, err := gifFrames(bad); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestProcessGIFLogicalScreen(t *testing.T) {
+	// the first frame covers only part of the screen
+	frame := image.NewPaletted(image.Rect(10, 10, 60, 50), palette.Plan9)
+	g := &gif.GIF{
+		Image:  []*image.Paletted{frame},
+		Delay:  []int{0},
+		Config: image.Config{ColorModel: color.Palette(palette.Plan9), Width: 300, Height: 240},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	storage := newMemStorage()
+	m, err := NewService(storage, 1<<20).Process(context.Background(), owner, "a.gif", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 300 || m.Height != 240 {
+		t.Errorf("record says %dx%d, want the 300x240 screen", m.Width, m.Height)
+	}
+	if len(m.Variants) != 1 || m.Variants[0].Width != 200 || m.Variants[0].Height != 160 {
+		t.Errorf("variants %+v, want a 200x160 thumb of the screen", m.Variants)
+	}
+}
+
+// marker bytes of every segment ahead of the scan data
+func jpegMarkers(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var markers []byte
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			t.Fatalf("no marker at %d", pos)
+		}
+		markers = append(markers, data[pos+1])
+		if data[pos+1] == 0xDA {
+			break
+		}
+		pos += 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+	}
+	return markers
+}
+
+func pngChunk(typ, data string) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ+data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE([]byte(typ+data)))
+}
+
+func pngChunkTypes(t *testing.T, data []byte) []string {
+	t.Helper()
+	var types []string
+	for pos := 8; pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		types = append(types, string(data[pos+4:pos+8]))
+		pos += 12 + length
+	}
+	return types
+}
+
+// the private bits every stripping test plants
+const secret = "GPS 51.5007N 0.1246W, serial 4C0FFEE"
+
+func checkStripped(t *testing.T, storage *memStorage, m *models.Media) {
+	t.Helper()
+	keys := []string{m.Key}
+	for _, v := range m.Variants {
+		keys = append(keys, v.Key)
+	}
+	for _, key := range keys {
+		data := storage.object(t, key).data
+		if bytes.Contains(data, []byte(secret)) || bytes.Contains(data, []byte("Exif\x00\x00")) {
+			t.Errorf("%s still carries metadata", key)
+		}
+	}
+}
+
+func TestProcessStripsMetadata(t *testing.T) {
+	t.Run("jpeg exif and comments", func(t *testing.T) {
+		data := withSegments(encodeJPEG(t, testImage(400, 300)),
+			jpegSegment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")),
+			exifSegment(binary.BigEndian, 1, secret),
+			jpegSegment(0xE2, []byte("ICC_PROFILE\x00"+secret)),
+			jpegSegment(0xFE, []byte(secret)),
+		)
+		storage := newMemStorage()
+		m, err := NewService(storage, 1<<20).Process(context.Background(), owner, "photo.jpg", bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Variants) == 0 {
+			t.Fatal("no variants to check")
+		}
+		checkStripped(t, storage, m)
+
+		for _, key := range []string{m.Key, m.Variants[0].Key} {
+			for _, marker := range jpegMarkers(t, storage.object(t, key).data) {
+				// APPn segments and comments
+				if marker >= 0xE0 && marker <= 0xEF || marker == 0xFE {
+					t.Errorf("%s keeps segment %#x", key, marker)
+				}
+			}
+		}
+	})
+
+	t.Run("png text chunks", func(t *testing.T) {
+		data := encodePNG(t, testImage(400, 300))
+		// after the 8 byte signature and 25 byte IHDR
+		var withText []byte
+		withText = append(withText, data[:33]...)
+		withText = append(withText, pngChunk("tEXt", "Comment\x00"+secret)...)
+		withText = append(withText, pngChunk("eXIf", "MM\x00\x2a"+secret)...)
+		withText = append(withText, data[33:]...)
+
+		storage := newMemStorage()
+		m, err := NewService(storage, 1<<20).Process(context.Background(), owner, "shot.png", bytes.NewReader(withText))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkStripped(t, storage, m)
+
+		for _, key := range []string{m.Key, m.Variants[0].Key} {
+			for _, typ := range pngChunkTypes(t, storage.object(t, key).data) {
+				switch typ {
+				case "IHDR", "PLTE", "tRNS", "IDAT", "IEND":
+				default:
+					t.Errorf("%s keeps chunk %s", key, typ)
+				}
+			}
+		}
+	})
+
+	t.Run("gif comments", func(t *testing.T) {
+		data := encodeGIF(t, 300, 200, 2)
+		// a comment extension just ahead of the trailer
+		comment := append([]byte{0x21, 0xFE, byte(len(secret))}, secret...)
+		comment = append(comment, 0)
+		data = append(append(data[:len(data)-1:len(data)-1], comment...), 0x3B)
+		if _, err := gif.DecodeAll(bytes.NewReader(data)); err != nil {
+			t.Fatalf("bad test gif: %v", err)
+		}
+
+		storage := newMemStorage()
+		m, err := NewService(storage, 1<<20).Process(context.Background(), owner, "anim.gif", bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkStripped(t, storage, m)
+	})
+}
+
+func TestProcessAppliesOrientation(t *testing.T) {
+	// 40x20, red on the left half and blue on the right
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 20 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	jpg := encodeJPEG(t, src)
+
+	for name, order := range map[string]binary.AppendByteOrder{"big endian": binary.BigEndian, "little endian": binary.LittleEndian} {
+		t.Run(name, func(t *testing.T) {
+			storage := newMemStorage()
+			// 6 is rotate 90 clockwise to display
+			data := withSegments(jpg, exifSegment(order, 6, secret))
+			m, err := NewService(storage, 1<<20).Process(context.Background(), owner, "portrait.jpg", bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Width != 20 || m.Height != 40 {
+				t.Errorf("record says %dx%d, want 20x40", m.Width, m.Height)
+			}
+
+			stored := storage.object(t, m.Key).data
+			if o := jpegOrientation(stored); o != 1 {
+				t.Errorf("stored orientation %d, the tag should be gone", o)
+			}
+			img, _ := decode(t, stored)
+			if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+				t.Fatalf("stored %dx%d, want 20x40", b.Dx(), b.Dy())
+			}
+			// the left half ends up on top
+			if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+				t.Errorf("top is %v, want red", img.At(10, 5))
+			}
+			if r, _, b, _ := img.At(10, 35).RGBA(); b < r {
+				t.Errorf("bottom is %v, want blue", img.At(10, 35))
+			}
+		})
+	}
+}
+
+func TestProcessVariants(t *testing.T) {
+	type size struct {
+		name string
+		w, h int
+	}
+	tests := []struct {
+		name string
+		w, h int
+		want []size
+	}{
+		{"landscape", 2000, 1000, []size{{"thumb", 200, 100}, {"medium", 800, 400}, {"large", 1600, 800}}},
+		{"portrait", 1000, 2000, []size{{"thumb", 100, 200}, {"medium", 400, 800}, {"large", 800, 1600}}},
+		{"between sizes", 500, 250, []size{{"thumb", 200, 100}}},
+		{"exactly a size", 800, 600, []size{{"thumb", 200, 150}}},
+		{"no bigger than a thumb", 200, 200, nil},
+		{"sliver", 3000, 2, []size{{"thumb", 200, 1}, {"medium", 800, 1}, {"large", 1600, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMemStorage()
+			m, err := NewService(storage, 16<<20).Process(context.Background(), owner, "img.png", bytes.NewReader(encodePNG(t, testImage(tt.w, tt.h))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// always a list, never null in the API
+			if m.Variants == nil {
+				t.Fatal("variants is nil")
+			}
+			if len(m.Variants) != len(tt.want) {
+				t.Fatalf("got %d variants, want %d: %+v", len(m.Variants), len(tt.want), m.Variants)
+			}
+			if n := storage.len(); n != 1+len(tt.want) {
+				t.Errorf("%d objects stored, want %d", n, 1+len(tt.want))
+			}
+
+			prefix := owner.Hex() + "/" + m.ID.Hex() + "/"
+			for i, v := range m.Variants {
+				want := tt.want[i]
+				if v.Name != want.name || v.Width != want.w || v.Height != want.h {
+					t.Errorf("variant %d is %s %dx%d, want %s %dx%d", i, v.Name, v.Width, v.Height, want.name, want.w, want.h)
+				}
+				if v.Key != prefix+want.name+".png" || v.URL != storage.URL(v.Key) {
+					t.Errorf("variant %s at %s (%s)", v.Name, v.Key, v.URL)
+				}
+				obj := storage.object(t, v.Key)
+				if obj.contentType != "image/png" || v.Size != int64(len(obj.data)) {
+					t.Errorf("variant %s stored as %s, %d bytes (record says %d)", v.Name, obj.contentType, len(obj.data), v.Size)
+				}
+				img, _ := decode(t, obj.data)
+				if b := img.Bounds(); b.Dx() != want.w || b.Dy() != want.h {
+					t.Errorf("variant %s decodes as %dx%d", v.Name, b.Dx(), b.Dy())
+				}
+			}
+		})
+	}
+}
+
+func TestProcessVariantFormats(t *testing.T) {
+	t.Run("jpeg stays jpeg", func(t *testing.T) {
+		storage := newMemStorage()
+		m, err := NewService(storage, 1<<20).Process(context.Background(), owner, "a.jpg", bytes.NewReader(encodeJPEG(t, testImage(300, 300))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := m.Variants[0]
+		if !strings.HasSuffix(v.Key, "/thumb.jpeg") || storage.object(t, v.Key).contentType != "image/jpeg" {
+			t.Errorf("thumb at %s as %s, want jpeg", v.Key, storage.object(t, v.Key).contentType)
+		}
+	})
+
+	t.Run("gif variants are png stills", func(t *testing.T) {
+		storage := newMemStorage()
+		m, err := NewService(storage, 1<<20).Process(context.Background(), owner, "a.gif", bytes.NewReader(encodeGIF(t, 300, 200, 3)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the original keeps its animation
+		original, err := gif.DecodeAll(bytes.NewReader(storage.object(t, m.Key).data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(original.Image) != 3 {
+			t.Errorf("original has %d frames, want 3", len(original.Image))
+		}
+
+		v := m.Variants[0]
+		obj := storage.object(t, v.Key)
+		if !strings.HasSuffix(v.Key, "/thumb.png") || obj.contentType != "image/png" {
+			t.Errorf("thumb at %s as %s, want png", v.Key, obj.contentType)
+		}
+		if _, format := decode(t, obj.data); format != "png" {
+			t.Errorf("thumb is a %s", format)
+		}
+	})
+}
+
+func TestProcessCleansUpAfterFailedPut(t *testing.T) {
+	storage := newMemStorage()
+	storage.failOn = "/medium."
+	_, err := NewService(storage, 16<<20).Process(context.Background(), owner, "big.png", bytes.NewReader(encodePNG(t, testImage(2000, 1000))))
+	if err == nil {
+		t.Fatal("expected the failed put to fail the upload")
+	}
+	// the original and thumb were stored before the failure
+	if n := storage.len(); n != 0 {
+		t.Errorf("%d objects left behind", n)
+	}
+}
+
+func TestServiceDelete(t *testing.T) {
+	storage := newMemStorage()
+	s := NewService(storage, 16<<20)
+	m, err := s.Process(context.Background(), owner, "big.png", bytes.NewReader(encodePNG(t, testImage(1000, 500))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep, err := s.Process(context.Background(), owner, "other.png", bytes.NewReader(encodePNG(t, testImage(10, 10))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if n := storage.len(); n != 1 {
+		t.Errorf("%d objects left, want only the other upload", n)
+	}
+	storage.object(t, keep.Key)
+}
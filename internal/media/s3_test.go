@@ -0,0 +1,319 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	s3AccessKey = "test-access"
+	s3Bucket    = "media"
+)
+
+type s3Object struct {
+	data   []byte
+	header http.Header
+}
+
+// just enough of the S3 API, path-style, for what S3Storage calls
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]s3Object
+	// bucket creations, to check existing buckets are reused
+	created int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{buckets: map[string]map[string]s3Object{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// requests must be signed with the configured key
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="+s3AccessKey+"/") {
+		s3Error(w, r, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	objects, exists := f.buckets[bucket]
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead && exists:
+		case r.Method == http.MethodHead:
+			s3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		case r.Method == http.MethodPut && exists:
+			s3Error(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou")
+		case r.Method == http.MethodPut:
+			f.buckets[bucket] = map[string]s3Object{}
+			f.created++
+		default:
+			s3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+		}
+		return
+	}
+	if !exists {
+		s3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := s3Body(r)
+		if err != nil {
+			s3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		objects[key] = s3Object{data: data, header: http.Header{
+			"Content-Type":  {r.Header.Get("Content-Type")},
+			"Cache-Control": {r.Header.Get("Cache-Control")},
+		}}
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		obj, ok := objects[key]
+		if !ok {
+			s3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for k, v := range obj.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		// deleting a missing key succeeds in S3
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) object(key string) (s3Object, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.buckets[s3Bucket][key]
+	return obj, ok
+}
+
+func (f *fakeS3) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.buckets[s3Bucket])
+}
+
+func s3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message><Resource>%s</Resource><RequestId>test</RequestId></Error>`, code, code, r.URL.Path)
+	}
+}
+
+// without TLS the client signs each chunk of the body (aws-chunked),
+// "<hex size>;chunk-signature=...\r\n<data>\r\n" ending in a zero-size chunk
+func s3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	br := bufio.NewReader(r.Body)
+	var data []byte
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			break
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+	if decoded := r.Header.Get("X-Amz-Decoded-Content-Length"); decoded != strconv.Itoa(len(data)) {
+		return nil, fmt.Errorf("decoded length %s, got %d bytes", decoded, len(data))
+	}
+	return data, nil
+}
+
+func newTestS3Storage(t *testing.T, srv *httptest.Server, publicURL string) *S3Storage {
+	t.Helper()
+	s, err := NewS3Storage(context.Background(), S3Options{
+		Endpoint:  srv.Listener.Addr().String(),
+		Region:    "us-east-1",
+		Bucket:    s3Bucket,
+		AccessKey: s3AccessKey,
+		SecretKey: "test-secret",
+		PublicURL: publicURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestS3StorageCreatesBucketOnce(t *testing.T) {
+	fake, srv := newFakeS3(t)
+	newTestS3Storage(t, srv, "")
+	newTestS3Storage(t, srv, "")
+	if fake.created != 1 {
+		t.Errorf("bucket created %d times, want once", fake.created)
+	}
+}
+
+func TestS3StorageBadCredentials(t *testing.T) {
+	_, srv := newFakeS3(t)
+	_, err := NewS3Storage(context.Background(), S3Options{
+		Endpoint:  srv.Listener.Addr().String(),
+		Region:    "us-east-1",
+		Bucket:    s3Bucket,
+		AccessKey: "someone-else",
+		SecretKey: "test-secret",
+	})
+	if err == nil {
+		t.Fatal("expected an error for a rejected key")
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	fake, srv := newFakeS3(t)
+	s := newTestS3Storage(t, srv, "")
+	ctx := context.Background()
+
+	// larger than one signed chunk
+	data := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+	const key = "owner/id/original.png"
+	if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, ok := fake.object(key)
+	if !ok {
+		t.Fatal("object not stored")
+	}
+	if !bytes.Equal(obj.data, data) {
+		t.Errorf("stored %d bytes, want the %d put", len(obj.data), len(data))
+	}
+	if ct := obj.header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("content type = %q", ct)
+	}
+	// keys are never reused, so objects can be cached forever
+	if cc := obj.header.Get("Cache-Control"); cc != "public, max-age=31536000, immutable" {
+		t.Errorf("cache control = %q", cc)
+	}
+
+	rc, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %d bytes back, want %d", len(got), len(data))
+	}
+
+	if _, err := s.Get(ctx, "owner/id/missing.png"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("missing key: got %v, want ErrObjectNotFound", err)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.object(key); ok {
+		t.Error("object still there after delete")
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("deleted key: got %v, want ErrObjectNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("deleting again: %v", err)
+	}
+}
+
+func TestS3StorageURL(t *testing.T) {
+	_, srv := newFakeS3(t)
+	host := srv.Listener.Addr().String()
+
+	tests := []struct {
+		publicURL string
+		want      string
+	}{
+		// path-style on the endpoint by default
+		{"", "http://" + host + "/media/a/b.png"},
+		{"https://cdn.example.com", "https://cdn.example.com/a/b.png"},
+		{"https://cdn.example.com/media/", "https://cdn.example.com/media/a/b.png"},
+	}
+	for _, tt := range tests {
+		if got := newTestS3Storage(t, srv, tt.publicURL).URL("a/b.png"); got != tt.want {
+			t.Errorf("URL with public url %q = %s, want %s", tt.publicURL, got, tt.want)
+		}
+	}
+}
+
+func TestProcessWithS3Storage(t *testing.T) {
+	fake, srv := newFakeS3(t)
+	s := NewService(newTestS3Storage(t, srv, "https://cdn.example.com"), 16<<20)
+	ctx := context.Background()
+
+	m, err := s.Process(ctx, owner, "photo.jpg", bytes.NewReader(encodeJPEG(t, testImage(1000, 500))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.len(); n != 1+len(m.Variants) || len(m.Variants) != 2 {
+		t.Fatalf("%d objects stored for %d variants, want the original and 2", n, len(m.Variants))
+	}
+
+	if m.URL != "https://cdn.example.com/"+m.Key {
+		t.Errorf("url = %s", m.URL)
+	}
+	for _, v := range m.Variants {
+		obj, ok := fake.object(v.Key)
+		if !ok {
+			t.Fatalf("variant %s not stored", v.Name)
+		}
+		if int64(len(obj.data)) != v.Size || obj.header.Get("Content-Type") != "image/jpeg" {
+			t.Errorf("variant %s stored as %s, %d bytes (record says %d)", v.Name, obj.header.Get("Content-Type"), len(obj.data), v.Size)
+		}
+		img, _ := decode(t, obj.data)
+		if b := img.Bounds(); b.Dx() != v.Width || b.Dy() != v.Height {
+			t.Errorf("variant %s decodes as %dx%d, record says %dx%d", v.Name, b.Dx(), b.Dy(), v.Width, v.Height)
+		}
+	}
+
+	if err := s.Delete(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.len(); n != 0 {
+		t.Errorf("%d objects left after delete", n)
+	}
+}
@@ -0,0 +1,18 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// blob store for uploaded files, keys are slash-separated relative paths
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// public URL clients use to fetch the object
+	URL(key string) string
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// holds every collector exposed on /metrics
+// methods are safe to call on a nil *Metrics so callers don't need to check
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	logins           *prometheus.CounterVec
+	tokenValidations prometheus.Gauge
+
+	dbDuration *prometheus.HistogramVec
+	dbErrors   *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route pattern, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_logins_total",
+			Help: "Login attempts by method and result.",
+		}, []string{"method", "result"}),
+		tokenValidations: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "auth_token_validations_in_flight",
+			Help: "JWT validations currently in progress.",
+		}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_operation_duration_seconds",
+			Help:    "MongoDB repository operation latency.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"collection", "operation"}),
+		dbErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_operation_errors_total",
+			Help: "MongoDB repository operations that failed, not counting not found, conflict and stale version results.",
+		}, []string{"collection", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.logins,
+		m.tokenValidations,
+		m.dbDuration,
+		m.dbErrors,
+	)
+
+	return m
+}
+
+// middleware recording count and latency per chi route pattern
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		// pattern is only known once chi has routed the request
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"route":  route,
+			"method": r.Method,
+			"status": strconv.Itoa(status),
+		}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// records a login attempt, method is "password" or "google"
+func (m *Metrics) ObserveLogin(method string, success bool) {
+	if m == nil {
+		return
+	}
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	m.logins.WithLabelValues(method, result).Inc()
+}
+
+// marks a token validation as started, call the returned func when done
+func (m *Metrics) TrackTokenValidation() func() {
+	if m == nil {
+		return func() {}
+	}
+	m.tokenValidations.Inc()
+	return m.tokenValidations.Dec
+}
+
+// times a single repository call, satisfies repository.Observer
+func (m *Metrics) StartOp(ctx context.Context, collection, operation string) (context.Context, func(err error)) {
+	if m == nil {
+		return ctx, func(error) {}
+	}
+	start := time.Now()
+	return ctx, func(err error) {
+		m.dbDuration.WithLabelValues(collection, operation).Observe(time.Since(start).Seconds())
+		if failed(err) {
+			m.dbErrors.WithLabelValues(collection, operation).Inc()
+		}
+	}
+}
+
+// whether a repository call's error is a failure rather than an answer, a
+// missing document, a duplicate or a stale version is what the caller asked
+// about and not an error of the database's
+func failed(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, apperr.ErrNotFound), errors.Is(err, apperr.ErrConflict), errors.Is(err, apperr.ErrPreconditionFailed):
+		return false
+	}
+	return true
+}
+
+// exposition handler, requires "Bearer <token>" when token is set
+func (m *Metrics) Handler(token string) http.Handler {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return h
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
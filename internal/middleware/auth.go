@@ -2,14 +2,17 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/metrics"
 	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/problem"
 	"github.com/kurtgray/blog-api-go/internal/repository"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
@@ -31,20 +34,26 @@ type JWTClaims struct {
 
 // service to handle operations
 type AuthService struct {
-	userRepo  repository.UserRepository
-	jwtSecret string
+	userRepo   repository.UserRepository
+	jwtSecret  string
+	tokenTTL   time.Duration
+	bcryptCost int
+	metrics    *metrics.Metrics
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string) *AuthService {
+func NewAuthService(userRepo repository.UserRepository, cfg config.AuthConfig, m *metrics.Metrics) *AuthService {
 	return &AuthService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:   userRepo,
+		jwtSecret:  cfg.JWTSecret,
+		tokenTTL:   cfg.TokenTTL,
+		bcryptCost: cfg.BcryptCost,
+		metrics:    m,
 	}
 }
 
 // hashes plain string
 func (s *AuthService) HashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
 	if err != nil {
 		return "", err
 	}
@@ -65,8 +74,8 @@ func (s *AuthService) GenerateToken(user *models.User) (string, error) {
 		Admin:      user.Admin,
 		CanPublish: user.CanPublish,
 		RegisteredClaims: jwt.RegisteredClaims{
-			// exp in 24h
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			// exp after configured ttl, 24h by default
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -78,6 +87,8 @@ func (s *AuthService) GenerateToken(user *models.User) (string, error) {
 
 // validates a JWT token, returns claims
 func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
+	defer s.metrics.TrackTokenValidation()()
+
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Verify signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -98,49 +109,79 @@ func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 func (s *AuthService) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract token from Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			respondWithError(w, http.StatusUnauthorized, "missing authorization header")
-			return
-		}
-
-		// format: "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			respondWithError(w, http.StatusUnauthorized, "invalid authorization format")
+		if r.Header.Get("Authorization") == "" {
+			problem.Write(w, r, problem.New(http.StatusUnauthorized, "missing_token", "missing authorization header"))
 			return
 		}
 
-		// <token>
-		tokenString := parts[1]
-
-		// validate
-		claims, err := s.ValidateToken(tokenString)
+		user, err := s.Authenticate(r.Context(), r.Header.Get("Authorization"))
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "invalid token")
+			problem.Write(w, r, err)
 			return
 		}
 
-		// string ID to ObjectID
-		userID, err := primitive.ObjectIDFromHex(claims.UserID)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "invalid user ID")
+		// Add user to request context
+		ctx := context.WithValue(r.Context(), UserContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// like RequireAuth, but a request without an Authorization header goes
+// through anonymously, a bad token is still a 401
+func (s *AuthService) OptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Fetch user from database
-		user, err := s.userRepo.FindByID(r.Context(), userID)
+		user, err := s.Authenticate(r.Context(), r.Header.Get("Authorization"))
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "user not found")
+			problem.Write(w, r, err)
 			return
 		}
 
-		// Add user to request context
 		ctx := context.WithValue(r.Context(), UserContextKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// the user a bearer token belongs to, authorization is an Authorization
+// header's value or, over gRPC, the authorization metadata's
+func (s *AuthService) Authenticate(ctx context.Context, authorization string) (*models.User, *problem.Problem) {
+	// format: "Bearer <token>"
+	parts := strings.Split(authorization, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, problem.New(http.StatusUnauthorized, "invalid_token", "invalid authorization format")
+	}
+
+	// <token>
+	tokenString := parts[1]
+
+	// validate
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, problem.New(http.StatusUnauthorized, "invalid_token", "invalid token")
+	}
+
+	// string ID to ObjectID
+	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return nil, problem.New(http.StatusUnauthorized, "invalid_token", "invalid user ID")
+	}
+
+	// Fetch user from database
+	user, err := s.userRepo.FindByID(ctx, userID)
+	// not found: the account was erased after the token was issued
+	if errors.Is(err, apperr.ErrNotFound) {
+		return nil, problem.New(http.StatusUnauthorized, "unknown_user", "user not found")
+	}
+	if err != nil {
+		return nil, problem.From(err)
+	}
+	return user, nil
+}
+
 // helper to get user from context
 func GetUserFromContext(ctx context.Context) (*models.User, error) {
 	user, ok := ctx.Value(UserContextKey).(*models.User)
@@ -149,13 +190,3 @@ func GetUserFromContext(ctx context.Context) (*models.User, error) {
 	}
 	return user, nil
 }
-
-// helper to send error responses
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": false,
-		"message": message,
-	})
-}
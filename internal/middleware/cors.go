@@ -2,19 +2,16 @@ package middleware
 
 import (
 	"github.com/go-chi/cors"
+	"github.com/kurtgray/blog-api-go/internal/config"
 )
 
-func SetupCORS() *cors.Cors {
+func SetupCORS(cfg config.CORSConfig) *cors.Cors {
 	return cors.New(cors.Options{
-		AllowedOrigins: []string{
-			"http://localhost:3000",
-			"https://morning-meadow-95658.herokuapp.com",
-			// "https://blog.kurtgray.dev", not currently working
-		},
+		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: true,
-		MaxAge:           300,
+		MaxAge:           cfg.MaxAge,
 	})
 }
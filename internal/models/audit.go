@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// audit actions
+const (
+	AuditExportRequested  = "privacy.export_requested"
+	AuditErasureRequested = "privacy.erasure_requested"
+	AuditErasureCancelled = "privacy.erasure_cancelled"
+	AuditErasureRejected  = "privacy.erasure_rejected"
+	AuditUserErased       = "privacy.user_erased"
+)
+
+// who did what to which record, details hold ids and counts, never
+// personal data, since entries outlive the users they mention
+type AuditEntry struct {
+	ID        primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	Actor     primitive.ObjectID     `json:"actor" bson:"actor"`
+	Action    string                 `json:"action" bson:"action"`
+	Target    primitive.ObjectID     `json:"target,omitempty" bson:"target,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	Timestamp time.Time              `json:"timestamp" bson:"timestamp"`
+}
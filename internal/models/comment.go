@@ -6,12 +6,19 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// HTML is rendered from Text (restricted markdown) on every write
 type Comment struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	Post      primitive.ObjectID `json:"post" bson:"post"`
 	Author    primitive.ObjectID `json:"author" bson:"author"`
 	Text      string             `json:"text" bson:"text"`
+	HTML      string             `json:"html" bson:"html,omitempty"`
 	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
+	// bumped by every edit, the comment's ETag
+	Version int64 `json:"version" bson:"version"`
+	// set while the comment is in the trash
+	DeletedAt *time.Time          `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	DeletedBy *primitive.ObjectID `json:"deletedBy,omitempty" bson:"deletedBy,omitempty"`
 }
 
 type CommentWithAuthor struct {
@@ -19,5 +26,7 @@ type CommentWithAuthor struct {
 	Post      primitive.ObjectID `json:"post" bson:"post"`
 	Author    *UserResponse      `json:"author,omitempty" bson:"author,omitempty"`
 	Text      string             `json:"text" bson:"text"`
+	HTML      string             `json:"html" bson:"html,omitempty"`
+	Version   int64              `json:"version" bson:"version"`
 	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
 }
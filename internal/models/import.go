@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// kinds of imported record
+const (
+	ImportUser    = "user"
+	ImportPost    = "post"
+	ImportComment = "comment"
+)
+
+// links a record from another blog to the one created for it here,
+// so re-running an import skips it and its old URLs can redirect
+type ImportMapping struct {
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	// import source label, e.g. "wordpress-2019"
+	Source string `json:"source" bson:"source"`
+	Kind   string `json:"kind" bson:"kind"`
+	// id of the record in the source system
+	Key      string             `json:"key" bson:"key"`
+	TargetID primitive.ObjectID `json:"targetId" bson:"targetId"`
+	// normalized paths the record lived at on the old site
+	OldPaths   []string  `json:"oldPaths,omitempty" bson:"oldPaths,omitempty"`
+	ImportedAt time.Time `json:"importedAt" bson:"importedAt"`
+}
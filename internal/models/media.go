@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Media struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Owner       primitive.ObjectID `json:"owner" bson:"owner"`
+	Filename    string             `json:"filename" bson:"filename"`
+	ContentType string             `json:"contentType" bson:"contentType"`
+	Size        int64              `json:"size" bson:"size"`
+	Width       int                `json:"width" bson:"width"`
+	Height      int                `json:"height" bson:"height"`
+	Key         string             `json:"-" bson:"key"`
+	URL         string             `json:"url" bson:"url"`
+	Variants    []MediaVariant     `json:"variants" bson:"variants"`
+	Timestamp   time.Time          `json:"timestamp" bson:"timestamp"`
+}
+
+// resized copy of an uploaded image, e.g. "thumb"
+type MediaVariant struct {
+	Name   string `json:"name" bson:"name"`
+	Key    string `json:"-" bson:"key"`
+	URL    string `json:"url" bson:"url"`
+	Width  int    `json:"width" bson:"width"`
+	Height int    `json:"height" bson:"height"`
+	Size   int64  `json:"size" bson:"size"`
+}
@@ -1,27 +1,115 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// content formats for Post.Text, legacy posts without one are html
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatPlain    = "plain"
+)
+
+// heading in a rendered post, ID is its anchor
+type TOCEntry struct {
+	Level int    `json:"level" bson:"level"`
+	ID    string `json:"id" bson:"id"`
+	Text  string `json:"text" bson:"text"`
+}
+
+// author-set search and social overrides, empty fields fall back to
+// defaults generated from the post when metadata is built
+type SEO struct {
+	Title        string `json:"title,omitempty" bson:"title,omitempty" validate:"max=120" label:"SEO title"`
+	Description  string `json:"description,omitempty" bson:"description,omitempty" validate:"max=300" label:"SEO description"`
+	CanonicalURL string `json:"canonicalUrl,omitempty" bson:"canonicalUrl,omitempty" validate:"url,max=2048" label:"Canonical URL"`
+	Image        string `json:"image,omitempty" bson:"image,omitempty" validate:"link,max=2048" label:"SEO image"`
+	// keeps the post out of search engines and the sitemap
+	NoIndex bool `json:"noIndex,omitempty" bson:"noIndex,omitempty"`
+}
+
+// resolved page metadata for a post, built on read and never stored
+type PostMeta struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	CanonicalURL string `json:"canonicalUrl"`
+	Robots       string `json:"robots"`
+	// keyed by property, e.g. og:title and twitter:card
+	OpenGraph map[string]string `json:"openGraph"`
+	Twitter   map[string]string `json:"twitter"`
+	// schema.org BlogPosting, ready for a ld+json script tag
+	JSONLD map[string]interface{} `json:"jsonLd"`
+}
+
+// what the sitemap needs to know about a published post
+type PostRef struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Timestamp time.Time          `bson:"timestamp"`
+	UpdatedAt time.Time          `bson:"updatedAt"`
+}
+
+// HTML and TOC are rendered from Text on every write
+// MediaID is an uploaded image, ImgURL mirrors its URL
 type Post struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Author    primitive.ObjectID `json:"author" bson:"author"`
-	Title     string             `json:"title" bson:"title"`
-	Text      string             `json:"text" bson:"text"`
-	ImgURL    string             `json:"imgUrl,omitempty" bson:"imgUrl,omitempty"`
-	Published bool               `json:"published" bson:"published"`
-	Timestamp time.Time          `json:"timestamp" bson:"timestamp"`
+	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Author    primitive.ObjectID  `json:"author" bson:"author"`
+	Title     string              `json:"title" bson:"title"`
+	Text      string              `json:"text" bson:"text"`
+	Format    string              `json:"format" bson:"format,omitempty"`
+	HTML      string              `json:"html" bson:"html,omitempty"`
+	TOC       []TOCEntry          `json:"toc,omitempty" bson:"toc,omitempty"`
+	Tags      []string            `json:"tags" bson:"tags,omitempty"`
+	ImgURL    string              `json:"imgUrl,omitempty" bson:"imgUrl,omitempty"`
+	MediaID   *primitive.ObjectID `json:"mediaId,omitempty" bson:"mediaId,omitempty"`
+	SEO       *SEO                `json:"seo,omitempty" bson:"seo,omitempty"`
+	Published bool                `json:"published" bson:"published"`
+	// kept by the content service, see the integrity checker for drift
+	CommentCount int64 `json:"commentCount" bson:"commentCount"`
+	// bumped by every edit, the post's ETag
+	Version   int64     `json:"version" bson:"version"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+	// set while the post is in the trash, reads skip it until it's restored
+	DeletedAt *time.Time          `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	DeletedBy *primitive.ObjectID `json:"deletedBy,omitempty" bson:"deletedBy,omitempty"`
 }
 
 type PostWithAuthor struct {
-	ID        string        `json:"_id" bson:"_id,omitempty"`
-	Author    *UserResponse `json:"author,omitempty" bson:"author,omitempty"`
-	Title     string        `json:"title" bson:"title"`
-	Text      string        `json:"text" bson:"text"`
-	ImgURL    string        `json:"imgUrl,omitempty" bson:"imgUrl,omitempty"`
-	Published bool          `json:"published" bson:"published"`
-	Timestamp time.Time     `json:"timestamp" bson:"timestamp"`
+	ID           string        `json:"_id" bson:"_id,omitempty"`
+	Author       *UserResponse `json:"author,omitempty" bson:"author,omitempty"`
+	Title        string        `json:"title" bson:"title"`
+	Text         string        `json:"text" bson:"text"`
+	Format       string        `json:"format" bson:"format,omitempty"`
+	HTML         string        `json:"html" bson:"html,omitempty"`
+	TOC          []TOCEntry    `json:"toc,omitempty" bson:"toc,omitempty"`
+	Tags         []string      `json:"tags" bson:"tags,omitempty"`
+	ImgURL       string        `json:"imgUrl,omitempty" bson:"imgUrl,omitempty"`
+	MediaID      string        `json:"mediaId,omitempty" bson:"mediaId,omitempty"`
+	SEO          *SEO          `json:"seo,omitempty" bson:"seo,omitempty"`
+	Published    bool          `json:"published" bson:"published"`
+	CommentCount int64         `json:"commentCount" bson:"commentCount"`
+	Version      int64         `json:"version" bson:"version"`
+	Timestamp    time.Time     `json:"timestamp" bson:"timestamp"`
+	UpdatedAt    time.Time     `json:"updatedAt" bson:"updatedAt"`
+	// filled by single-post reads only
+	Meta *PostMeta `json:"meta,omitempty" bson:"-"`
+}
+
+// lowercases, trims and dedupes tags, inner spaces become dashes
+func NormalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DataExport.Status
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// a zip of everything stored about a user, built in the background
+type DataExport struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	User   primitive.ObjectID `json:"user" bson:"user"`
+	Status string             `json:"status" bson:"status"`
+	Error  string             `json:"error,omitempty" bson:"error,omitempty"`
+	// archive size in bytes once ready
+	Size        int64      `json:"size,omitempty" bson:"size,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	// ready exports are deleted after this
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
+
+// ErasureRequest.Status
+const (
+	ErasurePending   = "pending"
+	ErasureCompleted = "completed"
+	ErasureRejected  = "rejected"
+	ErasureCancelled = "cancelled"
+)
+
+// a user's request to have their account erased, carried out once an
+// admin approves it
+type ErasureRequest struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	User        primitive.ObjectID  `json:"user" bson:"user"`
+	Status      string              `json:"status" bson:"status"`
+	Reason      string              `json:"reason,omitempty" bson:"reason,omitempty"`
+	RequestedAt time.Time           `json:"requestedAt" bson:"requestedAt"`
+	ResolvedAt  *time.Time          `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"`
+	ResolvedBy  *primitive.ObjectID `json:"resolvedBy,omitempty" bson:"resolvedBy,omitempty"`
+}
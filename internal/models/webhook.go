@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// events a webhook can subscribe to
+const (
+	EventPostCreated    = "post.created"
+	EventPostUpdated    = "post.updated"
+	EventPostPublished  = "post.published"
+	EventPostDeleted    = "post.deleted"
+	EventCommentCreated = "comment.created"
+	EventCommentUpdated = "comment.updated"
+	EventCommentDeleted = "comment.deleted"
+	EventUserRegistered = "user.registered"
+)
+
+var WebhookEvents = []string{
+	EventPostCreated,
+	EventPostUpdated,
+	EventPostPublished,
+	EventPostDeleted,
+	EventCommentCreated,
+	EventCommentUpdated,
+	EventCommentDeleted,
+	EventUserRegistered,
+}
+
+// an endpoint that gets a signed POST for each event it subscribes to
+type Webhook struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	URL         string             `json:"url" bson:"url"`
+	Events      []string           `json:"events" bson:"events"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	// signs deliveries, only shown when it's set
+	Secret string `json:"-" bson:"secret"`
+	// inactive webhooks get nothing, set by hand or once it keeps failing
+	Active bool `json:"active" bson:"active"`
+	// deliveries in a row that failed every attempt, reset by any success
+	Failures       int                `json:"failures" bson:"failures"`
+	DisabledAt     *time.Time         `json:"disabledAt,omitempty" bson:"disabledAt,omitempty"`
+	DisabledReason string             `json:"disabledReason,omitempty" bson:"disabledReason,omitempty"`
+	CreatedBy      primitive.ObjectID `json:"createdBy" bson:"createdBy"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// WebhookDelivery.Status
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// one event sent to one webhook, retried until it succeeds or runs out of
+// attempts, kept as the webhook's delivery log
+type WebhookDelivery struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Webhook primitive.ObjectID `json:"webhook" bson:"webhook"`
+	Event   string             `json:"event" bson:"event"`
+	// the JSON body, the same bytes on every attempt
+	Payload  string `json:"payload" bson:"payload"`
+	Status   string `json:"status" bson:"status"`
+	Attempts int    `json:"attempts" bson:"attempts"`
+	// while pending
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty" bson:"nextAttemptAt,omitempty"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty" bson:"lastAttemptAt,omitempty"`
+	// what the endpoint answered last, the body cut short
+	ResponseStatus int    `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	ResponseBody   string `json:"responseBody,omitempty" bson:"responseBody,omitempty"`
+	Error          string `json:"error,omitempty" bson:"error,omitempty"`
+	// the delivery a manual redelivery repeats
+	RedeliveryOf *primitive.ObjectID `json:"redeliveryOf,omitempty" bson:"redeliveryOf,omitempty"`
+	CreatedAt    time.Time           `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,78 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// compares what the mux serves with routes, a route on one side only is an
+// error listing every mismatch
+// routes mounted for any method, e.g. file servers, are matched by a GET
+func Check(mux chi.Routes, routes []Route) error {
+	registered := map[string]bool{}
+	walk(mux, "", registered)
+
+	described := map[string]bool{}
+	for _, rt := range routes {
+		key := rt.Method + " " + rt.Path
+		if described[key] {
+			return fmt.Errorf("openapi: %s is described twice", key)
+		}
+		described[key] = true
+	}
+
+	var missing, extra []string
+	for key := range registered {
+		if described[key] {
+			continue
+		}
+		if path, ok := strings.CutPrefix(key, "* "); ok && described[http.MethodGet+" "+path] {
+			continue
+		}
+		missing = append(missing, key)
+	}
+	for key := range described {
+		path := key[strings.Index(key, " ")+1:]
+		if !registered[key] && !(strings.HasPrefix(key, http.MethodGet+" ") && registered["* "+path]) {
+			extra = append(extra, key)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	sort.Strings(extra)
+	var msg []string
+	for _, key := range missing {
+		msg = append(msg, "registered but not described: "+key)
+	}
+	for _, key := range extra {
+		msg = append(msg, "described but not registered: "+key)
+	}
+	return errors.New("openapi: routes and spec disagree:\n\t" + strings.Join(msg, "\n\t"))
+}
+
+// like chi.Walk, but keeps routes mounted for any method as one "*" entry
+// instead of one per method
+func walk(r chi.Routes, parent string, out map[string]bool) {
+	for _, route := range r.Routes() {
+		pattern := strings.ReplaceAll(parent+route.Pattern, "/*/", "/")
+		if route.SubRoutes != nil {
+			walk(route.SubRoutes, strings.TrimSuffix(pattern, "/*"), out)
+			continue
+		}
+		if _, any := route.Handlers["*"]; any {
+			out["* "+pattern] = true
+			continue
+		}
+		for method := range route.Handlers {
+			out[method+" "+pattern] = true
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+)
+
+// request bodies larger than this pass through unchecked, the handlers
+// reject anything past 1MB anyway
+const maxContractBody = 1 << 20
+
+// checks every request and response against the document, for tests and
+// staging, violations are logged
+// when strict, a response that breaks the document is replaced by a 500,
+// as is a success for a request that should have been rejected
+// only JSON bodies with a schema are buffered, files and feeds stream as
+// usual; HEAD, 304s and routes the document doesn't know are skipped
+func (d *Document) Contract(strict bool) func(http.Handler) http.Handler {
+	v := validator{schemas: d.Components.Schemas}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var reqBody []byte
+			if isJSON(r.Header.Get("Content-Type")) && r.Body != nil {
+				buf, err := io.ReadAll(io.LimitReader(r.Body, maxContractBody+1))
+				if err == nil && len(buf) <= maxContractBody {
+					reqBody = buf
+					r.Body = io.NopCloser(bytes.NewReader(buf))
+				} else {
+					r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+				}
+			}
+
+			rec := &contractWriter{
+				ResponseWriter: w,
+				header:         w.Header().Clone(),
+				request:        r,
+				doc:            d,
+			}
+			next.ServeHTTP(rec, r)
+			if !rec.wrote {
+				rec.WriteHeader(http.StatusOK)
+			}
+
+			op := d.operation(r)
+			if op == nil || rec.status == http.StatusNotModified {
+				rec.flush()
+				return
+			}
+
+			// a request the handler turned away needs no report
+			var reqErrs []string
+			if rec.status < 400 {
+				reqErrs = v.request(op, r, reqBody)
+			}
+			respErrs := v.response(op, rec)
+			for _, msg := range append(reqErrs, respErrs...) {
+				log.Printf("openapi: %s %s %d: %s", r.Method, r.URL.Path, rec.status, msg)
+			}
+
+			// nothing buffered has reached w yet, not even the handler's headers
+			if strict && rec.buffer && (len(respErrs) > 0 || len(reqErrs) > 0) {
+				problem.Write(w, r, problem.New(http.StatusInternalServerError, "contract_violation",
+					"The response doesn't match the API description."))
+				return
+			}
+			rec.flush()
+		})
+	}
+}
+
+// the operation chi routed r to, nil outside the document
+func (d *Document) operation(r *http.Request) *Operation {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.RoutePattern() == "" {
+		return nil
+	}
+	path, _ := openAPIPath(rctx.RoutePattern())
+	item := d.Paths[path]
+	if item == nil {
+		return nil
+	}
+	return (*item)[strings.ToLower(r.Method)]
+}
+
+// the documented response for status, errors fall back to default
+func (op *Operation) response(status int) *Response {
+	if resp := op.Responses[strconv.Itoa(status)]; resp != nil {
+		return resp
+	}
+	if status >= 400 {
+		return op.Responses["default"]
+	}
+	return nil
+}
+
+func (v validator) request(op *Operation, r *http.Request, body []byte) []string {
+	var errs []string
+	rctx := chi.RouteContext(r.Context())
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			key := p.Name
+			if key == "path" {
+				key = "*"
+			}
+			raw = rctx.URLParam(key)
+			present = true
+		case "query":
+			raw = query.Get(p.Name)
+			present = query.Has(p.Name)
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				errs = append(errs, p.In+" "+p.Name+": missing")
+			}
+			continue
+		}
+		v.check(p.Schema, paramValue(p.Schema, raw), p.In+" "+p.Name, &errs)
+	}
+
+	if op.RequestBody == nil || body == nil {
+		return errs
+	}
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	media, ok := op.RequestBody.Content[mt]
+	if !ok {
+		return append(errs, "request: content type "+mt+" isn't accepted")
+	}
+	if media.Schema == nil {
+		return errs
+	}
+	value, err := decodeJSON(body)
+	if err != nil {
+		return append(errs, "request: "+err.Error())
+	}
+	v.check(media.Schema, value, "request", &errs)
+	return errs
+}
+
+func (v validator) response(op *Operation, rec *contractWriter) []string {
+	resp := op.response(rec.status)
+	if resp == nil {
+		return []string{fmt.Sprintf("response: status %d isn't documented", rec.status)}
+	}
+	if !rec.buffer {
+		return nil
+	}
+	// errors in the legacy envelope aren't described
+	if rec.status >= 400 && rec.mediaType() != problem.ContentType {
+		return nil
+	}
+	media, ok := resp.Content[rec.mediaType()]
+	if !ok {
+		return []string{"response: content type " + rec.mediaType() + " isn't documented for " + strconv.Itoa(rec.status)}
+	}
+	if media.Schema == nil {
+		return nil
+	}
+	value, err := decodeJSON(rec.body.Bytes())
+	if err != nil {
+		return []string{"response: " + err.Error()}
+	}
+	var errs []string
+	v.check(media.Schema, value, "response", &errs)
+	return errs
+}
+
+// a query or path parameter as the JSON value its schema expects, left a
+// string when it doesn't parse so the type check reports it
+func paramValue(s *Schema, raw string) interface{} {
+	if s == nil {
+		return raw
+	}
+	for _, t := range typeList(s.Type) {
+		switch t {
+		case "integer", "number":
+			if _, err := strconv.ParseFloat(raw, 64); err == nil {
+				return json.Number(raw)
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(raw); err == nil {
+				return b
+			}
+		}
+	}
+	return raw
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return value, nil
+}
+
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// holds back headers until the status is known, then buffers the body when
+// there's a JSON schema to check it against
+type contractWriter struct {
+	http.ResponseWriter
+	// what the handler sets, copied over once the response is committed
+	header  http.Header
+	request *http.Request
+	doc     *Document
+
+	wrote  bool
+	status int
+	buffer bool
+	body   bytes.Buffer
+}
+
+func (cw *contractWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *contractWriter) WriteHeader(status int) {
+	if cw.wrote {
+		return
+	}
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.wrote = true
+	cw.status = status
+	if isJSON(cw.header.Get("Content-Type")) {
+		if op := cw.doc.operation(cw.request); op != nil {
+			if resp := op.response(status); resp != nil && resp.Content[cw.mediaType()].Schema != nil {
+				cw.buffer = true
+			}
+		}
+	}
+	if !cw.buffer {
+		cw.commitHeader()
+	}
+}
+
+func (cw *contractWriter) Write(b []byte) (int, error) {
+	if !cw.wrote {
+		if cw.header.Get("Content-Type") == "" {
+			cw.header.Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.buffer {
+		return cw.body.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// flushing a buffered body would defeat the check, so it waits for the end
+func (cw *contractWriter) Flush() {
+	if !cw.buffer {
+		if !cw.wrote {
+			cw.WriteHeader(http.StatusOK)
+		}
+		http.NewResponseController(cw.ResponseWriter).Flush()
+	}
+}
+
+func (cw *contractWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *contractWriter) mediaType() string {
+	mt, _, _ := mime.ParseMediaType(cw.header.Get("Content-Type"))
+	return mt
+}
+
+func (cw *contractWriter) commitHeader() {
+	dst := cw.ResponseWriter.Header()
+	clear(dst)
+	maps.Copy(dst, cw.header)
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// sends a buffered response as the handler wrote it
+func (cw *contractWriter) flush() {
+	if !cw.buffer {
+		return
+	}
+	cw.commitHeader()
+	cw.ResponseWriter.Write(cw.body.Bytes())
+}
@@ -0,0 +1,43 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+//go:embed docs.html
+var docsSource string
+
+var docsPage = template.Must(template.New("docs").Parse(docsSource))
+
+// serves the document as JSON, encoded once since it never changes while
+// the server runs
+func (d *Document) Handler() http.HandlerFunc {
+	body, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		panic("openapi: encoding document: " + err.Error())
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(body)
+	}
+}
+
+// GET /docs, a reader for the document at specURL that needs nothing from
+// outside the binary
+func DocsHandler(specURL string) http.HandlerFunc {
+	var page bytes.Buffer
+	if err := docsPage.Execute(&page, specURL); err != nil {
+		panic("openapi: rendering docs: " + err.Error())
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+		w.Write(page.Bytes())
+	}
+}
@@ -0,0 +1,304 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kurtgray/blog-api-go/internal/problem"
+)
+
+// an OpenAPI 3.1 document, only the parts this API uses
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// operations keyed by lower case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// who may call a route
+type Access int
+
+const (
+	Public Access = iota
+	// any signed in user, a bearer token from POST /api/users/login
+	User
+	// signed in admins only
+	Admin
+)
+
+// one registered route and what it takes and returns
+// Path is the chi pattern it's registered under, a trailing /* becomes a
+// {path} parameter
+type Route struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tag         string
+	Auth        Access
+	// path parameters default to a string, or an object ID for names
+	// ending in Id, list one here to describe it better
+	Params []Parameter
+	// a struct whose json-named fields are the query parameters
+	Query interface{}
+	// body by content type, values are Go values or a *Schema
+	Request map[string]interface{}
+	// reads honour If-None-Match and writes need If-Match, see the handlers'
+	// preconditions
+	Conditional bool
+	// replaced by a newer version of the route
+	Deprecated bool
+	Replies    []Reply
+}
+
+// a response a route gives besides its errors, bodies are keyed by content
+// type like Route.Request
+type Reply struct {
+	Status      int
+	Description string
+	Bodies      map[string]interface{}
+}
+
+func JSON(status int, description string, body interface{}) Reply {
+	return Reply{Status: status, Description: description, Bodies: JSONBody(body)}
+}
+
+// a body that isn't described any further, e.g. a feed or a file
+func Content(status int, description string, contentTypes ...string) Reply {
+	bodies := map[string]interface{}{}
+	for _, ct := range contentTypes {
+		bodies[ct] = (*Schema)(nil)
+	}
+	return Reply{Status: status, Description: description, Bodies: bodies}
+}
+
+// no body, e.g. a redirect
+func Empty(status int, description string) Reply {
+	return Reply{Status: status, Description: description}
+}
+
+// a JSON request body
+func JSONBody(v interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": v}
+}
+
+// the component problem.Problem is listed under
+const problemSchema = "Problem"
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// the document for routes
+func Build(info Info, tags []Tag, routes []Route) *Document {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Tags:    tags,
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	// errors are written by problem.Write, which adds the request path, the
+	// trace id when the request is traced and any extension members
+	problemRef := g.response(problem.Problem{})
+	g.schemas[problemSchema].Properties["instance"] = &Schema{Type: "string"}
+	g.schemas[problemSchema].Properties["traceId"] = &Schema{Type: "string", Description: "the request's trace, for finding it in the logs and traces"}
+	g.schemas[problemSchema].Required = append(g.schemas[problemSchema].Required, "instance")
+	g.schemas[problemSchema].AdditionalProperties = nil
+	problemResponse := func(description string) *Response {
+		return &Response{
+			Description: description,
+			Content: map[string]MediaType{
+				problem.ContentType: {Schema: problemRef},
+			},
+		}
+	}
+
+	for _, rt := range routes {
+		path, params := openAPIPath(rt.Path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+
+		op := &Operation{
+			Summary:     rt.Summary,
+			Description: rt.Description,
+			OperationID: operationID(rt.Method, path),
+			Responses:   map[string]*Response{},
+			Deprecated:  rt.Deprecated,
+		}
+		if rt.Tag != "" {
+			op.Tags = []string{rt.Tag}
+		}
+
+		for _, name := range params {
+			p := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+			if strings.HasSuffix(name, "Id") {
+				p.Schema = g.response(objectIDValue)
+			}
+			for _, override := range rt.Params {
+				if override.In == "path" && override.Name == name {
+					p = override
+					p.Required = true
+				}
+			}
+			op.Parameters = append(op.Parameters, p)
+		}
+		for _, p := range rt.Params {
+			if p.In != "path" {
+				op.Parameters = append(op.Parameters, p)
+			}
+		}
+		if rt.Query != nil {
+			op.Parameters = append(op.Parameters, g.query(rt.Query)...)
+		}
+
+		if rt.Request != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+			for _, ct := range sortedKeys(rt.Request) {
+				op.RequestBody.Content[ct] = MediaType{Schema: g.request(rt.Request[ct])}
+			}
+		}
+
+		for _, reply := range rt.Replies {
+			r := &Response{Description: reply.Description}
+			if len(reply.Bodies) > 0 {
+				r.Content = map[string]MediaType{}
+				for _, ct := range sortedKeys(reply.Bodies) {
+					r.Content[ct] = MediaType{Schema: g.response(reply.Bodies[ct])}
+				}
+			}
+			if rt.Conditional && reply.Status < 300 {
+				r.Headers = map[string]Header{"ETag": {Description: "the document's version", Schema: &Schema{Type: "string"}}}
+			}
+			op.Responses[strconv.Itoa(reply.Status)] = r
+		}
+
+		if rt.Auth != Public {
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+			op.Responses["401"] = problemResponse("Missing or invalid token")
+		}
+		if rt.Auth == Admin {
+			op.Responses["403"] = problemResponse("Not an admin")
+		}
+		if rt.Conditional {
+			if rt.Method == http.MethodGet {
+				op.Parameters = append(op.Parameters, Parameter{Name: "If-None-Match", In: "header", Description: "an ETag from an earlier read", Schema: &Schema{Type: "string"}})
+				op.Responses["304"] = &Response{Description: "Not modified since the given ETag"}
+			} else {
+				op.Parameters = append(op.Parameters, Parameter{Name: "If-Match", In: "header", Description: "the ETag the write is based on", Schema: &Schema{Type: "string"}})
+				op.Responses["412"] = problemResponse("Changed since the given ETag")
+				op.Responses["428"] = problemResponse("If-Match is required")
+			}
+		}
+		op.Responses["default"] = problemResponse("Error")
+
+		(*item)[strings.ToLower(rt.Method)] = op
+	}
+
+	return doc
+}
+
+// the OpenAPI form of a chi pattern and its path parameters in order
+func openAPIPath(pattern string) (string, []string) {
+	var params []string
+	path := pathParam.ReplaceAllStringFunc(pattern, func(m string) string {
+		name := pathParam.FindStringSubmatch(m)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	if strings.HasSuffix(path, "/*") {
+		path = strings.TrimSuffix(path, "*") + "{path}"
+		params = append(params, "path")
+	}
+	return path, params
+}
+
+// e.g. get_api_posts_postId
+func operationID(method, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, seg := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '-'
+	}) {
+		parts = append(parts, seg)
+	}
+	if len(parts) == 1 {
+		parts = append(parts, "root")
+	}
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,305 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/validate"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// a JSON Schema (2020-12) as OpenAPI 3.1 uses it
+// Type is a string, or a list of them when null is allowed
+// AdditionalProperties is a *Schema for maps, or false for objects whose
+// members are all listed
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// an ad hoc JSON object, e.g. the {"success": true, "post": ...} envelopes
+// handlers write from maps, every member is always present
+type Object map[string]interface{}
+
+// a merge patch over a document, every member is optional
+type Partial struct {
+	Of interface{}
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	rawType      = reflect.TypeOf(json.RawMessage{})
+)
+
+var objectIDValue = primitive.ObjectID{}
+
+// turns Go values into schemas the way encoding/json would write them
+// named structs in responses become components, requests are inlined
+// since their validate tags make them stricter than the same type read back
+type generator struct {
+	schemas map[string]*Schema
+	// component name of each type seen
+	names map[reflect.Type]string
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+func (g *generator) response(v interface{}) *Schema {
+	return g.value(v, false)
+}
+
+func (g *generator) request(v interface{}) *Schema {
+	if p, ok := v.(Partial); ok {
+		s := g.value(p.Of, true)
+		s.Required = nil
+		return s
+	}
+	return g.value(v, true)
+}
+
+func (g *generator) value(v interface{}, input bool) *Schema {
+	switch v := v.(type) {
+	case *Schema:
+		return v
+	case Object:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+		for _, name := range sortedKeys(v) {
+			ps := g.value(v[name], input)
+			// as for struct fields, nil ones are written as null
+			switch reflect.TypeOf(v[name]).Kind() {
+			case reflect.Ptr, reflect.Slice, reflect.Map:
+				ps = nullable(ps)
+			}
+			s.Properties[name] = ps
+			s.Required = append(s.Required, name)
+		}
+		return s
+	}
+	return g.schema(reflect.TypeOf(v), input)
+}
+
+func (g *generator) schema(t reflect.Type, input bool) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return &Schema{Type: "string", Pattern: "^[0-9a-f]{24}$"}
+	case rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem(), input)
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), input)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem(), input)}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if input || t.Name() == "" {
+			return g.object(t, input)
+		}
+		return g.ref(t)
+	}
+	panic("openapi: no schema for " + t.String())
+}
+
+// a component for a named struct, the name is qualified by its package when
+// two packages use the same one, e.g. IntegrityReport
+func (g *generator) ref(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := g.schemas[name]; taken {
+			pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		g.names[t] = name
+		// placeholder first, types can refer to themselves
+		g.schemas[name] = &Schema{}
+		*g.schemas[name] = *g.object(t, false)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// closed, encoding/json writes nothing but the fields and requests are
+// decoded with unknown fields disallowed
+func (g *generator) object(t reflect.Type, input bool) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	g.fields(t, input, s)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (g *generator) fields(t reflect.Type, input bool, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// promoted fields, as encoding/json flattens them
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, input, s)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		omitempty := strings.Contains(opts, "omitempty")
+
+		fs := g.schema(sf.Type, input)
+		if input {
+			tag := sf.Tag.Get("validate")
+			applyRules(fs, tag)
+			if hasRule(tag, "required") {
+				s.Required = append(s.Required, name)
+			}
+			if sf.Type.Kind() == reflect.Ptr {
+				fs = nullable(fs)
+			}
+		} else {
+			if !omitempty {
+				s.Required = append(s.Required, name)
+				// nil pointers, slices and maps are written as null
+				switch sf.Type.Kind() {
+				case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+					if sf.Type != rawType {
+						fs = nullable(fs)
+					}
+				}
+			}
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// the validate rules a schema can state, see the validate package
+func applyRules(s *Schema, tag string) {
+	if tag == "" {
+		return
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		key, arg, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			if s.Type == "string" && s.MinLength == nil {
+				s.MinLength = intPtr(1)
+			}
+		case "min", "max":
+			n, _ := strconv.Atoi(arg)
+			switch {
+			case s.Type == "string" && key == "min":
+				s.MinLength = intPtr(n)
+			case s.Type == "string":
+				s.MaxLength = intPtr(n)
+			case key == "min":
+				s.MinItems = intPtr(n)
+			default:
+				s.MaxItems = intPtr(n)
+			}
+		case "url":
+			s.Format = "uri"
+		case "link":
+			s.Format = "uri-reference"
+		case "oneof":
+			for _, v := range strings.Fields(arg) {
+				s.Enum = append(s.Enum, v)
+			}
+		case "pattern":
+			s.Pattern = validate.PatternRegexp(arg)
+		}
+	}
+}
+
+func hasRule(tag, name string) bool {
+	for _, rule := range strings.Split(tag, ",") {
+		if key, _, _ := strings.Cut(rule, "="); key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// query parameters from a struct's json-named fields
+func (g *generator) query(v interface{}) []Parameter {
+	t := reflect.TypeOf(v)
+	s := g.object(t, true)
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		params = append(params, Parameter{
+			Name:        name,
+			In:          "query",
+			Description: t.Field(i).Tag.Get("doc"),
+			Required:    hasRule(t.Field(i).Tag.Get("validate"), "required"),
+			Schema:      s.Properties[name],
+		})
+	}
+	return params
+}
+
+func nullable(s *Schema) *Schema {
+	if t, ok := s.Type.(string); ok {
+		out := *s
+		out.Type = []string{t, "null"}
+		return &out
+	}
+	return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,177 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// checks decoded JSON against the document's schemas, numbers must be
+// json.Number as a decoder with UseNumber gives them
+type validator struct {
+	schemas map[string]*Schema
+}
+
+// patterns are compiled once, the document never changes
+var patterns sync.Map
+
+// appends one message per way value breaks s, at names value, e.g.
+// "response" for a body, and members get JSON pointers below it
+func (v validator) check(s *Schema, value interface{}, at string, errs *[]string) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		ref, ok := v.schemas[name]
+		if !ok {
+			*errs = append(*errs, at+": unknown schema "+s.Ref)
+			return
+		}
+		v.check(ref, value, at, errs)
+		return
+	}
+	if len(s.AnyOf) > 0 {
+		for _, alt := range s.AnyOf {
+			var altErrs []string
+			if v.check(alt, value, at, &altErrs); len(altErrs) == 0 {
+				return
+			}
+		}
+		*errs = append(*errs, at+": matches none of the allowed schemas")
+		return
+	}
+
+	if types := typeList(s.Type); len(types) > 0 {
+		got := jsonType(value)
+		ok := false
+		for _, t := range types {
+			if t == got || t == "number" && got == "integer" {
+				ok = true
+			}
+		}
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: want %s, got %s", at, strings.Join(types, " or "), got))
+			return
+		}
+	}
+	if len(s.Enum) > 0 {
+		ok := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				ok = true
+			}
+		}
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is not one of %v", at, value, s.Enum))
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.checkString(s, value, at, errs)
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			*errs = append(*errs, fmt.Sprintf("%s: fewer than %d items", at, *s.MinItems))
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			*errs = append(*errs, fmt.Sprintf("%s: more than %d items", at, *s.MaxItems))
+		}
+		for i, item := range value {
+			v.check(s.Items, item, fmt.Sprintf("%s/%d", at, i), errs)
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				*errs = append(*errs, at+": missing "+name)
+			}
+		}
+		for _, name := range sortedKeys(value) {
+			if ps, ok := s.Properties[name]; ok {
+				v.check(ps, value[name], at+"/"+escapePointer(name), errs)
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case bool:
+				if !extra {
+					*errs = append(*errs, at+": unexpected "+name)
+				}
+			case *Schema:
+				v.check(extra, value[name], at+"/"+escapePointer(name), errs)
+			}
+		}
+	}
+}
+
+func (v validator) checkString(s *Schema, value, at string, errs *[]string) {
+	n := utf8.RuneCountInString(value)
+	if s.MinLength != nil && n < *s.MinLength {
+		*errs = append(*errs, fmt.Sprintf("%s: shorter than %d characters", at, *s.MinLength))
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		*errs = append(*errs, fmt.Sprintf("%s: longer than %d characters", at, *s.MaxLength))
+	}
+	if s.Pattern != "" && !compiled(s.Pattern).MatchString(value) {
+		*errs = append(*errs, fmt.Sprintf("%s: %q doesn't match %s", at, value, s.Pattern))
+	}
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: %q is not a date-time", at, value))
+		}
+	case "uri":
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" {
+			*errs = append(*errs, fmt.Sprintf("%s: %q is not an absolute URI", at, value))
+		}
+	}
+}
+
+// a schema's type as a list, see Schema
+func typeList(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
+
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if strings.ContainsAny(value.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func compiled(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patterns.Store(pattern, re)
+	return re
+}
+
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
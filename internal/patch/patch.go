@@ -0,0 +1,400 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// media types of the two patch formats
+const (
+	// RFC 7396, the patch mirrors the document, null removes a member
+	MergePatchType = "application/merge-patch+json"
+	// RFC 6902, a list of operations addressed by JSON pointers
+	JSONPatchType = "application/json-patch+json"
+)
+
+var (
+	// the patch itself is not well formed
+	ErrMalformed = errors.New("malformed patch")
+	// a test operation didn't match, nothing was applied
+	ErrTestFailed = errors.New("test operation failed")
+)
+
+// applies an RFC 7396 merge patch to doc
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return json.Marshal(merge(target, p))
+}
+
+// the top-level members a merge patch writes, "" when it replaces the
+// whole document
+func MergeMembers(patch []byte) ([]string, error) {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	obj, ok := p.(map[string]interface{})
+	if !ok {
+		return []string{""}, nil
+	}
+	members := make([]string, 0, len(obj))
+	for name := range obj {
+		members = append(members, name)
+	}
+	return members, nil
+}
+
+// modifies target in place
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = merge(t[name], value)
+		}
+	}
+	return t
+}
+
+// one RFC 6902 operation, Value stays raw so a missing value can be told
+// apart from null
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// an RFC 6902 JSON patch
+type Patch []Operation
+
+// parses and checks a JSON patch, errors wrap ErrMalformed
+func Decode(data []byte) (Patch, error) {
+	var p Patch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	for i, op := range p {
+		if err := op.check(); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrMalformed, i, err)
+		}
+	}
+	return p, nil
+}
+
+func (op Operation) check() error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("%s needs a value", op.Op)
+		}
+	case "remove":
+	case "move", "copy":
+		if _, err := pointer(op.From); err != nil {
+			return fmt.Errorf("from: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	if _, err := pointer(op.Path); err != nil {
+		return fmt.Errorf("path: %v", err)
+	}
+	return nil
+}
+
+// the top-level members the patch writes, "" when an operation replaces
+// the whole document
+// test only reads, and copy only reads its source
+func (p Patch) Members() []string {
+	var members []string
+	for _, op := range p {
+		switch op.Op {
+		case "test":
+			continue
+		case "move":
+			members = append(members, member(op.From))
+		}
+		members = append(members, member(op.Path))
+	}
+	return members
+}
+
+func member(path string) string {
+	tokens, _ := pointer(path)
+	if len(tokens) == 0 {
+		return ""
+	}
+	return tokens[0]
+}
+
+// applies the operations in order, all or nothing
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	for i, op := range p {
+		var err error
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func (op Operation) apply(doc interface{}) (interface{}, error) {
+	path, err := pointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		doc, _, err := remove(doc, path)
+		return doc, err
+	case "replace":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, _, err = remove(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "move":
+		if op.From == op.Path {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.New("can't move a value into itself")
+		}
+		from, err := pointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, err := pointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		// the copy must not share maps or slices with its source
+		if value, err = clone(value); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "test":
+		want, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		have, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(have, want) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+func (op Operation) value() (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(op.Value, &v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return v, nil
+}
+
+// splits an RFC 6901 pointer into unescaped reference tokens
+func pointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path[0] != '/' {
+		return nil, fmt.Errorf("pointer %q must start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// a position in an array, "-" (one past the end) only when allowEnd
+func index(token string, n int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	max := n - 1
+	if allowEnd {
+		max = n
+	}
+	if i > max {
+		return 0, fmt.Errorf("index %d is out of range", i)
+	}
+	return i, nil
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("%q does not exist", token)
+			}
+			doc = v
+		case []interface{}:
+			i, err := index(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = c[i]
+		default:
+			return nil, fmt.Errorf("can't look up %q in a scalar", token)
+		}
+	}
+	return doc, nil
+}
+
+// returns doc with value added, parents must already exist
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, rest := path[0], path[1:]
+
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			c[token] = value
+			return c, nil
+		}
+		child, ok := c[token]
+		if !ok {
+			return nil, fmt.Errorf("%q does not exist", token)
+		}
+		child, err := add(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		c[token] = child
+		return c, nil
+	case []interface{}:
+		if len(rest) == 0 {
+			i, err := index(token, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		i, err := index(token, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := add(c[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = child
+		return c, nil
+	}
+	return nil, fmt.Errorf("can't add %q to a scalar", token)
+}
+
+// returns doc without the value at path, and the value
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("can't remove the whole document")
+	}
+	token, rest := path[0], path[1:]
+
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		child, ok := c[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%q does not exist", token)
+		}
+		if len(rest) == 0 {
+			delete(c, token)
+			return c, child, nil
+		}
+		child, removed, err := remove(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[token] = child
+		return c, removed, nil
+	case []interface{}:
+		i, err := index(token, len(c), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := c[i]
+			return append(c[:i], c[i+1:]...), removed, nil
+		}
+		child, removed, err := remove(c[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[i] = child
+		return c, removed, nil
+	}
+	return nil, nil, fmt.Errorf("can't remove %q from a scalar", token)
+}
+
+func clone(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
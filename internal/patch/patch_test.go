@@ -0,0 +1,430 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// compares as JSON values, member order and spacing don't matter
+func equalJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result isn't JSON: %v: %s", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("bad test, want isn't JSON: %v", err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+type patchTest struct {
+	name  string
+	doc   string
+	patch string
+	// the document after, unused when err is set
+	want string
+	// nil for success, errAny when any error will do
+	err error
+}
+
+var errAny = errors.New("any error")
+
+func runPatchTests(t *testing.T, tests []patchTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Decode([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			got, err := p.Apply([]byte(tt.doc))
+			switch {
+			case tt.err == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err == nil:
+				equalJSON(t, got, tt.want)
+			case err == nil:
+				t.Fatalf("got %s, want an error", got)
+			case tt.err != errAny && !errors.Is(err, tt.err):
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// RFC 6902 appendix A
+func TestApplyRFC6902Examples(t *testing.T) {
+	runPatchTests(t, []patchTest{
+		{
+			name:  "A.1 adding an object member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:  "A.2 adding an array element",
+			doc:   `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:  `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			name:  "A.3 removing an object member",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			want:  `{"foo": "bar"}`,
+		},
+		{
+			name:  "A.4 removing an array element",
+			doc:   `{"foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:  "A.5 replacing a value",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:  "A.6 moving a value",
+			doc:   `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:  `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			name:  "A.7 moving an array element",
+			doc:   `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:  `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			name:  "A.8 testing a value, success",
+			doc:   `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			want:  `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		},
+		{
+			name:  "A.9 testing a value, error",
+			doc:   `{"baz": "qux"}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.10 adding a nested member object",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			want:  `{"foo": "bar", "child": {"grandchild": {}}}`,
+		},
+		{
+			name:  "A.11 ignoring unrecognized elements",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			want:  `{"foo": "bar", "baz": "qux"}`,
+		},
+		{
+			name:  "A.12 adding to a nonexistent target",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			err:   errAny,
+		},
+		{
+			// the later op wins, it's never read as an add
+			name:  "A.13 invalid JSON patch document",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux", "op": "remove"}]`,
+			err:   errAny,
+		},
+		{
+			name:  "A.14 ~ escape ordering",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": 10}]`,
+			want:  `{"/": 9, "~1": 10}`,
+		},
+		{
+			name:  "A.15 comparing strings and numbers",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": "10"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.16 adding an array value",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:  `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+	})
+}
+
+func TestApplyTest(t *testing.T) {
+	runPatchTests(t, []patchTest{
+		{
+			name:  "whole document",
+			doc:   `{"a": [1, {"b": null}]}`,
+			patch: `[{"op": "test", "path": "", "value": {"a": [1, {"b": null}]}}]`,
+			want:  `{"a": [1, {"b": null}]}`,
+		},
+		{
+			name:  "null is a value",
+			doc:   `{"a": null}`,
+			patch: `[{"op": "test", "path": "/a", "value": null}]`,
+			want:  `{"a": null}`,
+		},
+		{
+			name:  "array order matters",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "test", "path": "/a", "value": [2, 1]}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "object members must all match",
+			doc:   `{"a": {"b": 1, "c": 2}}`,
+			patch: `[{"op": "test", "path": "/a", "value": {"b": 1}}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "integer and float are the same number",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "test", "path": "/a", "value": 1.0}]`,
+			want:  `{"a": 1}`,
+		},
+		{
+			// a missing member is an error of its own, not a mismatch
+			name:  "missing member",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "test", "path": "/b", "value": 1}]`,
+			err:   errAny,
+		},
+		{
+			name:  "a failure undoes earlier operations",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "add", "path": "/b", "value": 2}, {"op": "test", "path": "/a", "value": 2}, {"op": "remove", "path": "/a"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "sees earlier operations",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "replace", "path": "/a", "value": 2}, {"op": "test", "path": "/a", "value": 2}]`,
+			want:  `{"a": 2}`,
+		},
+	})
+}
+
+func TestApplyMove(t *testing.T) {
+	runPatchTests(t, []patchTest{
+		{
+			name:  "into its own child",
+			doc:   `{"a": {"b": 1}}`,
+			patch: `[{"op": "move", "from": "/a", "path": "/a/c"}]`,
+			err:   errAny,
+		},
+		{
+			name:  "into a grandchild",
+			doc:   `{"a": {"b": {"c": 1}}}`,
+			patch: `[{"op": "move", "from": "/a", "path": "/a/b/d"}]`,
+			err:   errAny,
+		},
+		{
+			name:  "the whole document into a member",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "move", "from": "", "path": "/b"}]`,
+			err:   errAny,
+		},
+		{
+			// /ab starts with /a but isn't under it
+			name:  "to a sibling sharing a prefix",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "move", "from": "/a", "path": "/ab"}]`,
+			want:  `{"ab": 1}`,
+		},
+		{
+			name:  "a child up to its parent's place",
+			doc:   `{"a": {"b": {"c": 1}}}`,
+			patch: `[{"op": "move", "from": "/a/b", "path": "/a"}]`,
+			want:  `{"a": {"c": 1}}`,
+		},
+		{
+			name:  "onto itself",
+			doc:   `{"a": {"b": 1}}`,
+			patch: `[{"op": "move", "from": "/a", "path": "/a"}]`,
+			want:  `{"a": {"b": 1}}`,
+		},
+		{
+			name:  "replaces the target member",
+			doc:   `{"a": 1, "b": 2}`,
+			patch: `[{"op": "move", "from": "/a", "path": "/b"}]`,
+			want:  `{"b": 1}`,
+		},
+		{
+			name:  "missing source",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "move", "from": "/b", "path": "/c"}]`,
+			err:   errAny,
+		},
+		{
+			name:  "between arrays",
+			doc:   `{"a": [1, 2], "b": [3]}`,
+			patch: `[{"op": "move", "from": "/a/0", "path": "/b/-"}]`,
+			want:  `{"a": [2], "b": [3, 1]}`,
+		},
+	})
+}
+
+func TestApplyArrayEnd(t *testing.T) {
+	runPatchTests(t, []patchTest{
+		{
+			name:  "add appends",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "add", "path": "/a/-", "value": 3}]`,
+			want:  `{"a": [1, 2, 3]}`,
+		},
+		{
+			name:  "add to an empty array",
+			doc:   `{"a": []}`,
+			patch: `[{"op": "add", "path": "/a/-", "value": {"b": 1}}]`,
+			want:  `{"a": [{"b": 1}]}`,
+		},
+		{
+			name:  "add at the length appends too",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "add", "path": "/a/2", "value": 3}]`,
+			want:  `{"a": [1, 2, 3]}`,
+		},
+		{
+			name:  "add past the length",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "add", "path": "/a/3", "value": 3}]`,
+			err:   errAny,
+		},
+		{
+			name:  "copy appends",
+			doc:   `{"a": [1], "b": {"c": 2}}`,
+			patch: `[{"op": "copy", "from": "/b", "path": "/a/-"}]`,
+			want:  `{"a": [1, {"c": 2}], "b": {"c": 2}}`,
+		},
+		{
+			name:  "add on a member named -",
+			doc:   `{"a": {}}`,
+			patch: `[{"op": "add", "path": "/a/-", "value": 1}]`,
+			want:  `{"a": {"-": 1}}`,
+		},
+		{
+			name:  "remove has nothing there",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "remove", "path": "/a/-"}]`,
+			err:   errAny,
+		},
+		{
+			name:  "replace has nothing there",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "replace", "path": "/a/-", "value": 3}]`,
+			err:   errAny,
+		},
+		{
+			name:  "test has nothing there",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "test", "path": "/a/-", "value": 2}]`,
+			err:   errAny,
+		},
+		{
+			name:  "move from it",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "move", "from": "/a/-", "path": "/b"}]`,
+			err:   errAny,
+		},
+		{
+			name:  "through it to a child",
+			doc:   `{"a": [{"b": 1}]}`,
+			patch: `[{"op": "add", "path": "/a/-/c", "value": 2}]`,
+			err:   errAny,
+		},
+		{
+			name:  "leading zero index",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "replace", "path": "/a/01", "value": 3}]`,
+			err:   errAny,
+		},
+	})
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"not an array", `{"op": "add", "path": "/a", "value": 1}`},
+		{"unknown op", `[{"op": "merge", "path": "/a", "value": 1}]`},
+		{"add without a value", `[{"op": "add", "path": "/a"}]`},
+		{"test without a value", `[{"op": "test", "path": "/a"}]`},
+		{"relative path", `[{"op": "remove", "path": "a"}]`},
+		{"relative from", `[{"op": "move", "from": "a", "path": "/b"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode([]byte(tt.patch)); !errors.Is(err, ErrMalformed) {
+				t.Fatalf("got %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+// RFC 7396 appendix A
+func TestMergeRFC7396Examples(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc+" "+tt.patch, func(t *testing.T) {
+			got, err := Merge([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			equalJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergeMalformed(t *testing.T) {
+	if _, err := Merge([]byte(`{}`), []byte(`{"a":`)); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("got %v, want ErrMalformed", err)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	p, err := Decode([]byte(`[
+		{"op": "test", "path": "/title", "value": "x"},
+		{"op": "copy", "from": "/text", "path": "/seo/description"},
+		{"op": "move", "from": "/imgUrl", "path": "/tags/-"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Members(), []string{"seo", "imgUrl", "tags"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Members() = %q, want %q", got, want)
+	}
+
+	members, err := MergeMembers([]byte(`["whole"]`))
+	if err != nil || !reflect.DeepEqual(members, []string{""}) {
+		t.Errorf("MergeMembers of an array = %q, %v, want the whole document", members, err)
+	}
+}
@@ -0,0 +1,251 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// config.PrivacyConfig.ErasedPosts
+const (
+	PostsReassign = "reassign"
+	PostsDelete   = "delete"
+)
+
+// what an erasure did, recorded on the tombstone audit entry
+type ErasureResult struct {
+	User       primitive.ObjectID `json:"user"`
+	PostPolicy string             `json:"postPolicy"`
+	Posts      int64              `json:"posts"`
+	Comments   int64              `json:"comments"`
+	Media      int64              `json:"media"`
+}
+
+func (s *Service) RequestErasure(ctx context.Context, user *models.User, reason string) (*models.ErasureRequest, error) {
+	if user.Username == s.cfg.DeletedUsername {
+		return nil, ErrProtectedUser
+	}
+
+	pending, err := s.erasureRepo.FindPendingByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return pending, ErrErasurePending
+	}
+
+	req := &models.ErasureRequest{User: user.ID, Reason: reason}
+	if err := s.erasureRepo.Create(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := s.audit(ctx, user.ID, models.AuditErasureRequested, req.ID, nil); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (s *Service) CancelErasure(ctx context.Context, user *models.User) error {
+	pending, err := s.erasureRepo.FindPendingByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		return ErrNoErasure
+	}
+
+	if err := s.erasureRepo.Resolve(ctx, pending.ID, models.ErasureCancelled, &user.ID); err != nil {
+		return err
+	}
+	return s.audit(ctx, user.ID, models.AuditErasureCancelled, pending.ID, nil)
+}
+
+// requests waiting for an admin, oldest first
+func (s *Service) PendingErasures(ctx context.Context) ([]models.ErasureRequest, error) {
+	return s.erasureRepo.FindByStatus(ctx, models.ErasurePending)
+}
+
+func (s *Service) RejectErasure(ctx context.Context, admin *models.User, id primitive.ObjectID) error {
+	req, err := s.erasureRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.erasureRepo.Resolve(ctx, id, models.ErasureRejected, &admin.ID); err != nil {
+		return err
+	}
+	return s.audit(ctx, admin.ID, models.AuditErasureRejected, req.User, map[string]interface{}{
+		"request": id,
+	})
+}
+
+// carries out a pending request: the user's comments are anonymized, their
+// posts and uploads reassigned or deleted per config, their exports and
+// account deleted, and a tombstone audit entry keeps the ids and counts
+// every step is safe to repeat, so a failed erasure can be approved again
+func (s *Service) ApproveErasure(ctx context.Context, admin *models.User, id primitive.ObjectID) (*ErasureResult, error) {
+	req, err := s.erasureRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != models.ErasurePending {
+		return nil, ErrNoErasure
+	}
+
+	result, err := s.erase(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.erasureRepo.Resolve(ctx, id, models.ErasureCompleted, &admin.ID); err != nil {
+		return nil, err
+	}
+	err = s.audit(ctx, admin.ID, models.AuditUserErased, req.User, map[string]interface{}{
+		"request":    id,
+		"postPolicy": result.PostPolicy,
+		"posts":      result.Posts,
+		"comments":   result.Comments,
+		"media":      result.Media,
+	})
+	return result, err
+}
+
+func (s *Service) erase(ctx context.Context, userID primitive.ObjectID) (*ErasureResult, error) {
+	// the account may already be gone, its content is still erased
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return nil, err
+	}
+	if user != nil && user.Username == s.cfg.DeletedUsername {
+		return nil, ErrProtectedUser
+	}
+
+	placeholder, err := s.content.DeletedUser(ctx, s.cfg.DeletedUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ErasureResult{User: userID, PostPolicy: s.cfg.ErasedPosts}
+	comments, err := s.authoredComments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if result.Comments, err = s.commentRepo.ReassignAuthor(ctx, userID, placeholder.ID); err != nil {
+		return nil, fmt.Errorf("anonymizing comments: %w", err)
+	}
+	if err := s.userRepo.AddComments(ctx, placeholder.ID, commentIDs(comments)...); err != nil {
+		return nil, err
+	}
+
+	switch s.cfg.ErasedPosts {
+	case PostsDelete:
+		if err := s.deleteContent(ctx, userID, result); err != nil {
+			return nil, err
+		}
+	default:
+		posts, err := s.authoredPosts(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		// uploads go with the posts that show them
+		if result.Posts, err = s.postRepo.ReassignAuthor(ctx, userID, placeholder.ID); err != nil {
+			return nil, fmt.Errorf("reassigning posts: %w", err)
+		}
+		if err := s.userRepo.AddPosts(ctx, placeholder.ID, postIDs(posts)...); err != nil {
+			return nil, err
+		}
+		if result.Media, err = s.mediaRepo.ReassignOwner(ctx, userID, placeholder.ID); err != nil {
+			return nil, fmt.Errorf("reassigning media: %w", err)
+		}
+	}
+
+	exports, err := s.exportRepo.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, export := range exports {
+		if err := s.removeExport(ctx, export.ID); err != nil {
+			return nil, fmt.Errorf("removing export: %w", err)
+		}
+	}
+
+	if user != nil {
+		if err := s.userRepo.Delete(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *Service) deleteContent(ctx context.Context, userID primitive.ObjectID, result *ErasureResult) error {
+	posts, err := s.authoredPosts(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		// other people's comments go with the post
+		if _, err := s.content.PurgePost(ctx, post.ID); err != nil {
+			return fmt.Errorf("deleting post %s: %w", post.ID.Hex(), err)
+		}
+		result.Posts++
+	}
+
+	uploads, err := s.mediaRepo.FindByOwner(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for i := range uploads {
+		if err := s.mediaService.Delete(ctx, &uploads[i]); err != nil {
+			return fmt.Errorf("deleting media %s: %w", uploads[i].ID.Hex(), err)
+		}
+		if err := s.mediaRepo.Delete(ctx, uploads[i].ID); err != nil {
+			return err
+		}
+		result.Media++
+	}
+	return nil
+}
+
+// everything the user wrote, the trash included
+func (s *Service) authoredPosts(ctx context.Context, userID primitive.ObjectID) ([]models.Post, error) {
+	posts, err := s.postRepo.FindByAuthor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := s.postRepo.FindTrash(ctx, repository.TrashFilter{Author: &userID})
+	if err != nil {
+		return nil, err
+	}
+	return append(posts, trashed...), nil
+}
+
+func (s *Service) authoredComments(ctx context.Context, userID primitive.ObjectID) ([]models.Comment, error) {
+	comments, err := s.commentRepo.FindByAuthor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := s.commentRepo.FindTrash(ctx, repository.TrashFilter{Author: &userID})
+	if err != nil {
+		return nil, err
+	}
+	return append(comments, trashed...), nil
+}
+
+func postIDs(posts []models.Post) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func commentIDs(comments []models.Comment) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+	return ids
+}
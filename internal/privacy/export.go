@@ -0,0 +1,214 @@
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/media"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// returns the user's current export, starting a new one in the background
+// when there is none in progress or ready to download
+func (s *Service) RequestExport(ctx context.Context, user *models.User) (*models.DataExport, error) {
+	latest, err := s.exportRepo.FindLatestByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && s.current(latest) {
+		return latest, nil
+	}
+
+	export := &models.DataExport{User: user.ID, Status: models.ExportPending}
+	if err := s.exportRepo.Create(ctx, export); err != nil {
+		return nil, err
+	}
+	if err := s.audit(ctx, user.ID, models.AuditExportRequested, export.ID, nil); err != nil {
+		return nil, err
+	}
+
+	go s.build(export.ID, user.ID)
+	return export, nil
+}
+
+// pending exports older than the build timeout died with a restart
+func (s *Service) current(export *models.DataExport) bool {
+	switch export.Status {
+	case models.ExportPending:
+		return time.Since(export.CreatedAt) < exportTimeout
+	case models.ExportReady:
+		return export.ExpiresAt != nil && time.Now().Before(*export.ExpiresAt)
+	}
+	return false
+}
+
+// opens a ready export for download, only ever for the user it belongs to
+func (s *Service) OpenExport(ctx context.Context, user *models.User, id primitive.ObjectID) (*models.DataExport, *os.File, error) {
+	export, err := s.exportRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	// someone else's export looks the same as a missing one
+	if export.User != user.ID {
+		return nil, nil, errors.New("export not found")
+	}
+	if export.Status != models.ExportReady {
+		return nil, nil, ErrExportNotReady
+	}
+	if !s.current(export) {
+		return nil, nil, ErrExportExpired
+	}
+
+	f, err := os.Open(s.exportPath(export.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	return export, f, nil
+}
+
+func (s *Service) build(id, userID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	size, err := s.writeExport(ctx, id, userID)
+	now := time.Now()
+	update := bson.M{"completedAt": now}
+	if err != nil {
+		log.Printf("privacy: export %s failed: %v", id.Hex(), err)
+		update["status"] = models.ExportFailed
+		update["error"] = "export failed, request a new one"
+	} else {
+		update["status"] = models.ExportReady
+		update["size"] = size
+		update["expiresAt"] = now.Add(s.cfg.ExportTTL)
+	}
+
+	if err := s.exportRepo.Update(ctx, id, update); err != nil {
+		log.Printf("privacy: recording export %s: %v", id.Hex(), err)
+	}
+}
+
+// zip layout:
+//
+//	profile.json   the account, without its password hash
+//	posts.json     posts the user wrote, drafts and trash included
+//	comments.json  comments the user wrote, trash included
+//	audit.json     audit entries for things the user did
+//	media.json     metadata for uploads
+//	media/<id>/<filename>  the uploaded files
+func (s *Service) writeExport(ctx context.Context, id, userID primitive.ObjectID) (int64, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	user.Password = ""
+
+	posts, err := s.authoredPosts(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	comments, err := s.authoredComments(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := s.auditRepo.FindByActor(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	uploads, err := s.mediaRepo.FindByOwner(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(s.cfg.ExportDir, ".export-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"profile.json", user},
+		{"posts.json", orEmpty(posts)},
+		{"comments.json", orEmpty(comments)},
+		{"audit.json", orEmpty(entries)},
+		{"media.json", orEmpty(uploads)},
+	}
+	for _, f := range files {
+		if err := writeJSON(zw, f.name, f.v); err != nil {
+			return 0, err
+		}
+	}
+	for _, m := range uploads {
+		if err := s.writeUpload(ctx, zw, m); err != nil {
+			return 0, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return size, os.Rename(tmp.Name(), s.exportPath(id))
+}
+
+func (s *Service) writeUpload(ctx context.Context, zw *zip.Writer, m models.Media) error {
+	rc, err := s.mediaService.Storage().Get(ctx, m.Key)
+	if errors.Is(err, media.ErrObjectNotFound) {
+		// metadata without a file is still listed in media.json
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     fmt.Sprintf("media/%s/%s", m.ID.Hex(), path.Base(m.Filename)),
+		Method:   zip.Store, // images are compressed already
+		Modified: m.Timestamp,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// [] rather than null in the export files
+func orEmpty[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/content"
+	"github.com/kurtgray/blog-api-go/internal/health"
+	"github.com/kurtgray/blog-api-go/internal/media"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrExportNotReady = apperr.Conflict("export_not_ready", "export is not ready yet")
+	ErrExportExpired  = errors.New("export has expired")
+	ErrErasurePending = apperr.Conflict("erasure_pending", "an erasure request is already pending")
+	ErrNoErasure      = errors.New("no pending erasure request")
+	ErrProtectedUser  = apperr.New(apperr.ErrForbidden, "protected_user", "this account can't be erased")
+)
+
+// how long building one export may take
+const exportTimeout = 10 * time.Minute
+
+// personal data exports and account erasure
+type Service struct {
+	userRepo     repository.UserRepository
+	postRepo     repository.PostRepository
+	commentRepo  repository.CommentRepository
+	mediaRepo    repository.MediaRepository
+	auditRepo    repository.AuditRepository
+	exportRepo   repository.ExportRepository
+	erasureRepo  repository.ErasureRepository
+	content      *content.Service
+	mediaService *media.Service
+	cfg          config.PrivacyConfig
+}
+
+func New(
+	userRepo repository.UserRepository,
+	postRepo repository.PostRepository,
+	commentRepo repository.CommentRepository,
+	mediaRepo repository.MediaRepository,
+	auditRepo repository.AuditRepository,
+	exportRepo repository.ExportRepository,
+	erasureRepo repository.ErasureRepository,
+	contentService *content.Service,
+	mediaService *media.Service,
+	cfg config.PrivacyConfig,
+) (*Service, error) {
+	// exports hold everything about a user, keep them private to this process
+	if err := os.MkdirAll(cfg.ExportDir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Service{
+		userRepo:     userRepo,
+		postRepo:     postRepo,
+		commentRepo:  commentRepo,
+		mediaRepo:    mediaRepo,
+		auditRepo:    auditRepo,
+		exportRepo:   exportRepo,
+		erasureRepo:  erasureRepo,
+		content:      contentService,
+		mediaService: mediaService,
+		cfg:          cfg,
+	}, nil
+}
+
+// removes expired exports every interval until ctx is done, beating hb on
+// every pass
+func (s *Service) Run(ctx context.Context, interval time.Duration, hb *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		hb.Beat()
+		if err := s.RemoveExpired(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("privacy: removing expired exports: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) RemoveExpired(ctx context.Context) error {
+	expired, err := s.exportRepo.FindExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, export := range expired {
+		errs = append(errs, s.removeExport(ctx, export.ID))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) removeExport(ctx context.Context, id primitive.ObjectID) error {
+	if err := os.Remove(s.exportPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return s.exportRepo.Delete(ctx, id)
+}
+
+func (s *Service) exportPath(id primitive.ObjectID) string {
+	return filepath.Join(s.cfg.ExportDir, id.Hex()+".zip")
+}
+
+func (s *Service) audit(ctx context.Context, actor primitive.ObjectID, action string, target primitive.ObjectID, details map[string]interface{}) error {
+	return s.auditRepo.Create(ctx, &models.AuditEntry{
+		Actor:   actor,
+		Action:  action,
+		Target:  target,
+		Details: details,
+	})
+}
@@ -0,0 +1,204 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/tracing"
+)
+
+const ContentType = "application/problem+json"
+
+// an RFC 7807 problem details response
+// Code is stable across releases, clients should switch on it rather than
+// on Detail
+type Problem struct {
+	Type   string              `json:"type"`
+	Title  string              `json:"title"`
+	Status int                 `json:"status"`
+	Detail string              `json:"detail,omitempty"`
+	Code   string              `json:"code"`
+	Errors []apperr.FieldError `json:"errors,omitempty"`
+	// extension members, e.g. the pending request a 409 clashed with
+	Extra map[string]interface{} `json:"-"`
+}
+
+// a Problem is an error so handlers can return one from helpers
+func (p *Problem) Error() string {
+	return p.Detail
+}
+
+// code defaults to one named after the status, e.g. not_found
+func New(status int, code, detail string) *Problem {
+	if code == "" {
+		code = statusCode(status)
+	}
+	return &Problem{
+		Type:   "/problems/" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// adds an extension member
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extra == nil {
+		p.Extra = map[string]interface{}{}
+	}
+	p.Extra[key] = value
+	return p
+}
+
+// the single mapping from errors to responses
+// anything that isn't a *Problem or an *apperr.Error is a 500 whose
+// message stays in the log, never the response
+func From(err error) *Problem {
+	return from(context.Background(), err)
+}
+
+// From for a request's error, the log line carries the request's trace
+func from(ctx context.Context, err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	var e *apperr.Error
+	if errors.As(err, &e) {
+		p := New(kindStatus(e.Kind), e.Code, sentence(e.Message))
+		p.Errors = e.Fields
+		return p
+	}
+
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		log.Printf("problem: unexpected error (trace %s): %v", traceID, err)
+	} else {
+		log.Printf("problem: unexpected error: %v", err)
+	}
+	return New(http.StatusInternalServerError, "", "Something went wrong, try again later.")
+}
+
+func kindStatus(kind error) int {
+	switch kind {
+	case apperr.ErrNotFound:
+		return http.StatusNotFound
+	case apperr.ErrConflict:
+		return http.StatusConflict
+	case apperr.ErrValidation:
+		return http.StatusBadRequest
+	case apperr.ErrPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case apperr.ErrForbidden:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// codes for problems that don't name their own
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusGone:
+		return "gone"
+	case http.StatusPreconditionFailed:
+		return "precondition_failed"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusUnprocessableEntity:
+		return "unprocessable"
+	case http.StatusPreconditionRequired:
+		return "precondition_required"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	return "internal_error"
+}
+
+// repository messages are lower case fragments, responses are sentences
+func sentence(s string) string {
+	if s == "" {
+		return s
+	}
+	s = strings.ToUpper(s[:1]) + s[1:]
+	if !strings.HasSuffix(s, ".") {
+		s += "."
+	}
+	return s
+}
+
+type contextKey string
+
+const legacyKey contextKey = "legacyErrors"
+
+// marks requests whose errors use the old {"success": false, "message"}
+// envelope, clients can still ask for problems with Accept
+func Legacy(legacy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if legacy && !strings.Contains(r.Header.Get("Accept"), ContentType) {
+				r = r.WithContext(context.WithValue(r.Context(), legacyKey, true))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// for requests that never get the legacy envelope whatever Legacy decided,
+// e.g. API v2
+func WithoutLegacy(ctx context.Context) context.Context {
+	return context.WithValue(ctx, legacyKey, false)
+}
+
+// writes err as a problem, or in the legacy envelope when the request asks
+// for it, with the request's trace id to look it up by
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	p := from(r.Context(), err)
+
+	body := map[string]interface{}{}
+	for k, v := range p.Extra {
+		body[k] = v
+	}
+	if legacy, _ := r.Context().Value(legacyKey).(bool); legacy {
+		w.Header().Set("Content-Type", "application/json")
+		body["success"] = false
+		body["message"] = p.Detail
+		body["code"] = p.Code
+	} else {
+		w.Header().Set("Content-Type", ContentType)
+		body["type"] = p.Type
+		body["title"] = p.Title
+		body["status"] = p.Status
+		body["detail"] = p.Detail
+		body["instance"] = r.URL.Path
+		body["code"] = p.Code
+	}
+	if len(p.Errors) > 0 {
+		body["errors"] = p.Errors
+	}
+	if traceID := tracing.TraceID(r.Context()); traceID != "" {
+		body["traceId"] = traceID
+	}
+
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,219 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// chroma style used for the stylesheet served alongside highlighted posts
+const highlightStyle = "github"
+
+// turns post and comment text into sanitized HTML
+// safe for concurrent use
+type Renderer struct {
+	post    goldmark.Markdown
+	comment goldmark.Markdown
+
+	postPolicy    *bluemonday.Policy
+	commentPolicy *bluemonday.Policy
+}
+
+// rendered post body
+type Result struct {
+	HTML string
+	TOC  []models.TOCEntry
+}
+
+func New() *Renderer {
+	return &Renderer{
+		post: goldmark.New(
+			goldmark.WithExtensions(
+				extension.GFM,
+				extension.Footnote,
+				highlighting.NewHighlighting(
+					highlighting.WithStyle(highlightStyle),
+					// classes instead of inline styles so the sanitizer can keep them
+					highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+				),
+			),
+			goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+			// raw html is allowed here and cleaned by postPolicy afterwards
+			goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+		),
+		comment: goldmark.New(
+			goldmark.WithExtensions(extension.Strikethrough, extension.Linkify),
+		),
+		postPolicy:    postPolicy(),
+		commentPolicy: commentPolicy(),
+	}
+}
+
+// renders a post body in the given format, empty format means html
+func (r *Renderer) Post(format, body string) (Result, error) {
+	switch format {
+	case models.FormatMarkdown:
+		return r.markdown(body)
+	case models.FormatHTML, "":
+		return Result{HTML: r.postPolicy.Sanitize(body)}, nil
+	case models.FormatPlain:
+		return Result{HTML: plain(body)}, nil
+	default:
+		return Result{}, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// renders a comment with a restricted markdown subset:
+// emphasis, links, code, lists and quotes, no headings, images or raw html
+func (r *Renderer) Comment(body string) (string, error) {
+	var buf bytes.Buffer
+	if err := r.comment.Convert([]byte(body), &buf); err != nil {
+		return "", err
+	}
+	return r.commentPolicy.Sanitize(buf.String()), nil
+}
+
+// stylesheet for the classes emitted on highlighted code blocks
+func HighlightCSS() string {
+	var buf bytes.Buffer
+	chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(&buf, styles.Get(highlightStyle))
+	return buf.String()
+}
+
+func ValidFormat(format string) bool {
+	switch format {
+	case models.FormatMarkdown, models.FormatHTML, models.FormatPlain:
+		return true
+	}
+	return false
+}
+
+func (r *Renderer) markdown(body string) (Result, error) {
+	source := []byte(body)
+	doc := r.post.Parser().Parse(text.NewReader(source))
+
+	var buf bytes.Buffer
+	if err := r.post.Renderer().Render(&buf, source, doc); err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		HTML: r.postPolicy.Sanitize(buf.String()),
+		TOC:  tableOfContents(doc, source),
+	}, nil
+}
+
+// collects headings in document order, ids come from WithAutoHeadingID
+func tableOfContents(doc ast.Node, source []byte) []models.TOCEntry {
+	var toc []models.TOCEntry
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		var id string
+		if v, ok := heading.AttributeString("id"); ok {
+			if b, ok := v.([]byte); ok {
+				id = string(b)
+			}
+		}
+		toc = append(toc, models.TOCEntry{
+			Level: heading.Level,
+			ID:    id,
+			Text:  nodeText(heading, source),
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	return toc
+}
+
+func nodeText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(source))
+		case *ast.String:
+			b.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// escapes and keeps paragraph/line breaks
+func plain(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	var b strings.Builder
+	for _, para := range strings.Split(body, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+var (
+	classNames = regexp.MustCompile(`^[a-zA-Z0-9 _-]+$`)
+	anchorIDs  = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+)
+
+// user generated content allow-list plus highlighting classes and heading ids
+func postPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(classNames).OnElements("pre", "code", "span", "div")
+	p.AllowAttrs("id").Matching(anchorIDs).OnElements("h1", "h2", "h3", "h4", "h5", "h6", "li", "sup")
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+func commentPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "em", "strong", "del", "code", "pre", "blockquote", "ul", "ol", "li")
+	p.AllowStandardURLs()
+	p.AllowAttrs("href").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// plain-text summary of rendered html, cut at a word boundary near maxLen runes
+func Excerpt(renderedHTML string, maxLen int) string {
+	text := html.UnescapeString(bluemonday.StrictPolicy().Sanitize(renderedHTML))
+	text = strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+
+	cut := string(runes[:maxLen])
+	if i := strings.LastIndex(cut, " "); i > maxLen/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// append-only, entries are never updated or deleted
+type AuditRepository interface {
+	Create(ctx context.Context, entry *models.AuditEntry) error
+	FindByActor(ctx context.Context, actor primitive.ObjectID) ([]models.AuditEntry, error)
+}
+
+type auditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(db *mongo.Database) AuditRepository {
+	return &auditRepository{
+		collection: db.Collection("audit_log"),
+	}
+}
+
+func (r *auditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	entry.ID = primitive.NewObjectID()
+	entry.Timestamp = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// oldest first
+func (r *auditRepository) FindByActor(ctx context.Context, actor primitive.ObjectID) ([]models.AuditEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"actor": actor}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []models.AuditEntry
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -2,9 +2,9 @@ package repository
 
 import (
 	"context"
-	"errors"
 	"time"
 
+	"github.com/kurtgray/blog-api-go/internal/apperr"
 	"github.com/kurtgray/blog-api-go/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -17,8 +17,20 @@ type CommentRepository interface {
 	FindByPostWithAuthor(ctx context.Context, postID primitive.ObjectID) ([]models.CommentWithAuthor, error)
 	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error)
 	FindByIDWithAuthor(ctx context.Context, id primitive.ObjectID) (*models.CommentWithAuthor, error)
-	Update(ctx context.Context, id primitive.ObjectID, text string) error
+	// like PostRepository.Update, returns the comment's new version
+	Update(ctx context.Context, id primitive.ObjectID, version *int64, text, html string) (int64, error)
+	// moves the comment to the trash, or back out of it
+	Trash(ctx context.Context, id, by primitive.ObjectID, version *int64) error
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	FindTrash(ctx context.Context, filter TrashFilter) ([]models.Comment, error)
+	// errors with "comment not found" when the comment isn't in the trash
+	FindTrashedByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error)
+	// removes the comment for good, trashed or not
 	Delete(ctx context.Context, id primitive.ObjectID) error
+	FindByAuthor(ctx context.Context, author primitive.ObjectID) ([]models.Comment, error)
+	// moves every comment by from to to, returns how many moved
+	ReassignAuthor(ctx context.Context, from, to primitive.ObjectID) (int64, error)
+	DeleteByPost(ctx context.Context, postID primitive.ObjectID) (int64, error)
 }
 
 type commentRepository struct {
@@ -33,14 +45,17 @@ func NewCommentRepository(db *mongo.Database) CommentRepository {
 
 func (r *commentRepository) Create(ctx context.Context, comment *models.Comment) error {
 	comment.ID = primitive.NewObjectID()
-	comment.Timestamp = time.Now()
+	// imports bring their original dates
+	if comment.Timestamp.IsZero() {
+		comment.Timestamp = time.Now()
+	}
 
 	_, err := r.collection.InsertOne(ctx, comment)
 	return err
 }
 
 func (r *commentRepository) FindByPost(ctx context.Context, postID primitive.ObjectID) ([]models.Comment, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{"post": postID})
+	cursor, err := r.collection.Find(ctx, bson.M{"post": postID, "deletedAt": nil})
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +71,7 @@ func (r *commentRepository) FindByPost(ctx context.Context, postID primitive.Obj
 
 func (r *commentRepository) FindByPostWithAuthor(ctx context.Context, postID primitive.ObjectID) ([]models.CommentWithAuthor, error) {
 	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.D{{Key: "post", Value: postID}}}},
+		{{Key: "$match", Value: bson.D{{Key: "post", Value: postID}, {Key: "deletedAt", Value: nil}}}},
 		{{Key: "$lookup", Value: bson.D{
 			{Key: "from", Value: "users"},
 			{Key: "localField", Value: "author"},
@@ -70,12 +85,17 @@ func (r *commentRepository) FindByPostWithAuthor(ctx context.Context, postID pri
 		{{Key: "$project", Value: bson.D{
 			{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$_id"}}}, 
 			{Key: "text", Value: 1},
+			{Key: "html", Value: 1},
 			{Key: "timestamp", Value: 1},
 			{Key: "post", Value: 1},
+			{Key: "version", Value: 1},
 			{Key: "author", Value: bson.D{
-				{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$authorData._id"}}}, {Key: "username", Value: "$authorData.username"},
+				{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$authorData._id"}}},
+				{Key: "username", Value: "$authorData.username"},
 				{Key: "fname", Value: "$authorData.fname"},
 				{Key: "lname", Value: "$authorData.lname"},
+				{Key: "admin", Value: "$authorData.admin"},
+				{Key: "canPublish", Value: "$authorData.canPublish"},
 			}},
 		}}},
 	}
@@ -96,11 +116,11 @@ func (r *commentRepository) FindByPostWithAuthor(ctx context.Context, postID pri
 
 func (r *commentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
 	var comment models.Comment
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&comment)
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "deletedAt": nil}).Decode(&comment)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("comment not found")
+			return nil, apperr.NotFound("comment")
 		}
 		return nil, err
 	}
@@ -110,7 +130,7 @@ func (r *commentRepository) FindByID(ctx context.Context, id primitive.ObjectID)
 
 func (r *commentRepository) FindByIDWithAuthor(ctx context.Context, id primitive.ObjectID) (*models.CommentWithAuthor, error) {
 	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.D{{Key: "_id", Value: id}}}},
+		{{Key: "$match", Value: bson.D{{Key: "_id", Value: id}, {Key: "deletedAt", Value: nil}}}},
 		{{Key: "$lookup", Value: bson.D{
 			{Key: "from", Value: "users"},
 			{Key: "localField", Value: "author"},
@@ -124,13 +144,17 @@ func (r *commentRepository) FindByIDWithAuthor(ctx context.Context, id primitive
 		{{Key: "$project", Value: bson.D{
 			{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$_id"}}}, 
 			{Key: "text", Value: 1},
+			{Key: "html", Value: 1},
 			{Key: "timestamp", Value: 1},
 			{Key: "post", Value: 1},
+			{Key: "version", Value: 1},
 			{Key: "author", Value: bson.D{
-				{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$authorData._id"}}}, {Key: "username", Value: "$authorData.username"},
+				{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$authorData._id"}}},
 				{Key: "username", Value: "$authorData.username"},
 				{Key: "fname", Value: "$authorData.fname"},
 				{Key: "lname", Value: "$authorData.lname"},
+				{Key: "admin", Value: "$authorData.admin"},
+				{Key: "canPublish", Value: "$authorData.canPublish"},
 			}},
 		}}},
 	}
@@ -147,30 +171,66 @@ func (r *commentRepository) FindByIDWithAuthor(ctx context.Context, id primitive
 	}
 
 	if len(comments) == 0 {
-		return nil, errors.New("comment not found")
+		return nil, apperr.NotFound("comment")
 	}
 
 	return &comments[0], nil
 }
 
-func (r *commentRepository) Update(ctx context.Context, id primitive.ObjectID, text string) error {
-	result, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": bson.M{"text": text}},
+func (r *commentRepository) Update(ctx context.Context, id primitive.ObjectID, version *int64, text, html string) (int64, error) {
+	return versionedUpdate(ctx, r.collection, id, version,
+		bson.M{"$set": bson.M{"text": text, "html": html}},
+		apperr.NotFound("comment"),
 	)
+}
 
+func (r *commentRepository) Trash(ctx context.Context, id, by primitive.ObjectID, version *int64) error {
+	_, err := versionedUpdate(ctx, r.collection, id, version, trashUpdate(by), apperr.NotFound("comment"))
+	return err
+}
+
+func (r *commentRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "deletedAt": bson.M{"$ne": nil}}, restoreUpdate)
 	if err != nil {
 		return err
 	}
 
 	if result.MatchedCount == 0 {
-		return errors.New("comment not found")
+		return apperr.NotFound("comment")
 	}
 
 	return nil
 }
 
+func (r *commentRepository) FindTrash(ctx context.Context, filter TrashFilter) ([]models.Comment, error) {
+	cursor, err := r.collection.Find(ctx, filter.match(), trashSort)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []models.Comment
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func (r *commentRepository) FindTrashedByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	var comment models.Comment
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "deletedAt": bson.M{"$ne": nil}}).Decode(&comment)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperr.NotFound("comment")
+		}
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
 func (r *commentRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
 	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 
@@ -179,8 +239,39 @@ func (r *commentRepository) Delete(ctx context.Context, id primitive.ObjectID) e
 	}
 
 	if result.DeletedCount == 0 {
-		return errors.New("comment not found")
+		return apperr.NotFound("comment")
 	}
 
 	return nil
 }
+
+func (r *commentRepository) FindByAuthor(ctx context.Context, author primitive.ObjectID) ([]models.Comment, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"author": author, "deletedAt": nil})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []models.Comment
+	if err = cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+func (r *commentRepository) ReassignAuthor(ctx context.Context, from, to primitive.ObjectID) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx, bson.M{"author": from}, bson.M{"$set": bson.M{"author": to}, "$inc": bumpVersion})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *commentRepository) DeleteByPost(ctx context.Context, postID primitive.ObjectID) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"post": postID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
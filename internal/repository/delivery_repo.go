@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DeliveryRepository interface {
+	// pending, due right away
+	Create(ctx context.Context, delivery *models.WebhookDelivery) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.WebhookDelivery, error)
+	// newest first, at most limit
+	FindByWebhook(ctx context.Context, webhook primitive.ObjectID, limit int64) ([]models.WebhookDelivery, error)
+	// takes the pending delivery due soonest, if one is due at t, and moves
+	// its next attempt past lease so no other worker takes it meanwhile
+	// nil when nothing is due
+	ClaimDue(ctx context.Context, t time.Time, lease time.Duration) (*models.WebhookDelivery, error)
+	Update(ctx context.Context, id primitive.ObjectID, update bson.M) error
+	DeleteByWebhook(ctx context.Context, webhook primitive.ObjectID) (int64, error)
+	// removes finished deliveries created before t
+	DeleteFinishedBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+type deliveryRepository struct {
+	collection *mongo.Collection
+}
+
+func NewDeliveryRepository(db *mongo.Database) DeliveryRepository {
+	return &deliveryRepository{
+		collection: db.Collection("webhook_deliveries"),
+	}
+}
+
+func (r *deliveryRepository) Create(ctx context.Context, delivery *models.WebhookDelivery) error {
+	delivery.ID = primitive.NewObjectID()
+	delivery.Status = models.DeliveryPending
+	delivery.CreatedAt = time.Now()
+	delivery.NextAttemptAt = &delivery.CreatedAt
+
+	_, err := r.collection.InsertOne(ctx, delivery)
+	return err
+}
+
+func (r *deliveryRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperr.NotFound("delivery")
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *deliveryRepository) FindByWebhook(ctx context.Context, webhook primitive.ObjectID, limit int64) ([]models.WebhookDelivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{"webhook": webhook}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []models.WebhookDelivery
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *deliveryRepository) ClaimDue(ctx context.Context, t time.Time, lease time.Duration) (*models.WebhookDelivery, error) {
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+	var delivery models.WebhookDelivery
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"status": models.DeliveryPending, "nextAttemptAt": bson.M{"$lte": t}},
+		bson.M{"$set": bson.M{"nextAttemptAt": t.Add(lease)}},
+		opts,
+	).Decode(&delivery)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *deliveryRepository) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, setOrUnset(update))
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return apperr.NotFound("delivery")
+	}
+
+	return nil
+}
+
+func (r *deliveryRepository) DeleteByWebhook(ctx context.Context, webhook primitive.ObjectID) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"webhook": webhook})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *deliveryRepository) DeleteFinishedBefore(ctx context.Context, t time.Time) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{
+		"status":    bson.M{"$ne": models.DeliveryPending},
+		"createdAt": bson.M{"$lt": t},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
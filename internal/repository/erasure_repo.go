@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ErasureRepository interface {
+	Create(ctx context.Context, req *models.ErasureRequest) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.ErasureRequest, error)
+	// nil when the user has no open request
+	FindPendingByUser(ctx context.Context, user primitive.ObjectID) (*models.ErasureRequest, error)
+	// oldest first
+	FindByStatus(ctx context.Context, status string) ([]models.ErasureRequest, error)
+	// moves a request from pending to status, fails if it's no longer pending
+	Resolve(ctx context.Context, id primitive.ObjectID, status string, by *primitive.ObjectID) error
+}
+
+var ErrErasureNotPending = apperr.Conflict("erasure_not_pending", "erasure request is not pending")
+
+type erasureRepository struct {
+	collection *mongo.Collection
+}
+
+func NewErasureRepository(db *mongo.Database) ErasureRepository {
+	return &erasureRepository{
+		collection: db.Collection("erasure_requests"),
+	}
+}
+
+func (r *erasureRepository) Create(ctx context.Context, req *models.ErasureRequest) error {
+	req.ID = primitive.NewObjectID()
+	req.Status = models.ErasurePending
+	req.RequestedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, req)
+	return err
+}
+
+func (r *erasureRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.ErasureRequest, error) {
+	var req models.ErasureRequest
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&req)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperr.NotFound("erasure request")
+		}
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *erasureRepository) FindPendingByUser(ctx context.Context, user primitive.ObjectID) (*models.ErasureRequest, error) {
+	var req models.ErasureRequest
+	err := r.collection.FindOne(ctx, bson.M{"user": user, "status": models.ErasurePending}).Decode(&req)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *erasureRepository) FindByStatus(ctx context.Context, status string) ([]models.ErasureRequest, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "requestedAt", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"status": status}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reqs []models.ErasureRequest
+	if err = cursor.All(ctx, &reqs); err != nil {
+		return nil, err
+	}
+
+	return reqs, nil
+}
+
+func (r *erasureRepository) Resolve(ctx context.Context, id primitive.ObjectID, status string, by *primitive.ObjectID) error {
+	set := bson.M{"status": status, "resolvedAt": time.Now()}
+	if by != nil {
+		set["resolvedBy"] = *by
+	}
+	// matching on status makes approve/cancel races resolve exactly once
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.ErasurePending},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrErasureNotPending
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExportRepository interface {
+	Create(ctx context.Context, export *models.DataExport) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.DataExport, error)
+	// nil when the user has never asked for one
+	FindLatestByUser(ctx context.Context, user primitive.ObjectID) (*models.DataExport, error)
+	FindByUser(ctx context.Context, user primitive.ObjectID) ([]models.DataExport, error)
+	// ready exports whose download window closed before t
+	FindExpired(ctx context.Context, t time.Time) ([]models.DataExport, error)
+	Update(ctx context.Context, id primitive.ObjectID, update interface{}) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type exportRepository struct {
+	collection *mongo.Collection
+}
+
+func NewExportRepository(db *mongo.Database) ExportRepository {
+	return &exportRepository{
+		collection: db.Collection("data_exports"),
+	}
+}
+
+func (r *exportRepository) Create(ctx context.Context, export *models.DataExport) error {
+	export.ID = primitive.NewObjectID()
+	export.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, export)
+	return err
+}
+
+func (r *exportRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&export)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperr.NotFound("export")
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *exportRepository) FindLatestByUser(ctx context.Context, user primitive.ObjectID) (*models.DataExport, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	var export models.DataExport
+	err := r.collection.FindOne(ctx, bson.M{"user": user}, opts).Decode(&export)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *exportRepository) FindByUser(ctx context.Context, user primitive.ObjectID) ([]models.DataExport, error) {
+	return r.find(ctx, bson.M{"user": user})
+}
+
+func (r *exportRepository) FindExpired(ctx context.Context, t time.Time) ([]models.DataExport, error) {
+	return r.find(ctx, bson.M{"expiresAt": bson.M{"$lt": t}})
+}
+
+func (r *exportRepository) find(ctx context.Context, filter bson.M) ([]models.DataExport, error) {
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var exports []models.DataExport
+	if err = cursor.All(ctx, &exports); err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+func (r *exportRepository) Update(ctx context.Context, id primitive.ObjectID, update interface{}) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return apperr.NotFound("export")
+	}
+
+	return nil
+}
+
+func (r *exportRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return apperr.NotFound("export")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ImportRepository interface {
+	Create(ctx context.Context, mapping *models.ImportMapping) error
+	Find(ctx context.Context, source, kind, key string) (*models.ImportMapping, error)
+	FindByOldPath(ctx context.Context, path string) (*models.ImportMapping, error)
+}
+
+type importRepository struct {
+	collection *mongo.Collection
+}
+
+func NewImportRepository(db *mongo.Database) ImportRepository {
+	return &importRepository{
+		collection: db.Collection("import_mappings"),
+	}
+}
+
+func (r *importRepository) Create(ctx context.Context, mapping *models.ImportMapping) error {
+	if mapping.ImportedAt.IsZero() {
+		mapping.ImportedAt = time.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, mapping)
+	return err
+}
+
+// nil when the record hasn't been imported from source yet
+func (r *importRepository) Find(ctx context.Context, source, kind, key string) (*models.ImportMapping, error) {
+	return r.findOne(ctx, bson.M{"source": source, "kind": kind, "key": key})
+}
+
+// nil when no imported record lived at path
+func (r *importRepository) FindByOldPath(ctx context.Context, path string) (*models.ImportMapping, error) {
+	return r.findOne(ctx, bson.M{"oldPaths": path})
+}
+
+func (r *importRepository) findOne(ctx context.Context, filter bson.M) (*models.ImportMapping, error) {
+	var mapping models.ImportMapping
+	err := r.collection.FindOne(ctx, filter).Decode(&mapping)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
@@ -0,0 +1,619 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// notified around every repository call
+// StartOp may derive a new context (e.g. a child span), end receives the call's error
+type Observer interface {
+	StartOp(ctx context.Context, collection, operation string) (context.Context, func(err error))
+}
+
+// fans a call out to several observers, ended in reverse order
+func MultiObserver(observers ...Observer) Observer {
+	return multiObserver(observers)
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) StartOp(ctx context.Context, collection, operation string) (context.Context, func(err error)) {
+	ends := make([]func(error), len(m))
+	for i, obs := range m {
+		ctx, ends[i] = obs.StartOp(ctx, collection, operation)
+	}
+	return ctx, func(err error) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](err)
+		}
+	}
+}
+
+// decorators wrap a repository and report each method to an Observer
+
+type instrumentedUserRepository struct {
+	next UserRepository
+	obs  Observer
+}
+
+func NewInstrumentedUserRepository(next UserRepository, obs Observer) UserRepository {
+	return &instrumentedUserRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedUserRepository) Create(ctx context.Context, user *models.User) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, user)
+}
+
+func (r *instrumentedUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (_ *models.User, err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "FindByID")
+	defer func() { end(err) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *instrumentedUserRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) (_ []models.User, err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "FindByIDs")
+	defer func() { end(err) }()
+	return r.next.FindByIDs(ctx, ids)
+}
+
+func (r *instrumentedUserRepository) FindByUsername(ctx context.Context, username string) (_ *models.User, err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "FindByUsername")
+	defer func() { end(err) }()
+	return r.next.FindByUsername(ctx, username)
+}
+
+func (r *instrumentedUserRepository) FindByGoogleID(ctx context.Context, googleID string) (_ *models.User, err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "FindByGoogleID")
+	defer func() { end(err) }()
+	return r.next.FindByGoogleID(ctx, googleID)
+}
+
+func (r *instrumentedUserRepository) Delete(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "Delete")
+	defer func() { end(err) }()
+	return r.next.Delete(ctx, id)
+}
+
+func (r *instrumentedUserRepository) AddPosts(ctx context.Context, userID primitive.ObjectID, postIDs ...primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "AddPosts")
+	defer func() { end(err) }()
+	return r.next.AddPosts(ctx, userID, postIDs...)
+}
+
+func (r *instrumentedUserRepository) RemovePosts(ctx context.Context, postIDs ...primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "RemovePosts")
+	defer func() { end(err) }()
+	return r.next.RemovePosts(ctx, postIDs...)
+}
+
+func (r *instrumentedUserRepository) AddComments(ctx context.Context, userID primitive.ObjectID, commentIDs ...primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "AddComments")
+	defer func() { end(err) }()
+	return r.next.AddComments(ctx, userID, commentIDs...)
+}
+
+func (r *instrumentedUserRepository) RemoveComments(ctx context.Context, commentIDs ...primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "users", "RemoveComments")
+	defer func() { end(err) }()
+	return r.next.RemoveComments(ctx, commentIDs...)
+}
+
+type instrumentedPostRepository struct {
+	next PostRepository
+	obs  Observer
+}
+
+func NewInstrumentedPostRepository(next PostRepository, obs Observer) PostRepository {
+	return &instrumentedPostRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedPostRepository) Create(ctx context.Context, post *models.Post) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, post)
+}
+
+func (r *instrumentedPostRepository) FindAll(ctx context.Context) (_ []models.Post, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindAll")
+	defer func() { end(err) }()
+	return r.next.FindAll(ctx)
+}
+
+func (r *instrumentedPostRepository) FindAllWithAuthor(ctx context.Context) (_ []models.PostWithAuthor, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindAllWithAuthor")
+	defer func() { end(err) }()
+	return r.next.FindAllWithAuthor(ctx)
+}
+
+func (r *instrumentedPostRepository) FindByID(ctx context.Context, id primitive.ObjectID) (_ *models.Post, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindByID")
+	defer func() { end(err) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *instrumentedPostRepository) FindByIDWithAuthor(ctx context.Context, id primitive.ObjectID) (_ *models.PostWithAuthor, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindByIDWithAuthor")
+	defer func() { end(err) }()
+	return r.next.FindByIDWithAuthor(ctx, id)
+}
+
+func (r *instrumentedPostRepository) FindByAuthor(ctx context.Context, author primitive.ObjectID) (_ []models.Post, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindByAuthor")
+	defer func() { end(err) }()
+	return r.next.FindByAuthor(ctx, author)
+}
+
+func (r *instrumentedPostRepository) FindPublished(ctx context.Context, filter PostFilter) (_ []models.PostWithAuthor, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindPublished")
+	defer func() { end(err) }()
+	return r.next.FindPublished(ctx, filter)
+}
+
+func (r *instrumentedPostRepository) CountPublished(ctx context.Context, filter PostFilter) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "CountPublished")
+	defer func() { end(err) }()
+	return r.next.CountPublished(ctx, filter)
+}
+
+func (r *instrumentedPostRepository) Find(ctx context.Context, filter PostQuery) (_ []models.Post, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "Find")
+	defer func() { end(err) }()
+	return r.next.Find(ctx, filter)
+}
+
+func (r *instrumentedPostRepository) Count(ctx context.Context, filter PostQuery) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "Count")
+	defer func() { end(err) }()
+	return r.next.Count(ctx, filter)
+}
+
+func (r *instrumentedPostRepository) CountIndexable(ctx context.Context) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "CountIndexable")
+	defer func() { end(err) }()
+	return r.next.CountIndexable(ctx)
+}
+
+func (r *instrumentedPostRepository) FindIndexable(ctx context.Context, skip, limit int64) (_ []models.PostRef, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindIndexable")
+	defer func() { end(err) }()
+	return r.next.FindIndexable(ctx, skip, limit)
+}
+
+func (r *instrumentedPostRepository) Update(ctx context.Context, id primitive.ObjectID, version *int64, update interface{}) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "Update")
+	defer func() { end(err) }()
+	return r.next.Update(ctx, id, version, update)
+}
+
+func (r *instrumentedPostRepository) Trash(ctx context.Context, id, by primitive.ObjectID, version *int64) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "Trash")
+	defer func() { end(err) }()
+	return r.next.Trash(ctx, id, by, version)
+}
+
+func (r *instrumentedPostRepository) Restore(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "Restore")
+	defer func() { end(err) }()
+	return r.next.Restore(ctx, id)
+}
+
+func (r *instrumentedPostRepository) FindTrash(ctx context.Context, filter TrashFilter) (_ []models.Post, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindTrash")
+	defer func() { end(err) }()
+	return r.next.FindTrash(ctx, filter)
+}
+
+func (r *instrumentedPostRepository) FindTrashedByID(ctx context.Context, id primitive.ObjectID) (_ *models.Post, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "FindTrashedByID")
+	defer func() { end(err) }()
+	return r.next.FindTrashedByID(ctx, id)
+}
+
+func (r *instrumentedPostRepository) Delete(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "Delete")
+	defer func() { end(err) }()
+	return r.next.Delete(ctx, id)
+}
+
+func (r *instrumentedPostRepository) ReassignAuthor(ctx context.Context, from, to primitive.ObjectID) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "ReassignAuthor")
+	defer func() { end(err) }()
+	return r.next.ReassignAuthor(ctx, from, to)
+}
+
+func (r *instrumentedPostRepository) IncCommentCount(ctx context.Context, id primitive.ObjectID, delta int) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "posts", "IncCommentCount")
+	defer func() { end(err) }()
+	return r.next.IncCommentCount(ctx, id, delta)
+}
+
+type instrumentedCommentRepository struct {
+	next CommentRepository
+	obs  Observer
+}
+
+func NewInstrumentedCommentRepository(next CommentRepository, obs Observer) CommentRepository {
+	return &instrumentedCommentRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedCommentRepository) Create(ctx context.Context, comment *models.Comment) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, comment)
+}
+
+func (r *instrumentedCommentRepository) FindByPost(ctx context.Context, postID primitive.ObjectID) (_ []models.Comment, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "FindByPost")
+	defer func() { end(err) }()
+	return r.next.FindByPost(ctx, postID)
+}
+
+func (r *instrumentedCommentRepository) FindByPostWithAuthor(ctx context.Context, postID primitive.ObjectID) (_ []models.CommentWithAuthor, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "FindByPostWithAuthor")
+	defer func() { end(err) }()
+	return r.next.FindByPostWithAuthor(ctx, postID)
+}
+
+func (r *instrumentedCommentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (_ *models.Comment, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "FindByID")
+	defer func() { end(err) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *instrumentedCommentRepository) FindByIDWithAuthor(ctx context.Context, id primitive.ObjectID) (_ *models.CommentWithAuthor, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "FindByIDWithAuthor")
+	defer func() { end(err) }()
+	return r.next.FindByIDWithAuthor(ctx, id)
+}
+
+func (r *instrumentedCommentRepository) Update(ctx context.Context, id primitive.ObjectID, version *int64, text, html string) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "Update")
+	defer func() { end(err) }()
+	return r.next.Update(ctx, id, version, text, html)
+}
+
+func (r *instrumentedCommentRepository) Trash(ctx context.Context, id, by primitive.ObjectID, version *int64) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "Trash")
+	defer func() { end(err) }()
+	return r.next.Trash(ctx, id, by, version)
+}
+
+func (r *instrumentedCommentRepository) Restore(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "Restore")
+	defer func() { end(err) }()
+	return r.next.Restore(ctx, id)
+}
+
+func (r *instrumentedCommentRepository) FindTrash(ctx context.Context, filter TrashFilter) (_ []models.Comment, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "FindTrash")
+	defer func() { end(err) }()
+	return r.next.FindTrash(ctx, filter)
+}
+
+func (r *instrumentedCommentRepository) FindTrashedByID(ctx context.Context, id primitive.ObjectID) (_ *models.Comment, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "FindTrashedByID")
+	defer func() { end(err) }()
+	return r.next.FindTrashedByID(ctx, id)
+}
+
+func (r *instrumentedCommentRepository) Delete(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "Delete")
+	defer func() { end(err) }()
+	return r.next.Delete(ctx, id)
+}
+
+func (r *instrumentedCommentRepository) FindByAuthor(ctx context.Context, author primitive.ObjectID) (_ []models.Comment, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "FindByAuthor")
+	defer func() { end(err) }()
+	return r.next.FindByAuthor(ctx, author)
+}
+
+func (r *instrumentedCommentRepository) ReassignAuthor(ctx context.Context, from, to primitive.ObjectID) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "ReassignAuthor")
+	defer func() { end(err) }()
+	return r.next.ReassignAuthor(ctx, from, to)
+}
+
+func (r *instrumentedCommentRepository) DeleteByPost(ctx context.Context, postID primitive.ObjectID) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "comments", "DeleteByPost")
+	defer func() { end(err) }()
+	return r.next.DeleteByPost(ctx, postID)
+}
+
+type instrumentedMediaRepository struct {
+	next MediaRepository
+	obs  Observer
+}
+
+func NewInstrumentedMediaRepository(next MediaRepository, obs Observer) MediaRepository {
+	return &instrumentedMediaRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedMediaRepository) Create(ctx context.Context, media *models.Media) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "media", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, media)
+}
+
+func (r *instrumentedMediaRepository) FindByID(ctx context.Context, id primitive.ObjectID) (_ *models.Media, err error) {
+	ctx, end := r.obs.StartOp(ctx, "media", "FindByID")
+	defer func() { end(err) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *instrumentedMediaRepository) FindByOwner(ctx context.Context, owner primitive.ObjectID) (_ []models.Media, err error) {
+	ctx, end := r.obs.StartOp(ctx, "media", "FindByOwner")
+	defer func() { end(err) }()
+	return r.next.FindByOwner(ctx, owner)
+}
+
+func (r *instrumentedMediaRepository) Delete(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "media", "Delete")
+	defer func() { end(err) }()
+	return r.next.Delete(ctx, id)
+}
+
+func (r *instrumentedMediaRepository) ReassignOwner(ctx context.Context, from, to primitive.ObjectID) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "media", "ReassignOwner")
+	defer func() { end(err) }()
+	return r.next.ReassignOwner(ctx, from, to)
+}
+
+type instrumentedImportRepository struct {
+	next ImportRepository
+	obs  Observer
+}
+
+func NewInstrumentedImportRepository(next ImportRepository, obs Observer) ImportRepository {
+	return &instrumentedImportRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedImportRepository) Create(ctx context.Context, mapping *models.ImportMapping) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "import_mappings", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, mapping)
+}
+
+func (r *instrumentedImportRepository) Find(ctx context.Context, source, kind, key string) (_ *models.ImportMapping, err error) {
+	ctx, end := r.obs.StartOp(ctx, "import_mappings", "Find")
+	defer func() { end(err) }()
+	return r.next.Find(ctx, source, kind, key)
+}
+
+func (r *instrumentedImportRepository) FindByOldPath(ctx context.Context, path string) (_ *models.ImportMapping, err error) {
+	ctx, end := r.obs.StartOp(ctx, "import_mappings", "FindByOldPath")
+	defer func() { end(err) }()
+	return r.next.FindByOldPath(ctx, path)
+}
+
+type instrumentedAuditRepository struct {
+	next AuditRepository
+	obs  Observer
+}
+
+func NewInstrumentedAuditRepository(next AuditRepository, obs Observer) AuditRepository {
+	return &instrumentedAuditRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedAuditRepository) Create(ctx context.Context, entry *models.AuditEntry) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "audit_log", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, entry)
+}
+
+func (r *instrumentedAuditRepository) FindByActor(ctx context.Context, actor primitive.ObjectID) (_ []models.AuditEntry, err error) {
+	ctx, end := r.obs.StartOp(ctx, "audit_log", "FindByActor")
+	defer func() { end(err) }()
+	return r.next.FindByActor(ctx, actor)
+}
+
+type instrumentedExportRepository struct {
+	next ExportRepository
+	obs  Observer
+}
+
+func NewInstrumentedExportRepository(next ExportRepository, obs Observer) ExportRepository {
+	return &instrumentedExportRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedExportRepository) Create(ctx context.Context, export *models.DataExport) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "data_exports", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, export)
+}
+
+func (r *instrumentedExportRepository) FindByID(ctx context.Context, id primitive.ObjectID) (_ *models.DataExport, err error) {
+	ctx, end := r.obs.StartOp(ctx, "data_exports", "FindByID")
+	defer func() { end(err) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *instrumentedExportRepository) FindLatestByUser(ctx context.Context, user primitive.ObjectID) (_ *models.DataExport, err error) {
+	ctx, end := r.obs.StartOp(ctx, "data_exports", "FindLatestByUser")
+	defer func() { end(err) }()
+	return r.next.FindLatestByUser(ctx, user)
+}
+
+func (r *instrumentedExportRepository) FindByUser(ctx context.Context, user primitive.ObjectID) (_ []models.DataExport, err error) {
+	ctx, end := r.obs.StartOp(ctx, "data_exports", "FindByUser")
+	defer func() { end(err) }()
+	return r.next.FindByUser(ctx, user)
+}
+
+func (r *instrumentedExportRepository) FindExpired(ctx context.Context, t time.Time) (_ []models.DataExport, err error) {
+	ctx, end := r.obs.StartOp(ctx, "data_exports", "FindExpired")
+	defer func() { end(err) }()
+	return r.next.FindExpired(ctx, t)
+}
+
+func (r *instrumentedExportRepository) Update(ctx context.Context, id primitive.ObjectID, update interface{}) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "data_exports", "Update")
+	defer func() { end(err) }()
+	return r.next.Update(ctx, id, update)
+}
+
+func (r *instrumentedExportRepository) Delete(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "data_exports", "Delete")
+	defer func() { end(err) }()
+	return r.next.Delete(ctx, id)
+}
+
+type instrumentedErasureRepository struct {
+	next ErasureRepository
+	obs  Observer
+}
+
+func NewInstrumentedErasureRepository(next ErasureRepository, obs Observer) ErasureRepository {
+	return &instrumentedErasureRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedErasureRepository) Create(ctx context.Context, req *models.ErasureRequest) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "erasure_requests", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, req)
+}
+
+func (r *instrumentedErasureRepository) FindByID(ctx context.Context, id primitive.ObjectID) (_ *models.ErasureRequest, err error) {
+	ctx, end := r.obs.StartOp(ctx, "erasure_requests", "FindByID")
+	defer func() { end(err) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *instrumentedErasureRepository) FindPendingByUser(ctx context.Context, user primitive.ObjectID) (_ *models.ErasureRequest, err error) {
+	ctx, end := r.obs.StartOp(ctx, "erasure_requests", "FindPendingByUser")
+	defer func() { end(err) }()
+	return r.next.FindPendingByUser(ctx, user)
+}
+
+func (r *instrumentedErasureRepository) FindByStatus(ctx context.Context, status string) (_ []models.ErasureRequest, err error) {
+	ctx, end := r.obs.StartOp(ctx, "erasure_requests", "FindByStatus")
+	defer func() { end(err) }()
+	return r.next.FindByStatus(ctx, status)
+}
+
+func (r *instrumentedErasureRepository) Resolve(ctx context.Context, id primitive.ObjectID, status string, by *primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "erasure_requests", "Resolve")
+	defer func() { end(err) }()
+	return r.next.Resolve(ctx, id, status, by)
+}
+
+type instrumentedWebhookRepository struct {
+	next WebhookRepository
+	obs  Observer
+}
+
+func NewInstrumentedWebhookRepository(next WebhookRepository, obs Observer) WebhookRepository {
+	return &instrumentedWebhookRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedWebhookRepository) Create(ctx context.Context, hook *models.Webhook) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, hook)
+}
+
+func (r *instrumentedWebhookRepository) FindByID(ctx context.Context, id primitive.ObjectID) (_ *models.Webhook, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "FindByID")
+	defer func() { end(err) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *instrumentedWebhookRepository) FindAll(ctx context.Context) (_ []models.Webhook, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "FindAll")
+	defer func() { end(err) }()
+	return r.next.FindAll(ctx)
+}
+
+func (r *instrumentedWebhookRepository) FindActiveByEvent(ctx context.Context, event string) (_ []models.Webhook, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "FindActiveByEvent")
+	defer func() { end(err) }()
+	return r.next.FindActiveByEvent(ctx, event)
+}
+
+func (r *instrumentedWebhookRepository) Update(ctx context.Context, id primitive.ObjectID, update bson.M) (_ *models.Webhook, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "Update")
+	defer func() { end(err) }()
+	return r.next.Update(ctx, id, update)
+}
+
+func (r *instrumentedWebhookRepository) Delete(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "Delete")
+	defer func() { end(err) }()
+	return r.next.Delete(ctx, id)
+}
+
+func (r *instrumentedWebhookRepository) RecordFailure(ctx context.Context, id primitive.ObjectID) (_ int, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "RecordFailure")
+	defer func() { end(err) }()
+	return r.next.RecordFailure(ctx, id)
+}
+
+func (r *instrumentedWebhookRepository) RecordSuccess(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "RecordSuccess")
+	defer func() { end(err) }()
+	return r.next.RecordSuccess(ctx, id)
+}
+
+func (r *instrumentedWebhookRepository) Disable(ctx context.Context, id primitive.ObjectID, reason string) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhooks", "Disable")
+	defer func() { end(err) }()
+	return r.next.Disable(ctx, id, reason)
+}
+
+type instrumentedDeliveryRepository struct {
+	next DeliveryRepository
+	obs  Observer
+}
+
+func NewInstrumentedDeliveryRepository(next DeliveryRepository, obs Observer) DeliveryRepository {
+	return &instrumentedDeliveryRepository{next: next, obs: obs}
+}
+
+func (r *instrumentedDeliveryRepository) Create(ctx context.Context, delivery *models.WebhookDelivery) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhook_deliveries", "Create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, delivery)
+}
+
+func (r *instrumentedDeliveryRepository) FindByID(ctx context.Context, id primitive.ObjectID) (_ *models.WebhookDelivery, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhook_deliveries", "FindByID")
+	defer func() { end(err) }()
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *instrumentedDeliveryRepository) FindByWebhook(ctx context.Context, webhook primitive.ObjectID, limit int64) (_ []models.WebhookDelivery, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhook_deliveries", "FindByWebhook")
+	defer func() { end(err) }()
+	return r.next.FindByWebhook(ctx, webhook, limit)
+}
+
+func (r *instrumentedDeliveryRepository) ClaimDue(ctx context.Context, t time.Time, lease time.Duration) (_ *models.WebhookDelivery, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhook_deliveries", "ClaimDue")
+	defer func() { end(err) }()
+	return r.next.ClaimDue(ctx, t, lease)
+}
+
+func (r *instrumentedDeliveryRepository) Update(ctx context.Context, id primitive.ObjectID, update bson.M) (err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhook_deliveries", "Update")
+	defer func() { end(err) }()
+	return r.next.Update(ctx, id, update)
+}
+
+func (r *instrumentedDeliveryRepository) DeleteByWebhook(ctx context.Context, webhook primitive.ObjectID) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhook_deliveries", "DeleteByWebhook")
+	defer func() { end(err) }()
+	return r.next.DeleteByWebhook(ctx, webhook)
+}
+
+func (r *instrumentedDeliveryRepository) DeleteFinishedBefore(ctx context.Context, t time.Time) (_ int64, err error) {
+	ctx, end := r.obs.StartOp(ctx, "webhook_deliveries", "DeleteFinishedBefore")
+	defer func() { end(err) }()
+	return r.next.DeleteFinishedBefore(ctx, t)
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MediaRepository interface {
+	Create(ctx context.Context, media *models.Media) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Media, error)
+	FindByOwner(ctx context.Context, owner primitive.ObjectID) ([]models.Media, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// moves every upload owned by from to to, returns how many moved
+	ReassignOwner(ctx context.Context, from, to primitive.ObjectID) (int64, error)
+}
+
+type mediaRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMediaRepository(db *mongo.Database) MediaRepository {
+	return &mediaRepository{
+		collection: db.Collection("media"),
+	}
+}
+
+func (r *mediaRepository) Create(ctx context.Context, media *models.Media) error {
+	// storage keys are derived from the id, so keep one assigned upstream
+	if media.ID.IsZero() {
+		media.ID = primitive.NewObjectID()
+	}
+	if media.Timestamp.IsZero() {
+		media.Timestamp = time.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, media)
+	return err
+}
+
+func (r *mediaRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Media, error) {
+	var media models.Media
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&media)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperr.NotFound("media")
+		}
+		return nil, err
+	}
+
+	return &media, nil
+}
+
+// newest first
+func (r *mediaRepository) FindByOwner(ctx context.Context, owner primitive.ObjectID) ([]models.Media, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"owner": owner}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var media []models.Media
+	if err = cursor.All(ctx, &media); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+func (r *mediaRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return apperr.NotFound("media")
+	}
+
+	return nil
+}
+
+func (r *mediaRepository) ReassignOwner(ctx context.Context, from, to primitive.ObjectID) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx, bson.M{"owner": from}, bson.M{"$set": bson.M{"owner": to}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
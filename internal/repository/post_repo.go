@@ -2,13 +2,14 @@ package repository
 
 import (
 	"context"
-	"errors"
 	"time"
 
+	"github.com/kurtgray/blog-api-go/internal/apperr"
 	"github.com/kurtgray/blog-api-go/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type PostRepository interface {
@@ -18,8 +19,44 @@ type PostRepository interface {
 	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error)
 	FindByIDWithAuthor(ctx context.Context, id primitive.ObjectID) (*models.PostWithAuthor, error)
 	FindByAuthor(ctx context.Context, author primitive.ObjectID) ([]models.Post, error)
-	Update(ctx context.Context, id primitive.ObjectID, update interface{}) error
+	FindPublished(ctx context.Context, filter PostFilter) ([]models.PostWithAuthor, error)
+	// posts newest first, drafts too unless filter says otherwise
+	Find(ctx context.Context, filter PostQuery) ([]models.Post, error)
+	// posts matching filter, Skip and Limit are ignored
+	Count(ctx context.Context, filter PostQuery) (int64, error)
+	CountPublished(ctx context.Context, filter PostFilter) (int64, error)
+	CountIndexable(ctx context.Context) (int64, error)
+	FindIndexable(ctx context.Context, skip, limit int64) ([]models.PostRef, error)
+	// sets the fields in update when the post is at version, or at any
+	// version when it's nil, returns the post's new version
+	Update(ctx context.Context, id primitive.ObjectID, version *int64, update interface{}) (int64, error)
+	// moves the post to the trash, or back out of it
+	Trash(ctx context.Context, id, by primitive.ObjectID, version *int64) error
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	FindTrash(ctx context.Context, filter TrashFilter) ([]models.Post, error)
+	// not found when the post isn't in the trash
+	FindTrashedByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error)
+	// removes the post for good, trashed or not
 	Delete(ctx context.Context, id primitive.ObjectID) error
+	// moves every post by from to to, returns how many moved
+	ReassignAuthor(ctx context.Context, from, to primitive.ObjectID) (int64, error)
+	// adjusts the denormalized comment count, leaves updatedAt and the
+	// version alone so comments don't fail an editor's If-Match
+	IncCommentCount(ctx context.Context, id primitive.ObjectID, delta int) error
+}
+
+// narrows FindPublished, zero values match everything
+type PostFilter struct {
+	Author *primitive.ObjectID
+	Tag    string
+	Skip   int64
+	Limit  int64
+}
+
+// narrows Find, a nil Published matches drafts and published posts alike
+type PostQuery struct {
+	PostFilter
+	Published *bool
 }
 
 type postRepository struct {
@@ -34,7 +71,13 @@ func NewPostRepository(db *mongo.Database) PostRepository {
 
 func (r *postRepository) Create(ctx context.Context, post *models.Post) error {
 	post.ID = primitive.NewObjectID()
-	post.Timestamp = time.Now()
+	// imports bring their original dates
+	if post.Timestamp.IsZero() {
+		post.Timestamp = time.Now()
+	}
+	if post.UpdatedAt.IsZero() {
+		post.UpdatedAt = post.Timestamp
+	}
 
 	_, err := r.collection.InsertOne(ctx, post)
 	return err
@@ -42,7 +85,7 @@ func (r *postRepository) Create(ctx context.Context, post *models.Post) error {
 
 func (r *postRepository) FindAll(ctx context.Context) ([]models.Post, error) {
 	// cursor for multiple results
-	cursor, err := r.collection.Find(ctx, bson.M{})
+	cursor, err := r.collection.Find(ctx, notDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -58,118 +101,140 @@ func (r *postRepository) FindAll(ctx context.Context) ([]models.Post, error) {
 }
 
 func (r *postRepository) FindAllWithAuthor(ctx context.Context) ([]models.PostWithAuthor, error) {
-	pipeline := mongo.Pipeline{
-		// lookup users collection
-		{{Key: "$lookup", Value: bson.D{
-			{Key: "from", Value: "users"},
-			{Key: "localField", Value: "author"},
-			{Key: "foreignField", Value: "_id"},
-			{Key: "as", Value: "authorData"},
-		}}},
-		// unwind the author array (converts [author] to author)
-		{{Key: "$unwind", Value: bson.D{
-			{Key: "path", Value: "$authorData"},
-			{Key: "preserveNullAndEmptyArrays", Value: true},
-		}}},
-		// project only needed user fields
-		{{Key: "$project", Value: bson.D{
-			{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$_id"}}},
-			{Key: "title", Value: 1},
-			{Key: "text", Value: 1},
-			{Key: "imgUrl", Value: 1},
-			{Key: "published", Value: 1},
-			{Key: "timestamp", Value: 1},
-			{Key: "author", Value: bson.D{
-				{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$authorData._id"}}},
-				{Key: "username", Value: "$authorData.username"},
-				{Key: "fname", Value: "$authorData.fname"},
-				{Key: "lname", Value: "$authorData.lname"},
-				{Key: "admin", Value: "$authorData.admin"},
-				{Key: "canPublish", Value: "$authorData.canPublish"},
-			}},
-		}}},
+	return r.aggregateWithAuthor(ctx, nil)
+}
+
+// published posts newest first, narrowed by filter
+func (r *postRepository) FindPublished(ctx context.Context, filter PostFilter) ([]models.PostWithAuthor, error) {
+	stages := mongo.Pipeline{
+		{{Key: "$match", Value: publishedMatch(filter)}},
+		{{Key: "$sort", Value: bson.D{{Key: "timestamp", Value: -1}}}},
+	}
+	if filter.Skip > 0 {
+		stages = append(stages, bson.D{{Key: "$skip", Value: filter.Skip}})
+	}
+	if filter.Limit > 0 {
+		stages = append(stages, bson.D{{Key: "$limit", Value: filter.Limit}})
 	}
 
-	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	return r.aggregateWithAuthor(ctx, stages)
+}
+
+// published posts matching filter, Skip and Limit are ignored
+func (r *postRepository) CountPublished(ctx context.Context, filter PostFilter) (int64, error) {
+	return r.collection.CountDocuments(ctx, publishedMatch(filter))
+}
+
+func publishedMatch(filter PostFilter) bson.D {
+	match := bson.D{{Key: "published", Value: true}, {Key: "deletedAt", Value: nil}}
+	if filter.Author != nil {
+		match = append(match, bson.E{Key: "author", Value: *filter.Author})
+	}
+	if filter.Tag != "" {
+		match = append(match, bson.E{Key: "tags", Value: filter.Tag})
+	}
+	return match
+}
+
+func (r *postRepository) Find(ctx context.Context, filter PostQuery) ([]models.Post, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+	if filter.Skip > 0 {
+		opts.SetSkip(filter.Skip)
+	}
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := r.collection.Find(ctx, queryMatch(filter), opts)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var posts []models.PostWithAuthor
+	var posts []models.Post
 	if err = cursor.All(ctx, &posts); err != nil {
 		return nil, err
 	}
-
 	return posts, nil
 }
 
+func (r *postRepository) Count(ctx context.Context, filter PostQuery) (int64, error) {
+	return r.collection.CountDocuments(ctx, queryMatch(filter))
+}
+
+func queryMatch(filter PostQuery) bson.D {
+	match := bson.D{{Key: "deletedAt", Value: nil}}
+	if filter.Published != nil {
+		match = append(match, bson.E{Key: "published", Value: *filter.Published})
+	}
+	if filter.Author != nil {
+		match = append(match, bson.E{Key: "author", Value: *filter.Author})
+	}
+	if filter.Tag != "" {
+		match = append(match, bson.E{Key: "tags", Value: filter.Tag})
+	}
+	return match
+}
+
+// published posts that search engines may index
+var indexableFilter = bson.M{"published": true, "seo.noIndex": bson.M{"$ne": true}, "deletedAt": nil}
+
+func (r *postRepository) CountIndexable(ctx context.Context) (int64, error) {
+	return r.collection.CountDocuments(ctx, indexableFilter)
+}
+
+// ids and dates of indexable posts, oldest first so pages stay stable as posts are added
+func (r *postRepository) FindIndexable(ctx context.Context, skip, limit int64) ([]models.PostRef, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1, "timestamp": 1, "updatedAt": 1}).
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, indexableFilter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var refs []models.PostRef
+	if err = cursor.All(ctx, &refs); err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
 func (r *postRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error) {
 	var post models.Post
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&post)
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "deletedAt": nil}).Decode(&post)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, nil
+			return nil, apperr.NotFound("post")
 		}
+		return nil, err
 	}
-	return &post, err
+	return &post, nil
 }
 
 func (r *postRepository) FindByIDWithAuthor(ctx context.Context, id primitive.ObjectID) (*models.PostWithAuthor, error) {
-	pipeline := mongo.Pipeline{
+	posts, err := r.aggregateWithAuthor(ctx, mongo.Pipeline{
 		// match the specific post
 		{{Key: "$match", Value: bson.D{{Key: "_id", Value: id}}}},
-		// lookup author
-		{{Key: "$lookup", Value: bson.D{
-			{Key: "from", Value: "users"},
-			{Key: "localField", Value: "author"},
-			{Key: "foreignField", Value: "_id"},
-			{Key: "as", Value: "authorData"},
-		}}},
-		// unwind author array
-		{{Key: "$unwind", Value: bson.D{
-			{Key: "path", Value: "$authorData"},
-			{Key: "preserveNullAndEmptyArrays", Value: true},
-		}}},
-		// project only needed fields
-		{{Key: "$project", Value: bson.D{
-			{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$_id"}}},
-			{Key: "title", Value: 1},
-			{Key: "text", Value: 1},
-			{Key: "imgUrl", Value: 1},
-			{Key: "published", Value: 1},
-			{Key: "timestamp", Value: 1},
-			{Key: "author", Value: bson.D{
-				{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$authorData._id"}}},
-				{Key: "username", Value: "$authorData.username"},
-				{Key: "fname", Value: "$authorData.fname"},
-				{Key: "lname", Value: "$authorData.lname"},
-				{Key: "admin", Value: "$authorData.admin"},
-				{Key: "canPublish", Value: "$authorData.canPublish"},
-			}},
-		}}},
-	}
-
-	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-
-	var posts []models.PostWithAuthor
-	if err = cursor.All(ctx, &posts); err != nil {
-		return nil, err
-	}
 
 	if len(posts) == 0 {
-		return nil, errors.New("post not found")
+		return nil, apperr.NotFound("post")
 	}
 
 	return &posts[0], nil
 }
 
 func (r *postRepository) FindByAuthor(ctx context.Context, authorID primitive.ObjectID) ([]models.Post, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{"author": authorID})
+	cursor, err := r.collection.Find(ctx, bson.M{"author": authorID, "deletedAt": nil})
 	if err != nil {
 		return nil, err
 	}
@@ -183,24 +248,58 @@ func (r *postRepository) FindByAuthor(ctx context.Context, authorID primitive.Ob
 	return posts, nil
 }
 
-func (r *postRepository) Update(ctx context.Context, id primitive.ObjectID, update interface{}) error {
-	result, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": update},
+func (r *postRepository) Update(ctx context.Context, id primitive.ObjectID, version *int64, update interface{}) (int64, error) {
+	return versionedUpdate(ctx, r.collection, id, version,
+		bson.M{"$set": update, "$currentDate": bson.M{"updatedAt": true}},
+		apperr.NotFound("post"),
 	)
+}
+
+func (r *postRepository) Trash(ctx context.Context, id, by primitive.ObjectID, version *int64) error {
+	_, err := versionedUpdate(ctx, r.collection, id, version, trashUpdate(by), apperr.NotFound("post"))
+	return err
+}
 
+func (r *postRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "deletedAt": bson.M{"$ne": nil}}, restoreUpdate)
 	if err != nil {
 		return err
 	}
 
 	if result.MatchedCount == 0 {
-		return errors.New("post not found")
+		return apperr.NotFound("post")
 	}
 
 	return nil
 }
 
+func (r *postRepository) FindTrash(ctx context.Context, filter TrashFilter) ([]models.Post, error) {
+	cursor, err := r.collection.Find(ctx, filter.match(), trashSort)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err = cursor.All(ctx, &posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (r *postRepository) FindTrashedByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error) {
+	var post models.Post
+	err := r.collection.FindOne(ctx, bson.M{"_id": id, "deletedAt": bson.M{"$ne": nil}}).Decode(&post)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperr.NotFound("post")
+		}
+		return nil, err
+	}
+	return &post, nil
+}
+
 func (r *postRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
 	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	if err != nil {
@@ -208,8 +307,87 @@ func (r *postRepository) Delete(ctx context.Context, id primitive.ObjectID) erro
 	}
 
 	if result.DeletedCount == 0 {
-		return errors.New("post not found")
+		return apperr.NotFound("post")
 	}
 
 	return nil
 }
+
+func (r *postRepository) ReassignAuthor(ctx context.Context, from, to primitive.ObjectID) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx, bson.M{"author": from}, bson.M{"$set": bson.M{"author": to}, "$inc": bumpVersion})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *postRepository) IncCommentCount(ctx context.Context, id primitive.ObjectID, delta int) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"commentCount": delta}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return apperr.NotFound("post")
+	}
+
+	return nil
+}
+
+// runs stages then joins the author and projects the public post shape
+func (r *postRepository) aggregateWithAuthor(ctx context.Context, stages mongo.Pipeline) ([]models.PostWithAuthor, error) {
+	pipeline := append(mongo.Pipeline{{{Key: "$match", Value: notDeleted}}}, stages...)
+	pipeline = append(pipeline,
+		// lookup users collection
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "users"},
+			{Key: "localField", Value: "author"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "authorData"},
+		}}},
+		// unwind the author array (converts [author] to author)
+		bson.D{{Key: "$unwind", Value: bson.D{
+			{Key: "path", Value: "$authorData"},
+			{Key: "preserveNullAndEmptyArrays", Value: true},
+		}}},
+		// project only needed user fields
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$_id"}}},
+			{Key: "title", Value: 1},
+			{Key: "text", Value: 1},
+			{Key: "format", Value: 1},
+			{Key: "html", Value: 1},
+			{Key: "toc", Value: 1},
+			{Key: "tags", Value: 1},
+			{Key: "imgUrl", Value: 1},
+			{Key: "seo", Value: 1},
+			{Key: "mediaId", Value: bson.D{{Key: "$toString", Value: "$mediaId"}}},
+			{Key: "published", Value: 1},
+			{Key: "commentCount", Value: 1},
+			{Key: "version", Value: 1},
+			{Key: "timestamp", Value: 1},
+			{Key: "updatedAt", Value: 1},
+			{Key: "author", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "$toString", Value: "$authorData._id"}}},
+				{Key: "username", Value: "$authorData.username"},
+				{Key: "fname", Value: "$authorData.fname"},
+				{Key: "lname", Value: "$authorData.lname"},
+				{Key: "admin", Value: "$authorData.admin"},
+				{Key: "canPublish", Value: "$authorData.canPublish"},
+			}},
+		}}},
+	)
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.PostWithAuthor
+	if err = cursor.All(ctx, &posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
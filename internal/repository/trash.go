@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trashed posts and comments carry a deletedAt, every read outside the
+// trash methods matches this so they stay hidden until restored
+var notDeleted = bson.M{"deletedAt": nil}
+
+// narrows FindTrash, zero values match everything in the trash
+type TrashFilter struct {
+	Author *primitive.ObjectID
+	// comments only
+	Post *primitive.ObjectID
+	// trashed before this time, for the purge job
+	DeletedBefore time.Time
+}
+
+func (f TrashFilter) match() bson.M {
+	deleted := bson.M{"$ne": nil}
+	if !f.DeletedBefore.IsZero() {
+		deleted["$lt"] = f.DeletedBefore
+	}
+	match := bson.M{"deletedAt": deleted}
+	if f.Author != nil {
+		match["author"] = *f.Author
+	}
+	if f.Post != nil {
+		match["post"] = *f.Post
+	}
+	return match
+}
+
+// most recently trashed first
+var trashSort = options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}})
+
+func trashUpdate(by primitive.ObjectID) bson.M {
+	return bson.M{"$set": bson.M{"deletedAt": time.Now(), "deletedBy": by}}
+}
+
+var restoreUpdate = bson.M{"$unset": bson.M{"deletedAt": "", "deletedBy": ""}, "$inc": bumpVersion}
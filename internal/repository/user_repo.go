@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/kurtgray/blog-api-go/internal/apperr"
 	"github.com/kurtgray/blog-api-go/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,8 +15,16 @@ import (
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	// the users that exist among ids, in no particular order
+	FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error)
 	FindByUsername(ctx context.Context, username string) (*models.User, error)
 	FindByGoogleID(ctx context.Context, googleID string) (*models.User, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// keep User.Posts and User.Comments in step with the collections
+	AddPosts(ctx context.Context, userID primitive.ObjectID, postIDs ...primitive.ObjectID) error
+	RemovePosts(ctx context.Context, postIDs ...primitive.ObjectID) error
+	AddComments(ctx context.Context, userID primitive.ObjectID, commentIDs ...primitive.ObjectID) error
+	RemoveComments(ctx context.Context, commentIDs ...primitive.ObjectID) error
 }
 
 type userRepository struct {
@@ -49,13 +58,26 @@ func (r *userRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*
 	// decode into user var with pointer
 	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
 	if err != nil {
-		// not an err, just no user found
 		if err == mongo.ErrNoDocuments {
-			return nil, nil
+			return nil, apperr.NotFound("user")
 		}
+		return nil, err
 	}
-	// return user and error (one will be nil)
-	return &user, err
+	return &user, nil
+}
+
+func (r *userRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
 }
 
 // finds user by username
@@ -64,10 +86,11 @@ func (r *userRepository) FindByUsername(ctx context.Context, username string) (*
 	err := r.collection.FindOne(ctx, bson.M{"username": username}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, nil
+			return nil, apperr.NotFound("user")
 		}
+		return nil, err
 	}
-	return &user, err
+	return &user, nil
 }
 
 // finds user by google id
@@ -76,8 +99,69 @@ func (r *userRepository) FindByGoogleID(ctx context.Context, googleID string) (*
 	err := r.collection.FindOne(ctx, bson.M{"googleId": googleID}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, nil
+			return nil, apperr.NotFound("user")
 		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return apperr.NotFound("user")
+	}
+
+	return nil
+}
+
+func (r *userRepository) AddPosts(ctx context.Context, userID primitive.ObjectID, postIDs ...primitive.ObjectID) error {
+	return r.addRefs(ctx, userID, "posts", postIDs)
+}
+
+// pulls the ids from whichever users hold them
+func (r *userRepository) RemovePosts(ctx context.Context, postIDs ...primitive.ObjectID) error {
+	return r.removeRefs(ctx, "posts", postIDs)
+}
+
+func (r *userRepository) AddComments(ctx context.Context, userID primitive.ObjectID, commentIDs ...primitive.ObjectID) error {
+	return r.addRefs(ctx, userID, "comments", commentIDs)
+}
+
+func (r *userRepository) RemoveComments(ctx context.Context, commentIDs ...primitive.ObjectID) error {
+	return r.removeRefs(ctx, "comments", commentIDs)
+}
+
+func (r *userRepository) addRefs(ctx context.Context, userID primitive.ObjectID, field string, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$addToSet": bson.M{field: bson.M{"$each": ids}}},
+	)
+	if err != nil {
+		return err
 	}
-	return &user, err
-}
\ No newline at end of file
+
+	if result.MatchedCount == 0 {
+		return apperr.NotFound("user")
+	}
+
+	return nil
+}
+
+func (r *userRepository) removeRefs(ctx context.Context, field string, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{field: bson.M{"$in": ids}},
+		bson.M{"$pullAll": bson.M{field: ids}},
+	)
+	return err
+}
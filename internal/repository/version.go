@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// returned by versioned writes when the document exists but has moved on
+var ErrVersionMismatch = apperr.New(apperr.ErrPreconditionFailed, "version_mismatch", "it was changed since you last read it, reload and try again")
+
+// narrows filter to documents at version, documents written before
+// versions existed count as version 0
+// a nil version matches any
+func withVersion(filter bson.M, version *int64) bson.M {
+	switch {
+	case version == nil:
+	case *version == 0:
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	default:
+		filter["version"] = *version
+	}
+	return filter
+}
+
+// every write to a versioned document bumps it
+var bumpVersion = bson.M{"version": 1}
+
+// applies update to the live document id when it is at version, returning
+// the version it moved to
+// a document that exists at another version is ErrVersionMismatch, a
+// missing or trashed one notFound
+func versionedUpdate(ctx context.Context, coll *mongo.Collection, id primitive.ObjectID, version *int64, update bson.M, notFound error) (int64, error) {
+	update["$inc"] = bumpVersion
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"version": 1})
+
+	var doc struct {
+		Version int64 `bson:"version"`
+	}
+	err := coll.FindOneAndUpdate(ctx, withVersion(bson.M{"_id": id, "deletedAt": nil}, version), update, opts).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		if version != nil {
+			n, err := coll.CountDocuments(ctx, bson.M{"_id": id, "deletedAt": nil})
+			if err != nil {
+				return 0, err
+			}
+			if n > 0 {
+				return 0, ErrVersionMismatch
+			}
+		}
+		return 0, notFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return doc.Version, nil
+}
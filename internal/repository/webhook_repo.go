@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookRepository interface {
+	Create(ctx context.Context, hook *models.Webhook) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Webhook, error)
+	// newest first
+	FindAll(ctx context.Context) ([]models.Webhook, error)
+	// active webhooks subscribed to event
+	FindActiveByEvent(ctx context.Context, event string) ([]models.Webhook, error)
+	// sets the fields in update, returns the webhook as updated
+	Update(ctx context.Context, id primitive.ObjectID, update bson.M) (*models.Webhook, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// counts a failed delivery, returns the failures in a row
+	RecordFailure(ctx context.Context, id primitive.ObjectID) (int, error)
+	RecordSuccess(ctx context.Context, id primitive.ObjectID) error
+	// deactivates an active webhook
+	Disable(ctx context.Context, id primitive.ObjectID, reason string) error
+}
+
+type webhookRepository struct {
+	collection *mongo.Collection
+}
+
+func NewWebhookRepository(db *mongo.Database) WebhookRepository {
+	return &webhookRepository{
+		collection: db.Collection("webhooks"),
+	}
+}
+
+func (r *webhookRepository) Create(ctx context.Context, hook *models.Webhook) error {
+	hook.ID = primitive.NewObjectID()
+	hook.CreatedAt = time.Now()
+	hook.UpdatedAt = hook.CreatedAt
+
+	_, err := r.collection.InsertOne(ctx, hook)
+	return err
+}
+
+func (r *webhookRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Webhook, error) {
+	var hook models.Webhook
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&hook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperr.NotFound("webhook")
+		}
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (r *webhookRepository) FindAll(ctx context.Context) ([]models.Webhook, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *webhookRepository) FindActiveByEvent(ctx context.Context, event string) ([]models.Webhook, error) {
+	return r.find(ctx, bson.M{"active": true, "events": event})
+}
+
+func (r *webhookRepository) find(ctx context.Context, filter bson.M) ([]models.Webhook, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var hooks []models.Webhook
+	if err = cursor.All(ctx, &hooks); err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
+
+func (r *webhookRepository) Update(ctx context.Context, id primitive.ObjectID, update bson.M) (*models.Webhook, error) {
+	change := setOrUnset(update)
+	change["$set"].(bson.M)["updatedAt"] = time.Now()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var hook models.Webhook
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, change, opts).Decode(&hook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, apperr.NotFound("webhook")
+		}
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return apperr.NotFound("webhook")
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) RecordFailure(ctx context.Context, id primitive.ObjectID) (int, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var hook models.Webhook
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"failures": 1}}, opts).Decode(&hook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, apperr.NotFound("webhook")
+		}
+		return 0, err
+	}
+	return hook.Failures, nil
+}
+
+func (r *webhookRepository) RecordSuccess(ctx context.Context, id primitive.ObjectID) error {
+	// most deliveries succeed, skip the write when there's nothing to reset
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "failures": bson.M{"$ne": 0}},
+		bson.M{"$set": bson.M{"failures": 0}},
+	)
+	return err
+}
+
+func (r *webhookRepository) Disable(ctx context.Context, id primitive.ObjectID, reason string) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "active": true},
+		bson.M{"$set": bson.M{"active": false, "disabledAt": now, "disabledReason": reason, "updatedAt": now}},
+	)
+	return err
+}
+
+// an update document for fields, nil clears a field rather than storing a null
+func setOrUnset(fields bson.M) bson.M {
+	set := bson.M{}
+	unset := bson.M{}
+	for k, v := range fields {
+		if v == nil {
+			unset[k] = ""
+			continue
+		}
+		set[k] = v
+	}
+	change := bson.M{"$set": set}
+	if len(unset) > 0 {
+		change["$unset"] = unset
+	}
+	return change
+}
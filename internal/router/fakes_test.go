@@ -0,0 +1,1104 @@
+package router
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// an in-memory stand-in for the database, the repositories below answer
+// the way the mongo ones do, not-found errors and nil results included
+// each embeds its interface for the methods no test reaches, calling one
+// panics
+type memDB struct {
+	mu     sync.Mutex
+	nextID uint32
+
+	users      []*models.User
+	posts      []*models.Post
+	comments   []*models.Comment
+	media      []*models.Media
+	imports    []*models.ImportMapping
+	audit      []*models.AuditEntry
+	exports    []*models.DataExport
+	erasures   []*models.ErasureRequest
+	webhooks   []*models.Webhook
+	deliveries []*models.WebhookDelivery
+}
+
+// ids the tests pick, created documents count up from 0x1000 so they
+// never collide and sort after them
+func oid(n uint32) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[8:], n)
+	return id
+}
+
+func (db *memDB) newID() primitive.ObjectID {
+	if db.nextID == 0 {
+		db.nextID = 0x1000
+	}
+	db.nextID++
+	return oid(db.nextID)
+}
+
+// $set of bson field names on doc, nil clears the field the way $unset does
+func setFields(doc interface{}, set bson.M) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		panic(err)
+	}
+	for k, v := range set {
+		if v == nil {
+			delete(fields, k)
+			continue
+		}
+		fields[k] = v
+	}
+	if raw, err = bson.Marshal(fields); err != nil {
+		panic(err)
+	}
+	v := reflect.ValueOf(doc).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	if err := bson.Unmarshal(raw, doc); err != nil {
+		panic(err)
+	}
+}
+
+// runs fn without a transaction, nothing here fails halfway
+type memTransactor struct{}
+
+func (memTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// users
+
+type memUsers struct {
+	repository.UserRepository
+	db *memDB
+}
+
+func (r memUsers) Create(ctx context.Context, user *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	user.ID = r.db.newID()
+	user.CreatedAt = time.Now()
+	user.Posts = []primitive.ObjectID{}
+	user.Comments = []primitive.ObjectID{}
+	u := *user
+	r.db.users = append(r.db.users, &u)
+	return nil
+}
+
+func (r memUsers) find(match func(*models.User) bool) (*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, u := range r.db.users {
+		if match(u) {
+			found := *u
+			return &found, nil
+		}
+	}
+	return nil, apperr.NotFound("user")
+}
+
+func (r memUsers) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.ID == id })
+}
+
+func (r memUsers) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Username == username })
+}
+
+func (r memUsers) FindByGoogleID(ctx context.Context, googleID string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.GoogleID != "" && u.GoogleID == googleID })
+}
+
+func (r memUsers) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for i, u := range r.db.users {
+		if u.ID == id {
+			r.db.users = append(r.db.users[:i:i], r.db.users[i+1:]...)
+			return nil
+		}
+	}
+	return apperr.NotFound("user")
+}
+
+func (r memUsers) AddPosts(ctx context.Context, userID primitive.ObjectID, postIDs ...primitive.ObjectID) error {
+	return r.addRefs(userID, func(u *models.User) *[]primitive.ObjectID { return &u.Posts }, postIDs)
+}
+
+func (r memUsers) AddComments(ctx context.Context, userID primitive.ObjectID, commentIDs ...primitive.ObjectID) error {
+	return r.addRefs(userID, func(u *models.User) *[]primitive.ObjectID { return &u.Comments }, commentIDs)
+}
+
+func (r memUsers) addRefs(userID primitive.ObjectID, field func(*models.User) *[]primitive.ObjectID, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, u := range r.db.users {
+		if u.ID != userID {
+			continue
+		}
+		refs := append([]primitive.ObjectID{}, *field(u)...)
+		for _, id := range ids {
+			if !containsID(refs, id) {
+				refs = append(refs, id)
+			}
+		}
+		*field(u) = refs
+		return nil
+	}
+	return apperr.NotFound("user")
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, have := range ids {
+		if have == id {
+			return true
+		}
+	}
+	return false
+}
+
+// the author as the aggregations project it, without createdAt
+func (db *memDB) authorOf(id primitive.ObjectID) *models.UserResponse {
+	for _, u := range db.users {
+		if u.ID == id {
+			return &models.UserResponse{ID: u.ID.Hex(), Username: u.Username, Fname: u.Fname, Lname: u.Lname, Admin: u.Admin, CanPublish: u.CanPublish}
+		}
+	}
+	return &models.UserResponse{}
+}
+
+// posts
+
+type memPosts struct {
+	repository.PostRepository
+	db *memDB
+}
+
+func (r memPosts) Create(ctx context.Context, post *models.Post) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	post.ID = r.db.newID()
+	if post.Timestamp.IsZero() {
+		post.Timestamp = time.Now()
+	}
+	if post.UpdatedAt.IsZero() {
+		post.UpdatedAt = post.Timestamp
+	}
+	p := *post
+	r.db.posts = append(r.db.posts, &p)
+	return nil
+}
+
+// copies of the posts match keeps, newest first
+func (r memPosts) list(match func(*models.Post) bool) []models.Post {
+	var posts []models.Post
+	for _, p := range r.db.posts {
+		if match(p) {
+			posts = append(posts, *p)
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		if !posts[i].Timestamp.Equal(posts[j].Timestamp) {
+			return posts[i].Timestamp.After(posts[j].Timestamp)
+		}
+		return posts[i].ID.Hex() > posts[j].ID.Hex()
+	})
+	return posts
+}
+
+func (r memPosts) withAuthor(p models.Post) models.PostWithAuthor {
+	out := models.PostWithAuthor{
+		ID:           p.ID.Hex(),
+		Author:       r.db.authorOf(p.Author),
+		Title:        p.Title,
+		Text:         p.Text,
+		Format:       p.Format,
+		HTML:         p.HTML,
+		TOC:          p.TOC,
+		Tags:         p.Tags,
+		ImgURL:       p.ImgURL,
+		SEO:          p.SEO,
+		Published:    p.Published,
+		CommentCount: p.CommentCount,
+		Version:      p.Version,
+		Timestamp:    p.Timestamp,
+		UpdatedAt:    p.UpdatedAt,
+	}
+	if p.MediaID != nil {
+		out.MediaID = p.MediaID.Hex()
+	}
+	return out
+}
+
+func (r memPosts) withAuthors(posts []models.Post) []models.PostWithAuthor {
+	var out []models.PostWithAuthor
+	for _, p := range posts {
+		out = append(out, r.withAuthor(p))
+	}
+	return out
+}
+
+func live(p *models.Post) bool { return p.DeletedAt == nil }
+
+func page(posts []models.Post, skip, limit int64) []models.Post {
+	if skip >= int64(len(posts)) {
+		return nil
+	}
+	posts = posts[skip:]
+	if limit > 0 && limit < int64(len(posts)) {
+		posts = posts[:limit]
+	}
+	return posts
+}
+
+func (r memPosts) FindAllWithAuthor(ctx context.Context) ([]models.PostWithAuthor, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.withAuthors(r.list(live)), nil
+}
+
+func (r memPosts) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, p := range r.db.posts {
+		if p.ID == id && live(p) {
+			found := *p
+			return &found, nil
+		}
+	}
+	return nil, apperr.NotFound("post")
+}
+
+func (r memPosts) FindByIDWithAuthor(ctx context.Context, id primitive.ObjectID) (*models.PostWithAuthor, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	posts := r.list(func(p *models.Post) bool { return p.ID == id && live(p) })
+	if len(posts) == 0 {
+		return nil, apperr.NotFound("post")
+	}
+	found := r.withAuthor(posts[0])
+	return &found, nil
+}
+
+func (r memPosts) FindByAuthor(ctx context.Context, author primitive.ObjectID) ([]models.Post, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.list(func(p *models.Post) bool { return p.Author == author && live(p) }), nil
+}
+
+func publishedMatch(filter repository.PostFilter) func(*models.Post) bool {
+	published := true
+	return queryMatch(repository.PostQuery{PostFilter: filter, Published: &published})
+}
+
+func queryMatch(filter repository.PostQuery) func(*models.Post) bool {
+	return func(p *models.Post) bool {
+		switch {
+		case !live(p):
+			return false
+		case filter.Published != nil && p.Published != *filter.Published:
+			return false
+		case filter.Author != nil && p.Author != *filter.Author:
+			return false
+		case filter.Tag != "" && !containsTag(p.Tags, filter.Tag):
+			return false
+		}
+		return true
+	}
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (r memPosts) FindPublished(ctx context.Context, filter repository.PostFilter) ([]models.PostWithAuthor, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.withAuthors(page(r.list(publishedMatch(filter)), filter.Skip, filter.Limit)), nil
+}
+
+func (r memPosts) CountPublished(ctx context.Context, filter repository.PostFilter) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return int64(len(r.list(publishedMatch(filter)))), nil
+}
+
+func indexable(p *models.Post) bool {
+	return p.Published && live(p) && (p.SEO == nil || !p.SEO.NoIndex)
+}
+
+func (r memPosts) CountIndexable(ctx context.Context) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return int64(len(r.list(indexable))), nil
+}
+
+// oldest id first like the mongo one
+func (r memPosts) FindIndexable(ctx context.Context, skip, limit int64) ([]models.PostRef, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	posts := r.list(indexable)
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID.Hex() < posts[j].ID.Hex() })
+	var refs []models.PostRef
+	for _, p := range page(posts, skip, limit) {
+		refs = append(refs, models.PostRef{ID: p.ID, Timestamp: p.Timestamp, UpdatedAt: p.UpdatedAt})
+	}
+	return refs, nil
+}
+
+// the live post id at version, or the error versionedUpdate gives
+func (r memPosts) at(id primitive.ObjectID, version *int64) (*models.Post, error) {
+	for _, p := range r.db.posts {
+		if p.ID != id || !live(p) {
+			continue
+		}
+		if version != nil && p.Version != *version {
+			return nil, repository.ErrVersionMismatch
+		}
+		return p, nil
+	}
+	return nil, apperr.NotFound("post")
+}
+
+func (r memPosts) Update(ctx context.Context, id primitive.ObjectID, version *int64, update interface{}) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	p, err := r.at(id, version)
+	if err != nil {
+		return 0, err
+	}
+	set := bson.M{}
+	for k, v := range update.(bson.M) {
+		set[k] = v
+	}
+	set["updatedAt"] = time.Now()
+	setFields(p, set)
+	p.Version++
+	return p.Version, nil
+}
+
+func (r memPosts) Trash(ctx context.Context, id, by primitive.ObjectID, version *int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	p, err := r.at(id, version)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	p.DeletedAt, p.DeletedBy = &now, &by
+	p.Version++
+	return nil
+}
+
+func (r memPosts) Restore(ctx context.Context, id primitive.ObjectID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, p := range r.db.posts {
+		if p.ID == id && !live(p) {
+			p.DeletedAt, p.DeletedBy = nil, nil
+			p.Version++
+			return nil
+		}
+	}
+	return apperr.NotFound("post")
+}
+
+func trashMatch(filter repository.TrashFilter, deletedAt *time.Time, author primitive.ObjectID) bool {
+	switch {
+	case deletedAt == nil:
+		return false
+	case !filter.DeletedBefore.IsZero() && !deletedAt.Before(filter.DeletedBefore):
+		return false
+	case filter.Author != nil && author != *filter.Author:
+		return false
+	}
+	return true
+}
+
+func (r memPosts) FindTrash(ctx context.Context, filter repository.TrashFilter) ([]models.Post, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var posts []models.Post
+	for _, p := range r.db.posts {
+		if trashMatch(filter, p.DeletedAt, p.Author) {
+			posts = append(posts, *p)
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].DeletedAt.After(*posts[j].DeletedAt) })
+	return posts, nil
+}
+
+func (r memPosts) FindTrashedByID(ctx context.Context, id primitive.ObjectID) (*models.Post, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, p := range r.db.posts {
+		if p.ID == id && !live(p) {
+			found := *p
+			return &found, nil
+		}
+	}
+	return nil, apperr.NotFound("post")
+}
+
+func (r memPosts) ReassignAuthor(ctx context.Context, from, to primitive.ObjectID) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var n int64
+	for _, p := range r.db.posts {
+		if p.Author == from {
+			p.Author = to
+			p.Version++
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r memPosts) IncCommentCount(ctx context.Context, id primitive.ObjectID, delta int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, p := range r.db.posts {
+		if p.ID == id {
+			p.CommentCount += int64(delta)
+			return nil
+		}
+	}
+	return apperr.NotFound("post")
+}
+
+// comments
+
+type memComments struct {
+	repository.CommentRepository
+	db *memDB
+}
+
+func (r memComments) Create(ctx context.Context, comment *models.Comment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	comment.ID = r.db.newID()
+	if comment.Timestamp.IsZero() {
+		comment.Timestamp = time.Now()
+	}
+	c := *comment
+	r.db.comments = append(r.db.comments, &c)
+	return nil
+}
+
+// copies of the comments match keeps, in the order they were written
+func (r memComments) list(match func(*models.Comment) bool) []models.Comment {
+	var comments []models.Comment
+	for _, c := range r.db.comments {
+		if match(c) {
+			comments = append(comments, *c)
+		}
+	}
+	return comments
+}
+
+func (r memComments) withAuthor(c models.Comment) models.CommentWithAuthor {
+	return models.CommentWithAuthor{
+		ID:        c.ID.Hex(),
+		Post:      c.Post,
+		Author:    r.db.authorOf(c.Author),
+		Text:      c.Text,
+		HTML:      c.HTML,
+		Version:   c.Version,
+		Timestamp: c.Timestamp,
+	}
+}
+
+func (r memComments) FindByPostWithAuthor(ctx context.Context, postID primitive.ObjectID) ([]models.CommentWithAuthor, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var out []models.CommentWithAuthor
+	for _, c := range r.list(func(c *models.Comment) bool { return c.Post == postID && c.DeletedAt == nil }) {
+		out = append(out, r.withAuthor(c))
+	}
+	return out, nil
+}
+
+func (r memComments) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	found := r.list(func(c *models.Comment) bool { return c.ID == id && c.DeletedAt == nil })
+	if len(found) == 0 {
+		return nil, apperr.NotFound("comment")
+	}
+	return &found[0], nil
+}
+
+func (r memComments) FindByIDWithAuthor(ctx context.Context, id primitive.ObjectID) (*models.CommentWithAuthor, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	found := r.list(func(c *models.Comment) bool { return c.ID == id && c.DeletedAt == nil })
+	if len(found) == 0 {
+		return nil, apperr.NotFound("comment")
+	}
+	c := r.withAuthor(found[0])
+	return &c, nil
+}
+
+func (r memComments) at(id primitive.ObjectID, version *int64) (*models.Comment, error) {
+	for _, c := range r.db.comments {
+		if c.ID != id || c.DeletedAt != nil {
+			continue
+		}
+		if version != nil && c.Version != *version {
+			return nil, repository.ErrVersionMismatch
+		}
+		return c, nil
+	}
+	return nil, apperr.NotFound("comment")
+}
+
+func (r memComments) Update(ctx context.Context, id primitive.ObjectID, version *int64, text, html string) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	c, err := r.at(id, version)
+	if err != nil {
+		return 0, err
+	}
+	c.Text, c.HTML = text, html
+	c.Version++
+	return c.Version, nil
+}
+
+func (r memComments) Trash(ctx context.Context, id, by primitive.ObjectID, version *int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	c, err := r.at(id, version)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	c.DeletedAt, c.DeletedBy = &now, &by
+	c.Version++
+	return nil
+}
+
+func (r memComments) Restore(ctx context.Context, id primitive.ObjectID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, c := range r.db.comments {
+		if c.ID == id && c.DeletedAt != nil {
+			c.DeletedAt, c.DeletedBy = nil, nil
+			c.Version++
+			return nil
+		}
+	}
+	return apperr.NotFound("comment")
+}
+
+func (r memComments) FindTrash(ctx context.Context, filter repository.TrashFilter) ([]models.Comment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	comments := r.list(func(c *models.Comment) bool {
+		return trashMatch(filter, c.DeletedAt, c.Author) && (filter.Post == nil || c.Post == *filter.Post)
+	})
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].DeletedAt.After(*comments[j].DeletedAt) })
+	return comments, nil
+}
+
+func (r memComments) FindTrashedByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	found := r.list(func(c *models.Comment) bool { return c.ID == id && c.DeletedAt != nil })
+	if len(found) == 0 {
+		return nil, apperr.NotFound("comment")
+	}
+	return &found[0], nil
+}
+
+func (r memComments) FindByAuthor(ctx context.Context, author primitive.ObjectID) ([]models.Comment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return r.list(func(c *models.Comment) bool { return c.Author == author && c.DeletedAt == nil }), nil
+}
+
+func (r memComments) ReassignAuthor(ctx context.Context, from, to primitive.ObjectID) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var n int64
+	for _, c := range r.db.comments {
+		if c.Author == from {
+			c.Author = to
+			c.Version++
+			n++
+		}
+	}
+	return n, nil
+}
+
+// media
+
+type memMedia struct {
+	repository.MediaRepository
+	db *memDB
+}
+
+func (r memMedia) Create(ctx context.Context, media *models.Media) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if media.ID.IsZero() {
+		media.ID = r.db.newID()
+	}
+	if media.Timestamp.IsZero() {
+		media.Timestamp = time.Now()
+	}
+	m := *media
+	r.db.media = append(r.db.media, &m)
+	return nil
+}
+
+func (r memMedia) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Media, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, m := range r.db.media {
+		if m.ID == id {
+			found := *m
+			return &found, nil
+		}
+	}
+	return nil, apperr.NotFound("media")
+}
+
+func (r memMedia) FindByOwner(ctx context.Context, owner primitive.ObjectID) ([]models.Media, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var media []models.Media
+	for _, m := range r.db.media {
+		if m.Owner == owner {
+			media = append(media, *m)
+		}
+	}
+	sort.SliceStable(media, func(i, j int) bool { return media[i].Timestamp.After(media[j].Timestamp) })
+	return media, nil
+}
+
+func (r memMedia) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for i, m := range r.db.media {
+		if m.ID == id {
+			r.db.media = append(r.db.media[:i:i], r.db.media[i+1:]...)
+			return nil
+		}
+	}
+	return apperr.NotFound("media")
+}
+
+func (r memMedia) ReassignOwner(ctx context.Context, from, to primitive.ObjectID) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var n int64
+	for _, m := range r.db.media {
+		if m.Owner == from {
+			m.Owner = to
+			n++
+		}
+	}
+	return n, nil
+}
+
+// imports
+
+type memImports struct {
+	repository.ImportRepository
+	db *memDB
+}
+
+func (r memImports) Create(ctx context.Context, mapping *models.ImportMapping) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	if mapping.ImportedAt.IsZero() {
+		mapping.ImportedAt = time.Now()
+	}
+	mapping.ID = r.db.newID()
+	m := *mapping
+	r.db.imports = append(r.db.imports, &m)
+	return nil
+}
+
+func (r memImports) find(match func(*models.ImportMapping) bool) (*models.ImportMapping, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, m := range r.db.imports {
+		if match(m) {
+			found := *m
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r memImports) Find(ctx context.Context, source, kind, key string) (*models.ImportMapping, error) {
+	return r.find(func(m *models.ImportMapping) bool { return m.Source == source && m.Kind == kind && m.Key == key })
+}
+
+func (r memImports) FindByOldPath(ctx context.Context, path string) (*models.ImportMapping, error) {
+	return r.find(func(m *models.ImportMapping) bool { return containsTag(m.OldPaths, path) })
+}
+
+// audit log
+
+type memAudit struct {
+	repository.AuditRepository
+	db *memDB
+}
+
+func (r memAudit) Create(ctx context.Context, entry *models.AuditEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	entry.ID = r.db.newID()
+	entry.Timestamp = time.Now()
+	e := *entry
+	r.db.audit = append(r.db.audit, &e)
+	return nil
+}
+
+func (r memAudit) FindByActor(ctx context.Context, actor primitive.ObjectID) ([]models.AuditEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var entries []models.AuditEntry
+	for _, e := range r.db.audit {
+		if e.Actor == actor {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+
+// data exports
+
+type memExports struct {
+	repository.ExportRepository
+	db *memDB
+}
+
+func (r memExports) Create(ctx context.Context, export *models.DataExport) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	export.ID = r.db.newID()
+	export.CreatedAt = time.Now()
+	e := *export
+	r.db.exports = append(r.db.exports, &e)
+	return nil
+}
+
+func (r memExports) FindByID(ctx context.Context, id primitive.ObjectID) (*models.DataExport, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, e := range r.db.exports {
+		if e.ID == id {
+			found := *e
+			return &found, nil
+		}
+	}
+	return nil, apperr.NotFound("export")
+}
+
+func (r memExports) FindLatestByUser(ctx context.Context, user primitive.ObjectID) (*models.DataExport, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var latest *models.DataExport
+	for _, e := range r.db.exports {
+		if e.User == user && (latest == nil || !e.CreatedAt.Before(latest.CreatedAt)) {
+			found := *e
+			latest = &found
+		}
+	}
+	return latest, nil
+}
+
+func (r memExports) find(match func(*models.DataExport) bool) []models.DataExport {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var exports []models.DataExport
+	for _, e := range r.db.exports {
+		if match(e) {
+			exports = append(exports, *e)
+		}
+	}
+	return exports
+}
+
+func (r memExports) FindByUser(ctx context.Context, user primitive.ObjectID) ([]models.DataExport, error) {
+	return r.find(func(e *models.DataExport) bool { return e.User == user }), nil
+}
+
+func (r memExports) Update(ctx context.Context, id primitive.ObjectID, update interface{}) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, e := range r.db.exports {
+		if e.ID == id {
+			setFields(e, update.(bson.M))
+			return nil
+		}
+	}
+	return apperr.NotFound("export")
+}
+
+// erasure requests
+
+type memErasures struct {
+	repository.ErasureRepository
+	db *memDB
+}
+
+func (r memErasures) Create(ctx context.Context, req *models.ErasureRequest) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	req.ID = r.db.newID()
+	req.Status = models.ErasurePending
+	req.RequestedAt = time.Now()
+	e := *req
+	r.db.erasures = append(r.db.erasures, &e)
+	return nil
+}
+
+func (r memErasures) FindByID(ctx context.Context, id primitive.ObjectID) (*models.ErasureRequest, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, e := range r.db.erasures {
+		if e.ID == id {
+			found := *e
+			return &found, nil
+		}
+	}
+	return nil, apperr.NotFound("erasure request")
+}
+
+func (r memErasures) FindPendingByUser(ctx context.Context, user primitive.ObjectID) (*models.ErasureRequest, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, e := range r.db.erasures {
+		if e.User == user && e.Status == models.ErasurePending {
+			found := *e
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r memErasures) FindByStatus(ctx context.Context, status string) ([]models.ErasureRequest, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var reqs []models.ErasureRequest
+	for _, e := range r.db.erasures {
+		if e.Status == status {
+			reqs = append(reqs, *e)
+		}
+	}
+	sort.SliceStable(reqs, func(i, j int) bool { return reqs[i].RequestedAt.Before(reqs[j].RequestedAt) })
+	return reqs, nil
+}
+
+func (r memErasures) Resolve(ctx context.Context, id primitive.ObjectID, status string, by *primitive.ObjectID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, e := range r.db.erasures {
+		if e.ID == id && e.Status == models.ErasurePending {
+			now := time.Now()
+			e.Status, e.ResolvedAt = status, &now
+			if by != nil {
+				resolvedBy := *by
+				e.ResolvedBy = &resolvedBy
+			}
+			return nil
+		}
+	}
+	return repository.ErrErasureNotPending
+}
+
+// webhooks
+
+type memWebhooks struct {
+	repository.WebhookRepository
+	db *memDB
+}
+
+func (r memWebhooks) Create(ctx context.Context, hook *models.Webhook) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	hook.ID = r.db.newID()
+	hook.CreatedAt = time.Now()
+	hook.UpdatedAt = hook.CreatedAt
+	h := *hook
+	r.db.webhooks = append(r.db.webhooks, &h)
+	return nil
+}
+
+func (r memWebhooks) get(id primitive.ObjectID) *models.Webhook {
+	for _, h := range r.db.webhooks {
+		if h.ID == id {
+			return h
+		}
+	}
+	return nil
+}
+
+func (r memWebhooks) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Webhook, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	h := r.get(id)
+	if h == nil {
+		return nil, apperr.NotFound("webhook")
+	}
+	found := *h
+	return &found, nil
+}
+
+func (r memWebhooks) find(match func(*models.Webhook) bool) []models.Webhook {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var hooks []models.Webhook
+	for _, h := range r.db.webhooks {
+		if match(h) {
+			hooks = append(hooks, *h)
+		}
+	}
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].CreatedAt.After(hooks[j].CreatedAt) })
+	return hooks
+}
+
+func (r memWebhooks) FindAll(ctx context.Context) ([]models.Webhook, error) {
+	return r.find(func(*models.Webhook) bool { return true }), nil
+}
+
+func (r memWebhooks) FindActiveByEvent(ctx context.Context, event string) ([]models.Webhook, error) {
+	return r.find(func(h *models.Webhook) bool { return h.Active && containsTag(h.Events, event) }), nil
+}
+
+func (r memWebhooks) Update(ctx context.Context, id primitive.ObjectID, update bson.M) (*models.Webhook, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	h := r.get(id)
+	if h == nil {
+		return nil, apperr.NotFound("webhook")
+	}
+	set := bson.M{"updatedAt": time.Now()}
+	for k, v := range update {
+		set[k] = v
+	}
+	setFields(h, set)
+	found := *h
+	return &found, nil
+}
+
+func (r memWebhooks) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for i, h := range r.db.webhooks {
+		if h.ID == id {
+			r.db.webhooks = append(r.db.webhooks[:i:i], r.db.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return apperr.NotFound("webhook")
+}
+
+// webhook deliveries
+
+type memDeliveries struct {
+	repository.DeliveryRepository
+	db *memDB
+}
+
+func (r memDeliveries) Create(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	delivery.ID = r.db.newID()
+	delivery.Status = models.DeliveryPending
+	delivery.CreatedAt = time.Now()
+	delivery.NextAttemptAt = &delivery.CreatedAt
+	d := *delivery
+	r.db.deliveries = append(r.db.deliveries, &d)
+	return nil
+}
+
+func (r memDeliveries) FindByID(ctx context.Context, id primitive.ObjectID) (*models.WebhookDelivery, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	for _, d := range r.db.deliveries {
+		if d.ID == id {
+			found := *d
+			return &found, nil
+		}
+	}
+	return nil, apperr.NotFound("delivery")
+}
+
+func (r memDeliveries) FindByWebhook(ctx context.Context, webhook primitive.ObjectID, limit int64) ([]models.WebhookDelivery, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	var deliveries []models.WebhookDelivery
+	for _, d := range r.db.deliveries {
+		if d.Webhook == webhook {
+			deliveries = append(deliveries, *d)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].ID.Hex() > deliveries[j].ID.Hex() })
+	if limit > 0 && int64(len(deliveries)) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r memDeliveries) deleteWhere(match func(*models.WebhookDelivery) bool) int64 {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	kept := r.db.deliveries[:0:0]
+	for _, d := range r.db.deliveries {
+		if !match(d) {
+			kept = append(kept, d)
+		}
+	}
+	n := int64(len(r.db.deliveries) - len(kept))
+	r.db.deliveries = kept
+	return n
+}
+
+func (r memDeliveries) DeleteByWebhook(ctx context.Context, webhook primitive.ObjectID) (int64, error) {
+	return r.deleteWhere(func(d *models.WebhookDelivery) bool { return d.Webhook == webhook }), nil
+}
+
+var (
+	_ repository.UserRepository     = memUsers{}
+	_ repository.PostRepository     = memPosts{}
+	_ repository.CommentRepository  = memComments{}
+	_ repository.MediaRepository    = memMedia{}
+	_ repository.ImportRepository   = memImports{}
+	_ repository.AuditRepository    = memAudit{}
+	_ repository.ExportRepository   = memExports{}
+	_ repository.ErasureRepository  = memErasures{}
+	_ repository.WebhookRepository  = memWebhooks{}
+	_ repository.DeliveryRepository = memDeliveries{}
+)
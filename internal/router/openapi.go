@@ -0,0 +1,392 @@
+package router
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kurtgray/blog-api-go/internal/apiversion"
+	"github.com/kurtgray/blog-api-go/internal/handlers"
+	"github.com/kurtgray/blog-api-go/internal/health"
+	"github.com/kurtgray/blog-api-go/internal/importer"
+	"github.com/kurtgray/blog-api-go/internal/integrity"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/openapi"
+	"github.com/kurtgray/blog-api-go/internal/patch"
+	"github.com/kurtgray/blog-api-go/internal/privacy"
+	"github.com/kurtgray/blog-api-go/internal/views"
+	"github.com/kurtgray/blog-api-go/internal/webhooks"
+)
+
+var specInfo = openapi.Info{
+	Title:   "Blog API",
+	Version: "2.0.0",
+	Description: "Posts, comments and users for the blog, plus its feeds and reader pages. Errors are application/problem+json, see the Problem schema. " +
+		"The API is under /api/v1 and /api/v2, v2 has users, posts and comments with every body in a {\"data\": ...} envelope. " +
+		"The unversioned /api is v1 unless Accept asks for " + apiversion.MediaTypeV2 + ".",
+}
+
+var specTags = []openapi.Tag{
+	{Name: "users", Description: "Registration, login and the signed in user"},
+	{Name: "posts"},
+	{Name: "comments"},
+	{Name: "trash", Description: "Deleted posts and comments, restorable until purged"},
+	{Name: "media", Description: "Image uploads"},
+	{Name: "privacy", Description: "Personal data export and account erasure"},
+	{Name: "admin"},
+	{Name: "webhooks", Description: "Admin-managed subscriptions to " + strings.Join(models.WebhookEvents, ", ") + ". " +
+		"Each delivery POSTs {\"id\", \"type\", \"createdAt\", \"data\"}, data being what v2 shows of the subject, " +
+		"with " + webhooks.HeaderSignature + " set to sha256= and the hex HMAC-SHA256, keyed with the webhook's secret, of " + webhooks.HeaderTimestamp + ", a dot and the body. " +
+		"Anything but a 2xx is retried with exponential backoff, and a webhook whose deliveries keep failing is disabled."},
+	{Name: "graphql", Description: "Users, posts and comments over GraphQL, the schema is introspectable"},
+	{Name: "feeds", Description: "RSS, Atom and JSON Feed"},
+	{Name: "crawlers"},
+	{Name: "pages", Description: "Server-rendered reader pages"},
+	{Name: "meta", Description: "Probes and this document"},
+}
+
+// the users/login and users/me summaries, written from maps by UserHandler
+var (
+	createdUser = openapi.Object{
+		"id":         models.User{}.ID,
+		"fname":      "",
+		"username":   "",
+		"canPublish": false,
+		"admin":      false,
+	}
+	currentUser = openapi.Object{
+		"id":         models.User{}.ID,
+		"fname":      "",
+		"username":   "",
+		"canPublish": false,
+		"admin":      false,
+		"posts":      models.User{}.Posts,
+	}
+)
+
+// what a write that only reports success returns
+func done(extra openapi.Object) openapi.Object {
+	body := openapi.Object{"success": true, "message": ""}
+	for k, v := range extra {
+		body[k] = v
+	}
+	return body
+}
+
+const (
+	mGet    = http.MethodGet
+	mPost   = http.MethodPost
+	mPut    = http.MethodPut
+	mPatch  = http.MethodPatch
+	mDelete = http.MethodDelete
+)
+
+// every route Setup registers, a route without a description or the other
+// way round fails TestSpecMatchesRoutes
+func (rt *Router) spec() []openapi.Route {
+	routes := []openapi.Route{
+		// probes and docs
+		{Method: mGet, Path: "/healthz", Tag: "meta", Summary: "Liveness", Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "Alive", health.Report{}),
+			openapi.JSON(http.StatusServiceUnavailable, "A liveness check failed", health.Report{}),
+		}},
+		{Method: mGet, Path: "/readyz", Tag: "meta", Summary: "Readiness", Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "Ready for traffic", health.Report{}),
+			openapi.JSON(http.StatusServiceUnavailable, "A dependency is down or the server is shutting down", health.Report{}),
+		}},
+		{Method: mGet, Path: "/openapi.json", Tag: "meta", Summary: "This document", Replies: []openapi.Reply{
+			openapi.Content(http.StatusOK, "OpenAPI 3.1 document", "application/json"),
+		}},
+		{Method: mGet, Path: "/docs", Tag: "meta", Summary: "Interactive API docs", Replies: []openapi.Reply{
+			openapi.Content(http.StatusOK, "HTML page", "text/html"),
+		}},
+	}
+	routes = append(routes, versioned(apiSpec(), !rt.options.V1Deprecation.IsZero())...)
+	routes = append(routes, publicSpec()...)
+	if rt.handlers.GraphQL != nil {
+		routes = append(routes, graphqlSpec()...)
+	}
+	if rt.handlers.MediaFiles != nil {
+		routes = append(routes, openapi.Route{Method: mGet, Path: "/media/*", Tag: "media", Summary: "Uploaded files on local storage", Replies: []openapi.Reply{
+			openapi.Content(http.StatusOK, "The file", "image/jpeg", "image/png", "image/gif"),
+		}})
+	}
+	if rt.handlers.Web != nil {
+		routes = append(routes, rt.handlers.Web.Spec()...)
+	} else {
+		routes = append(routes, openapi.Route{Method: mGet, Path: "/", Summary: "Redirects to the posts", Replies: []openapi.Reply{
+			openapi.Empty(http.StatusMovedPermanently, "To /api/posts"),
+		}})
+	}
+	return routes
+}
+
+// errors in the query are GraphQL errors in a 200, only requests that
+// can't be read are problems
+func graphqlSpec() []openapi.Route {
+	const description = "A bearer token is optional, mutations and User.posts need one. " +
+		"Errors carry the code and status the REST routes would have answered with in their extensions."
+	replies := []openapi.Reply{openapi.JSON(http.StatusOK, "Data and errors", handlers.GraphQLResponse{})}
+	return []openapi.Route{
+		{Method: mGet, Path: "/graphql", Tag: "graphql", Summary: "Run a query, mutations need POST", Description: description,
+			Query: handlers.GraphQLQuery{}, Replies: replies},
+		{Method: mPost, Path: "/graphql", Tag: "graphql", Summary: "Run a query or mutation", Description: description,
+			Request: openapi.JSONBody(handlers.GraphQLRequest{}), Replies: replies},
+	}
+}
+
+// what mountPublic registers besides the web pages
+func publicSpec() []openapi.Route {
+	format := []openapi.Parameter{
+		{Name: "format", In: "path", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"rss", "atom", "json"}}},
+		{Name: "content", In: "query", Description: "excerpt leaves out the full post bodies", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"excerpt"}}},
+	}
+	feed := []openapi.Reply{openapi.Content(http.StatusOK, "Feed", "application/rss+xml", "application/atom+xml", "application/feed+json")}
+	sitemap := []openapi.Reply{openapi.Content(http.StatusOK, "Sitemap or sitemap index", "application/xml")}
+
+	return []openapi.Route{
+		{Method: mGet, Path: "/feed.{format}", Tag: "feeds", Summary: "Latest published posts", Params: format, Replies: feed},
+		{Method: mGet, Path: "/authors/{userId}/feed.{format}", Tag: "feeds", Summary: "An author's latest posts", Params: format, Replies: feed},
+		{Method: mGet, Path: "/tags/{tag}/feed.{format}", Tag: "feeds", Summary: "Latest posts with a tag", Params: format, Replies: feed},
+		{Method: mGet, Path: "/robots.txt", Tag: "crawlers", Summary: "Crawler rules", Replies: []openapi.Reply{openapi.Content(http.StatusOK, "robots.txt", "text/plain")}},
+		{Method: mGet, Path: "/sitemap.xml", Tag: "crawlers", Summary: "Sitemap, an index once there are too many URLs for one file", Replies: sitemap},
+		{Method: mGet, Path: "/sitemap-{page}.xml", Tag: "crawlers", Summary: "A page of the sitemap index",
+			Params: []openapi.Parameter{{Name: "page", In: "path", Schema: &openapi.Schema{Type: "integer"}}}, Replies: sitemap},
+	}
+}
+
+// a route of the JSON API, Path is below the version prefix and Replies
+// are v1's, V2 is nil for routes only v1 has
+type apiRoute struct {
+	openapi.Route
+	V2 []openapi.Reply
+}
+
+// a v2 body, always in the {"data": ...} envelope
+func data(status int, description string, body interface{}) openapi.Reply {
+	return openapi.Reply{Status: status, Description: description, Bodies: map[string]interface{}{
+		apiversion.MediaTypeV2: openapi.Object{"data": body},
+	}}
+}
+
+// each route under /api/v1, /api, where both versions' bodies are listed by
+// media type, and /api/v2 when it's there
+// with deprecated, v1 routes v2 replaces are marked deprecated
+// all of v1 comes first so its types keep the plain component names
+func versioned(routes []apiRoute, deprecated bool) []openapi.Route {
+	var v1, negotiated, v2 []openapi.Route
+	for _, ar := range routes {
+		rt := ar.Route
+		rt.Path = "/api/v1" + ar.Path
+		rt.Deprecated = deprecated && ar.V2 != nil
+		v1 = append(v1, rt)
+
+		rt = ar.Route
+		rt.Path = "/api" + ar.Path
+		if ar.V2 != nil {
+			rt.Description = strings.TrimSpace(rt.Description + " v1, or v2 with Accept: " + apiversion.MediaTypeV2 + ".")
+			rt.Replies = mergeReplies(ar.Replies, ar.V2)
+
+			next := ar.Route
+			next.Path = "/api/v2" + ar.Path
+			next.Replies = ar.V2
+			v2 = append(v2, next)
+		}
+		negotiated = append(negotiated, rt)
+	}
+	return slices.Concat(v1, negotiated, v2)
+}
+
+// both versions' replies, bodies of the same status side by side
+func mergeReplies(v1, v2 []openapi.Reply) []openapi.Reply {
+	out := append([]openapi.Reply{}, v1...)
+	for _, reply := range v2 {
+		i := slices.IndexFunc(out, func(r openapi.Reply) bool { return r.Status == reply.Status })
+		if i < 0 {
+			out = append(out, reply)
+			continue
+		}
+		bodies := maps.Clone(out[i].Bodies)
+		maps.Copy(bodies, reply.Bodies)
+		out[i].Bodies = bodies
+	}
+	return out
+}
+
+// the routes apiRoutes and contentRoutes register
+func apiSpec() []apiRoute {
+	ok := func(description string, body interface{}) []openapi.Reply {
+		return []openapi.Reply{openapi.JSON(http.StatusOK, description, body)}
+	}
+	postPatch := map[string]interface{}{
+		patch.MergePatchType: openapi.Partial{Of: handlers.PostDocument{}},
+		patch.JSONPatchType:  patch.Patch{},
+		"application/json":   openapi.Partial{Of: handlers.PostDocument{}},
+	}
+	commentPatch := map[string]interface{}{
+		patch.MergePatchType: openapi.Partial{Of: handlers.CommentDocument{}},
+		patch.JSONPatchType:  patch.Patch{},
+		"application/json":   openapi.Partial{Of: handlers.CommentDocument{}},
+	}
+	trashed := openapi.Empty(http.StatusNoContent, "Trashed")
+
+	return []apiRoute{
+		// users
+		{Route: openapi.Route{Method: mPost, Path: "/users", Tag: "users", Summary: "Register",
+			Request: openapi.JSONBody(handlers.RegisterRequest{}),
+			Replies: []openapi.Reply{openapi.JSON(http.StatusCreated, "Registered", done(openapi.Object{"user": createdUser}))}},
+			V2: []openapi.Reply{data(http.StatusCreated, "Registered", views.User{})}},
+		{Route: openapi.Route{Method: mPost, Path: "/users/login", Tag: "users", Summary: "Log in",
+			Description: "With a username and password, or a googleId and profile, which registers the user on first login.",
+			Request:     openapi.JSONBody(handlers.LoginRequest{}),
+			Replies:     ok("Logged in", done(openapi.Object{"token": "", "user": currentUser}))},
+			V2: []openapi.Reply{data(http.StatusOK, "Logged in", views.Session{})}},
+		{Route: openapi.Route{Method: mGet, Path: "/users", Tag: "users", Summary: "The signed in user", Auth: openapi.User,
+			Description: "Also how clients check a stored token is still good.",
+			Replies:     ok("The user", openapi.Object{"success": true, "user": currentUser})},
+			V2: []openapi.Reply{data(http.StatusOK, "The user", views.User{})}},
+		{Route: openapi.Route{Method: mGet, Path: "/users/{userId}/posts", Tag: "posts", Summary: "A user's posts", Auth: openapi.User,
+			Replies: ok("Posts split by state", openapi.Object{"success": true, "posts": openapi.Object{
+				"published":   []models.Post{},
+				"unpublished": []models.Post{},
+			}})},
+			V2: []openapi.Reply{data(http.StatusOK, "Posts, published or not", []views.Post{})}},
+
+		// posts
+		{Route: openapi.Route{Method: mGet, Path: "/highlight.css", Tag: "posts", Summary: "Styles for highlighted code in rendered posts", Replies: []openapi.Reply{
+			openapi.Content(http.StatusOK, "Stylesheet", "text/css"),
+		}}},
+		{Route: openapi.Route{Method: mGet, Path: "/posts", Tag: "posts", Summary: "All posts",
+			Replies: ok("Posts with their authors", openapi.Object{"posts": []models.PostWithAuthor{}})},
+			V2: []openapi.Reply{data(http.StatusOK, "Posts with their authors", []views.Post{})}},
+		{Route: openapi.Route{Method: mGet, Path: "/posts/{postId}", Tag: "posts", Summary: "A post", Conditional: true,
+			Replies: ok("The post with its author and page metadata", openapi.Object{"post": models.PostWithAuthor{}})},
+			V2: []openapi.Reply{data(http.StatusOK, "The post with its author and page metadata", views.Post{})}},
+		{Route: openapi.Route{Method: mPost, Path: "/posts", Tag: "posts", Summary: "Write a post", Auth: openapi.User,
+			Request: openapi.JSONBody(handlers.PostDocument{}),
+			Replies: []openapi.Reply{openapi.JSON(http.StatusCreated, "Created", openapi.Object{"success": true, "post": models.Post{}})}},
+			V2: []openapi.Reply{data(http.StatusCreated, "Created", views.Post{})}},
+		{Route: openapi.Route{Method: mPut, Path: "/posts/{postId}", Tag: "posts", Summary: "Replace a post", Auth: openapi.User, Conditional: true,
+			Description: "Every editable field is replaced, omitted ones are cleared. Only its author or an admin can.",
+			Request:     openapi.JSONBody(handlers.PostDocument{}),
+			Replies:     ok("Updated", done(openapi.Object{"version": int64(0)}))},
+			V2: []openapi.Reply{data(http.StatusOK, "Updated", views.Post{})}},
+		{Route: openapi.Route{Method: mPatch, Path: "/posts/{postId}", Tag: "posts", Summary: "Change some of a post", Auth: openapi.User, Conditional: true,
+			Description: "A merge patch or a JSON patch over the fields of PUT, plain JSON is read as a merge patch. Only its author or an admin can.",
+			Request:     postPatch,
+			Replies:     ok("Updated", openapi.Object{"success": true, "updatedPost": models.PostWithAuthor{}})},
+			V2: []openapi.Reply{data(http.StatusOK, "Updated", views.Post{})}},
+		{Route: openapi.Route{Method: mDelete, Path: "/posts/{postId}", Tag: "posts", Summary: "Move a post to the trash", Auth: openapi.User, Conditional: true,
+			Description: "Its comments go with it and come back when it's restored. Only its author or an admin can.",
+			Replies:     ok("Trashed", done(openapi.Object{"id": models.Post{}.ID}))},
+			V2: []openapi.Reply{trashed}},
+
+		// comments
+		{Route: openapi.Route{Method: mGet, Path: "/posts/{postId}/comments", Tag: "comments", Summary: "A post's comments",
+			Replies: ok("Comments with their authors", openapi.Object{"success": true, "comments": []models.CommentWithAuthor{}})},
+			V2: []openapi.Reply{data(http.StatusOK, "Comments with their authors", []views.Comment{})}},
+		{Route: openapi.Route{Method: mGet, Path: "/posts/{postId}/comments/{commentId}", Tag: "comments", Summary: "A comment", Conditional: true,
+			Replies: ok("The comment", openapi.Object{"success": true, "comment": models.CommentWithAuthor{}})},
+			V2: []openapi.Reply{data(http.StatusOK, "The comment", views.Comment{})}},
+		{Route: openapi.Route{Method: mPost, Path: "/posts/{postId}/comments", Tag: "comments", Summary: "Comment on a published post", Auth: openapi.User,
+			Request: openapi.JSONBody(handlers.CommentDocument{}),
+			Replies: ok("Created", openapi.Object{"success": true, "comment": models.Comment{}})},
+			V2: []openapi.Reply{data(http.StatusCreated, "Created", views.Comment{})}},
+		{Route: openapi.Route{Method: mPatch, Path: "/posts/{postId}/comments/{commentId}", Tag: "comments", Summary: "Edit a comment", Auth: openapi.User, Conditional: true,
+			Description: "Only its author or an admin can.",
+			Request:     commentPatch,
+			Replies:     ok("Updated", openapi.Object{"success": true, "updatedComment": models.CommentWithAuthor{}})},
+			V2: []openapi.Reply{data(http.StatusOK, "Updated", views.Comment{})}},
+		{Route: openapi.Route{Method: mDelete, Path: "/posts/{postId}/comments/{commentId}", Tag: "comments", Summary: "Move a comment to the trash", Auth: openapi.User, Conditional: true,
+			Description: "Only its author or an admin can.",
+			Replies:     ok("Trashed", done(openapi.Object{"id": models.Comment{}.ID}))},
+			V2: []openapi.Reply{trashed}},
+
+		// trash
+		{Route: openapi.Route{Method: mGet, Path: "/trash", Tag: "trash", Summary: "What's in the trash", Auth: openapi.User,
+			Description: "Admins see everything, everyone else what they wrote.",
+			Replies:     ok("Trashed posts and comments", openapi.Object{"success": true, "posts": []handlers.TrashedPost{}, "comments": []handlers.TrashedComment{}})}},
+		{Route: openapi.Route{Method: mPost, Path: "/trash/posts/{postId}/restore", Tag: "trash", Summary: "Restore a post and its comments", Auth: openapi.User,
+			Replies: ok("Restored", done(openapi.Object{"id": models.Post{}.ID}))}},
+		{Route: openapi.Route{Method: mPost, Path: "/trash/comments/{commentId}/restore", Tag: "trash", Summary: "Restore a comment", Auth: openapi.User,
+			Replies: ok("Restored", done(openapi.Object{"id": models.Comment{}.ID}))}},
+
+		// media
+		{Route: openapi.Route{Method: mPost, Path: "/media", Tag: "media", Summary: "Upload an image", Auth: openapi.User,
+			Request: map[string]interface{}{"multipart/form-data": &openapi.Schema{
+				Type:       "object",
+				Properties: map[string]*openapi.Schema{"file": {Type: "string", Format: "binary", Description: "a JPEG, PNG or GIF"}},
+				Required:   []string{"file"},
+			}},
+			Replies: []openapi.Reply{openapi.JSON(http.StatusCreated, "Stored, with resized variants", openapi.Object{"success": true, "media": models.Media{}})}}},
+		{Route: openapi.Route{Method: mGet, Path: "/media", Tag: "media", Summary: "The signed in user's uploads", Auth: openapi.User,
+			Replies: ok("Uploads", openapi.Object{"success": true, "media": []models.Media{}})}},
+		{Route: openapi.Route{Method: mGet, Path: "/media/{mediaId}", Tag: "media", Summary: "An upload", Auth: openapi.User,
+			Replies: ok("The upload", openapi.Object{"success": true, "media": models.Media{}})}},
+		{Route: openapi.Route{Method: mDelete, Path: "/media/{mediaId}", Tag: "media", Summary: "Delete an upload", Auth: openapi.User,
+			Replies: ok("Deleted", done(openapi.Object{"id": models.Media{}.ID}))}},
+
+		// privacy
+		{Route: openapi.Route{Method: mGet, Path: "/users/me/export", Tag: "privacy", Summary: "Export everything stored about the signed in user", Auth: openapi.User,
+			Description: "Starts an export, poll until it answers 200 with a downloadUrl.",
+			Replies: []openapi.Reply{
+				openapi.JSON(http.StatusOK, "Ready", openapi.Object{"success": true, "export": models.DataExport{}, "downloadUrl": ""}),
+				openapi.JSON(http.StatusAccepted, "Still being built", openapi.Object{"success": true, "export": models.DataExport{}}),
+			}}},
+		{Route: openapi.Route{Method: mGet, Path: "/users/me/export/{exportId}", Tag: "privacy", Summary: "Download an export", Auth: openapi.User, Replies: []openapi.Reply{
+			openapi.Content(http.StatusOK, "Zip archive", "application/zip"),
+		}}},
+		{Route: openapi.Route{Method: mPost, Path: "/users/me/erasure", Tag: "privacy", Summary: "Ask for the signed in user's account to be erased", Auth: openapi.User,
+			Description: "An admin reviews the request. The body is optional.",
+			Request:     openapi.JSONBody(handlers.ErasureBody{}),
+			Replies:     []openapi.Reply{openapi.JSON(http.StatusAccepted, "Requested", done(openapi.Object{"request": models.ErasureRequest{}}))}}},
+		{Route: openapi.Route{Method: mDelete, Path: "/users/me/erasure", Tag: "privacy", Summary: "Cancel a pending erasure request", Auth: openapi.User,
+			Replies: ok("Cancelled", done(nil))}},
+
+		// admin
+		{Route: openapi.Route{Method: mPost, Path: "/admin/import", Tag: "admin", Summary: "Import from WordPress, Ghost or Markdown", Auth: openapi.Admin,
+			Query: handlers.ImportQuery{},
+			Request: map[string]interface{}{
+				"application/xml":  &openapi.Schema{Description: "WordPress WXR export"},
+				"application/json": &openapi.Schema{Description: "Ghost export"},
+				"application/zip":  &openapi.Schema{Description: "directory of Markdown files with front matter"},
+			},
+			Replies: ok("What was imported", openapi.Object{"success": true, "report": importer.Report{}})}},
+		{Route: openapi.Route{Method: mGet, Path: "/admin/backup", Tag: "admin", Summary: "Download a backup archive", Auth: openapi.Admin,
+			Params:  []openapi.Parameter{{Name: "secrets", In: "query", Description: "include password hashes", Schema: &openapi.Schema{Type: "boolean"}}},
+			Replies: []openapi.Reply{openapi.Content(http.StatusOK, "Checksummed tar.gz, restore with blogctl restore", "application/gzip")}}},
+		{Route: openapi.Route{Method: mGet, Path: "/admin/erasure-requests", Tag: "admin", Summary: "Pending erasure requests", Auth: openapi.Admin,
+			Replies: ok("Requests", openapi.Object{"success": true, "requests": []models.ErasureRequest{}})}},
+		{Route: openapi.Route{Method: mPost, Path: "/admin/erasure-requests/{requestId}/approve", Tag: "admin", Summary: "Approve and carry out an erasure", Auth: openapi.Admin,
+			Replies: ok("Erased", done(openapi.Object{"result": privacy.ErasureResult{}}))}},
+		{Route: openapi.Route{Method: mPost, Path: "/admin/erasure-requests/{requestId}/reject", Tag: "admin", Summary: "Reject an erasure request", Auth: openapi.Admin,
+			Replies: ok("Rejected", done(nil))}},
+		{Route: openapi.Route{Method: mGet, Path: "/admin/integrity", Tag: "admin", Summary: "Report drift between users, posts and comments", Auth: openapi.Admin,
+			Replies: ok("Report", openapi.Object{"success": true, "report": integrity.Report{}, "outstanding": 0})}},
+		{Route: openapi.Route{Method: mPost, Path: "/admin/integrity/repair", Tag: "admin", Summary: "Repair drift between users, posts and comments", Auth: openapi.Admin,
+			Replies: ok("Report of what was repaired", openapi.Object{"success": true, "report": integrity.Report{}, "outstanding": 0})}},
+
+		// webhooks
+		{Route: openapi.Route{Method: mGet, Path: "/admin/webhooks", Tag: "webhooks", Summary: "Registered webhooks", Auth: openapi.Admin,
+			Replies: ok("Webhooks, newest first", openapi.Object{"success": true, "webhooks": []models.Webhook{}})}},
+		{Route: openapi.Route{Method: mPost, Path: "/admin/webhooks", Tag: "webhooks", Summary: "Register a webhook", Auth: openapi.Admin,
+			Description: "The answer has the signing secret, it isn't shown again.",
+			Request:     openapi.JSONBody(handlers.WebhookBody{}),
+			Replies:     []openapi.Reply{openapi.JSON(http.StatusCreated, "Registered", openapi.Object{"success": true, "webhook": models.Webhook{}, "secret": ""})}}},
+		{Route: openapi.Route{Method: mGet, Path: "/admin/webhooks/{webhookId}", Tag: "webhooks", Summary: "A webhook", Auth: openapi.Admin,
+			Replies: ok("The webhook", openapi.Object{"success": true, "webhook": models.Webhook{}})}},
+		{Route: openapi.Route{Method: mPut, Path: "/admin/webhooks/{webhookId}", Tag: "webhooks", Summary: "Replace a webhook's settings", Auth: openapi.Admin,
+			Description: "The secret is kept when left out. Setting active enables a disabled webhook and clears its failures.",
+			Request:     openapi.JSONBody(handlers.WebhookBody{}),
+			Replies:     ok("Updated", openapi.Object{"success": true, "webhook": models.Webhook{}})}},
+		{Route: openapi.Route{Method: mDelete, Path: "/admin/webhooks/{webhookId}", Tag: "webhooks", Summary: "Delete a webhook and its delivery log", Auth: openapi.Admin,
+			Replies: ok("Deleted", done(openapi.Object{"id": models.Webhook{}.ID}))}},
+		{Route: openapi.Route{Method: mGet, Path: "/admin/webhooks/{webhookId}/deliveries", Tag: "webhooks", Summary: "A webhook's delivery log", Auth: openapi.Admin,
+			Params:  []openapi.Parameter{{Name: "limit", In: "query", Description: "most deliveries to list, 1 to 100", Schema: &openapi.Schema{Type: "integer"}}},
+			Replies: ok("Deliveries, newest first", openapi.Object{"success": true, "deliveries": []models.WebhookDelivery{}})}},
+		{Route: openapi.Route{Method: mPost, Path: "/admin/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", Tag: "webhooks", Summary: "Send a delivery's payload again", Auth: openapi.Admin,
+			Description: "Queues a new delivery of the same payload, the webhook must be active.",
+			Replies:     []openapi.Reply{openapi.JSON(http.StatusAccepted, "Queued", done(openapi.Object{"delivery": models.WebhookDelivery{}}))}}},
+	}
+}
@@ -6,31 +6,70 @@ import (
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/kurtgray/blog-api-go/internal/apiversion"
 	"github.com/kurtgray/blog-api-go/internal/handlers"
+	"github.com/kurtgray/blog-api-go/internal/health"
+	"github.com/kurtgray/blog-api-go/internal/metrics"
 	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/openapi"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+	"github.com/kurtgray/blog-api-go/internal/tracing"
+	"github.com/kurtgray/blog-api-go/internal/web"
 )
 
+// what a Router serves, the ones noted may be nil to leave their routes out
+type Handlers struct {
+	Users    *handlers.UserHandler
+	Posts    *handlers.PostHandler
+	Comments *handlers.CommentHandler
+	Media    *handlers.MediaHandler
+	// serves locally stored uploads under /media, nil for remote storage
+	MediaFiles http.Handler
+	Feed       *handlers.FeedHandler
+	Sitemap    *handlers.SitemapHandler
+	// reader-facing html pages, nil when the web frontend is disabled
+	Web *web.Site
+	// admin imports, also redirects old URLs of imported content
+	Import *handlers.ImportHandler
+	Backup *handlers.BackupHandler
+	// personal data export and erasure
+	Privacy *handlers.PrivacyHandler
+	// reports and repairs drift between users, posts and comments
+	Integrity *handlers.IntegrityHandler
+	Trash     *handlers.TrashHandler
+	// admin-managed subscriptions to content events
+	Webhooks *handlers.WebhookHandler
+	// nil when GraphQL is disabled
+	GraphQL *handlers.GraphQLHandler
+}
+
+// how a Router answers, from config.ServerConfig
+type Options struct {
+	// errors in the pre-problem+json envelope
+	LegacyErrors bool
+	// off, log or strict, see config.ServerConfig.ContractValidation
+	ContractValidation string
+	// headers on v1 responses of routes v2 replaces
+	V1Deprecation apiversion.Deprecation
+}
+
 type Router struct {
-	userHandler    *handlers.UserHandler
-	postHandler    *handlers.PostHandler
-	commentHandler *handlers.CommentHandler
+	handlers       Handlers
 	authService    *middleware.AuthService
 	corsMiddleware *cors.Cors
+	metrics        *metrics.Metrics
+	health         *health.Registry
+	options        Options
 }
 
-func New(
-	userHandler *handlers.UserHandler,
-	postHandler *handlers.PostHandler,
-	commentHandler *handlers.CommentHandler,
-	authService *middleware.AuthService,
-	corsMiddleware *cors.Cors,
-) *Router {
+func New(h Handlers, authService *middleware.AuthService, corsMiddleware *cors.Cors, m *metrics.Metrics, healthRegistry *health.Registry, opts Options) *Router {
 	return &Router{
-		userHandler:    userHandler,
-		postHandler:    postHandler,
-		commentHandler: commentHandler,
+		handlers:       h,
 		authService:    authService,
 		corsMiddleware: corsMiddleware,
+		metrics:        m,
+		health:         healthRegistry,
+		options:        opts,
 	}
 }
 
@@ -41,46 +80,180 @@ func (rt *Router) Setup() *chi.Mux {
 	r.Use(chimiddleware.RequestID)
 	r.Use(chimiddleware.StripSlashes) 
 	r.Use(chimiddleware.RealIP)
+	r.Use(tracing.Middleware)
+	// outside Recoverer so panics are counted as 500s
+	r.Use(rt.metrics.Middleware)
 	r.Use(chimiddleware.Recoverer)
 	r.Use(rt.corsMiddleware.Handler)
+	r.Use(problem.Legacy(rt.options.LegacyErrors))
 
-	// root redirect
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, "/api/posts", http.StatusMovedPermanently)
-	})
+	// this API's description, the tests check it against the routes below
+	spec := rt.spec()
+	doc := openapi.Build(specInfo, specTags, spec)
+	if rt.options.ContractValidation != "off" {
+		r.Use(doc.Contract(rt.options.ContractValidation == "strict"))
+	}
 
-	// API routes
-	r.Route("/api", func(r chi.Router) {
-		// public
+	// probes
+	r.Get("/healthz", rt.health.LivenessHandler)
+	r.Get("/readyz", rt.health.ReadinessHandler)
+
+	r.Get("/openapi.json", doc.Handler())
+	r.Get("/docs", openapi.DocsHandler("/openapi.json"))
 
-		// nested "/api/users", handler method
-		r.Post("/users", rt.userHandler.CreateUser)
-		r.Post("/users/login", rt.userHandler.Login)
-		r.Get("/posts", rt.postHandler.GetAllPosts)
-		r.Get("/posts/{postId}", rt.postHandler.GetPost)
-		r.Get("/posts/{postId}/comments", rt.commentHandler.GetPostComments)
-		r.Get("/posts/{postId}/comments/{commentId}", rt.commentHandler.GetComment)
+	// uploaded files on local storage
+	if rt.handlers.MediaFiles != nil {
+		r.Handle("/media/*", http.StripPrefix("/media", rt.handlers.MediaFiles))
+	}
+
+	mountPublic(r, rt.handlers.Feed, rt.handlers.Sitemap, rt.handlers.Web)
+	if rt.handlers.Web == nil {
+		// root redirect
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/api/posts", http.StatusMovedPermanently)
+		})
+	}
 
-		// protected by auth mw
+	// users, posts and comments in one round trip, a token is optional and
+	// only needed for what needs one over REST
+	if rt.handlers.GraphQL != nil {
 		r.Group(func(r chi.Router) {
-			r.Use(rt.authService.RequireAuth)
-
-			// user
-			r.Get("/users", rt.userHandler.GetCurrentUser)
-			r.Get("/users/{userId}/posts", rt.postHandler.GetUserPosts)
-
-			// post
-			r.Post("/posts", rt.postHandler.CreatePost)
-			r.Put("/posts/{postId}", rt.postHandler.UpdatePost)
-			r.Patch("/posts/{postId}", rt.postHandler.PatchPost)
-			r.Delete("/posts/{postId}", rt.postHandler.DeletePost)
-
-			// comment
-			r.Post("/posts/{postId}/comments", rt.commentHandler.CreateComment)
-			r.Patch("/posts/{postId}/comments/{commentId}", rt.commentHandler.UpdateComment)
-			r.Delete("/posts/{postId}/comments/{commentId}", rt.commentHandler.DeleteComment)
+			r.Use(rt.authService.OptionalAuth)
+			r.Get("/graphql", rt.handlers.GraphQL.Execute)
+			r.Post("/graphql", rt.handlers.GraphQL.Execute)
 		})
+	}
+
+	// anything unmatched may be a link to the site content was imported from
+	r.NotFound(rt.handlers.Import.Redirect)
+
+	// API routes, today's under /api/v1 and, unversioned, /api where Accept
+	// can ask for v2; users, posts and comments also under /api/v2
+	r.Route("/api/v2", func(r chi.Router) {
+		r.Use(apiversion.Pin(apiversion.V2))
+		rt.contentRoutes(r)
+	})
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(apiversion.Pin(apiversion.V1))
+		rt.apiRoutes(r)
+	})
+	r.Route("/api", func(r chi.Router) {
+		r.Use(apiversion.Negotiate)
+		rt.apiRoutes(r)
 	})
 
 	return r
 }
+
+// the v1 API, the routes v2 replaces say so in their v1 responses
+func (rt *Router) apiRoutes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(rt.options.V1Deprecation.Middleware)
+		rt.contentRoutes(r)
+	})
+
+	// public
+	r.Get("/highlight.css", rt.handlers.Posts.HighlightCSS)
+
+	// protected by auth mw
+	r.Group(func(r chi.Router) {
+		r.Use(rt.authService.RequireAuth)
+
+		// user
+		r.Get("/users/me/export", rt.handlers.Privacy.Export)
+		r.Get("/users/me/export/{exportId}", rt.handlers.Privacy.DownloadExport)
+		r.Post("/users/me/erasure", rt.handlers.Privacy.RequestErasure)
+		r.Delete("/users/me/erasure", rt.handlers.Privacy.CancelErasure)
+
+		// trash, restorable until purged
+		r.Get("/trash", rt.handlers.Trash.GetTrash)
+		r.Post("/trash/posts/{postId}/restore", rt.handlers.Trash.RestorePost)
+		r.Post("/trash/comments/{commentId}/restore", rt.handlers.Trash.RestoreComment)
+
+		// media
+		r.Post("/media", rt.handlers.Media.Upload)
+		r.Get("/media", rt.handlers.Media.GetMyMedia)
+		r.Get("/media/{mediaId}", rt.handlers.Media.GetMedia)
+		r.Delete("/media/{mediaId}", rt.handlers.Media.DeleteMedia)
+
+		// admin
+		r.Post("/admin/import", rt.handlers.Import.Import)
+		r.Get("/admin/backup", rt.handlers.Backup.Download)
+		r.Get("/admin/erasure-requests", rt.handlers.Privacy.PendingErasures)
+		r.Post("/admin/erasure-requests/{requestId}/approve", rt.handlers.Privacy.ApproveErasure)
+		r.Post("/admin/erasure-requests/{requestId}/reject", rt.handlers.Privacy.RejectErasure)
+		r.Get("/admin/integrity", rt.handlers.Integrity.Check)
+		r.Post("/admin/integrity/repair", rt.handlers.Integrity.Repair)
+		r.Get("/admin/webhooks", rt.handlers.Webhooks.GetWebhooks)
+		r.Post("/admin/webhooks", rt.handlers.Webhooks.CreateWebhook)
+		r.Get("/admin/webhooks/{webhookId}", rt.handlers.Webhooks.GetWebhook)
+		r.Put("/admin/webhooks/{webhookId}", rt.handlers.Webhooks.UpdateWebhook)
+		r.Delete("/admin/webhooks/{webhookId}", rt.handlers.Webhooks.DeleteWebhook)
+		r.Get("/admin/webhooks/{webhookId}/deliveries", rt.handlers.Webhooks.GetDeliveries)
+		r.Post("/admin/webhooks/{webhookId}/deliveries/{deliveryId}/redeliver", rt.handlers.Webhooks.Redeliver)
+	})
+}
+
+// users, posts and comments, in both versions, the handlers write each
+// version's bodies
+func (rt *Router) contentRoutes(r chi.Router) {
+	// public
+
+	// nested "/api/users", handler method
+	r.Post("/users", rt.handlers.Users.CreateUser)
+	r.Post("/users/login", rt.handlers.Users.Login)
+	r.Get("/posts", rt.handlers.Posts.GetAllPosts)
+	r.Get("/posts/{postId}", rt.handlers.Posts.GetPost)
+	r.Get("/posts/{postId}/comments", rt.handlers.Comments.GetPostComments)
+	r.Get("/posts/{postId}/comments/{commentId}", rt.handlers.Comments.GetComment)
+
+	// protected by auth mw
+	r.Group(func(r chi.Router) {
+		r.Use(rt.authService.RequireAuth)
+
+		// user
+		r.Get("/users", rt.handlers.Users.GetCurrentUser)
+		r.Get("/users/{userId}/posts", rt.handlers.Posts.GetUserPosts)
+
+		// post
+		r.Post("/posts", rt.handlers.Posts.CreatePost)
+		r.Put("/posts/{postId}", rt.handlers.Posts.UpdatePost)
+		r.Patch("/posts/{postId}", rt.handlers.Posts.PatchPost)
+		r.Delete("/posts/{postId}", rt.handlers.Posts.DeletePost)
+
+		// comment
+		r.Post("/posts/{postId}/comments", rt.handlers.Comments.CreateComment)
+		r.Patch("/posts/{postId}/comments/{commentId}", rt.handlers.Comments.UpdateComment)
+		r.Delete("/posts/{postId}/comments/{commentId}", rt.handlers.Comments.DeleteComment)
+	})
+}
+
+// the read-only public site without the API, as exported by blogctl export-static
+func StaticSite(
+	postHandler *handlers.PostHandler,
+	feedHandler *handlers.FeedHandler,
+	sitemapHandler *handlers.SitemapHandler,
+	webSite *web.Site,
+) *chi.Mux {
+	r := chi.NewRouter()
+	mountPublic(r, feedHandler, sitemapHandler, webSite)
+	r.Get("/api/highlight.css", postHandler.HighlightCSS)
+	return r
+}
+
+// feeds, crawler files and, when enabled, the html pages
+func mountPublic(r chi.Router, feedHandler *handlers.FeedHandler, sitemapHandler *handlers.SitemapHandler, webSite *web.Site) {
+	// syndication feeds
+	r.Get("/feed.{format}", feedHandler.SiteFeed)
+	r.Get("/authors/{userId}/feed.{format}", feedHandler.AuthorFeed)
+	r.Get("/tags/{tag}/feed.{format}", feedHandler.TagFeed)
+
+	// crawlers
+	r.Get("/robots.txt", sitemapHandler.Robots)
+	r.Get("/sitemap.xml", sitemapHandler.Sitemap)
+	r.Get("/sitemap-{page}.xml", sitemapHandler.SitemapPage)
+
+	if webSite != nil {
+		webSite.Routes(r)
+	}
+}
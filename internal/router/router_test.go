@@ -0,0 +1,155 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kurtgray/blog-api-go/internal/apiversion"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/handlers"
+	"github.com/kurtgray/blog-api-go/internal/health"
+	"github.com/kurtgray/blog-api-go/internal/metrics"
+	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/openapi"
+	"github.com/kurtgray/blog-api-go/internal/render"
+	"github.com/kurtgray/blog-api-go/internal/tracing"
+	"github.com/kurtgray/blog-api-go/internal/web"
+	"go.opentelemetry.io/otel"
+)
+
+// the config the tests start from, args are flags on top of the defaults
+func testConfig(t *testing.T, args ...string) *config.Config {
+	t.Helper()
+	args = append([]string{"-mongo-uri=mongodb://localhost:27017", "-jwt-secret=test-secret"}, args...)
+	cfg, err := config.Load(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// a router with every handler built the way main builds it, on nothing but
+// nil repositories and services, enough to route but not to answer
+func routerWithoutData(t *testing.T, cfg *config.Config) *Router {
+	t.Helper()
+	m := metrics.New()
+	auth := middleware.NewAuthService(nil, cfg.Auth, m)
+	renderer := render.New()
+
+	h := Handlers{
+		Users:     handlers.NewUserHandler(nil, auth, m, nil),
+		Posts:     handlers.NewPostHandler(nil, nil, nil, nil, nil, renderer, cfg.Site, cfg.Server.RequireIfMatch, cfg.Web.Enabled),
+		Comments:  handlers.NewCommentHandler(nil, nil, renderer, cfg.Server.RequireIfMatch),
+		Media:     handlers.NewMediaHandler(nil, nil),
+		Feed:      handlers.NewFeedHandler(nil, nil, renderer, cfg.Site, cfg.Web.Enabled),
+		Sitemap:   handlers.NewSitemapHandler(nil, cfg.Site, cfg.Web.Enabled),
+		Import:    handlers.NewImportHandler(nil, nil, cfg.Web.Enabled),
+		Backup:    handlers.NewBackupHandler(nil),
+		Privacy:   handlers.NewPrivacyHandler(nil),
+		Integrity: handlers.NewIntegrityHandler(nil),
+		Trash:     handlers.NewTrashHandler(nil, cfg.Trash.Retention),
+		Webhooks:  handlers.NewWebhookHandler(nil),
+	}
+	if cfg.Media.Storage == "local" {
+		h.MediaFiles = http.NotFoundHandler()
+	}
+	if cfg.GraphQL.Enabled {
+		var err error
+		if h.GraphQL, err = handlers.NewGraphQLHandler(h.Users, h.Posts, h.Comments, cfg.GraphQL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cfg.Web.Enabled {
+		var err error
+		if h.Web, err = web.New(nil, nil, nil, renderer, cfg.Site, cfg.Web); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deprecation, err := apiversion.ParseDeprecation(cfg.Server.V1DeprecatedAt, cfg.Server.V1SunsetAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(h, auth, middleware.SetupCORS(cfg.CORS), m, health.NewRegistry(), Options{
+		LegacyErrors:       cfg.Server.LegacyErrors,
+		ContractValidation: cfg.Server.ContractValidation,
+		V1Deprecation:      deprecation,
+	})
+}
+
+// a route without a description, or the other way round, is a bug, with
+// the optional parts of the API on or off
+func TestSpecMatchesRoutes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"defaults", nil},
+		{"everything on", []string{"-web-enabled", "-graphql-enabled", "-media-storage=local", "-v1-deprecated-at=2026-01-01"}},
+		{"remote media", []string{"-media-storage=s3", "-media-s3-endpoint=localhost:9000", "-media-s3-bucket=test"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := routerWithoutData(t, testConfig(t, tt.args...))
+			if err := openapi.Check(rt.Setup(), rt.spec()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// clients quote the header or the problem's traceId to find a request in the
+// logs, so the two must be the same trace
+func TestTraceIDMatchesProblem(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	shutdown, err := tracing.Setup(context.Background(), tracing.ExporterNone, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		shutdown(context.Background())
+		otel.SetTracerProvider(prev)
+	})
+
+	const incoming = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := []struct {
+		name        string
+		traceparent string
+		want        string
+	}{
+		{"new trace", "", ""},
+		{"continued trace", "00-" + incoming + "-00f067aa0ba902b7-01", incoming},
+	}
+	handler := routerWithoutData(t, testConfig(t)).Setup()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/posts", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			var body struct {
+				TraceID string `json:"traceId"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			header := rec.Header().Get(tracing.TraceIDHeader)
+			if header == "" || header == strings.Repeat("0", 32) {
+				t.Fatalf("%s = %q, want a trace id", tracing.TraceIDHeader, header)
+			}
+			if body.TraceID != header {
+				t.Errorf("traceId = %q, %s = %q, want them equal", body.TraceID, tracing.TraceIDHeader, header)
+			}
+			if tt.want != "" && header != tt.want {
+				t.Errorf("%s = %q, want the incoming trace %q", tracing.TraceIDHeader, header, tt.want)
+			}
+		})
+	}
+}
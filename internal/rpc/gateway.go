@@ -0,0 +1,53 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/kurtgray/blog-api-go/internal/rpc/blogv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// JSON over HTTP for the blog.v1 services, per the google.api.http rules in
+// blog.proto, e.g. GET /v1/posts/{id}
+// calls go to the gRPC server at endpoint like any client's, so they get
+// the same interceptors, Authorization becomes the authorization metadata
+// and streams arrive as newline-delimited JSON
+type Gateway struct {
+	http.Handler
+	conn *grpc.ClientConn
+}
+
+func NewGateway(ctx context.Context, endpoint string) (*Gateway, error) {
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := runtime.NewServeMux(
+		// the default, except that unknown fields are refused like the REST
+		// API's strict decoding does rather than dropped
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{EmitUnpopulated: true},
+		}),
+	)
+	for _, register := range []func(context.Context, *runtime.ServeMux, *grpc.ClientConn) error{
+		blogv1.RegisterUserServiceHandler,
+		blogv1.RegisterPostServiceHandler,
+		blogv1.RegisterCommentServiceHandler,
+	} {
+		if err := register(ctx, mux, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &Gateway{Handler: mux, conn: conn}, nil
+}
+
+// once the HTTP server in front of it has shut down
+func (g *Gateway) Close() error {
+	return g.conn.Close()
+}
@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"context"
+	"net"
+
+	"github.com/kurtgray/blog-api-go/internal/handlers"
+	"github.com/kurtgray/blog-api-go/internal/middleware"
+	"github.com/kurtgray/blog-api-go/internal/rpc/blogv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// the gRPC server, the blog.v1 services plus grpc.health.v1 and, when
+// enabled, reflection
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+}
+
+func NewServer(services *handlers.GRPCServices, authService *middleware.AuthService, enableReflection bool) *Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryErrors, unaryAuth(authService)),
+		grpc.ChainStreamInterceptor(streamErrors, streamAuth(authService)),
+	)
+	blogv1.RegisterUserServiceServer(s, services.Users)
+	blogv1.RegisterPostServiceServer(s, services.Posts)
+	blogv1.RegisterCommentServiceServer(s, services.Comments)
+
+	// "" is the server as a whole
+	healthServer := health.NewServer()
+	for _, name := range []string{"", blogv1.UserService_ServiceDesc.ServiceName, blogv1.PostService_ServiceDesc.ServiceName, blogv1.CommentService_ServiceDesc.ServiceName} {
+		healthServer.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	if enableReflection {
+		reflection.Register(s)
+	}
+	return &Server{grpc: s, health: healthServer}
+}
+
+// blocks until Shutdown
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// health checks report NOT_SERVING from here on, like readiness does once
+// the health registry is shutting down
+func (s *Server) SetShuttingDown() {
+	s.health.Shutdown()
+}
+
+// stops taking calls and waits for running ones, streams included, until
+// ctx is done, then cuts them off
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
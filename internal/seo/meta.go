@@ -0,0 +1,130 @@
+package seo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/render"
+)
+
+// search engines cut descriptions at roughly this many characters
+const descriptionLength = 160
+
+// builds page metadata for a post, author overrides win over generated defaults
+// without pages, the html site being off, only an overridden canonical url
+// is given and nothing links to post or author pages
+func PostMeta(site config.SiteConfig, post *models.PostWithAuthor, pages bool) *models.PostMeta {
+	base := strings.TrimRight(site.BaseURL, "/")
+	overrides := post.SEO
+	if overrides == nil {
+		overrides = &models.SEO{}
+	}
+
+	meta := &models.PostMeta{
+		Title:        firstNonEmpty(overrides.Title, post.Title),
+		Description:  firstNonEmpty(overrides.Description, render.Excerpt(post.HTML, descriptionLength)),
+		CanonicalURL: overrides.CanonicalURL,
+		Robots:       "index, follow",
+	}
+	if pages {
+		meta.CanonicalURL = firstNonEmpty(overrides.CanonicalURL, base+"/posts/"+post.ID)
+	}
+	if overrides.NoIndex || !post.Published {
+		meta.Robots = "noindex, nofollow"
+	}
+
+	image := absolute(base, firstNonEmpty(overrides.Image, post.ImgURL))
+	published := post.Timestamp.UTC().Format(time.RFC3339)
+	modified := post.UpdatedAt
+	if modified.IsZero() {
+		modified = post.Timestamp
+	}
+
+	meta.OpenGraph = map[string]string{
+		"og:type":                "article",
+		"og:title":               meta.Title,
+		"og:description":         meta.Description,
+		"og:site_name":           site.Title,
+		"article:published_time": published,
+		"article:modified_time":  modified.UTC().Format(time.RFC3339),
+	}
+	if meta.CanonicalURL != "" {
+		meta.OpenGraph["og:url"] = meta.CanonicalURL
+	}
+	if site.Language != "" {
+		meta.OpenGraph["og:locale"] = site.Language
+	}
+
+	meta.Twitter = map[string]string{
+		"twitter:card":        "summary",
+		"twitter:title":       meta.Title,
+		"twitter:description": meta.Description,
+	}
+
+	posting := map[string]interface{}{
+		"@context":      "https://schema.org",
+		"@type":         "BlogPosting",
+		"headline":      meta.Title,
+		"description":   meta.Description,
+		"datePublished": published,
+		"dateModified":  modified.UTC().Format(time.RFC3339),
+		"publisher":     map[string]interface{}{"@type": "Organization", "name": site.Title, "url": base},
+	}
+	if meta.CanonicalURL != "" {
+		posting["url"] = meta.CanonicalURL
+		posting["mainEntityOfPage"] = map[string]interface{}{"@type": "WebPage", "@id": meta.CanonicalURL}
+	}
+	if site.Language != "" {
+		posting["inLanguage"] = site.Language
+	}
+	if len(post.Tags) > 0 {
+		posting["keywords"] = strings.Join(post.Tags, ", ")
+	}
+	if post.Author != nil {
+		author := map[string]interface{}{
+			"@type": "Person",
+			"name":  authorName(post.Author),
+		}
+		if pages {
+			author["url"] = base + "/authors/" + post.Author.ID
+		}
+		posting["author"] = author
+	}
+
+	if image != "" {
+		meta.OpenGraph["og:image"] = image
+		meta.Twitter["twitter:card"] = "summary_large_image"
+		meta.Twitter["twitter:image"] = image
+		posting["image"] = image
+	}
+	meta.JSONLD = posting
+
+	return meta
+}
+
+// full name when known, else username
+func authorName(u *models.UserResponse) string {
+	if name := strings.TrimSpace(u.Fname + " " + u.Lname); name != "" {
+		return name
+	}
+	return u.Username
+}
+
+// site-relative refs such as local media become absolute
+func absolute(base, ref string) string {
+	if strings.HasPrefix(ref, "/") && !strings.HasPrefix(ref, "//") {
+		return base + ref
+	}
+	return ref
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package seo
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// sitemaps.org limit per file, larger sites need a sitemap index
+const MaxSitemapURLs = 50000
+
+const (
+	sitemapNS          = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	SitemapContentType = "application/xml; charset=utf-8"
+)
+
+type URL struct {
+	Loc          string
+	LastModified time.Time
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	NS       string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// <urlset> document, at most MaxSitemapURLs entries
+func URLSet(urls []URL) ([]byte, error) {
+	return marshal(urlSet{NS: sitemapNS, URLs: entries(urls)})
+}
+
+// <sitemapindex> pointing at the given sitemaps
+func Index(sitemaps []URL) ([]byte, error) {
+	return marshal(sitemapIndex{NS: sitemapNS, Sitemaps: entries(sitemaps)})
+}
+
+// allows everything but the private api, and advertises the sitemap
+func Robots(baseURL string) string {
+	base := strings.TrimRight(baseURL, "/")
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	b.WriteString("Allow: /\n")
+	b.WriteString("Disallow: /api/users\n")
+	b.WriteString("Disallow: /api/media\n")
+	b.WriteString("\n")
+	b.WriteString("Sitemap: " + base + "/sitemap.xml\n")
+	return b.String()
+}
+
+func entries(urls []URL) []sitemapURL {
+	out := make([]sitemapURL, 0, len(urls))
+	for _, u := range urls {
+		entry := sitemapURL{Loc: u.Loc}
+		if !u.LastModified.IsZero() {
+			entry.LastMod = u.LastModified.UTC().Format(time.RFC3339)
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+func marshal(v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
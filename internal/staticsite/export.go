@@ -0,0 +1,361 @@
+package staticsite
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/seo"
+	"github.com/kurtgray/blog-api-go/internal/web"
+)
+
+var feedFormats = []string{"rss", "atom", "json"}
+
+// renders the public site to files by requesting every page from handler,
+// the same router the API serves, so exported pages match live ones
+type Exporter struct {
+	handler  http.Handler
+	postRepo repository.PostRepository
+	// theme files, copied under /theme
+	assets   fs.FS
+	pageSize int64
+}
+
+func NewExporter(handler http.Handler, postRepo repository.PostRepository, assets fs.FS, pageSize int) *Exporter {
+	return &Exporter{
+		handler:  handler,
+		postRepo: postRepo,
+		assets:   assets,
+		pageSize: int64(pageSize),
+	}
+}
+
+type Options struct {
+	Dir string
+	// re-render every post page even when its timestamp is unchanged
+	Full bool
+}
+
+// writes the site into opts.Dir and returns the manifest of what changed
+// post pages whose updatedAt matches the previous run are left alone,
+// everything else is regenerated and only rewritten when its bytes differ
+func (e *Exporter) Export(ctx context.Context, opts Options) (*Manifest, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	prev, err := readManifest(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading previous manifest: %w", err)
+	}
+
+	run := &run{
+		exporter: e,
+		ctx:      ctx,
+		dir:      opts.Dir,
+		prev:     prev,
+		next: &Manifest{
+			GeneratedAt: time.Now().UTC(),
+			Posts:       map[string]time.Time{},
+			Files:       map[string]string{},
+			Changes:     Changes{Added: []string{}, Updated: []string{}, Removed: []string{}},
+		},
+	}
+	if err := run.pages(opts.Full); err != nil {
+		return nil, err
+	}
+	if err := run.assets(); err != nil {
+		return nil, err
+	}
+	if err := run.removeStale(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(run.next.Changes.Added)
+	sort.Strings(run.next.Changes.Updated)
+	sort.Strings(run.next.Changes.Removed)
+	if err := writeManifest(opts.Dir, run.next); err != nil {
+		return nil, err
+	}
+	return run.next, nil
+}
+
+type run struct {
+	exporter *Exporter
+	ctx      context.Context
+	dir      string
+	prev     *Manifest
+	next     *Manifest
+}
+
+func (r *run) pages(full bool) error {
+	posts, err := r.exporter.postRepo.FindPublished(r.ctx, repository.PostFilter{})
+	if err != nil {
+		return err
+	}
+
+	authors := map[string]int64{}
+	tags := map[string]int64{}
+	for _, post := range posts {
+		if post.Author != nil && post.Author.ID != "" {
+			authors[post.Author.ID]++
+		}
+		for _, tag := range post.Tags {
+			tags[tag]++
+		}
+
+		updated := post.UpdatedAt
+		if updated.IsZero() {
+			updated = post.Timestamp
+		}
+		r.next.Posts[post.ID] = updated
+
+		urlPath := "/posts/" + post.ID
+		if last, ok := r.prev.Posts[post.ID]; ok && !full && last.Equal(updated) && r.keep(urlPath) {
+			continue
+		}
+		if err := r.fetch(urlPath); err != nil {
+			return err
+		}
+	}
+
+	if err := r.list("/", int64(len(posts))); err != nil {
+		return err
+	}
+	if err := r.feeds(""); err != nil {
+		return err
+	}
+	for id, count := range authors {
+		base := "/authors/" + id
+		if err := r.list(base, count); err != nil {
+			return err
+		}
+		if err := r.feeds(base); err != nil {
+			return err
+		}
+	}
+	for tag, count := range tags {
+		// a tag that isn't a single clean path segment can't become a directory
+		if tag == "" || tag == "." || tag == ".." || strings.ContainsAny(tag, `/\`) {
+			continue
+		}
+		base := "/tags/" + url.PathEscape(tag)
+		if err := r.list(base, count); err != nil {
+			return err
+		}
+		if err := r.feeds(base); err != nil {
+			return err
+		}
+	}
+
+	if err := r.sitemaps(); err != nil {
+		return err
+	}
+	for _, p := range []string{"/robots.txt", "/api/highlight.css"} {
+		if err := r.fetchFile(p); err != nil {
+			return err
+		}
+	}
+
+	// most static hosts serve 404.html for missing paths
+	return r.fetchAs("/posts/not-found", "404.html", http.StatusNotFound)
+}
+
+// every page of a post list with count posts
+func (r *run) list(base string, count int64) error {
+	pages := (count + r.exporter.pageSize - 1) / r.exporter.pageSize
+	if pages < 1 {
+		pages = 1
+	}
+	for n := int64(1); n <= pages; n++ {
+		if err := r.fetch(web.PagePath(base, n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *run) feeds(base string) error {
+	for _, format := range feedFormats {
+		if err := r.fetchFile(base + "/feed." + format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *run) sitemaps() error {
+	if err := r.fetchFile("/sitemap.xml"); err != nil {
+		return err
+	}
+
+	count, err := r.exporter.postRepo.CountIndexable(r.ctx)
+	if err != nil {
+		return err
+	}
+	// one file holds everything, otherwise sitemap.xml is an index of numbered pages
+	total := count + 1
+	if total <= seo.MaxSitemapURLs {
+		return nil
+	}
+	for n := int64(1); (n-1)*seo.MaxSitemapURLs < total; n++ {
+		if err := r.fetchFile(fmt.Sprintf("/sitemap-%d.xml", n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *run) assets() error {
+	return fs.WalkDir(r.exporter.assets, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(r.exporter.assets, p)
+		if err != nil {
+			return err
+		}
+		return r.write(path.Join("theme", p), data)
+	})
+}
+
+// html page, stored as a directory index so its URL needs no extension
+func (r *run) fetch(urlPath string) error {
+	return r.fetchAs(urlPath, pageFile(urlPath), http.StatusOK)
+}
+
+// feeds, sitemaps and the like, stored under their own name
+func (r *run) fetchFile(urlPath string) error {
+	return r.fetchAs(urlPath, unescape(urlPath), http.StatusOK)
+}
+
+func (r *run) fetchAs(urlPath, file string, want int) error {
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+
+	req := httptest.NewRequest(http.MethodGet, urlPath, nil).WithContext(r.ctx)
+	rec := httptest.NewRecorder()
+	r.exporter.handler.ServeHTTP(rec, req)
+	if rec.Code != want {
+		return fmt.Errorf("GET %s: status %d", urlPath, rec.Code)
+	}
+	return r.write(file, rec.Body.Bytes())
+}
+
+// carries a file over from the previous run without re-rendering it
+func (r *run) keep(urlPath string) bool {
+	file := pageFile(urlPath)
+	sum, ok := r.prev.Files[file]
+	if !ok {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(r.dir, filepath.FromSlash(file))); err != nil {
+		return false
+	}
+	r.next.Files[file] = sum
+	r.next.Changes.Unchanged++
+	return true
+}
+
+func (r *run) write(file string, data []byte) error {
+	hash := sha256.Sum256(data)
+	sum := hex.EncodeToString(hash[:])
+	if _, seen := r.next.Files[file]; seen {
+		return nil
+	}
+	r.next.Files[file] = sum
+
+	target := filepath.Join(r.dir, filepath.FromSlash(file))
+	old, existed := r.prev.Files[file]
+	if existed && old == sum {
+		if _, err := os.Stat(target); err == nil {
+			r.next.Changes.Unchanged++
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(target, data); err != nil {
+		return err
+	}
+	if existed {
+		r.next.Changes.Updated = append(r.next.Changes.Updated, file)
+	} else {
+		r.next.Changes.Added = append(r.next.Changes.Added, file)
+	}
+	return nil
+}
+
+// deletes files the previous run wrote that this one didn't
+func (r *run) removeStale() error {
+	for file := range r.prev.Files {
+		if _, ok := r.next.Files[file]; ok {
+			continue
+		}
+		target := filepath.Join(r.dir, filepath.FromSlash(file))
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// drop directories left empty, stops at the first one that isn't
+		for dir := filepath.Dir(target); dir != filepath.Clean(r.dir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+		r.next.Changes.Removed = append(r.next.Changes.Removed, file)
+	}
+	return nil
+}
+
+// /posts/x -> posts/x/index.html
+func pageFile(urlPath string) string {
+	p := unescape(urlPath)
+	if p == "" {
+		return "index.html"
+	}
+	return p + "/index.html"
+}
+
+// /tags/a%20b/feed.rss -> tags/a b/feed.rss
+func unescape(urlPath string) string {
+	p, err := url.PathUnescape(urlPath)
+	if err != nil {
+		p = urlPath
+	}
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	return p
+}
+
+func writeFileAtomic(target string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
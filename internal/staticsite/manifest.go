@@ -0,0 +1,64 @@
+package staticsite
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// kept in the output directory between runs, drives incremental rebuilds
+const manifestFile = ".export-manifest.json"
+
+type Manifest struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	// post id -> updatedAt of the post when its page was last rendered
+	Posts map[string]time.Time `json:"posts"`
+	// file path relative to the output directory -> sha256 of its contents
+	Files   map[string]string `json:"files"`
+	Changes Changes           `json:"changes"`
+}
+
+// what the last run did to the output directory
+type Changes struct {
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0
+}
+
+// previous manifest, empty when this is the first export
+func readManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Posts: map[string]time.Time{}, Files: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Posts == nil {
+		m.Posts = map[string]time.Time{}
+	}
+	if m.Files == nil {
+		m.Files = map[string]string{}
+	}
+	return m, nil
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, manifestFile), data)
+}
@@ -0,0 +1,63 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+)
+
+// decodes a single JSON value from r into v
+// unknown fields, wrong types and trailing data are validation errors, a
+// read error such as *http.MaxBytesError is returned as it is
+func Decode(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		if err != nil && !isSyntax(err) {
+			return err
+		}
+		return apperr.New(apperr.ErrValidation, "invalid_body", "Request body must be a single JSON value.")
+	}
+	return nil
+}
+
+// Decode then Struct
+func JSON(r io.Reader, v interface{}) error {
+	if err := Decode(r, v); err != nil {
+		return err
+	}
+	return Struct(v)
+}
+
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return apperr.New(apperr.ErrValidation, "invalid_body", "Request body is empty.")
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return apperr.New(apperr.ErrValidation, "invalid_body", fmt.Sprintf("Request body must be a JSON object, not a %s.", typeErr.Value))
+		}
+		return apperr.Field(field, "invalid_type", fmt.Sprintf("%s can't be a %s.", field, typeErr.Value))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no type for this one
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return apperr.Field(field, "unknown_field", fmt.Sprintf("%s is not a known field.", field))
+	case isSyntax(err):
+		return apperr.New(apperr.ErrValidation, "invalid_body", "Request body is not valid JSON.")
+	}
+	return err
+}
+
+func isSyntax(err error) bool {
+	var syntaxErr *json.SyntaxError
+	return errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
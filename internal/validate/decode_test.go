@@ -0,0 +1,142 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/problem"
+)
+
+type body struct {
+	Title string   `json:"title" validate:"required,max=10" label:"Title"`
+	Tags  []string `json:"tags"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		code  string
+		field string
+	}{
+		{"valid", `{"title": "Hello", "tags": ["a"]}`, "", ""},
+		{"empty", ``, "invalid_body", ""},
+		{"not json", `title=Hello`, "invalid_body", ""},
+		{"truncated", `{"title": "Hel`, "invalid_body", ""},
+		{"not an object", `["Hello"]`, "invalid_body", ""},
+		{"trailing value", `{"title": "Hello"} {}`, "invalid_body", ""},
+		{"trailing garbage", `{"title": "Hello"} x`, "invalid_body", ""},
+		{"unknown field", `{"title": "Hello", "author": "me"}`, "unknown_field", "author"},
+		{"wrong type", `{"title": 5}`, "invalid_type", "title"},
+		{"wrong item type", `{"title": "Hello", "tags": [1]}`, "invalid_type", "tags.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b body
+			err := Decode(strings.NewReader(tt.body), &b)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("Decode() = %v, want nil", err)
+				}
+				return
+			}
+
+			var e *apperr.Error
+			if !errors.As(err, &e) || !errors.Is(err, apperr.ErrValidation) {
+				t.Fatalf("Decode() = %v, want a validation error", err)
+			}
+			if e.Code != tt.code && (len(e.Fields) == 0 || e.Fields[0].Code != tt.code) {
+				t.Errorf("Decode() code = %q %+v, want %q", e.Code, e.Fields, tt.code)
+			}
+			if tt.field != "" && (len(e.Fields) != 1 || e.Fields[0].Field != tt.field) {
+				t.Errorf("Decode() fields = %+v, want %s", e.Fields, tt.field)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		tooLong bool
+	}{
+		{"under the limit", `{"title": "Hello"}`, false},
+		{"over the limit", `{"title": "` + strings.Repeat("a", 64) + `"}`, true},
+		// the limit is hit looking for trailing data
+		{"trailing data over the limit", `{"title": "Hello"}` + strings.Repeat(" ", 64) + `{}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader(tt.body)), 32)
+			var b body
+			err := Decode(r, &b)
+
+			var maxBytesErr *http.MaxBytesError
+			if got := errors.As(err, &maxBytesErr); got != tt.tooLong {
+				t.Errorf("Decode() = %v, want a *http.MaxBytesError: %v", err, tt.tooLong)
+			}
+			if tt.tooLong && errors.Is(err, apperr.ErrValidation) {
+				t.Error("Decode() turned the read error into a validation error")
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var b body
+	if err := JSON(strings.NewReader(`{"title": "Hello"}`), &b); err != nil || b.Title != "Hello" {
+		t.Fatalf("JSON() = %v, title %q", err, b.Title)
+	}
+
+	err := JSON(strings.NewReader(`{"title": " "}`), &b)
+	var e *apperr.Error
+	if !errors.As(err, &e) || len(e.Fields) != 1 || e.Fields[0].Code != "required" {
+		t.Errorf("JSON() = %v, want title required", err)
+	}
+}
+
+// the shape clients see for a field error
+func TestFieldErrorProblem(t *testing.T) {
+	var b body
+	err := JSON(strings.NewReader(`{"title": "`+strings.Repeat("a", 11)+`"}`), &b)
+
+	rec := httptest.NewRecorder()
+	problem.Write(rec, httptest.NewRequest(http.MethodPost, "/api/posts", nil), err)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, problem.ContentType)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"type":     "/problems/validation_failed",
+		"title":    "Bad Request",
+		"status":   float64(400),
+		"detail":   "Title must be at most 10 characters.",
+		"instance": "/api/posts",
+		"code":     "validation_failed",
+		"errors": []interface{}{
+			map[string]interface{}{
+				"field":   "title",
+				"code":    "too_long",
+				"message": "Title must be at most 10 characters.",
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problem = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,215 @@
+package validate
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+)
+
+// rules go in a validate struct tag, comma separated, and every rule but
+// required passes empty values
+//
+//	required      set, strings need more than whitespace
+//	min=n, max=n  characters for strings, items for slices
+//	url           absolute http(s) URL
+//	link          absolute http(s) URL or a path on this site
+//	oneof=a b c   one of the listed values
+//	pattern=name  matches one of the patterns below
+//
+// fields are named by their json tag and labelled in messages by a label
+// tag, nested structs are checked with dotted names, e.g. seo.title
+//
+// a bad tag is a programming error and panics
+
+type pattern struct {
+	re *regexp.Regexp
+	// completes "<label> must ..."
+	want string
+}
+
+var patterns = map[string]pattern{
+	"username": {regexp.MustCompile(`^[A-Za-z0-9_.-]+$`), "contain only letters, numbers, dots, dashes and underscores"},
+	"objectid": {regexp.MustCompile(`^[0-9a-f]{24}$`), "be a 24 character hex ID"},
+}
+
+// the expression behind a pattern rule, for documenting it
+func PatternRegexp(name string) string {
+	p, ok := patterns[name]
+	if !ok {
+		panic(fmt.Sprintf("validate: unknown pattern %q", name))
+	}
+	return p.re.String()
+}
+
+// checks v, a struct or pointer to one, against its tags
+// returns an apperr validation error listing every invalid field, or nil
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+
+	var fields []apperr.FieldError
+	check(rv, "", &fields)
+	if len(fields) == 0 {
+		return nil
+	}
+	return apperr.Validation(fields...)
+}
+
+func check(rv reflect.Value, prefix string, fields *[]apperr.FieldError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+		name = prefix + name
+		label := sf.Tag.Get("label")
+		if label == "" {
+			label = name
+		}
+
+		fv := rv.Field(i)
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			if fe := checkField(fv, name, label, tag); fe != nil {
+				*fields = append(*fields, *fe)
+				continue
+			}
+		}
+
+		// nested documents, e.g. a post's seo overrides
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			check(fv, name+".", fields)
+		}
+	}
+}
+
+// the first rule the field breaks
+func checkField(fv reflect.Value, name, label, tag string) *apperr.FieldError {
+	fail := func(code, format string, args ...interface{}) *apperr.FieldError {
+		return &apperr.FieldError{
+			Field:   name,
+			Code:    code,
+			Message: fmt.Sprintf("%s "+format+".", append([]interface{}{label}, args...)...),
+		}
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		key, arg, _ := strings.Cut(rule, "=")
+		if key == "required" {
+			if empty(fv) {
+				return fail("required", "must be specified")
+			}
+			continue
+		}
+		if empty(fv) {
+			return nil
+		}
+
+		switch key {
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validate: %s: bad %s", name, rule))
+			}
+			size, unit := length(fv)
+			if key == "min" && size < n {
+				return fail("too_short", "must be at least %d %s", n, unit)
+			}
+			if key == "max" && size > n {
+				return fail("too_long", "must be at most %d %s", n, unit)
+			}
+		case "url", "link":
+			s := fv.String()
+			if key == "link" && strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") {
+				continue
+			}
+			u, err := url.Parse(s)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				if key == "link" {
+					return fail("invalid_url", "must be an absolute http(s) URL or a path starting with /")
+				}
+				return fail("invalid_url", "must be an absolute http(s) URL")
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			s := fmt.Sprint(fv.Interface())
+			ok := false
+			for _, a := range allowed {
+				ok = ok || a == s
+			}
+			if !ok {
+				return fail("not_allowed", "must be %s", list(allowed))
+			}
+		case "pattern":
+			p, ok := patterns[arg]
+			if !ok {
+				panic(fmt.Sprintf("validate: %s: unknown pattern %q", name, arg))
+			}
+			if !p.re.MatchString(fv.String()) {
+				return fail("invalid_format", "must %s", p.want)
+			}
+		default:
+			panic(fmt.Sprintf("validate: %s: unknown rule %q", name, key))
+		}
+	}
+	return nil
+}
+
+func empty(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(fv.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return fv.IsNil()
+	}
+	return fv.IsZero()
+}
+
+func length(fv reflect.Value) (int, string) {
+	switch fv.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(fv.String()), "characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return fv.Len(), "items"
+	}
+	panic(fmt.Sprintf("validate: length of a %s", fv.Kind()))
+}
+
+// "a, b or c"
+func list(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
@@ -0,0 +1,149 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+)
+
+type seo struct {
+	Title string `json:"title" validate:"max=5" label:"SEO title"`
+}
+
+type doc struct {
+	Title    string   `json:"title" validate:"required,max=10" label:"Title"`
+	Username string   `json:"username" validate:"pattern=username"`
+	Owner    string   `json:"owner" validate:"pattern=objectid" label:"Owner"`
+	Website  string   `json:"website" validate:"url"`
+	Image    string   `json:"image" validate:"link" label:"Image"`
+	Status   string   `json:"status" validate:"oneof=draft published" label:"Status"`
+	Tags     []string `json:"tags" validate:"min=1,max=2" label:"Tags"`
+	SEO      *seo     `json:"seo"`
+	Skipped  string   `json:"-" validate:"required"`
+	internal string
+}
+
+func valid() doc {
+	return doc{Title: "Hello", Skipped: "set"}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*doc)
+		want   []apperr.FieldError
+	}{
+		{"valid", func(d *doc) {}, nil},
+		{"required missing", func(d *doc) { d.Title = "" }, []apperr.FieldError{
+			{Field: "title", Code: "required", Message: "Title must be specified."},
+		}},
+		{"required whitespace", func(d *doc) { d.Title = " \t" }, []apperr.FieldError{
+			{Field: "title", Code: "required", Message: "Title must be specified."},
+		}},
+		{"max characters", func(d *doc) { d.Title = strings.Repeat("a", 11) }, []apperr.FieldError{
+			{Field: "title", Code: "too_long", Message: "Title must be at most 10 characters."},
+		}},
+		{"max counts runes", func(d *doc) { d.Title = strings.Repeat("é", 10) }, nil},
+		{"min items", func(d *doc) { d.Tags = []string{} }, nil},
+		{"max items", func(d *doc) { d.Tags = []string{"a", "b", "c"} }, []apperr.FieldError{
+			{Field: "tags", Code: "too_long", Message: "Tags must be at most 2 items."},
+		}},
+		{"pattern without a label", func(d *doc) { d.Username = "no spaces" }, []apperr.FieldError{
+			{Field: "username", Code: "invalid_format", Message: "username must contain only letters, numbers, dots, dashes and underscores."},
+		}},
+		{"objectid", func(d *doc) { d.Owner = "5f1d7f0e8a3b2c1d0e9f8a7" }, []apperr.FieldError{
+			{Field: "owner", Code: "invalid_format", Message: "Owner must be a 24 character hex ID."},
+		}},
+		{"url", func(d *doc) { d.Website = "https://example.com/a" }, nil},
+		{"url without a scheme", func(d *doc) { d.Website = "example.com" }, []apperr.FieldError{
+			{Field: "website", Code: "invalid_url", Message: "website must be an absolute http(s) URL."},
+		}},
+		{"url with another scheme", func(d *doc) { d.Website = "javascript:alert(1)" }, []apperr.FieldError{
+			{Field: "website", Code: "invalid_url", Message: "website must be an absolute http(s) URL."},
+		}},
+		{"link path", func(d *doc) { d.Image = "/media/a.png" }, nil},
+		{"link protocol relative", func(d *doc) { d.Image = "//evil.example/a.png" }, []apperr.FieldError{
+			{Field: "image", Code: "invalid_url", Message: "Image must be an absolute http(s) URL or a path starting with /."},
+		}},
+		{"oneof", func(d *doc) { d.Status = "archived" }, []apperr.FieldError{
+			{Field: "status", Code: "not_allowed", Message: "Status must be draft or published."},
+		}},
+		{"nested", func(d *doc) { d.SEO = &seo{Title: "too long"} }, []apperr.FieldError{
+			{Field: "seo.title", Code: "too_long", Message: "SEO title must be at most 5 characters."},
+		}},
+		{"every invalid field", func(d *doc) {
+			d.Title = ""
+			d.Status = "archived"
+			d.SEO = &seo{Title: "too long"}
+		}, []apperr.FieldError{
+			{Field: "title", Code: "required", Message: "Title must be specified."},
+			{Field: "status", Code: "not_allowed", Message: "Status must be draft or published."},
+			{Field: "seo.title", Code: "too_long", Message: "SEO title must be at most 5 characters."},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := valid()
+			tt.modify(&d)
+			err := Struct(&d)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Struct() = %v, want nil", err)
+				}
+				return
+			}
+
+			var e *apperr.Error
+			if !errors.As(err, &e) {
+				t.Fatalf("Struct() = %v, want an *apperr.Error", err)
+			}
+			if !errors.Is(err, apperr.ErrValidation) {
+				t.Errorf("Struct() kind = %v, want ErrValidation", e.Kind)
+			}
+			if !reflect.DeepEqual(e.Fields, tt.want) {
+				t.Errorf("Struct() fields = %+v, want %+v", e.Fields, tt.want)
+			}
+			if e.Message != tt.want[0].Message {
+				t.Errorf("Struct() message = %q, want the first field's", e.Message)
+			}
+		})
+	}
+}
+
+func TestStructNil(t *testing.T) {
+	var d *doc
+	if err := Struct(d); err != nil {
+		t.Errorf("Struct(nil) = %v, want nil", err)
+	}
+}
+
+func TestStructBadTag(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"not a struct", "title"},
+		{"unknown rule", &struct {
+			A string `validate:"email"`
+		}{A: "a"}},
+		{"bad max", &struct {
+			A string `validate:"max=ten"`
+		}{A: "a"}},
+		{"unknown pattern", &struct {
+			A string `validate:"pattern=slug"`
+		}{A: "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Struct() didn't panic")
+				}
+			}()
+			Struct(tt.v)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package views
+
+import (
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+)
+
+// API v2 bodies for users, posts and comments, built from the same models
+// v1 writes as they are
+// every id is a hex string named id, authors are always embedded, times
+// are UTC and nothing that is always there is omitted
+
+// who wrote a post or comment, what anyone may see of a user
+type Author struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Fname    string `json:"fname"`
+	Lname    string `json:"lname"`
+}
+
+// a user as they see themselves
+type User struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	Fname      string    `json:"fname"`
+	Lname      string    `json:"lname"`
+	Admin      bool      `json:"admin"`
+	CanPublish bool      `json:"canPublish"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// a successful login
+type Session struct {
+	Token string `json:"token"`
+	User  User   `json:"user"`
+}
+
+// Author is null once the author's account is gone
+type Post struct {
+	ID           string            `json:"id"`
+	Author       *Author           `json:"author"`
+	Title        string            `json:"title"`
+	Text         string            `json:"text"`
+	Format       string            `json:"format"`
+	HTML         string            `json:"html"`
+	TOC          []models.TOCEntry `json:"toc"`
+	Tags         []string          `json:"tags"`
+	ImgURL       string            `json:"imgUrl"`
+	MediaID      *string           `json:"mediaId"`
+	SEO          *models.SEO       `json:"seo"`
+	Published    bool              `json:"published"`
+	CommentCount int64             `json:"commentCount"`
+	Version      int64             `json:"version"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+	// single-post reads only
+	Meta *models.PostMeta `json:"meta,omitempty"`
+}
+
+type Comment struct {
+	ID        string    `json:"id"`
+	PostID    string    `json:"postId"`
+	Author    *Author   `json:"author"`
+	Text      string    `json:"text"`
+	HTML      string    `json:"html"`
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewUser(u *models.User) User {
+	return User{
+		ID:         u.ID.Hex(),
+		Username:   u.Username,
+		Fname:      u.Fname,
+		Lname:      u.Lname,
+		Admin:      u.Admin,
+		CanPublish: u.CanPublish,
+		CreatedAt:  u.CreatedAt.UTC(),
+	}
+}
+
+func NewPost(p *models.PostWithAuthor) Post {
+	post := Post{
+		ID:           p.ID,
+		Author:       authorOf(p.Author),
+		Title:        p.Title,
+		Text:         p.Text,
+		Format:       p.Format,
+		HTML:         p.HTML,
+		TOC:          p.TOC,
+		Tags:         p.Tags,
+		ImgURL:       p.ImgURL,
+		SEO:          p.SEO,
+		Published:    p.Published,
+		CommentCount: p.CommentCount,
+		Version:      p.Version,
+		CreatedAt:    p.Timestamp.UTC(),
+		UpdatedAt:    p.UpdatedAt.UTC(),
+		Meta:         p.Meta,
+	}
+	if p.MediaID != "" {
+		post.MediaID = &p.MediaID
+	}
+	return post.defaults()
+}
+
+func NewPosts(ps []models.PostWithAuthor) []Post {
+	out := make([]Post, len(ps))
+	for i := range ps {
+		out[i] = NewPost(&ps[i])
+	}
+	return out
+}
+
+// a post read without its author, e.g. one just written by them
+func NewPostBy(p *models.Post, author *models.User) Post {
+	post := Post{
+		ID:           p.ID.Hex(),
+		Author:       userAuthor(author),
+		Title:        p.Title,
+		Text:         p.Text,
+		Format:       p.Format,
+		HTML:         p.HTML,
+		TOC:          p.TOC,
+		Tags:         p.Tags,
+		ImgURL:       p.ImgURL,
+		SEO:          p.SEO,
+		Published:    p.Published,
+		CommentCount: p.CommentCount,
+		Version:      p.Version,
+		CreatedAt:    p.Timestamp.UTC(),
+		UpdatedAt:    p.UpdatedAt.UTC(),
+	}
+	if p.MediaID != nil {
+		id := p.MediaID.Hex()
+		post.MediaID = &id
+	}
+	return post.defaults()
+}
+
+// empty lists rather than null, legacy posts without a format are html
+func (p Post) defaults() Post {
+	if p.TOC == nil {
+		p.TOC = []models.TOCEntry{}
+	}
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	if p.Format == "" {
+		p.Format = models.FormatHTML
+	}
+	return p
+}
+
+func NewComment(c *models.CommentWithAuthor) Comment {
+	return Comment{
+		ID:        c.ID,
+		PostID:    c.Post.Hex(),
+		Author:    authorOf(c.Author),
+		Text:      c.Text,
+		HTML:      c.HTML,
+		Version:   c.Version,
+		CreatedAt: c.Timestamp.UTC(),
+	}
+}
+
+func NewComments(cs []models.CommentWithAuthor) []Comment {
+	out := make([]Comment, len(cs))
+	for i := range cs {
+		out[i] = NewComment(&cs[i])
+	}
+	return out
+}
+
+// a comment read without its author, e.g. one just written by them
+func NewCommentBy(c *models.Comment, author *models.User) Comment {
+	return Comment{
+		ID:        c.ID.Hex(),
+		PostID:    c.Post.Hex(),
+		Author:    userAuthor(author),
+		Text:      c.Text,
+		HTML:      c.HTML,
+		Version:   c.Version,
+		CreatedAt: c.Timestamp.UTC(),
+	}
+}
+
+// nil for the empty author the $lookup leaves when the user is gone
+func authorOf(u *models.UserResponse) *Author {
+	if u == nil || u.ID == "" {
+		return nil
+	}
+	return &Author{ID: u.ID, Username: u.Username, Fname: u.Fname, Lname: u.Lname}
+}
+
+func userAuthor(u *models.User) *Author {
+	if u == nil {
+		return nil
+	}
+	return &Author{ID: u.ID.Hex(), Username: u.Username, Fname: u.Fname, Lname: u.Lname}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/render"
+)
+
+//go:embed themes
+var embedded embed.FS
+
+// every theme provides layout.html, which defines "layout" and calls
+// "content" (plus an optional "head" block), one file per page below,
+// and a static/ directory served under /theme
+var pageFiles = []string{"index.html", "post.html", "list.html", "error.html"}
+
+type theme struct {
+	pages  map[string]*template.Template
+	static fs.FS
+}
+
+// loads the theme in dir, or the embedded theme called name when dir is empty
+func loadTheme(name, dir string) (*theme, error) {
+	var fsys fs.FS
+	if dir != "" {
+		fsys = os.DirFS(dir)
+	} else {
+		sub, err := fs.Sub(embedded, "themes/"+name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fs.Stat(sub, "layout.html"); err != nil {
+			return nil, fmt.Errorf("unknown theme %q", name)
+		}
+		fsys = sub
+	}
+
+	t := &theme{pages: map[string]*template.Template{}}
+	for _, page := range pageFiles {
+		tmpl, err := template.New(page).Funcs(funcs).ParseFS(fsys, "layout.html", page)
+		if err != nil {
+			return nil, fmt.Errorf("theme: %w", err)
+		}
+		if tmpl.Lookup("layout") == nil || tmpl.Lookup("content") == nil {
+			return nil, fmt.Errorf("theme: %s must define \"layout\" and \"content\"", page)
+		}
+		t.pages[page] = tmpl
+	}
+
+	static, err := fs.Sub(fsys, "static")
+	if err != nil {
+		return nil, fmt.Errorf("theme: %w", err)
+	}
+	t.static = static
+
+	return t, nil
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format("January 2, 2006")
+	},
+	"isoDate": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+	// post and comment html is sanitized when it's rendered, so it can go out as is
+	"trusted": func(s string) template.HTML {
+		return template.HTML(s)
+	},
+	"excerpt": func(html string, maxLen int) string {
+		return render.Excerpt(html, maxLen)
+	},
+	"authorName": authorName,
+	"tagPath": func(tag string) string {
+		return "/tags/" + url.PathEscape(tag)
+	},
+}
+
+func authorName(u *models.UserResponse) string {
+	if u == nil {
+		return "Unknown"
+	}
+	if name := strings.TrimSpace(u.Fname + " " + u.Lname); name != "" {
+		return name
+	}
+	return u.Username
+}
@@ -0,0 +1,360 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/httpcache"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/openapi"
+	"github.com/kurtgray/blog-api-go/internal/render"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"github.com/kurtgray/blog-api-go/internal/seo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// server-rendered reader pages, straight from the repositories
+type Site struct {
+	postRepo    repository.PostRepository
+	commentRepo repository.CommentRepository
+	userRepo    repository.UserRepository
+	renderer    *render.Renderer
+	site        config.SiteConfig
+	cfg         config.WebConfig
+	theme       *theme
+}
+
+func New(
+	postRepo repository.PostRepository,
+	commentRepo repository.CommentRepository,
+	userRepo repository.UserRepository,
+	renderer *render.Renderer,
+	site config.SiteConfig,
+	cfg config.WebConfig,
+) (*Site, error) {
+	t, err := loadTheme(cfg.Theme, cfg.ThemeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	site.BaseURL = strings.TrimRight(site.BaseURL, "/")
+	return &Site{
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+		userRepo:    userRepo,
+		renderer:    renderer,
+		site:        site,
+		cfg:         cfg,
+		theme:       t,
+	}, nil
+}
+
+func (s *Site) Routes(r chi.Router) {
+	// later pages are paths rather than query strings so a static export can hold them
+	r.Get("/", s.Index)
+	r.Get("/page/{page}", s.Index)
+	r.Get("/posts/{postId}", s.Post)
+	r.Get("/authors/{userId}", s.Author)
+	r.Get("/authors/{userId}/page/{page}", s.Author)
+	r.Get("/tags/{tag}", s.Tag)
+	r.Get("/tags/{tag}/page/{page}", s.Tag)
+	r.Handle("/theme/*", http.StripPrefix("/theme", cacheFor(24*time.Hour, http.FileServer(http.FS(s.theme.static)))))
+}
+
+// what Routes registers, for the API description
+func (s *Site) Spec() []openapi.Route {
+	page := openapi.Content(http.StatusOK, "HTML page", "text/html")
+	pageParam := []openapi.Parameter{{Name: "page", In: "path", Schema: &openapi.Schema{Type: "integer"}}}
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/", Tag: "pages", Summary: "Latest published posts", Replies: []openapi.Reply{page}},
+		{Method: http.MethodGet, Path: "/page/{page}", Tag: "pages", Summary: "Older published posts", Params: pageParam, Replies: []openapi.Reply{page}},
+		{Method: http.MethodGet, Path: "/posts/{postId}", Tag: "pages", Summary: "A published post with its comments", Replies: []openapi.Reply{page}},
+		{Method: http.MethodGet, Path: "/authors/{userId}", Tag: "pages", Summary: "An author's published posts", Replies: []openapi.Reply{page}},
+		{Method: http.MethodGet, Path: "/authors/{userId}/page/{page}", Tag: "pages", Summary: "An author's older posts", Params: pageParam, Replies: []openapi.Reply{page}},
+		{Method: http.MethodGet, Path: "/tags/{tag}", Tag: "pages", Summary: "Published posts with a tag", Replies: []openapi.Reply{page}},
+		{Method: http.MethodGet, Path: "/tags/{tag}/page/{page}", Tag: "pages", Summary: "Older posts with a tag", Params: pageParam, Replies: []openapi.Reply{page}},
+		{Method: http.MethodGet, Path: "/theme/*", Tag: "pages", Summary: "Theme stylesheets, scripts and images", Replies: []openapi.Reply{
+			openapi.Content(http.StatusOK, "Theme file", "text/css", "application/javascript", "image/*"),
+		}},
+	}
+}
+
+// everything a theme template can use, unused fields are zero
+type pageData struct {
+	Site        config.SiteConfig
+	Title       string
+	Description string
+	Canonical   string
+	Robots      string
+	// rss feed advertised as the page alternate
+	Feed string
+
+	Posts    []models.PostWithAuthor
+	Pager    *pager
+	Post     *models.PostWithAuthor
+	Meta     *models.PostMeta
+	Comments []models.CommentWithAuthor
+	Author   *models.User
+	Tag      string
+
+	Status  int
+	Message string
+}
+
+type pager struct {
+	Page  int64
+	Pages int64
+	Prev  string
+	Next  string
+}
+
+// GET /
+func (s *Site) Index(w http.ResponseWriter, r *http.Request) {
+	s.servePostList(w, r, "index.html", "/", repository.PostFilter{}, pageData{
+		Title:       s.site.Title,
+		Description: s.site.Description,
+		Feed:        "/feed.rss",
+	})
+}
+
+// GET /authors/:userId
+func (s *Site) Author(w http.ResponseWriter, r *http.Request) {
+	userID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "userId"))
+	if err != nil {
+		s.renderError(w, r, http.StatusNotFound, "Author not found")
+		return
+	}
+
+	user, err := s.userRepo.FindByID(r.Context(), userID)
+	if errors.Is(err, apperr.ErrNotFound) {
+		s.renderError(w, r, http.StatusNotFound, "Author not found")
+		return
+	}
+	if err != nil {
+		s.renderError(w, r, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	name := strings.TrimSpace(user.Fname + " " + user.Lname)
+	if name == "" {
+		name = user.Username
+	}
+	path := "/authors/" + userID.Hex()
+	s.servePostList(w, r, "list.html", path, repository.PostFilter{Author: &userID}, pageData{
+		Title:       "Posts by " + name,
+		Description: "Posts by " + name + " on " + s.site.Title,
+		Feed:        path + "/feed.rss",
+		Author:      user,
+	})
+}
+
+// GET /tags/:tag
+func (s *Site) Tag(w http.ResponseWriter, r *http.Request) {
+	tags := models.NormalizeTags([]string{chi.URLParam(r, "tag")})
+	if len(tags) == 0 {
+		s.renderError(w, r, http.StatusNotFound, "Tag not found")
+		return
+	}
+	tag := tags[0]
+
+	path := "/tags/" + url.PathEscape(tag)
+	s.servePostList(w, r, "list.html", path, repository.PostFilter{Tag: tag}, pageData{
+		Title:       "Posts tagged #" + tag,
+		Description: "Posts tagged #" + tag + " on " + s.site.Title,
+		Feed:        path + "/feed.rss",
+		Tag:         tag,
+	})
+}
+
+// GET /posts/:postId
+func (s *Site) Post(w http.ResponseWriter, r *http.Request) {
+	postID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "postId"))
+	if err != nil {
+		s.renderError(w, r, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	post, err := s.postRepo.FindByIDWithAuthor(r.Context(), postID)
+	if err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		s.renderError(w, r, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	// drafts stay private, the JSON API is where authors preview them
+	if err != nil || !post.Published {
+		s.renderError(w, r, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	comments, err := s.commentRepo.FindByPostWithAuthor(r.Context(), postID)
+	if err != nil {
+		s.renderError(w, r, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	s.ensureRendered(post)
+	meta := seo.PostMeta(s.site, post, true)
+
+	modified := latest(post.UpdatedAt, post.Timestamp)
+	for _, c := range comments {
+		modified = latest(modified, c.Timestamp)
+	}
+
+	s.serve(w, r, "post.html", pageData{
+		Title:       meta.Title,
+		Description: meta.Description,
+		Canonical:   meta.CanonicalURL,
+		Robots:      meta.Robots,
+		Feed:        "/feed.rss",
+		Post:        post,
+		Meta:        meta,
+		Comments:    comments,
+	}, modified)
+}
+
+// paginated list of published posts at path, later pages at PagePath
+func (s *Site) servePostList(w http.ResponseWriter, r *http.Request, page, path string, filter repository.PostFilter, data pageData) {
+	current := int64(1)
+	if v := chi.URLParam(r, "page"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			s.renderError(w, r, http.StatusNotFound, "Page not found")
+			return
+		}
+		if n == 1 {
+			http.Redirect(w, r, path, http.StatusMovedPermanently)
+			return
+		}
+		current = n
+	}
+
+	total, err := s.postRepo.CountPublished(r.Context(), filter)
+	if err != nil {
+		s.renderError(w, r, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	size := int64(s.cfg.PageSize)
+	pages := (total + size - 1) / size
+	if pages == 0 {
+		pages = 1
+	}
+	if current > pages {
+		s.renderError(w, r, http.StatusNotFound, "Page not found")
+		return
+	}
+
+	filter.Skip = (current - 1) * size
+	filter.Limit = size
+	posts, err := s.postRepo.FindPublished(r.Context(), filter)
+	if err != nil {
+		s.renderError(w, r, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	var modified time.Time
+	for i := range posts {
+		s.ensureRendered(&posts[i])
+		modified = latest(modified, latest(posts[i].UpdatedAt, posts[i].Timestamp))
+	}
+
+	data.Posts = posts
+	data.Pager = &pager{Page: current, Pages: pages}
+	data.Canonical = s.site.BaseURL + PagePath(path, current)
+	if current > 1 {
+		data.Pager.Prev = PagePath(path, current-1)
+	}
+	if current < pages {
+		data.Pager.Next = PagePath(path, current+1)
+	}
+
+	s.serve(w, r, page, data, modified)
+}
+
+// renders page into a buffer so a template error never sends half a page
+func (s *Site) serve(w http.ResponseWriter, r *http.Request, page string, data pageData, modified time.Time) {
+	data.Site = s.site
+	if data.Robots == "" {
+		data.Robots = "index, follow"
+	}
+
+	var buf bytes.Buffer
+	if err := s.theme.pages[page].ExecuteTemplate(&buf, "layout", data); err != nil {
+		log.Printf("web: rendering %s: %v", page, err)
+		s.renderError(w, r, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.cfg.CacheMaxAge.Seconds())))
+	httpcache.Serve(w, r, "text/html; charset=utf-8", modified, buf.Bytes())
+}
+
+func (s *Site) renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	data := pageData{
+		Site:    s.site,
+		Title:   message,
+		Robots:  "noindex",
+		Status:  status,
+		Message: message,
+	}
+
+	var buf bytes.Buffer
+	if err := s.theme.pages["error.html"].ExecuteTemplate(&buf, "layout", data); err != nil {
+		log.Printf("web: rendering error page: %v", err)
+		http.Error(w, message, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// posts written before rendering existed have no stored html
+func (s *Site) ensureRendered(post *models.PostWithAuthor) {
+	if post.HTML != "" || post.Text == "" {
+		return
+	}
+	if rendered, err := s.renderer.Post(post.Format, post.Text); err == nil {
+		post.HTML = rendered.HTML
+		post.TOC = rendered.TOC
+	}
+}
+
+// path of page n of the post list at path
+func PagePath(path string, n int64) string {
+	if n <= 1 {
+		return path
+	}
+	return strings.TrimSuffix(path, "/") + "/page/" + strconv.FormatInt(n, 10)
+}
+
+// theme files served under /theme
+func (s *Site) Assets() fs.FS {
+	return s.theme.static
+}
+
+func cacheFor(d time.Duration, next http.Handler) http.Handler {
+	value := "public, max-age=" + strconv.Itoa(int(d.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", value)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,227 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/health"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// headers on every delivery, a receiver checks the signature against
+// Sign(secret, timestamp, body) and may refuse old timestamps to stop
+// replays
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// deliveries sent at once
+	concurrency = 4
+	// how much of an endpoint's answer goes in the log
+	maxResponseBody = 1 << 10
+	// how often finished deliveries past the log retention are removed
+	pruneInterval = time.Hour
+)
+
+// "sha256=" and the hex HMAC-SHA256, keyed with the webhook's secret, of
+// the unix timestamp, a dot and the body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sends deliveries as they're queued and retries as they come due, every
+// poll interval at the latest, until ctx is done
+// hb beats on every pass and every delivery taken, so a long backlog
+// doesn't read as a stuck worker
+func (s *Service) Run(ctx context.Context, hb *health.Heartbeat) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+	var pruned time.Time
+	for {
+		hb.Beat()
+		if err := s.deliverDue(ctx, hb.Beat); err != nil && ctx.Err() == nil {
+			log.Printf("webhooks: delivering: %v", err)
+		}
+		if time.Since(pruned) >= pruneInterval {
+			n, err := s.deliveries.DeleteFinishedBefore(ctx, time.Now().Add(-s.cfg.LogRetention))
+			if err != nil && ctx.Err() == nil {
+				log.Printf("webhooks: pruning the delivery log: %v", err)
+			}
+			if n > 0 {
+				log.Printf("webhooks: pruned %d deliveries from the log", n)
+			}
+			pruned = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// sends every delivery that is due, returns once they've all been tried
+func (s *Service) DeliverDue(ctx context.Context) error {
+	return s.deliverDue(ctx, func() {})
+}
+
+// DeliverDue, calling beat as each delivery is taken
+func (s *Service) deliverDue(ctx context.Context, beat func()) error {
+	// long enough for an attempt to be sent and recorded, after that a
+	// claim is given up and the delivery is due again
+	lease := s.cfg.Timeout + time.Minute
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	sem := make(chan struct{}, concurrency)
+	defer wg.Wait()
+	for ctx.Err() == nil {
+		sem <- struct{}{}
+		beat()
+		delivery, err := s.deliveries.ClaimDue(ctx, time.Now(), lease)
+		if err != nil || delivery == nil {
+			<-sem
+			wg.Wait()
+			return errors.Join(append(errs, err)...)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := s.deliver(ctx, delivery); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("delivery %s: %w", delivery.ID.Hex(), err))
+				mu.Unlock()
+			}
+		}()
+	}
+	return ctx.Err()
+}
+
+// one attempt at delivery, then either done, due again after a backoff or,
+// out of attempts, failed
+func (s *Service) deliver(ctx context.Context, delivery *models.WebhookDelivery) error {
+	hook, err := s.hooks.FindByID(ctx, delivery.Webhook)
+	switch {
+	case errors.Is(err, apperr.ErrNotFound):
+		return s.deliveries.Update(ctx, delivery.ID, bson.M{"status": models.DeliveryFailed, "nextAttemptAt": nil, "error": "webhook was deleted"})
+	case err != nil:
+		return err
+	case !hook.Active:
+		return s.deliveries.Update(ctx, delivery.ID, bson.M{"status": models.DeliveryFailed, "nextAttemptAt": nil, "error": "webhook is disabled"})
+	}
+
+	now := time.Now()
+	status, body, sendErr := s.send(ctx, hook, delivery, now)
+	if sendErr != nil && ctx.Err() != nil {
+		// shutting down, the claim runs out and the attempt is made again
+		return nil
+	}
+
+	attempts := delivery.Attempts + 1
+	update := bson.M{
+		"attempts":       attempts,
+		"lastAttemptAt":  now,
+		"responseStatus": nil,
+		"responseBody":   nil,
+		"error":          nil,
+	}
+	if status != 0 {
+		update["responseStatus"] = status
+	}
+	if body != "" {
+		update["responseBody"] = body
+	}
+
+	switch {
+	case sendErr == nil:
+		update["status"] = models.DeliverySucceeded
+		update["nextAttemptAt"] = nil
+		if err := s.deliveries.Update(ctx, delivery.ID, update); err != nil {
+			return err
+		}
+		return s.hooks.RecordSuccess(ctx, hook.ID)
+
+	case attempts < s.cfg.MaxAttempts:
+		update["error"] = sendErr.Error()
+		update["nextAttemptAt"] = now.Add(s.backoff(attempts))
+		return s.deliveries.Update(ctx, delivery.ID, update)
+	}
+
+	update["error"] = sendErr.Error()
+	update["status"] = models.DeliveryFailed
+	update["nextAttemptAt"] = nil
+	if err := s.deliveries.Update(ctx, delivery.ID, update); err != nil {
+		return err
+	}
+	failures, err := s.hooks.RecordFailure(ctx, hook.ID)
+	if err != nil || failures < s.cfg.DisableAfter {
+		return err
+	}
+	log.Printf("webhooks: disabling %s after %d failed deliveries in a row", hook.ID.Hex(), failures)
+	return s.hooks.Disable(ctx, hook.ID, fmt.Sprintf("%d deliveries in a row failed", failures))
+}
+
+// posts the delivery's payload to the webhook, an error unless it answers
+// with a 2xx
+// returns the status and the start of the body it answered with
+func (s *Service) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "blog-api-webhooks")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID.Hex())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	body := strings.ToValidUTF8(string(b), "")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, body, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// the wait after attempt failed, RetryBackoff doubled for every attempt
+// before it, MaxBackoff at most
+func (s *Service) backoff(attempt int) time.Duration {
+	d := s.cfg.RetryBackoff
+	for i := 1; i < attempt && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, s.cfg.MaxBackoff)
+}
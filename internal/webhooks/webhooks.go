@@ -0,0 +1,238 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/kurtgray/blog-api-go/internal/apperr"
+	"github.com/kurtgray/blog-api-go/internal/config"
+	"github.com/kurtgray/blog-api-go/internal/models"
+	"github.com/kurtgray/blog-api-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrWebhookDisabled  = apperr.Conflict("webhook_disabled", "the webhook is disabled, enable it first")
+	ErrDeliveryNotFound = apperr.NotFound("delivery")
+)
+
+// the body of every delivery, ID stays the same across retries and
+// redeliveries so receivers can drop repeats
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// webhook subscriptions and the deliveries of events to them
+type Service struct {
+	hooks      repository.WebhookRepository
+	deliveries repository.DeliveryRepository
+	client     *http.Client
+	cfg        config.WebhooksConfig
+	// nudges Run when a delivery has been queued
+	wake chan struct{}
+}
+
+func New(hooks repository.WebhookRepository, deliveries repository.DeliveryRepository, cfg config.WebhooksConfig) *Service {
+	return &Service{
+		hooks:      hooks,
+		deliveries: deliveries,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// a redirect is answered like any other non-2xx
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg:  cfg,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// queues event for every active webhook subscribed to it, data is the
+// event's subject as the v2 API shows it
+func (s *Service) Publish(ctx context.Context, event string, data any) error {
+	hooks, err := s.hooks.FindActiveByEvent(ctx, event)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(Event{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, hook := range hooks {
+		errs = append(errs, s.deliveries.Create(ctx, &models.WebhookDelivery{
+			Webhook: hook.ID,
+			Event:   event,
+			Payload: string(payload),
+		}))
+	}
+	s.notify()
+	return errors.Join(errs...)
+}
+
+// newest first
+func (s *Service) Webhooks(ctx context.Context) ([]models.Webhook, error) {
+	return s.hooks.FindAll(ctx)
+}
+
+func (s *Service) Webhook(ctx context.Context, id primitive.ObjectID) (*models.Webhook, error) {
+	return s.hooks.FindByID(ctx, id)
+}
+
+// stores hook, with a generated secret unless it has one
+// returns the secret, the only time it's shown
+func (s *Service) Create(ctx context.Context, admin *models.User, hook *models.Webhook) (string, error) {
+	events, err := checkEvents(hook.Events)
+	if err != nil {
+		return "", err
+	}
+	hook.Events = events
+	if hook.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return "", err
+		}
+		hook.Secret = secret
+	}
+	hook.CreatedBy = admin.ID
+	if err := s.hooks.Create(ctx, hook); err != nil {
+		return "", err
+	}
+	return hook.Secret, nil
+}
+
+// replaces the webhook's url, events, description and whether it's active,
+// and its secret when next has one
+// enabling a webhook clears its failures, disabling one records when
+func (s *Service) Update(ctx context.Context, id primitive.ObjectID, next *models.Webhook) (*models.Webhook, error) {
+	events, err := checkEvents(next.Events)
+	if err != nil {
+		return nil, err
+	}
+	hook, err := s.hooks.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	update := bson.M{
+		"url":         next.URL,
+		"events":      events,
+		"description": next.Description,
+		"active":      next.Active,
+	}
+	if next.Description == "" {
+		update["description"] = nil
+	}
+	if next.Secret != "" {
+		update["secret"] = next.Secret
+	}
+	switch {
+	case next.Active && !hook.Active:
+		update["failures"] = 0
+		update["disabledAt"] = nil
+		update["disabledReason"] = nil
+	case !next.Active && hook.Active:
+		update["disabledAt"] = time.Now()
+		update["disabledReason"] = "disabled by an admin"
+	}
+	return s.hooks.Update(ctx, id, update)
+}
+
+// removes the webhook and its delivery log
+func (s *Service) Delete(ctx context.Context, id primitive.ObjectID) error {
+	if err := s.hooks.Delete(ctx, id); err != nil {
+		return err
+	}
+	_, err := s.deliveries.DeleteByWebhook(ctx, id)
+	return err
+}
+
+// the webhook's latest deliveries, newest first
+func (s *Service) Deliveries(ctx context.Context, id primitive.ObjectID, limit int64) ([]models.WebhookDelivery, error) {
+	if _, err := s.hooks.FindByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.deliveries.FindByWebhook(ctx, id, limit)
+}
+
+// queues the payload of one of the webhook's deliveries again, as a new
+// delivery with attempts of its own
+func (s *Service) Redeliver(ctx context.Context, id, deliveryID primitive.ObjectID) (*models.WebhookDelivery, error) {
+	hook, err := s.hooks.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	original, err := s.deliveries.FindByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Webhook != hook.ID {
+		return nil, ErrDeliveryNotFound
+	}
+	if !hook.Active {
+		return nil, ErrWebhookDisabled
+	}
+
+	delivery := &models.WebhookDelivery{
+		Webhook:      hook.ID,
+		Event:        original.Event,
+		Payload:      original.Payload,
+		RedeliveryOf: &original.ID,
+	}
+	if err := s.deliveries.Create(ctx, delivery); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return delivery, nil
+}
+
+func (s *Service) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// events sorted without repeats, or a validation error naming one that
+// doesn't exist
+func checkEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, apperr.Field("events", "required", "Events must be specified.")
+	}
+	for _, event := range events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return nil, apperr.Field("events", "invalid", fmt.Sprintf("Unknown event %q.", event))
+		}
+	}
+	events = slices.Clone(events)
+	slices.Sort(events)
+	return slices.Compact(events), nil
+}
+
+// 32 random bytes, hex encoded
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}